@@ -1,6 +1,30 @@
 package flux
 
-import "fmt"
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cast"
+)
+
+var (
+	// 参数校验的正则表达式缓存
+	argumentPatterns = new(sync.Map)
+	// 参数校验的格式
+	argumentFormats = map[string]func(string) bool{
+		ArgumentFormatEmail: regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`).MatchString,
+		ArgumentFormatPhone: regexp.MustCompile(`^\+?[0-9][0-9\-]{5,19}$`).MatchString,
+		ArgumentFormatDate: func(s string) bool {
+			_, err := time.Parse("2006-01-02", s)
+			return nil == err
+		},
+	}
+)
 
 // Resolve 解析Argument参数值
 func (a Argument) Resolve(ctx Context) (interface{}, error) {
@@ -22,6 +46,10 @@ func (a Argument) Resolve(ctx Context) (interface{}, error) {
 		if nil != err {
 			return nil, err
 		}
+		// 参数值为空时，使用默认值
+		if nil != a.Validation.Default && isEmptyArgumentValue(mtv.Value) {
+			mtv = WrapObjectMTValue(a.Validation.Default)
+		}
 		return a.ValueResolver(mtv, a.Class, a.Generic)
 	}
 	// POJO Values
@@ -36,3 +64,131 @@ func (a Argument) Resolve(ctx Context) (interface{}, error) {
 	}
 	return sm, nil
 }
+
+// Validate 按校验规则检查参数值，返回全部校验失败的字段错误；子结构字段递归校验
+func (a Argument) Validate(ctx Context) []ArgumentFieldError {
+	return a.validate(ctx, "")
+}
+
+func (a Argument) validate(ctx Context, prefix string) []ArgumentFieldError {
+	field := prefix + a.Name
+	if len(a.Fields) > 0 {
+		errs := make([]ArgumentFieldError, 0)
+		for _, f := range a.Fields {
+			errs = append(errs, f.validate(ctx, field+".")...)
+		}
+		return errs
+	}
+	// 固定值参数不校验
+	if !a.Validation.IsDefined() || nil != a.ValueLoader || nil == a.LookupFunc {
+		return nil
+	}
+	mtv, err := a.LookupFunc(a.HttpScope, a.HttpName, ctx)
+	if nil != err {
+		return []ArgumentFieldError{{Field: field, Rule: "lookup", Message: err.Error()}}
+	}
+	if isEmptyArgumentValue(mtv.Value) {
+		if a.Validation.Required && nil == a.Validation.Default {
+			return []ArgumentFieldError{{Field: field, Rule: "required", Message: "must not be empty"}}
+		}
+		return nil
+	}
+	return a.Validation.Check(field, mtv.Value)
+}
+
+// IsDefined 判断是否定义了任意校验规则
+func (v ArgumentValidation) IsDefined() bool {
+	return v.Required || nil != v.Default || nil != v.Min || nil != v.Max || nil != v.MinLength || nil != v.MaxLength ||
+		"" != v.Pattern || len(v.Enum) > 0 || "" != v.Format
+}
+
+// Check 校验非空参数值，返回全部校验失败的字段错误
+func (v ArgumentValidation) Check(field string, value interface{}) []ArgumentFieldError {
+	errs := make([]ArgumentFieldError, 0)
+	failed := func(rule, format string, args ...interface{}) {
+		errs = append(errs, ArgumentFieldError{Field: field, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+	if nil != v.Min || nil != v.Max {
+		if num, err := cast.ToFloat64E(value); nil != err {
+			failed("type", "must be a number")
+		} else {
+			if nil != v.Min && num < *v.Min {
+				failed("min", "must be greater than or equal to %v", *v.Min)
+			}
+			if nil != v.Max && num > *v.Max {
+				failed("max", "must be less than or equal to %v", *v.Max)
+			}
+		}
+	}
+	str, strErr := cast.ToStringE(value)
+	if nil != v.MinLength || nil != v.MaxLength {
+		length := utf8.RuneCountInString(str)
+		if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array || rv.Kind() == reflect.Map {
+			length = rv.Len()
+		}
+		if nil != v.MinLength && length < *v.MinLength {
+			failed("minLength", "length must be greater than or equal to %d", *v.MinLength)
+		}
+		if nil != v.MaxLength && length > *v.MaxLength {
+			failed("maxLength", "length must be less than or equal to %d", *v.MaxLength)
+		}
+	}
+	// 以下规则只校验可转换为字符串的参数值
+	if nil != strErr {
+		return errs
+	}
+	if "" != v.Pattern {
+		if pattern, err := loadArgumentPattern(v.Pattern); nil != err {
+			failed("pattern", "invalid pattern: %s", v.Pattern)
+		} else if !pattern.MatchString(str) {
+			failed("pattern", "must match pattern: %s", v.Pattern)
+		}
+	}
+	if len(v.Enum) > 0 {
+		matched := false
+		options := make([]string, len(v.Enum))
+		for i, e := range v.Enum {
+			options[i] = cast.ToString(e)
+			matched = matched || options[i] == str
+		}
+		if !matched {
+			failed("enum", "must be one of: %s", strings.Join(options, ", "))
+		}
+	}
+	if "" != v.Format {
+		if fn, ok := argumentFormats[strings.ToLower(v.Format)]; !ok {
+			failed("format", "unsupported format: %s", v.Format)
+		} else if !fn(str) {
+			failed("format", "must be a valid %s", strings.ToLower(v.Format))
+		}
+	}
+	return errs
+}
+
+func loadArgumentPattern(expr string) (*regexp.Regexp, error) {
+	if v, ok := argumentPatterns.Load(expr); ok {
+		return v.(*regexp.Regexp), nil
+	}
+	pattern, err := regexp.Compile(expr)
+	if nil != err {
+		return nil, err
+	}
+	argumentPatterns.Store(expr, pattern)
+	return pattern, nil
+}
+
+func isEmptyArgumentValue(value interface{}) bool {
+	if nil == value {
+		return true
+	}
+	if str, ok := value.(string); ok {
+		return "" == str
+	}
+	switch rv := reflect.ValueOf(value); rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
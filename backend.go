@@ -18,6 +18,11 @@ type (
 		GetResponseCodecFunc() BackendResponseCodecFunc
 	}
 
+	// BackendServiceListener BackendTransport的可选扩展接口，接收后端服务元数据变更事件，用于预热或销毁后端调用资源
+	BackendServiceListener interface {
+		OnBackendServiceEvent(event BackendServiceEvent)
+	}
+
 	// BackendResponse 后端服务返回统一响应数据结构
 	BackendResponse struct {
 		// Http状态码
@@ -32,6 +37,9 @@ type (
 
 	// BackendResponseCodecFunc 解析Backend返回的原始数据
 	BackendResponseCodecFunc func(ctx Context, raw interface{}) (*BackendResponse, error)
+
+	// LocalHandlerFunc 进程内执行的后端服务处理函数，由LOCAL协议的BackendTransport调用；args为已解析的参数值，Key为参数名
+	LocalHandlerFunc func(ctx Context, args map[string]interface{}) (*BackendResponse, *ServeError)
 )
 
 func (b *BackendResponse) GetStatusCode() int {
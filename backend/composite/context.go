@@ -0,0 +1,90 @@
+package composite
+
+import (
+	"context"
+	"github.com/bytepowered/flux"
+	"strings"
+	"sync"
+	"time"
+)
+
+type parentContext = flux.Context
+
+// CallContext 单个子调用的上下文：通过 GetValue 读取依赖调用的结果，Context() 带有子调用超时
+type CallContext struct {
+	parentContext
+	name    string
+	goctx   context.Context
+	results map[string]interface{}
+	metrics []flux.Metric
+	mu      sync.Mutex
+}
+
+func NewCallContext(parent flux.Context, goctx context.Context, name string, results map[string]interface{}) *CallContext {
+	return &CallContext{
+		parentContext: parent,
+		name:          name,
+		goctx:         goctx,
+		results:       results,
+	}
+}
+
+// Context 返回带有子调用超时的Context
+func (c *CallContext) Context() context.Context {
+	return c.goctx
+}
+
+// GetValue 优先读取依赖调用的结果，支持以 name.field.field 访问嵌套字段
+func (c *CallContext) GetValue(name string) (interface{}, bool) {
+	if v, ok := LookupResult(c.results, name); ok {
+		return v, true
+	}
+	return c.parentContext.GetValue(name)
+}
+
+// GetValueString 优先读取依赖调用的结果
+func (c *CallContext) GetValueString(name string, defaultValue string) string {
+	if v, ok := LookupResult(c.results, name); ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return c.parentContext.GetValueString(name, defaultValue)
+}
+
+// AddMetric 子调用并发执行，先记录在子调用上下文，完成后合并到请求上下文
+func (c *CallContext) AddMetric(name string, elapsed time.Duration) {
+	c.mu.Lock()
+	c.metrics = append(c.metrics, flux.Metric{Name: name + "@" + c.name, Elapsed: elapsed})
+	c.mu.Unlock()
+}
+
+// LoadMetrics 返回子调用记录的统计数据
+func (c *CallContext) LoadMetrics() []flux.Metric {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]flux.Metric(nil), c.metrics...)
+}
+
+// LookupResult 按路径 name.field.field 读取调用结果
+func LookupResult(results map[string]interface{}, path string) (interface{}, bool) {
+	keys := strings.Split(path, ".")
+	value, ok := results[keys[0]]
+	if !ok {
+		return nil, false
+	}
+	for _, key := range keys[1:] {
+		switch m := value.(type) {
+		case map[string]interface{}:
+			value, ok = m[key]
+		case map[interface{}]interface{}:
+			value, ok = m[key]
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
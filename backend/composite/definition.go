@@ -0,0 +1,116 @@
+package composite
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	"strings"
+	"time"
+)
+
+const (
+	// ExtKeyComposite BackendService扩展属性：聚合调用定义，JSON对象或JSON文本
+	ExtKeyComposite = "composite"
+)
+
+// CompositeDefinition 聚合调用定义
+type CompositeDefinition struct {
+	Calls []CompositeCall `json:"calls"` // 子调用列表
+	// Merge 合并模板：
+	// 1. 字符串：text/template模板，模板数据为 .Results/.Errors/.Request，例如 {"user": {{json .Results.user}}}；
+	// 2. JSON对象：值为 ${name.field} 格式的字符串，替换为对应的调用结果；
+	// 3. 未定义：以 调用名称 -> 调用结果 的Map作为响应Body；
+	Merge interface{} `json:"merge"`
+}
+
+// CompositeCall 子调用定义
+type CompositeCall struct {
+	Name      string   `json:"name"`      // 调用名称，在合并模板及依赖调用中引用
+	ServiceId string   `json:"serviceId"` // 后端服务ID
+	DependsOn []string `json:"dependsOn"` // 依赖的调用名称；依赖调用的结果可通过VALUE作用域参数读取：name.field
+	Timeout   string   `json:"timeout"`   // 调用超时；未定义时使用默认超时
+	Optional  bool     `json:"optional"`  // 可选调用失败时，结果为空，不影响整体响应
+}
+
+// DefinitionOf 读取BackendService扩展属性中的聚合调用定义
+func DefinitionOf(service flux.BackendService) (CompositeDefinition, error) {
+	def := CompositeDefinition{}
+	v, ok := service.Ext(ExtKeyComposite)
+	if !ok || nil == v {
+		return def, fmt.Errorf("composite definition not found, service: %s", service.ServiceID())
+	}
+	var err error
+	if text, ok := v.(string); ok {
+		err = _json.UnmarshalFromString(text, &def)
+	} else if data, merr := _json.Marshal(v); nil == merr {
+		err = _json.Unmarshal(data, &def)
+	} else {
+		err = merr
+	}
+	if nil != err {
+		return def, fmt.Errorf("decode composite definition, service: %s, err: %w", service.ServiceID(), err)
+	}
+	return def, def.Validate()
+}
+
+// Validate 校验调用名称唯一、依赖调用存在且无循环依赖、超时配置有效
+func (d CompositeDefinition) Validate() error {
+	if len(d.Calls) == 0 {
+		return fmt.Errorf("composite calls is empty")
+	}
+	calls := make(map[string]CompositeCall, len(d.Calls))
+	for _, call := range d.Calls {
+		if "" == call.Name || "" == call.ServiceId {
+			return fmt.Errorf("composite call requires name and serviceId, name: %s", call.Name)
+		}
+		if strings.Contains(call.Name, ".") {
+			return fmt.Errorf("composite call name must not contain '.', name: %s", call.Name)
+		}
+		if _, ok := calls[call.Name]; ok {
+			return fmt.Errorf("duplicated composite call, name: %s", call.Name)
+		}
+		if _, err := call.TimeoutOf(0); nil != err {
+			return err
+		}
+		calls[call.Name] = call
+	}
+	// 循环依赖检查：0 未访问，1 访问中，2 已完成
+	marks := make(map[string]int, len(calls))
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch marks[name] {
+		case 1:
+			return fmt.Errorf("composite call dependency cycle, name: %s", name)
+		case 2:
+			return nil
+		}
+		marks[name] = 1
+		for _, dep := range calls[name].DependsOn {
+			if _, ok := calls[dep]; !ok {
+				return fmt.Errorf("composite call dependency not found, name: %s, depends: %s", name, dep)
+			}
+			if err := visit(dep); nil != err {
+				return err
+			}
+		}
+		marks[name] = 2
+		return nil
+	}
+	for _, call := range d.Calls {
+		if err := visit(call.Name); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// TimeoutOf 返回调用超时；未定义时返回默认超时
+func (c CompositeCall) TimeoutOf(defaults time.Duration) (time.Duration, error) {
+	if "" == c.Timeout {
+		return defaults, nil
+	}
+	timeout, err := time.ParseDuration(c.Timeout)
+	if nil != err || timeout <= 0 {
+		return 0, fmt.Errorf("illegal composite call timeout, name: %s, timeout: %s", c.Name, c.Timeout)
+	}
+	return timeout, nil
+}
@@ -0,0 +1,400 @@
+package composite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	jsoniter "github.com/json-iterator/go"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	ConfigKeyCallTimeout = "call-timeout"
+)
+
+func init() {
+	ext.StoreBackendTransport(flux.ProtoComposite, NewBackendTransportService())
+}
+
+var (
+	_     flux.BackendTransport = new(BackendTransportService)
+	_json                       = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+type (
+	// Option 配置函数
+	Option func(service *BackendTransportService)
+	// InvokeFunc 执行子调用的函数，默认为 backend.DoInvokeCodec
+	InvokeFunc func(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError)
+)
+
+// CompositeResult 聚合调用结果
+type CompositeResult struct {
+	Results map[string]interface{} // 调用名称 -> 调用结果
+	Errors  map[string]string      // 调用名称 -> 失败原因
+	Body    interface{}            // 按合并模板生成的响应Body
+}
+
+type callState struct {
+	done   chan struct{}
+	result interface{}
+	serr   *flux.ServeError
+	ctx    *CallContext
+}
+
+// BackendTransportService 聚合调用BackendService：按聚合定义并行执行多个后端服务，
+// 子调用之间可定义依赖关系，最终按合并模板生成响应数据。
+type BackendTransportService struct {
+	responseCodecFunc flux.BackendResponseCodecFunc
+	invokeFunc        InvokeFunc
+	defaults          map[string]interface{}
+	timeout           time.Duration
+}
+
+// WithResponseCodecFunc 用于配置响应数据解析实现函数
+func WithResponseCodecFunc(fun flux.BackendResponseCodecFunc) Option {
+	return func(service *BackendTransportService) {
+		service.responseCodecFunc = fun
+	}
+}
+
+// WithInvokeFunc 用于配置执行子调用的函数
+func WithInvokeFunc(fun InvokeFunc) Option {
+	return func(service *BackendTransportService) {
+		service.invokeFunc = fun
+	}
+}
+
+// WithDefaults 用于配置默认配置值
+func WithDefaults(defaults map[string]interface{}) Option {
+	return func(service *BackendTransportService) {
+		service.defaults = defaults
+	}
+}
+
+func NewBackendTransportService() *BackendTransportService {
+	return NewBackendTransportServiceWith()
+}
+
+func NewBackendTransportServiceWith(opts ...Option) *BackendTransportService {
+	bts := &BackendTransportService{
+		responseCodecFunc: NewBackendResponseCodecFunc(),
+		invokeFunc:        backend.DoInvokeCodec,
+		timeout:           time.Second * 5,
+		defaults: map[string]interface{}{
+			ConfigKeyCallTimeout: "5s",
+		},
+	}
+	for _, opt := range opts {
+		opt(bts)
+	}
+	return bts
+}
+
+// Init 读取子调用默认超时
+func (b *BackendTransportService) Init(config *flux.Configuration) error {
+	logger.Info("Composite backend transport initializing")
+	config.SetDefaults(b.defaults)
+	if t := config.GetDuration(ConfigKeyCallTimeout); t > 0 {
+		b.timeout = t
+	}
+	return nil
+}
+
+func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return b.responseCodecFunc
+}
+
+func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
+	return backend.DoExchangeTransport(ctx, b)
+}
+
+func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	raw, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	result, err := b.GetResponseCodecFunc()(ctx, raw)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   fmt.Errorf("decode composite response, err: %w", err),
+		}
+	}
+	return result, nil
+}
+
+func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	def, err := DefinitionOf(service)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayEndpoint,
+			Message:    flux.ErrorMessageCompositeInvalidDefinition,
+			Internal:   err,
+		}
+	}
+	goctx, cancel := context.WithCancel(ctx.Context())
+	defer cancel()
+	states := make(map[string]*callState, len(def.Calls))
+	for _, call := range def.Calls {
+		states[call.Name] = &callState{done: make(chan struct{})}
+	}
+	// 首个失败的必选调用，作为整体失败原因；其它调用因取消而失败
+	var cause *flux.ServeError
+	var once sync.Once
+	var wg sync.WaitGroup
+	for _, call := range def.Calls {
+		wg.Add(1)
+		go func(call CompositeCall) {
+			defer wg.Done()
+			state := states[call.Name]
+			defer close(state.done)
+			b.invokeCall(ctx, goctx, call, state, states)
+			// 必选调用失败，取消其它调用
+			if nil != state.serr && !call.Optional {
+				once.Do(func() {
+					cause = state.serr
+					cancel()
+				})
+			}
+		}(call)
+	}
+	wg.Wait()
+	result := &CompositeResult{
+		Results: make(map[string]interface{}, len(def.Calls)),
+		Errors:  make(map[string]string, 0),
+	}
+	for _, call := range def.Calls {
+		state := states[call.Name]
+		if nil != state.ctx {
+			for _, m := range state.ctx.LoadMetrics() {
+				ctx.AddMetric(m.Name, m.Elapsed)
+			}
+		}
+		result.Results[call.Name] = state.result
+		if nil == state.serr {
+			continue
+		}
+		result.Errors[call.Name] = state.serr.Error()
+		logger.TraceContext(ctx).Warnw("BACKEND:COMPOSITE:CALL_FAILED",
+			"call", call.Name, "service-id", call.ServiceId, "optional", call.Optional, "error", state.serr)
+	}
+	if nil != cause {
+		return nil, cause
+	}
+	body, err := b.MergeResults(def, result, ctx)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageCompositeRenderFailed,
+			Internal:   err,
+		}
+	}
+	result.Body = body
+	return result, nil
+}
+
+func (b *BackendTransportService) invokeCall(ctx flux.Context, goctx context.Context, call CompositeCall, state *callState, states map[string]*callState) {
+	// 等待依赖调用完成；依赖调用失败时，跳过本调用
+	results := make(map[string]interface{}, len(call.DependsOn))
+	for _, dep := range call.DependsOn {
+		depState := states[dep]
+		select {
+		case <-depState.done:
+		case <-goctx.Done():
+			state.serr = newCallError(call, http.StatusBadGateway, goctx.Err())
+			return
+		}
+		if nil != depState.serr {
+			state.serr = newCallError(call, http.StatusBadGateway, fmt.Errorf("dependency call failed: %s", dep))
+			return
+		}
+		results[dep] = depState.result
+	}
+	service, ok := ext.LoadBackendService(call.ServiceId)
+	if !ok {
+		state.serr = &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayEndpoint,
+			Message:    flux.ErrorMessageCompositeServiceNotFound,
+			Internal:   fmt.Errorf("composite call service not found, name: %s, service-id: %s", call.Name, call.ServiceId),
+		}
+		return
+	}
+	timeout, _ := call.TimeoutOf(b.timeout)
+	toctx, cancel := context.WithTimeout(goctx, timeout)
+	defer cancel()
+	// 子调用超时后仍在后台执行，请求结束后Context被回收；使用请求数据的快照执行子调用
+	parent, release := ctx, context.CancelFunc(func() {})
+	if snapshotter, ok := ctx.(flux.ContextSnapshotter); ok {
+		parent, release = snapshotter.Snapshot(timeout)
+	}
+	callctx := NewCallContext(parent, toctx, call.Name, results)
+	state.ctx = callctx
+	start := time.Now()
+	type output struct {
+		resp *flux.BackendResponse
+		serr *flux.ServeError
+	}
+	outputs := make(chan output, 1)
+	go func() {
+		defer release()
+		resp, serr := b.invokeFunc(callctx, service)
+		outputs <- output{resp: resp, serr: serr}
+	}()
+	select {
+	case out := <-outputs:
+		state.ctx.AddMetric("M-Composite", time.Since(start))
+		if nil != out.serr {
+			out.serr.PutExtraTrace("composite-call", call.Name)
+			state.serr = out.serr
+			return
+		}
+		body, err := DecodeBody(out.resp.Body)
+		if nil != err {
+			state.serr = newCallError(call, http.StatusBadGateway, err)
+			return
+		}
+		if out.resp.StatusCode >= http.StatusBadRequest {
+			state.serr = newCallError(call, http.StatusBadGateway, fmt.Errorf("response status: %d", out.resp.StatusCode))
+			state.serr.PutExtraTrace("composite-response", body)
+			return
+		}
+		state.result = body
+	case <-toctx.Done():
+		state.ctx.AddMetric("M-Composite", time.Since(start))
+		state.serr = newCallError(call, http.StatusGatewayTimeout, toctx.Err())
+		// 超时后丢弃调用结果，释放响应资源
+		go func() {
+			if out := <-outputs; nil != out.resp {
+				if closer, ok := out.resp.Body.(io.Closer); ok {
+					_ = closer.Close()
+				}
+			}
+		}()
+	}
+}
+
+// MergeResults 按合并模板生成响应Body
+func (b *BackendTransportService) MergeResults(def CompositeDefinition, result *CompositeResult, ctx flux.Context) (interface{}, error) {
+	switch merge := def.Merge.(type) {
+	case nil:
+		return result.Results, nil
+	case string:
+		tpl, err := template.New("composite").Funcs(template.FuncMap{
+			"json": func(v interface{}) (string, error) {
+				return _json.MarshalToString(v)
+			},
+		}).Parse(merge)
+		if nil != err {
+			return nil, err
+		}
+		buf := new(bytes.Buffer)
+		err = tpl.Execute(buf, map[string]interface{}{
+			"Results": result.Results,
+			"Errors":  result.Errors,
+			"Request": map[string]interface{}{
+				"Id":     ctx.RequestId(),
+				"Method": ctx.Method(),
+				"URI":    ctx.RequestURI(),
+			},
+		})
+		return bytes.NewReader(buf.Bytes()), err
+	default:
+		return replaceResults(merge, result.Results), nil
+	}
+}
+
+// replaceResults 将JSON对象中 ${name.field} 格式的字符串值，替换为对应的调用结果
+func replaceResults(value interface{}, results map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, iv := range v {
+			out[k] = replaceResults(iv, results)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, iv := range v {
+			out[i] = replaceResults(iv, results)
+		}
+		return out
+	case string:
+		if strings.HasPrefix(v, "${") && strings.HasSuffix(v, "}") {
+			r, _ := LookupResult(results, v[2:len(v)-1])
+			return r
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// DecodeBody 读取子调用的响应Body：JSON数据解析为对象，其它数据作为字符串
+func DecodeBody(body interface{}) (interface{}, error) {
+	var data []byte
+	switch v := body.(type) {
+	case io.Reader:
+		if closer, ok := v.(io.Closer); ok {
+			defer closer.Close()
+		}
+		bs, err := ioutil.ReadAll(v)
+		if nil != err {
+			return nil, err
+		}
+		data = bs
+	case []byte:
+		data = v
+	default:
+		return body, nil
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var out interface{}
+	if err := _json.Unmarshal(data, &out); nil == err {
+		return out, nil
+	}
+	return string(data), nil
+}
+
+func newCallError(call CompositeCall, status int, err error) *flux.ServeError {
+	serr := &flux.ServeError{
+		StatusCode: status,
+		ErrorCode:  flux.ErrorCodeGatewayBackend,
+		Message:    flux.ErrorMessageCompositeCallFailed,
+		Internal:   fmt.Errorf("composite call: %s, err: %w", call.Name, err),
+	}
+	serr.PutExtraTrace("composite-call", call.Name)
+	return serr
+}
+
+func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return func(ctx flux.Context, value interface{}) (*flux.BackendResponse, error) {
+		result, ok := value.(*CompositeResult)
+		if !ok {
+			return nil, errors.New("unknown composite result")
+		}
+		return &flux.BackendResponse{
+			StatusCode: flux.StatusOK,
+			Headers:    make(http.Header, 0),
+			Body:       result.Body,
+		}, nil
+	}
+}
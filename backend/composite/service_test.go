@@ -0,0 +1,204 @@
+package composite
+
+import (
+	"bytes"
+	"context"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	ext.StoreLoggerFactory(func(context.Context) flux.Logger {
+		return logger.SimpleLogger()
+	})
+	for _, id := range []string{"test.user", "test.order", "test.slow", "test.failed"} {
+		ext.StoreBackendService(flux.BackendService{ServiceId: id, Interface: id, Method: "get"})
+	}
+}
+
+func newTestInvokeFunc() InvokeFunc {
+	return func(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+		switch service.ServiceId {
+		case "test.user":
+			return &flux.BackendResponse{StatusCode: 200, Body: bytes.NewReader([]byte(`{"id":"u1","name":"yongjiachen"}`))}, nil
+		case "test.order":
+			uid, _ := ctx.GetValue("user.id")
+			return &flux.BackendResponse{StatusCode: 200, Body: map[string]interface{}{"owner": uid, "count": 2}}, nil
+		case "test.slow":
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Context().Done():
+			}
+			return &flux.BackendResponse{StatusCode: 200, Body: "slow"}, nil
+		default:
+			return &flux.BackendResponse{StatusCode: 500, Body: []byte("failed")}, nil
+		}
+	}
+}
+
+func newTestCompositeService(def string) flux.BackendService {
+	service := flux.BackendService{ServiceId: "test.composite"}
+	service.Extensions = map[string]interface{}{ExtKeyComposite: def}
+	return service
+}
+
+func TestBackendTransportService_InvokeCodec(t *testing.T) {
+	cases := []struct {
+		def          string
+		expectedBody interface{}
+	}{
+		{
+			def: `{"calls": [{"name": "user", "serviceId": "test.user"}, {"name": "order", "serviceId": "test.order", "dependsOn": ["user"]}]}`,
+			expectedBody: map[string]interface{}{
+				"user":  map[string]interface{}{"id": "u1", "name": "yongjiachen"},
+				"order": map[string]interface{}{"owner": "u1", "count": 2},
+			},
+		},
+		{
+			def: `{"calls": [{"name": "user", "serviceId": "test.user"}, {"name": "order", "serviceId": "test.order", "dependsOn": ["user"]}],
+				"merge": {"name": "${user.name}", "orders": "${order.count}", "tag": "vip"}}`,
+			expectedBody: map[string]interface{}{"name": "yongjiachen", "orders": 2, "tag": "vip"},
+		},
+		{
+			def: `{"calls": [{"name": "user", "serviceId": "test.user"}, {"name": "slow", "serviceId": "test.slow", "timeout": "10ms", "optional": true}],
+				"merge": "{\"name\": {{json .Results.user.name}}, \"slow\": {{json .Results.slow}}, \"failed\": {{len .Errors}}}"}`,
+			expectedBody: `{"name": "yongjiachen", "slow": null, "failed": 1}`,
+		},
+	}
+	transport := NewBackendTransportServiceWith(WithInvokeFunc(newTestInvokeFunc()))
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		ctx := support.NewValuesContext(map[string]interface{}{})
+		resp, serr := transport.InvokeCodec(ctx, newTestCompositeService(tcase.def))
+		assert.Nil(serr)
+		assert.Equal(flux.StatusOK, resp.StatusCode)
+		if reader, ok := resp.Body.(io.Reader); ok {
+			data, err := ioutil.ReadAll(reader)
+			assert.Nil(err)
+			assert.Equal(tcase.expectedBody, string(data))
+		} else {
+			assert.Equal(tcase.expectedBody, resp.Body)
+		}
+	}
+}
+
+func TestBackendTransportService_Failures(t *testing.T) {
+	cases := []struct {
+		def             string
+		expectedStatus  int
+		expectedMessage string
+	}{
+		{
+			def:             `{"calls": [{"name": "user", "serviceId": "test.user"}, {"name": "failed", "serviceId": "test.failed"}]}`,
+			expectedStatus:  502,
+			expectedMessage: flux.ErrorMessageCompositeCallFailed,
+		},
+		{
+			def:             `{"calls": [{"name": "slow", "serviceId": "test.slow", "timeout": "10ms"}]}`,
+			expectedStatus:  504,
+			expectedMessage: flux.ErrorMessageCompositeCallFailed,
+		},
+		{
+			def:             `{"calls": [{"name": "user", "serviceId": "test.notfound"}]}`,
+			expectedStatus:  500,
+			expectedMessage: flux.ErrorMessageCompositeServiceNotFound,
+		},
+		{
+			def:             `{"calls": [{"name": "a", "serviceId": "test.user", "dependsOn": ["b"]}, {"name": "b", "serviceId": "test.user", "dependsOn": ["a"]}]}`,
+			expectedStatus:  500,
+			expectedMessage: flux.ErrorMessageCompositeInvalidDefinition,
+		},
+		{
+			def:             `{"calls": [{"name": "a", "serviceId": "test.user", "dependsOn": ["x"]}]}`,
+			expectedStatus:  500,
+			expectedMessage: flux.ErrorMessageCompositeInvalidDefinition,
+		},
+	}
+	transport := NewBackendTransportServiceWith(WithInvokeFunc(newTestInvokeFunc()))
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		ctx := support.NewValuesContext(map[string]interface{}{})
+		_, serr := transport.InvokeCodec(ctx, newTestCompositeService(tcase.def))
+		assert.NotNil(serr, tcase.def)
+		assert.Equal(tcase.expectedStatus, serr.StatusCode, tcase.def)
+		assert.Equal(tcase.expectedMessage, serr.Message, tcase.def)
+	}
+}
+
+func TestBackendTransportService_OptionalDependency(t *testing.T) {
+	def := `{"calls": [{"name": "failed", "serviceId": "test.failed", "optional": true},
+		{"name": "order", "serviceId": "test.order", "dependsOn": ["failed"], "optional": true},
+		{"name": "user", "serviceId": "test.user"}]}`
+	transport := NewBackendTransportServiceWith(WithInvokeFunc(newTestInvokeFunc()))
+	ctx := support.NewValuesContext(map[string]interface{}{})
+	raw, serr := transport.Invoke(ctx, newTestCompositeService(def))
+	assert := assert2.New(t)
+	assert.Nil(serr)
+	result := raw.(*CompositeResult)
+	assert.Nil(result.Results["failed"])
+	assert.Nil(result.Results["order"])
+	assert.NotNil(result.Results["user"])
+	assert.True(strings.Contains(result.Errors["order"], "dependency call failed"))
+}
+
+type testSnapshotContext struct {
+	*support.ValuesContext
+	snapshots int
+	released  chan struct{}
+}
+
+func (c *testSnapshotContext) Snapshot(timeout time.Duration) (flux.Context, context.CancelFunc) {
+	c.snapshots++
+	snapshot := support.NewValuesContext(map[string]interface{}{"snapshot": true})
+	return snapshot, func() {
+		c.released <- struct{}{}
+	}
+}
+
+func TestBackendTransportService_Snapshot(t *testing.T) {
+	def := `{"calls": [{"name": "slow", "serviceId": "test.slow", "timeout": "10ms"}]}`
+	snapshot := false
+	invoke := newTestInvokeFunc()
+	transport := NewBackendTransportServiceWith(WithInvokeFunc(func(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+		_, snapshot = ctx.GetValue("snapshot")
+		return invoke(ctx, service)
+	}))
+	ctx := &testSnapshotContext{
+		ValuesContext: support.NewValuesContext(map[string]interface{}{}).(*support.ValuesContext),
+		released:      make(chan struct{}, 1),
+	}
+	_, serr := transport.Invoke(ctx, newTestCompositeService(def))
+	assert := assert2.New(t)
+	assert.NotNil(serr)
+	assert.Equal(504, serr.StatusCode)
+	// 超时的子调用在后台执行完成后，释放快照
+	select {
+	case <-ctx.released:
+	case <-time.After(time.Second * 2):
+		assert.Fail("snapshot not released")
+	}
+	assert.Equal(1, ctx.snapshots)
+	assert.True(snapshot, "call should use snapshot context")
+}
+
+func TestLookupResult(t *testing.T) {
+	results := map[string]interface{}{
+		"user": map[string]interface{}{"profile": map[string]interface{}{"age": 18}},
+	}
+	assert := assert2.New(t)
+	v, ok := LookupResult(results, "user.profile.age")
+	assert.True(ok)
+	assert.Equal(18, v)
+	_, ok = LookupResult(results, "user.profile.none")
+	assert.False(ok)
+	_, ok = LookupResult(results, "order")
+	assert.False(ok)
+}
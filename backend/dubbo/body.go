@@ -13,6 +13,12 @@ type BodyValues map[interface{}]interface{}
 func WrapBodyValues(v interface{}) (BodyValues, bool) {
 	if m, ok := v.(map[interface{}]interface{}); ok {
 		return BodyValues(m), true
+	} else if m, ok := v.(map[string]interface{}); ok {
+		bv := make(BodyValues, len(m))
+		for k, v := range m {
+			bv[k] = v
+		}
+		return bv, true
 	} else {
 		return nil, false
 	}
@@ -26,6 +32,15 @@ func UnwrapBodyValues(v interface{}) interface{} {
 	}
 }
 
+func (b BodyValues) contains(keys ...string) bool {
+	for _, key := range keys {
+		if _, ok := b[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (b BodyValues) ReadBodyValue(bodyKey string) interface{} {
 	if body, ok := b[bodyKey]; ok {
 		return body
@@ -79,6 +94,10 @@ func _addToHeader(headers http.Header, key string, v interface{}) {
 		for _, iv := range sa {
 			headers.Add(key, iv)
 		}
+	} else if ia, ok := v.([]interface{}); ok {
+		for _, iv := range ia {
+			headers.Add(key, cast.ToString(iv))
+		}
 	} else {
 		headers.Add(key, cast.ToString(v))
 	}
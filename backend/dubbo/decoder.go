@@ -1,15 +1,26 @@
 package dubbo
 
 import (
+	"bufio"
 	"github.com/apache/dubbo-go/protocol"
 	"github.com/bytepowered/flux"
-	"github.com/spf13/cast"
 	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
 )
 
 const (
 	ResponseKeyStatusCode = "@net.bytepowered.flux.http-status"
 	ResponseKeyHeaders    = "@net.bytepowered.flux.http-headers"
+	ResponseKeyBody       = "@net.bytepowered.flux.http-body"
+)
+
+const (
+	// ResponseCodecModeAttachment 从Attachment中读取响应状态码和Header
+	ResponseCodecModeAttachment = "attachment"
+	// ResponseCodecModeBody 从响应数据体（Map）中读取响应状态码、Header和Body；Attachment中的状态码和Header同样生效
+	ResponseCodecModeBody = "body"
 )
 
 func NewBackendResponseCodecFuncWith(codeKey, headerKey string) flux.BackendResponseCodecFunc {
@@ -21,27 +32,126 @@ func NewBackendResponseCodecFuncWith(codeKey, headerKey string) flux.BackendResp
 				StatusCode: flux.StatusOK, Headers: make(http.Header, 0), Body: raw,
 			}, nil
 		}
-		attrs := make(map[string]interface{}, 8)
 		if err := rpcr.Error(); nil != err {
 			return nil, err
 		}
-		data := rpcr.Result()
-		status := flux.StatusOK
-		for k, v := range rpcr.Attachments() {
-			if k == codeKey {
-				status = cast.ToInt(v)
-			} else if k == headerKey {
-				// TODO 需要更新Attachment类型为map[string]interface{}
-			} else {
-				attrs[k] = v
+		return decodeAttachments(rpcr, codeKey, headerKey)
+	}
+}
+
+// NewBodyResponseCodecFuncWith 从响应数据体读取状态码、Header和Body的解析函数：
+// 响应数据体为Map且包含bodyKey时，以bodyKey的值作为响应Body；否则以移除状态码、Header字段后的Map作为响应Body。
+func NewBodyResponseCodecFuncWith(codeKey, headerKey, bodyKey string) flux.BackendResponseCodecFunc {
+	attachment := NewBackendResponseCodecFuncWith(codeKey, headerKey)
+	return func(ctx flux.Context, raw interface{}) (*flux.BackendResponse, error) {
+		resp, err := attachment(ctx, raw)
+		if nil != err {
+			return nil, err
+		}
+		values, ok := WrapBodyValues(resp.Body)
+		if !ok || !values.contains(codeKey, headerKey, bodyKey) {
+			return resp, nil
+		}
+		if _, ok := values[codeKey]; ok {
+			if resp.StatusCode, err = values.ReadStatusValue(codeKey); nil != err {
+				return nil, err
 			}
 		}
-		return &flux.BackendResponse{
-			StatusCode: status, Headers: make(http.Header, 0), Attachments: attrs, Body: data,
-		}, nil
+		headers, err := values.ReadHeaderValue(headerKey)
+		if nil != err {
+			return nil, err
+		}
+		mergeHeaders(resp.Headers, headers)
+		if _, ok := values[bodyKey]; ok {
+			resp.Body = UnwrapBodyValues(values.ReadBodyValue(bodyKey))
+		} else if smap, ok := resp.Body.(map[string]interface{}); ok {
+			delete(smap, codeKey)
+			delete(smap, headerKey)
+		} else {
+			delete(values, codeKey)
+			delete(values, headerKey)
+		}
+		return resp, nil
 	}
 }
 
 func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
 	return NewBackendResponseCodecFuncWith(ResponseKeyStatusCode, ResponseKeyHeaders)
 }
+
+// NewBodyResponseCodecFunc 从响应数据体读取状态码、Header和Body的默认解析函数
+func NewBodyResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return NewBodyResponseCodecFuncWith(ResponseKeyStatusCode, ResponseKeyHeaders, ResponseKeyBody)
+}
+
+func decodeAttachments(rpcr protocol.Result, codeKey, headerKey string) (*flux.BackendResponse, error) {
+	attrs := make(map[string]interface{}, 8)
+	status := flux.StatusOK
+	headers := make(http.Header, 0)
+	for k, v := range rpcr.Attachments() {
+		switch k {
+		case codeKey:
+			code, err := BodyValues{k: v}.ReadStatusValue(k)
+			if nil != err {
+				return nil, err
+			}
+			status = code
+		case headerKey:
+			values, err := DecodeHeaderValue(v)
+			if nil != err {
+				return nil, err
+			}
+			mergeHeaders(headers, values)
+		default:
+			attrs[k] = v
+		}
+	}
+	return &flux.BackendResponse{
+		StatusCode: status, Headers: headers, Attachments: attrs, Body: rpcr.Result(),
+	}, nil
+}
+
+// DecodeHeaderValue 解析Attachment中的Header值，支持以下编码格式：
+// 1. Map类型：map[string][]string, map[string]interface{}, map[interface{}]interface{}；
+// 2. JSON文本：{"Set-Cookie": ["a=1", "b=2"], "Cache-Control": "no-cache"}；
+// 3. Http Header文本：每行一个 Key: Value；
+// 4. URL Query文本：Key=Value&Key=Value；
+func DecodeHeaderValue(value interface{}) (http.Header, error) {
+	text, ok := value.(string)
+	if !ok {
+		return BodyValues{ResponseKeyHeaders: value}.ReadHeaderValue(ResponseKeyHeaders)
+	}
+	text = strings.TrimSpace(text)
+	switch {
+	case "" == text:
+		return make(http.Header), nil
+	case strings.HasPrefix(text, "{"):
+		jsonv := make(map[string]interface{})
+		if err := _json.UnmarshalFromString(text, &jsonv); nil != err {
+			return nil, ErrDecodeInvalidHeaders
+		}
+		return BodyValues{ResponseKeyHeaders: jsonv}.ReadHeaderValue(ResponseKeyHeaders)
+	case strings.Contains(text, ":"):
+		reader := textproto.NewReader(bufio.NewReader(strings.NewReader(text + "\r\n\r\n")))
+		if mime, err := reader.ReadMIMEHeader(); nil == err {
+			return http.Header(mime), nil
+		}
+		fallthrough
+	case strings.Contains(text, "="):
+		query, err := url.ParseQuery(text)
+		if nil != err {
+			return nil, ErrDecodeInvalidHeaders
+		}
+		return http.Header(query), nil
+	default:
+		return nil, ErrDecodeInvalidHeaders
+	}
+}
+
+func mergeHeaders(dst http.Header, src http.Header) {
+	for k, vs := range src {
+		for _, v := range vs {
+			dst.Add(k, v)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package dubbo
+
+import (
+	"github.com/apache/dubbo-go/protocol"
+	"github.com/bytepowered/flux"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestDecodeHeaderValue(t *testing.T) {
+	cases := []struct {
+		value    interface{}
+		expected http.Header
+		err      bool
+	}{
+		{value: "", expected: http.Header{}},
+		{
+			value:    `{"Set-Cookie": ["a=1", "b=2"], "Cache-Control": "no-cache"}`,
+			expected: http.Header{"Set-Cookie": {"a=1", "b=2"}, "Cache-Control": {"no-cache"}},
+		},
+		{
+			value:    "Location: https://www.example.com/login\r\nX-Trace-Id: 1001",
+			expected: http.Header{"Location": {"https://www.example.com/login"}, "X-Trace-Id": {"1001"}},
+		},
+		{
+			value:    "X-Trace-Id=1001&Set-Cookie=a%3D1&Set-Cookie=b%3D2",
+			expected: http.Header{"X-Trace-Id": {"1001"}, "Set-Cookie": {"a=1", "b=2"}},
+		},
+		{
+			value:    map[interface{}]interface{}{"X-Trace-Id": 1001},
+			expected: http.Header{"X-Trace-Id": {"1001"}},
+		},
+		{value: "invalid", err: true},
+		{value: "{invalid", err: true},
+		{value: 1001, err: true},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		headers, err := DecodeHeaderValue(tcase.value)
+		if tcase.err {
+			assert.NotNil(err, "value: %v", tcase.value)
+		} else {
+			assert.Nil(err, "value: %v", tcase.value)
+			assert.Equal(tcase.expected, headers, "value: %v", tcase.value)
+		}
+	}
+}
+
+func TestBackendResponseCodecFunc(t *testing.T) {
+	codec := NewBackendResponseCodecFunc()
+	assert := assert2.New(t)
+	resp, err := codec(nil, &protocol.RPCResult{
+		Attrs: map[string]string{
+			ResponseKeyStatusCode: "302",
+			ResponseKeyHeaders:    `{"Location": "/login", "Set-Cookie": ["a=1", "b=2"]}`,
+			"trace-id":            "1001",
+		},
+		Rest: "redirect",
+	})
+	assert.Nil(err)
+	assert.Equal(302, resp.StatusCode)
+	assert.Equal("/login", resp.Headers.Get("Location"))
+	assert.Equal([]string{"a=1", "b=2"}, resp.Headers.Values("Set-Cookie"))
+	assert.Equal(map[string]interface{}{"trace-id": "1001"}, resp.Attachments)
+	assert.Equal("redirect", resp.Body)
+	// 无效的状态码
+	_, err = codec(nil, &protocol.RPCResult{
+		Attrs: map[string]string{ResponseKeyStatusCode: "abc"},
+	})
+	assert.Equal(ErrDecodeInvalidStatus, err)
+}
+
+func TestBodyResponseCodecFunc(t *testing.T) {
+	codec := NewBodyResponseCodecFunc()
+	cases := []struct {
+		body           interface{}
+		expectedStatus int
+		expectedHeader http.Header
+		expectedBody   interface{}
+	}{
+		{
+			body: map[interface{}]interface{}{
+				ResponseKeyStatusCode: 201,
+				ResponseKeyHeaders:    map[interface{}]interface{}{"X-Id": "1001"},
+				ResponseKeyBody:       map[interface{}]interface{}{"id": 1001},
+			},
+			expectedStatus: 201,
+			expectedHeader: http.Header{"X-Id": {"1001"}, "X-Trace": {"t1"}},
+			expectedBody:   map[interface{}]interface{}{"id": 1001},
+		},
+		{
+			body: map[string]interface{}{
+				ResponseKeyStatusCode: "404",
+				"message":             "not found",
+			},
+			expectedStatus: 404,
+			expectedHeader: http.Header{"X-Trace": {"t1"}},
+			expectedBody:   map[string]interface{}{"message": "not found"},
+		},
+		{
+			body:           map[string]interface{}{"id": 1001},
+			expectedStatus: flux.StatusOK,
+			expectedHeader: http.Header{"X-Trace": {"t1"}},
+			expectedBody:   map[string]interface{}{"id": 1001},
+		},
+		{
+			body:           "text",
+			expectedStatus: flux.StatusOK,
+			expectedHeader: http.Header{"X-Trace": {"t1"}},
+			expectedBody:   "text",
+		},
+	}
+	assert := assert2.New(t)
+	for i, tcase := range cases {
+		resp, err := codec(nil, &protocol.RPCResult{
+			Attrs: map[string]string{ResponseKeyHeaders: "X-Trace: t1"},
+			Rest:  tcase.body,
+		})
+		assert.Nil(err, "case: %d", i)
+		assert.Equal(tcase.expectedStatus, resp.StatusCode, "case: %d", i)
+		assert.Equal(tcase.expectedHeader, resp.Headers, "case: %d", i)
+		assert.Equal(tcase.expectedBody, resp.Body, "case: %d", i)
+	}
+}
@@ -0,0 +1,184 @@
+package dubbo
+
+import (
+	"reflect"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/pkg"
+)
+
+import (
+	"github.com/apache/dubbo-go/common"
+	dubgo "github.com/apache/dubbo-go/config"
+	"github.com/apache/dubbo-go/protocol"
+)
+
+// referenceCreateMutex DubboGo的 SetConsumerService 写入全局Map且未加锁，创建引用的过程需全局串行执行
+var referenceCreateMutex sync.Mutex
+
+// GenericReference 缓存的Dubbo泛化调用引用；相同 Interface+Group+Version 的服务共享同一引用
+type GenericReference struct {
+	once    sync.Once
+	key     string
+	service common.RPCService
+	config  *dubgo.ReferenceConfig
+}
+
+// Service 返回泛化调用Service实例
+func (r *GenericReference) Service() common.RPCService {
+	return r.service
+}
+
+// GenericReferenceKey 返回服务的引用缓存Key：Interface:Group:Version
+func GenericReferenceKey(service *flux.BackendService) string {
+	return service.Interface + ":" + service.AttrRpcGroup() + ":" + service.AttrRpcVersion()
+}
+
+// LoadGenericService 加载已缓存的泛化调用Service；未缓存时创建引用，并只阻塞等待同一引用的请求
+func (b *BackendTransportService) LoadGenericService(backend *flux.BackendService) common.RPCService {
+	key := GenericReferenceKey(backend)
+	v, ok := b.references.Load(key)
+	if !ok {
+		v, _ = b.references.LoadOrStore(key, &GenericReference{key: key})
+	}
+	ref := v.(*GenericReference)
+	ref.once.Do(func() {
+		defer func() {
+			// 创建失败时移除缓存，下次请求重新创建
+			if nil == ref.service {
+				b.deleteReference(ref)
+			}
+		}()
+		ref.config, ref.service = b.newGenericReference(backend)
+	})
+	return ref.service
+}
+
+// OnBackendServiceEvent 服务添加、更新时，后台预热泛化调用引用；服务删除后，销毁不再被使用的引用
+func (b *BackendTransportService) OnBackendServiceEvent(event flux.BackendServiceEvent) {
+	service := event.Service
+	if nil == b.configuration || !service.IsValid() {
+		return
+	}
+	switch event.EventType {
+	case flux.EventTypeAdded, flux.EventTypeUpdated:
+		if "" != service.ServiceId {
+			if unused := b.bindReference(service.ServiceId, GenericReferenceKey(&service)); "" != unused {
+				b.destroyReference(unused)
+			}
+		}
+		go b.warmup(service)
+	case flux.EventTypeRemoved:
+		if unused := b.bindReference(service.ServiceId, ""); "" != unused {
+			b.destroyReference(unused)
+		}
+	}
+}
+
+func (b *BackendTransportService) warmup(service flux.BackendService) {
+	defer func() {
+		if r := recover(); nil != r {
+			logger.Errorw("Dubbo generic reference warm-up failed", "service-id", service.ServiceID(), "error", r)
+		}
+	}()
+	b.LoadGenericService(&service)
+}
+
+// bindReference 更新服务使用的引用Key；返回不再被任何服务使用的引用Key
+func (b *BackendTransportService) bindReference(serviceId string, key string) (unused string) {
+	b.referenceMutex.Lock()
+	defer b.referenceMutex.Unlock()
+	old, bound := b.serviceRefs[serviceId]
+	if bound && old == key {
+		return ""
+	}
+	if "" != key {
+		b.serviceRefs[serviceId] = key
+		b.referenceCounts[key]++
+	} else {
+		delete(b.serviceRefs, serviceId)
+	}
+	if !bound {
+		return ""
+	}
+	if b.referenceCounts[old]--; b.referenceCounts[old] <= 0 {
+		delete(b.referenceCounts, old)
+		return old
+	}
+	return ""
+}
+
+// destroyReference 移除缓存，并在延迟后销毁引用的Invoker，等待进行中的请求完成
+func (b *BackendTransportService) destroyReference(key string) {
+	v, ok := b.references.Load(key)
+	if !ok {
+		return
+	}
+	ref := v.(*GenericReference)
+	b.deleteReference(ref)
+	delay := b.configuration.GetDuration(ConfigKeyReferenceDestroyDelay)
+	logger.Infow("Destroy dubbo generic reference: PENDING", "reference", key, "delay", delay)
+	time.AfterFunc(delay, func() {
+		// 等待引用创建完成
+		ref.once.Do(func() {})
+		if invoker := referenceInvoker(ref.config); nil != invoker {
+			invoker.Destroy()
+		}
+		logger.Infow("Destroy dubbo generic reference: OK", "reference", key)
+	})
+}
+
+func (b *BackendTransportService) deleteReference(ref *GenericReference) {
+	if v, ok := b.references.Load(ref.key); ok && v == ref {
+		b.references.Delete(ref.key)
+	}
+}
+
+func (b *BackendTransportService) newGenericReference(backend *flux.BackendService) (*dubgo.ReferenceConfig, common.RPCService) {
+	newRef := NewReference(backend.Interface, backend, b.configuration)
+	// Options
+	const msg = "Dubbo option-func return nil reference"
+	for _, optsFunc := range b.dubboOptionsFunc {
+		if nil != optsFunc {
+			newRef = pkg.RequireNotNil(optsFunc(backend, b.configuration, newRef), msg).(*dubgo.ReferenceConfig)
+		}
+	}
+	logger.Infow("Create dubbo generic service: PENDING", "interface", backend.Interface,
+		"rpc-group", backend.AttrRpcGroup(), "rpc-version", backend.AttrRpcVersion())
+	srv := b.dubboServiceFunc(backend)
+	referenceCreateMutex.Lock()
+	func() {
+		defer referenceCreateMutex.Unlock()
+		dubgo.SetConsumerService(srv)
+		newRef.Refer(srv)
+		newRef.Implement(srv)
+	}()
+	t := b.configuration.GetDuration(ConfigKeyReferenceDelay)
+	if t == 0 {
+		t = time.Millisecond * 10
+	}
+	<-time.After(t)
+	logger.Infow("Create dubbo generic service: OK", "interface", backend.Interface,
+		"rpc-group", backend.AttrRpcGroup(), "rpc-version", backend.AttrRpcVersion())
+	return newRef, srv
+}
+
+// referenceInvoker 读取ReferenceConfig的Invoker；DubboGo未公开此字段，通过反射读取
+func referenceInvoker(ref *dubgo.ReferenceConfig) protocol.Invoker {
+	if nil == ref {
+		return nil
+	}
+	field := reflect.ValueOf(ref).Elem().FieldByName("invoker")
+	if !field.IsValid() || field.IsNil() {
+		return nil
+	}
+	field = reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+	invoker, _ := field.Interface().(protocol.Invoker)
+	return invoker
+}
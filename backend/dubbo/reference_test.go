@@ -0,0 +1,115 @@
+package dubbo
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	assert2 "github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+import (
+	"github.com/apache/dubbo-go/common"
+	"github.com/apache/dubbo-go/common/extension"
+	_ "github.com/apache/dubbo-go/common/proxy/proxy_factory"
+	dubgo "github.com/apache/dubbo-go/config"
+	"github.com/apache/dubbo-go/protocol"
+)
+
+const testReferenceProtocol = "fluxtest"
+
+func init() {
+	extension.SetProtocol(testReferenceProtocol, func() protocol.Protocol {
+		return new(testProtocol)
+	})
+}
+
+// testProtocol 不建立连接的Protocol，Refer时返回BaseInvoker
+type testProtocol struct{}
+
+func (p *testProtocol) Export(protocol.Invoker) protocol.Exporter {
+	return nil
+}
+
+func (p *testProtocol) Refer(url common.URL) protocol.Invoker {
+	return protocol.NewBaseInvoker(url)
+}
+
+func (p *testProtocol) Destroy() {}
+
+func newTestReferenceService(iface, group, version string) *flux.BackendService {
+	return &flux.BackendService{
+		Interface: iface,
+		Method:    "hello",
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{
+				{Tag: flux.ServiceAttrTagRpcGroup, Name: "RpcGroup", Value: group},
+				{Tag: flux.ServiceAttrTagRpcVersion, Name: "RpcVersion", Value: version},
+			},
+		},
+	}
+}
+
+func TestGenericReferenceKey(t *testing.T) {
+	assert := assert2.New(t)
+	assert.Equal("net.bytepowered.UserService::", GenericReferenceKey(newTestReferenceService("net.bytepowered.UserService", "", "")))
+	assert.Equal("net.bytepowered.UserService:gray:1.0.0", GenericReferenceKey(newTestReferenceService("net.bytepowered.UserService", "gray", "1.0.0")))
+	assert.NotEqual(
+		GenericReferenceKey(newTestReferenceService("net.bytepowered.UserService", "gray", "1.0.0")),
+		GenericReferenceKey(newTestReferenceService("net.bytepowered.UserService", "prod", "1.0.0")))
+}
+
+func TestBackendTransportService_BindReference(t *testing.T) {
+	bts := NewBackendTransportService().(*BackendTransportService)
+	assert := assert2.New(t)
+	assert.Equal("", bts.bindReference("UserService:get", "UserService:gray:1.0"))
+	assert.Equal("", bts.bindReference("UserService:list", "UserService:gray:1.0"))
+	// 重复绑定
+	assert.Equal("", bts.bindReference("UserService:get", "UserService:gray:1.0"))
+	// 更新版本后，旧引用仍被其它服务使用
+	assert.Equal("", bts.bindReference("UserService:get", "UserService:gray:2.0"))
+	assert.Equal("UserService:gray:1.0", bts.bindReference("UserService:list", ""))
+	assert.Equal("UserService:gray:2.0", bts.bindReference("UserService:get", ""))
+	assert.Equal("", bts.bindReference("UserService:get", ""))
+	assert.Equal(0, len(bts.referenceCounts))
+}
+
+func TestBackendTransportService_ConcurrentWarmup(t *testing.T) {
+	consumer := dubgo.ConsumerConfig{}
+	consumer.ApplicationConfig = &dubgo.ApplicationConfig{Name: "flux-test"}
+	dubgo.SetConsumerConfig(consumer)
+	bts := NewBackendTransportService().(*BackendTransportService)
+	config := flux.NewConfiguration(nil)
+	config.Set(ConfigKeyReferenceDelay, "1ms")
+	assert := assert2.New(t)
+	assert.Nil(bts.Init(config))
+	// 相同Interface、不同Group的服务同时预热
+	services := make([]*flux.BackendService, 0, 8)
+	for i := 0; i < 8; i++ {
+		service := newTestReferenceService("net.bytepowered.WarmupService", fmt.Sprintf("group-%d", i), "1.0.0")
+		service.RemoteHost = testReferenceProtocol + "://127.0.0.1:20880"
+		services = append(services, service)
+	}
+	var wg sync.WaitGroup
+	for _, service := range services {
+		wg.Add(1)
+		go func(service flux.BackendService) {
+			defer wg.Done()
+			bts.warmup(service)
+		}(*service)
+	}
+	wg.Wait()
+	for _, service := range services {
+		key := GenericReferenceKey(service)
+		v, ok := bts.references.Load(key)
+		assert.True(ok, key)
+		ref := v.(*GenericReference)
+		assert.NotNil(ref.Service(), key)
+		// 每个Group使用独立的ConsumerService，不相互覆盖
+		assert.Same(ref.Service(), dubgo.GetConsumerService(key), key)
+		// 反射读取ReferenceConfig的invoker字段
+		invoker := referenceInvoker(ref.config)
+		assert.NotNil(invoker, key)
+		assert.Equal(testReferenceProtocol, invoker.GetUrl().Protocol, key)
+	}
+}
@@ -28,8 +28,10 @@ import (
 )
 
 const (
-	ConfigKeyTraceEnable    = "trace-enable"
-	ConfigKeyReferenceDelay = "reference-delay"
+	ConfigKeyTraceEnable           = "trace-enable"
+	ConfigKeyReferenceDelay        = "reference-delay"
+	ConfigKeyReferenceDestroyDelay = "reference-destroy-delay"
+	ConfigKeyResponseCodecMode     = "response-codec-mode"
 )
 
 func init() {
@@ -42,8 +44,9 @@ var (
 )
 
 var (
-	_     flux.BackendTransport = new(BackendTransportService)
-	_json                       = jsoniter.ConfigCompatibleWithStandardLibrary
+	_     flux.BackendTransport       = new(BackendTransportService)
+	_     flux.BackendServiceListener = new(BackendTransportService)
+	_json                             = jsoniter.ConfigCompatibleWithStandardLibrary
 )
 
 type (
@@ -79,9 +82,12 @@ type BackendTransportService struct {
 	attAssembleFunc   AttachmentAssembleFun         // Attachment封装函数
 	responseCodecFunc flux.BackendResponseCodecFunc // 解析响应结果的函数
 	// 内部私有
-	traceEnable   bool
-	configuration *flux.Configuration
-	serviceMutex  sync.RWMutex
+	traceEnable     bool
+	configuration   *flux.Configuration
+	references      sync.Map          // 泛化调用引用缓存：Interface:Group:Version -> *GenericReference
+	serviceRefs     map[string]string // ServiceId -> 引用Key
+	referenceCounts map[string]int    // 引用Key -> 使用的服务数量
+	referenceMutex  sync.Mutex
 }
 
 // WithArgumentAssembleFunc 用于配置Dubbo参数封装实现函数
@@ -144,6 +150,8 @@ func WithDefaults(defaults map[string]interface{}) Option {
 func NewBackendTransportServiceWith(opts ...Option) flux.BackendTransport {
 	bts := &BackendTransportService{
 		dubboOptionsFunc: make([]DubboGenericOptionsFunc, 0),
+		serviceRefs:      make(map[string]string, 16),
+		referenceCounts:  make(map[string]int, 16),
 	}
 	for _, opt := range opts {
 		opt(bts)
@@ -172,16 +180,19 @@ func NewBackendTransportServiceOverrides(overrides ...Option) flux.BackendTransp
 			"password": "dubbo.registry.password",
 		}),
 		WithDefaults(map[string]interface{}{
-			ConfigKeyReferenceDelay: time.Millisecond * 10,
-			ConfigKeyTraceEnable:    false,
-			"timeout":               "5000",
-			"retries":               "0",
-			"cluster":               "failover",
-			"load-balance":          "random",
-			"protocol":              dubbo.DUBBO,
+			ConfigKeyReferenceDelay:        time.Millisecond * 10,
+			ConfigKeyReferenceDestroyDelay: time.Second * 10,
+			ConfigKeyTraceEnable:           false,
+			ConfigKeyResponseCodecMode:     ResponseCodecModeAttachment,
+			"timeout":                      "5000",
+			"retries":                      "0",
+			"cluster":                      "failover",
+			"load-balance":                 "random",
+			"protocol":                     dubbo.DUBBO,
 		}),
 		WithGenericServiceFunc(func(backend *flux.BackendService) common.RPCService {
-			return dubgo.NewGenericService(backend.Interface)
+			// 以引用Key注册ConsumerService，避免相同Interface、不同Group/Version的服务相互覆盖
+			return dubgo.NewGenericService(GenericReferenceKey(backend))
 		}),
 		WithGenericInvokeFunc(func(ctx context.Context, args []interface{}, rpc common.RPCService) protocol.Result {
 			srv := rpc.(*dubgo.GenericService)
@@ -220,6 +231,11 @@ func (b *BackendTransportService) Init(config *flux.Configuration) error {
 	if pkg.IsNil(b.argAssembleFunc) {
 		b.argAssembleFunc = DefaultArgAssembleFunc
 	}
+	// 可选：从响应数据体读取状态码、Header
+	if mode := config.GetString(ConfigKeyResponseCodecMode); ResponseCodecModeBody == mode {
+		b.responseCodecFunc = NewBodyResponseCodecFunc()
+		logger.Infow("Dubbo backend transport response codec", "mode", mode)
+	}
 	// 修改默认Consumer配置
 	consumerc := dubgo.GetConsumerConfig()
 	// 支持定义Registry
@@ -327,35 +343,6 @@ func (b *BackendTransportService) DoInvoke(types []string, values interface{}, s
 	}
 }
 
-// LoadGenericService create and cache dubbo generic service
-func (b *BackendTransportService) LoadGenericService(backend *flux.BackendService) common.RPCService {
-	b.serviceMutex.Lock()
-	defer b.serviceMutex.Unlock()
-	if srv := dubgo.GetConsumerService(backend.Interface); nil != srv {
-		return srv
-	}
-	newRef := NewReference(backend.Interface, backend, b.configuration)
-	// Options
-	const msg = "Dubbo option-func return nil reference"
-	for _, optsFunc := range b.dubboOptionsFunc {
-		if nil != optsFunc {
-			newRef = pkg.RequireNotNil(optsFunc(backend, b.configuration, newRef), msg).(*dubgo.ReferenceConfig)
-		}
-	}
-	logger.Infow("Create dubbo generic service: PENDING", "interface", backend.Interface)
-	srv := b.dubboServiceFunc(backend)
-	dubgo.SetConsumerService(srv)
-	newRef.Refer(srv)
-	newRef.Implement(srv)
-	t := b.configuration.GetDuration(ConfigKeyReferenceDelay)
-	if t == 0 {
-		t = time.Millisecond * 10
-	}
-	<-time.After(t)
-	logger.Infow("Create dubbo generic service: OK", "interface", backend.Interface)
-	return srv
-}
-
 func newConsumerRegistry(config *flux.Configuration) (string, *dubgo.RegistryConfig) {
 	if !config.IsSet("id", "protocol") {
 		return "", nil
@@ -0,0 +1,66 @@
+package grpc
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/spf13/cast"
+)
+
+import (
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// DefaultArgAssembleFunc gRPC默认参数封装处理：以参数名作为请求消息的字段名，转换成动态Protobuf消息对象。
+// 当仅有一个COMPLEX参数且其Class与请求消息类型相同时，以该参数的值作为整个请求消息。
+func DefaultArgAssembleFunc(method protoreflect.MethodDescriptor, arguments []flux.Argument, ctx flux.Context) (proto.Message, error) {
+	input := method.Input()
+	values := make(map[string]interface{}, len(arguments))
+	for _, arg := range arguments {
+		val, err := arg.Resolve(ctx)
+		if nil != err {
+			return nil, err
+		}
+		if len(arguments) == 1 && flux.ArgumentTypeComplex == arg.Type && string(input.FullName()) == arg.Class {
+			if fields, ok := val.(map[string]interface{}); ok {
+				values = fields
+				break
+			}
+		}
+		values[arg.Name] = val
+	}
+	return NewDynamicMessage(input, values)
+}
+
+// DefaultAttAssembleFun 默认实现封装gRPC Metadata的函数
+func DefaultAttAssembleFun(ctx flux.Context) (metadata.MD, error) {
+	attrs, err := cast.ToStringMapStringE(ctx.Attributes())
+	if nil != err {
+		return nil, err
+	}
+	return metadata.New(attrs), nil
+}
+
+// NewDynamicMessage 根据消息描述和字段值，构建动态Protobuf消息；忽略未定义的字段。
+func NewDynamicMessage(desc protoreflect.MessageDescriptor, values map[string]interface{}) (proto.Message, error) {
+	msg := dynamicpb.NewMessage(desc)
+	if len(values) == 0 {
+		return msg, nil
+	}
+	data, err := ext.JSONMarshal(values)
+	if nil != err {
+		return nil, fmt.Errorf("marshal grpc message values, type: %s, err: %w", desc.FullName(), err)
+	}
+	opts := protojson.UnmarshalOptions{DiscardUnknown: true}
+	if err := opts.Unmarshal(data, msg); nil != err {
+		return nil, fmt.Errorf("unmarshal grpc message, type: %s, err: %w", desc.FullName(), err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,108 @@
+package grpc
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+)
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrUnknownGrpcBackendResponse = errors.New("BACKEND:UNKNOWN_GRPC_RESPONSE")
+)
+
+// RpcResult gRPC调用结果
+type RpcResult struct {
+	Header  metadata.MD
+	Trailer metadata.MD
+	Message proto.Message
+}
+
+// NewBackendResponseCodecFunc 默认响应解析函数：响应消息转换为Map结构，Header/Trailer合并为Http响应Header。
+func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return func(ctx flux.Context, value interface{}) (*flux.BackendResponse, error) {
+		result, ok := value.(*RpcResult)
+		if !ok || nil == result.Message {
+			return &flux.BackendResponse{
+				StatusCode: http.StatusBadGateway,
+				Headers:    make(http.Header, 0),
+				Body:       nil,
+			}, ErrUnknownGrpcBackendResponse
+		}
+		opts := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+		data, err := opts.Marshal(result.Message)
+		if nil != err {
+			return nil, err
+		}
+		body := make(map[string]interface{})
+		if err := ext.JSONUnmarshal(data, &body); nil != err {
+			return nil, err
+		}
+		return &flux.BackendResponse{
+			StatusCode: http.StatusOK,
+			Headers:    ToHttpHeader(result.Header, result.Trailer),
+			Body:       body,
+		}, nil
+	}
+}
+
+// ToHttpHeader 将gRPC Metadata转换为Http Header；忽略gRPC保留的伪Header和二进制Header。
+func ToHttpHeader(mds ...metadata.MD) http.Header {
+	header := make(http.Header, 8)
+	for _, md := range mds {
+		for k, vs := range md {
+			if strings.HasPrefix(k, ":") || strings.HasPrefix(k, "grpc-") || strings.HasSuffix(k, "-bin") ||
+				"content-type" == k {
+				continue
+			}
+			for _, v := range vs {
+				header.Add(k, v)
+			}
+		}
+	}
+	return header
+}
+
+// StatusCodeOf 将gRPC状态码转换为Http状态码
+// Ref: https://github.com/grpc-ecosystem/grpc-gateway/blob/master/runtime/errors.go
+func StatusCodeOf(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.Unknown, codes.Internal, codes.DataLoss:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusInternalServerError
+	}
+}
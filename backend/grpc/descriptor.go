@@ -0,0 +1,246 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+import (
+	"github.com/bytepowered/flux"
+)
+
+import (
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+var (
+	ErrMethodNotFound      = errors.New(flux.ErrorMessageGrpcMethodNotFound)
+	ErrReflectionDisabled  = errors.New("BACKEND:GR:REFLECTION_DISABLED")
+	ErrReflectionBadResult = errors.New("BACKEND:GR:REFLECTION_BAD_RESPONSE")
+)
+
+// DescriptorResolver 用于查找gRPC方法的描述信息；
+// 优先从本地DescriptorSet文件中查找，未找到时通过服务端Reflection接口获取并缓存。
+type DescriptorResolver struct {
+	locals     *protoregistry.Files
+	reflection bool
+	cached     sync.Map // host+service -> protoreflect.ServiceDescriptor
+}
+
+// NewDescriptorResolver 创建DescriptorResolver
+func NewDescriptorResolver(reflection bool) *DescriptorResolver {
+	return &DescriptorResolver{
+		locals:     new(protoregistry.Files),
+		reflection: reflection,
+	}
+}
+
+// LoadDescriptorSetFile 加载由 protoc --descriptor_set_out --include_imports 生成的DescriptorSet文件
+func (r *DescriptorResolver) LoadDescriptorSetFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return fmt.Errorf("read descriptor-set, path: %s, err: %w", path, err)
+	}
+	fds := new(descriptorpb.FileDescriptorSet)
+	if err := proto.Unmarshal(data, fds); nil != err {
+		return fmt.Errorf("unmarshal descriptor-set, path: %s, err: %w", path, err)
+	}
+	return r.RegisterFileDescriptorSet(fds)
+}
+
+// RegisterFileDescriptorSet 注册DescriptorSet中的全部文件描述
+func (r *DescriptorResolver) RegisterFileDescriptorSet(fds *descriptorpb.FileDescriptorSet) error {
+	files, err := protodesc.NewFiles(fds)
+	if nil != err {
+		return err
+	}
+	var rerr error
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		if _, err := r.locals.FindFileByPath(fd.Path()); nil == err {
+			return true
+		}
+		if err := r.locals.RegisterFile(fd); nil != err {
+			rerr = err
+			return false
+		}
+		return true
+	})
+	return rerr
+}
+
+// FindMethod 根据服务全名和方法名，查找方法描述
+func (r *DescriptorResolver) FindMethod(ctx context.Context, conn *grpc.ClientConn, service, method string) (protoreflect.MethodDescriptor, error) {
+	sd, err := r.FindService(ctx, conn, service)
+	if nil != err {
+		return nil, err
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if nil == md {
+		return nil, fmt.Errorf("%w: %s/%s", ErrMethodNotFound, service, method)
+	}
+	return md, nil
+}
+
+// FindService 根据服务全名查找服务描述
+func (r *DescriptorResolver) FindService(ctx context.Context, conn *grpc.ClientConn, service string) (protoreflect.ServiceDescriptor, error) {
+	if sd, ok := findServiceIn(r.locals, service); ok {
+		return sd, nil
+	}
+	key := conn.Target() + "/" + service
+	if sd, ok := r.cached.Load(key); ok {
+		return sd.(protoreflect.ServiceDescriptor), nil
+	}
+	if !r.reflection {
+		if sd, ok := findServiceIn(protoregistry.GlobalFiles, service); ok {
+			return sd, nil
+		}
+		return nil, fmt.Errorf("%w, service: %s", ErrReflectionDisabled, service)
+	}
+	files, err := resolveByReflection(ctx, conn, service)
+	if nil != err {
+		return nil, err
+	}
+	sd, ok := findServiceIn(files, service)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMethodNotFound, service)
+	}
+	r.cached.Store(key, sd)
+	return sd, nil
+}
+
+// Purge 清除指定服务端的Reflection缓存
+func (r *DescriptorResolver) Purge(target string) {
+	prefix := target + "/"
+	r.cached.Range(func(key, _ interface{}) bool {
+		if strings.HasPrefix(key.(string), prefix) {
+			r.cached.Delete(key)
+		}
+		return true
+	})
+}
+
+func findServiceIn(files interface {
+	FindDescriptorByName(protoreflect.FullName) (protoreflect.Descriptor, error)
+}, service string) (protoreflect.ServiceDescriptor, bool) {
+	d, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if nil != err {
+		return nil, false
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	return sd, ok
+}
+
+// resolveByReflection 通过ServerReflection获取服务所在文件及其依赖，构建本地描述注册表
+func resolveByReflection(ctx context.Context, conn *grpc.ClientConn, service string) (*protoregistry.Files, error) {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if nil != err {
+		return nil, err
+	}
+	defer func() {
+		_ = stream.CloseSend()
+	}()
+	protos := make(map[string]*descriptorpb.FileDescriptorProto)
+	if err := reflectionRequest(stream, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	}, protos); nil != err {
+		return nil, err
+	}
+	// 补齐缺失的依赖文件
+	for {
+		missing := ""
+		for _, fdp := range protos {
+			for _, dep := range fdp.GetDependency() {
+				if _, ok := protos[dep]; ok {
+					continue
+				}
+				if _, err := protoregistry.GlobalFiles.FindFileByPath(dep); nil == err {
+					continue
+				}
+				missing = dep
+				break
+			}
+			if missing != "" {
+				break
+			}
+		}
+		if missing == "" {
+			break
+		}
+		if err := reflectionRequest(stream, &rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: missing},
+		}, protos); nil != err {
+			return nil, err
+		}
+		if _, ok := protos[missing]; !ok {
+			return nil, fmt.Errorf("%w, missing file: %s", ErrReflectionBadResult, missing)
+		}
+	}
+	files := new(protoregistry.Files)
+	for name := range protos {
+		if err := registerWithDeps(files, protos, name); nil != err {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func reflectionRequest(stream rpb.ServerReflection_ServerReflectionInfoClient, req *rpb.ServerReflectionRequest,
+	out map[string]*descriptorpb.FileDescriptorProto) error {
+	if err := stream.Send(req); nil != err {
+		return err
+	}
+	resp, err := stream.Recv()
+	if nil != err {
+		return err
+	}
+	if eresp := resp.GetErrorResponse(); nil != eresp {
+		return fmt.Errorf("%w, code: %d, message: %s", ErrMethodNotFound, eresp.GetErrorCode(), eresp.GetErrorMessage())
+	}
+	fdresp := resp.GetFileDescriptorResponse()
+	if nil == fdresp {
+		return ErrReflectionBadResult
+	}
+	for _, data := range fdresp.GetFileDescriptorProto() {
+		fdp := new(descriptorpb.FileDescriptorProto)
+		if err := proto.Unmarshal(data, fdp); nil != err {
+			return err
+		}
+		out[fdp.GetName()] = fdp
+	}
+	return nil
+}
+
+// registerWithDeps 按依赖顺序注册文件描述；本地未提供的依赖从全局注册表中查找
+func registerWithDeps(files *protoregistry.Files, protos map[string]*descriptorpb.FileDescriptorProto, name string) error {
+	if _, err := files.FindFileByPath(name); nil == err {
+		return nil
+	}
+	fdp, ok := protos[name]
+	if !ok {
+		fd, err := protoregistry.GlobalFiles.FindFileByPath(name)
+		if nil != err {
+			return err
+		}
+		return files.RegisterFile(fd)
+	}
+	for _, dep := range fdp.GetDependency() {
+		if err := registerWithDeps(files, protos, dep); nil != err {
+			return err
+		}
+	}
+	fd, err := protodesc.NewFile(fdp, files)
+	if nil != err {
+		return err
+	}
+	return files.RegisterFile(fd)
+}
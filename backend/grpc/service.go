@@ -0,0 +1,355 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/pkg"
+)
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	ConfigKeyTraceEnable      = "trace-enable"
+	ConfigKeyTimeout          = "timeout"
+	ConfigKeyDescriptorSets   = "descriptor-sets"
+	ConfigKeyReflectionEnable = "reflection-enable"
+)
+
+func init() {
+	ext.StoreBackendTransport(flux.ProtoGRPC, NewBackendTransportService())
+}
+
+var _ flux.BackendTransport = new(BackendTransportService)
+
+type (
+	// Option 配置函数
+	Option func(service *BackendTransportService)
+	// ArgumentsAssembleFunc gRPC调用参数封装函数，根据方法描述构建请求消息
+	ArgumentsAssembleFunc func(method protoreflect.MethodDescriptor, arguments []flux.Argument, ctx flux.Context) (proto.Message, error)
+	// AttachmentAssembleFun 封装gRPC Metadata的函数
+	AttachmentAssembleFun func(ctx flux.Context) (metadata.MD, error)
+)
+
+// BackendTransportService 基于服务端Reflection或DescriptorSet，以动态消息执行gRPC调用的BackendService
+type BackendTransportService struct {
+	// 可外部配置
+	defaults          map[string]interface{}        // 配置默认值
+	dialOptions       []grpc.DialOption             // 连接配置
+	argAssembleFunc   ArgumentsAssembleFunc         // 参数封装函数
+	attAssembleFunc   AttachmentAssembleFun         // Metadata封装函数
+	responseCodecFunc flux.BackendResponseCodecFunc // 解析响应结果的函数
+	// 内部私有
+	traceEnable   bool
+	timeout       time.Duration
+	resolver      *DescriptorResolver
+	retryer       *backend.Retryer
+	configuration *flux.Configuration
+	conns         map[string]*grpc.ClientConn
+	connMutex     sync.RWMutex
+}
+
+// WithArgumentAssembleFunc 用于配置gRPC参数封装实现函数
+func WithArgumentAssembleFunc(fun ArgumentsAssembleFunc) Option {
+	return func(service *BackendTransportService) {
+		service.argAssembleFunc = fun
+	}
+}
+
+// WithAttachmentAssembleFunc 用于配置Metadata封装实现函数
+func WithAttachmentAssembleFunc(fun AttachmentAssembleFun) Option {
+	return func(service *BackendTransportService) {
+		service.attAssembleFunc = fun
+	}
+}
+
+// WithResponseCodecFunc 用于配置响应数据解析实现函数
+func WithResponseCodecFunc(fun flux.BackendResponseCodecFunc) Option {
+	return func(service *BackendTransportService) {
+		service.responseCodecFunc = fun
+	}
+}
+
+// WithDialOptions 用于配置gRPC连接参数
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(service *BackendTransportService) {
+		service.dialOptions = append(service.dialOptions, opts...)
+	}
+}
+
+// WithDefaults 用于配置默认配置值
+func WithDefaults(defaults map[string]interface{}) Option {
+	return func(service *BackendTransportService) {
+		service.defaults = defaults
+	}
+}
+
+// NewBackendTransportServiceWith New grpc backend service with options
+func NewBackendTransportServiceWith(opts ...Option) *BackendTransportService {
+	bts := &BackendTransportService{
+		dialOptions: make([]grpc.DialOption, 0),
+		timeout:     time.Second * 10,
+		resolver:    NewDescriptorResolver(true),
+		conns:       make(map[string]*grpc.ClientConn, 8),
+	}
+	for _, opt := range opts {
+		opt(bts)
+	}
+	return bts
+}
+
+// NewBackendTransportService New grpc backend instance
+func NewBackendTransportService() *BackendTransportService {
+	return NewBackendTransportServiceOverrides()
+}
+
+// NewBackendTransportServiceOverrides New grpc backend instance
+func NewBackendTransportServiceOverrides(overrides ...Option) *BackendTransportService {
+	opts := []Option{
+		WithArgumentAssembleFunc(DefaultArgAssembleFunc),
+		WithAttachmentAssembleFunc(DefaultAttAssembleFun),
+		WithResponseCodecFunc(NewBackendResponseCodecFunc()),
+		WithDialOptions(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		WithDefaults(map[string]interface{}{
+			ConfigKeyTraceEnable:      false,
+			ConfigKeyTimeout:          "10s",
+			ConfigKeyReflectionEnable: true,
+		}),
+	}
+	return NewBackendTransportServiceWith(append(opts, overrides...)...)
+}
+
+// Configuration get config instance
+func (b *BackendTransportService) Configuration() *flux.Configuration {
+	return b.configuration
+}
+
+// GetResponseCodecFunc returns result decode func
+func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return b.responseCodecFunc
+}
+
+// Resolver returns descriptor resolver
+func (b *BackendTransportService) Resolver() *DescriptorResolver {
+	return b.resolver
+}
+
+// Init init backend
+func (b *BackendTransportService) Init(config *flux.Configuration) error {
+	logger.Info("gRPC backend transport initializing")
+	config.SetDefaults(b.defaults)
+	b.configuration = config
+	b.traceEnable = config.GetBool(ConfigKeyTraceEnable)
+	if t := config.GetDuration(ConfigKeyTimeout); t > 0 {
+		b.timeout = t
+	}
+	if pkg.IsNil(b.argAssembleFunc) {
+		b.argAssembleFunc = DefaultArgAssembleFunc
+	}
+	if pkg.IsNil(b.attAssembleFunc) {
+		b.attAssembleFunc = DefaultAttAssembleFun
+	}
+	b.resolver = NewDescriptorResolver(config.GetBool(ConfigKeyReflectionEnable))
+	b.retryer = backend.NewRetryerOf(config)
+	for _, path := range config.GetStringSlice(ConfigKeyDescriptorSets) {
+		if err := b.resolver.LoadDescriptorSetFile(path); nil != err {
+			return err
+		}
+		logger.Infow("gRPC backend transport load descriptor-set", "path", path)
+	}
+	logger.Infow("gRPC backend transport request trace", "enable", b.traceEnable)
+	return nil
+}
+
+// Startup startup service
+func (b *BackendTransportService) Startup() error {
+	return nil
+}
+
+// Shutdown shutdown service
+func (b *BackendTransportService) Shutdown(_ context.Context) error {
+	b.connMutex.Lock()
+	defer b.connMutex.Unlock()
+	for host, conn := range b.conns {
+		if err := conn.Close(); nil != err {
+			logger.Warnw("gRPC backend transport close conn", "remote-host", host, "error", err)
+		}
+	}
+	b.conns = make(map[string]*grpc.ClientConn, 8)
+	return nil
+}
+
+// Exchange do exchange with context
+func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
+	return backend.DoExchangeTransport(ctx, b)
+}
+
+// InvokeCodec invoke backend service and decode response, retry by policy if rpc-retries defined
+func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	if nil != b.retryer {
+		return b.retryer.InvokeCodec(ctx, service, b.invokeCodec)
+	}
+	return b.invokeCodec(ctx, service)
+}
+
+func (b *BackendTransportService) invokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	raw, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	// decode response
+	result, err := b.GetResponseCodecFunc()(ctx, raw)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   fmt.Errorf("decode grpc response, err: %w", err),
+		}
+	}
+	return result, nil
+}
+
+// Invoke invoke backend service with context
+func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	conn, err := b.LoadClientConn(service.RemoteHost)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadGateway,
+			ErrorCode:  flux.ErrorCodeGatewayBackend,
+			Message:    flux.ErrorMessageGrpcInvokeFailed,
+			Internal:   err,
+		}
+	}
+	toctx, cancel := context.WithTimeout(ctx.Context(), b.timeoutOf(service))
+	defer cancel()
+	method, err := b.resolver.FindMethod(toctx, conn, service.Interface, service.Method)
+	if nil != err {
+		if _, ok := status.FromError(err); ok {
+			return nil, NewServeError(err)
+		}
+		// 后端服务未定义该方法返回404；Reflection不可用等其它错误返回500
+		code := flux.StatusServerError
+		if errors.Is(err, ErrMethodNotFound) {
+			code = flux.StatusNotFound
+		}
+		return nil, &flux.ServeError{
+			StatusCode: code,
+			ErrorCode:  flux.ErrorCodeGatewayEndpoint,
+			Message:    flux.ErrorMessageGrpcMethodNotFound,
+			Internal:   err,
+		}
+	}
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayEndpoint,
+			Message:    flux.ErrorMessageGrpcMethodNotFound,
+			Internal:   fmt.Errorf("streaming method not supported: %s", method.FullName()),
+		}
+	}
+	request, err := b.argAssembleFunc(method, service.Arguments, ctx)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageGrpcAssembleFailed,
+			Internal:   err,
+		}
+	}
+	md, err := b.attAssembleFunc(ctx)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageGrpcAssembleFailed,
+			Internal:   err,
+		}
+	}
+	return b.DoInvoke(metadata.NewOutgoingContext(toctx, md), conn, method, request, service, ctx)
+}
+
+// DoInvoke execute backend service with request message
+func (b *BackendTransportService) DoInvoke(goctx context.Context, conn *grpc.ClientConn, method protoreflect.MethodDescriptor,
+	request proto.Message, service flux.BackendService, ctx flux.Context) (interface{}, *flux.ServeError) {
+	if b.traceEnable {
+		logger.TraceContext(ctx).Infow("BACKEND:GRPC:INVOKE",
+			"backend-service", service.ServiceID(), "remote-host", service.RemoteHost, "request", request)
+	}
+	result := &RpcResult{Message: dynamicpb.NewMessage(method.Output())}
+	fullMethod := "/" + service.Interface + "/" + service.Method
+	err := conn.Invoke(goctx, fullMethod, request, result.Message, grpc.Header(&result.Header), grpc.Trailer(&result.Trailer))
+	if nil != err {
+		logger.TraceContext(ctx).Errorw("BACKEND:GRPC:RPC_ERROR",
+			"backend-service", service.ServiceID(), "error", err)
+		return nil, NewServeError(err)
+	}
+	if b.traceEnable {
+		logger.TraceContext(ctx).Infow("BACKEND:GRPC:RECEIVED",
+			"backend-service", service.ServiceID(), "response", result.Message)
+	}
+	return result, nil
+}
+
+// LoadClientConn 获取或创建到目标地址的gRPC连接
+func (b *BackendTransportService) LoadClientConn(target string) (*grpc.ClientConn, error) {
+	b.connMutex.RLock()
+	conn, ok := b.conns[target]
+	b.connMutex.RUnlock()
+	if ok {
+		return conn, nil
+	}
+	b.connMutex.Lock()
+	defer b.connMutex.Unlock()
+	if conn, ok := b.conns[target]; ok {
+		return conn, nil
+	}
+	logger.Infow("Create grpc client conn", "remote-host", target)
+	conn, err := grpc.Dial(target, b.dialOptions...)
+	if nil != err {
+		return nil, err
+	}
+	b.conns[target] = conn
+	return conn, nil
+}
+
+func (b *BackendTransportService) timeoutOf(service flux.BackendService) time.Duration {
+	if to := service.AttrRpcTimeout(); to != "" {
+		if timeout, err := time.ParseDuration(to); nil == err && timeout > 0 {
+			return timeout
+		}
+		logger.Warnw("Illegal backend rpc-timeout", "timeout", to)
+	}
+	return b.timeout
+}
+
+// NewServeError 将gRPC调用错误转换为ServeError，Http状态码由gRPC状态码映射。
+func NewServeError(err error) *flux.ServeError {
+	st, _ := status.FromError(err)
+	serr := &flux.ServeError{
+		StatusCode: StatusCodeOf(st.Code()),
+		ErrorCode:  flux.ErrorCodeGatewayBackend,
+		Message:    flux.ErrorMessageGrpcInvokeFailed,
+		Internal:   err,
+	}
+	serr.PutExtraTrace("grpc-code", st.Code().String())
+	serr.PutExtraTrace("grpc-message", st.Message())
+	return serr
+}
@@ -0,0 +1,134 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+)
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestTransport(t *testing.T) *BackendTransportService {
+	ext.StoreLoggerFactory(func(_ context.Context) flux.Logger {
+		return logger.SimpleLogger()
+	})
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	hs := health.NewServer()
+	hs.SetServingStatus("flux.test", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, hs)
+	reflection.Register(server)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+	bts := NewBackendTransportServiceOverrides(WithDialOptions(
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+	))
+	t.Cleanup(func() {
+		_ = bts.Shutdown(context.Background())
+	})
+	return bts
+}
+
+func TestBackendTransportService_InvokeCodec(t *testing.T) {
+	ext.StoreArgumentLookupFunc(support.DefaultArgumentValueLookupFunc)
+	serializer := flux.NewJsonSerializer()
+	ext.StoreSerializer(ext.TypeNameSerializerDefault, serializer)
+	ext.StoreSerializer(ext.TypeNameSerializerJson, serializer)
+	bts := newTestTransport(t)
+	cases := []struct {
+		service        string
+		expectedStatus int
+		expectedBody   interface{}
+	}{
+		{
+			service:        "flux.test",
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]interface{}{"status": "SERVING"},
+		},
+		{
+			service:        "flux.unknown",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		ctx := support.NewValuesContext(map[string]interface{}{
+			"service": tcase.service,
+		})
+		resp, serr := bts.InvokeCodec(ctx, flux.BackendService{
+			RemoteHost: "bufnet",
+			Interface:  "grpc.health.v1.Health",
+			Method:     "Check",
+			Arguments: []flux.Argument{
+				ext.NewStringArgument("service"),
+			},
+		})
+		if tcase.expectedStatus == http.StatusOK {
+			assert.Nil(serr)
+			assert.Equal(tcase.expectedStatus, resp.StatusCode)
+			assert.Equal(tcase.expectedBody, resp.Body)
+		} else {
+			assert.NotNil(serr)
+			assert.Equal(tcase.expectedStatus, serr.StatusCode)
+			assert.Equal(flux.ErrorCodeGatewayBackend, serr.GetErrorCode())
+			assert.Equal(codes.NotFound.String(), serr.GetExtraTrace("grpc-code"))
+		}
+	}
+}
+
+func TestBackendTransportService_MethodNotFound(t *testing.T) {
+	bts := newTestTransport(t)
+	_, serr := bts.Invoke(support.NewEmptyContext(), flux.BackendService{
+		RemoteHost: "bufnet",
+		Interface:  "grpc.health.v1.Health",
+		Method:     "NotExists",
+	})
+	assert := assert2.New(t)
+	assert.NotNil(serr)
+	assert.Equal(http.StatusNotFound, serr.StatusCode)
+	assert.Equal(flux.ErrorCodeGatewayEndpoint, serr.GetErrorCode())
+	assert.Equal(flux.ErrorMessageGrpcMethodNotFound, serr.Message)
+	assert.True(errors.Is(serr.Internal, ErrMethodNotFound))
+}
+
+func TestStatusCodeOf(t *testing.T) {
+	cases := []struct {
+		code     codes.Code
+		expected int
+	}{
+		{code: codes.OK, expected: http.StatusOK},
+		{code: codes.InvalidArgument, expected: http.StatusBadRequest},
+		{code: codes.NotFound, expected: http.StatusNotFound},
+		{code: codes.PermissionDenied, expected: http.StatusForbidden},
+		{code: codes.Unauthenticated, expected: http.StatusUnauthorized},
+		{code: codes.ResourceExhausted, expected: http.StatusTooManyRequests},
+		{code: codes.Unavailable, expected: http.StatusServiceUnavailable},
+		{code: codes.DeadlineExceeded, expected: http.StatusGatewayTimeout},
+		{code: codes.Internal, expected: http.StatusInternalServerError},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		assert.Equal(tcase.expected, StatusCodeOf(tcase.code), tcase.code.String())
+	}
+}
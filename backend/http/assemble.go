@@ -1,44 +1,79 @@
 package http
 
 import (
-	"context"
+	"bytes"
 	"fmt"
 	"github.com/bytepowered/flux"
-	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/ext"
 	"github.com/spf13/cast"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 )
 
+const (
+	// ExtKeyBodyMode BackendService扩展配置：转发请求Body的编码模式
+	ExtKeyBodyMode = "http-body-mode"
+)
+
+// 请求Body编码模式
+const (
+	BodyModeForm      = "form"      // application/x-www-form-urlencoded，默认
+	BodyModeJSON      = "json"      // application/json
+	BodyModeMultipart = "multipart" // multipart/form-data
+	BodyModeRaw       = "raw"       // 透传原始请求Body；参数拼接到URL中
+)
+
+const (
+	ContentTypeForm = "application/x-www-form-urlencoded"
+	ContentTypeJSON = "application/json; charset=utf-8"
+)
+
+// BodyModeOf 返回BackendService定义的Body编码模式；未定义时返回 BodyModeForm
+func BodyModeOf(service *flux.BackendService) string {
+	mode := strings.ToLower(service.ExtString(ExtKeyBodyMode))
+	switch mode {
+	case BodyModeJSON, BodyModeMultipart, BodyModeRaw:
+		return mode
+	default:
+		return BodyModeForm
+	}
+}
+
 func DefaultArgumentAssemble(service *flux.BackendService, inURL *url.URL, bodyReader io.ReadCloser, ctx flux.Context) (*http.Request, error) {
 	inParams := service.Arguments
 	newQuery := inURL.RawQuery
 	// 使用可重复读的GetBody函数
-	defer func() {
-		_ = bodyReader.Close()
-	}()
+	if nil != bodyReader {
+		defer func() {
+			_ = bodyReader.Close()
+		}()
+	}
 	var newBodyReader io.Reader = bodyReader
+	var contentType string
+	mode := BodyModeOf(service)
+	if BodyModeRaw == mode {
+		// 透传原始Body，保留原请求的Content-Type
+		contentType = ctx.Request().HeaderValue("Content-Type")
+	}
 	if len(inParams) > 0 {
 		// 如果Endpoint定义了参数，即表示限定参数传递
-		var data string
-		if values, err := AssembleHttpValues(inParams, ctx); nil != err {
-			return nil, err
-		} else {
-			data = values.Encode()
-		}
-		// GET：参数拼接到URL中；
-		if http.MethodGet == service.Method {
-			if newQuery == "" {
-				newQuery = data
+		// GET/RAW：参数拼接到URL中；
+		if http.MethodGet == service.Method || BodyModeRaw == mode {
+			if values, err := AssembleHttpValues(inParams, ctx); nil != err {
+				return nil, err
 			} else {
-				newQuery += "&" + data
+				newQuery = appendQuery(newQuery, values.Encode())
 			}
 		} else {
-			// 其它方法：拼接到Body中，并设置form-data/x-www-url-encoded
-			newBodyReader = strings.NewReader(data)
+			// 其它方法：按编码模式拼接到Body中
+			body, ctype, err := AssembleHttpBody(mode, inParams, ctx)
+			if nil != err {
+				return nil, err
+			}
+			newBodyReader, contentType = body, ctype
 		}
 	}
 	// 未定义参数，即透传Http请求：Rewrite inRequest path
@@ -53,25 +88,41 @@ func DefaultArgumentAssemble(service *flux.BackendService, inURL *url.URL, bodyR
 		RawQuery:   newQuery,
 		Fragment:   inURL.Fragment,
 	}
-	to := service.AttrRpcTimeout()
-	timeout, err := time.ParseDuration(to)
-	if err != nil {
-		logger.Warnf("Illegal endpoint rpc-timeout: ", to)
-		timeout = time.Second * 10
-	}
-	toctx, _ := context.WithTimeout(ctx.Context(), timeout)
-	newRequest, err := http.NewRequestWithContext(toctx, service.Method, newUrl.String(), newBodyReader)
+	newRequest, err := http.NewRequestWithContext(ctx.Context(), service.Method, newUrl.String(), newBodyReader)
 	if nil != err {
 		return nil, fmt.Errorf("new request, method: %s, url: %s, err: %w", service.Method, newUrl, err)
 	}
-	// Body数据设置application/x-www-url-encoded
-	if http.MethodGet != service.Method {
-		newRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if "" != contentType {
+		newRequest.Header.Set("Content-Type", contentType)
 	}
 	newRequest.Header.Set("User-Agent", "FluxGo/Backend/v1")
 	return newRequest, err
 }
 
+// AssembleHttpBody 根据编码模式，将参数封装为请求Body，返回Body数据及其Content-Type
+func AssembleHttpBody(mode string, arguments []flux.Argument, ctx flux.Context) (io.Reader, string, error) {
+	switch mode {
+	case BodyModeJSON:
+		values, err := AssembleJsonValues(arguments, ctx)
+		if nil != err {
+			return nil, "", err
+		}
+		data, err := ext.JSONMarshal(values)
+		if nil != err {
+			return nil, "", fmt.Errorf("marshal json body, err: %w", err)
+		}
+		return bytes.NewReader(data), ContentTypeJSON, nil
+	case BodyModeMultipart:
+		return AssembleMultipartBody(arguments, ctx)
+	default:
+		values, err := AssembleHttpValues(arguments, ctx)
+		if nil != err {
+			return nil, "", err
+		}
+		return strings.NewReader(values.Encode()), ContentTypeForm, nil
+	}
+}
+
 func AssembleHttpValues(arguments []flux.Argument, ctx flux.Context) (url.Values, error) {
 	values := make(url.Values, len(arguments))
 	for _, arg := range arguments {
@@ -83,3 +134,67 @@ func AssembleHttpValues(arguments []flux.Argument, ctx flux.Context) (url.Values
 	}
 	return values, nil
 }
+
+// AssembleJsonValues 将参数封装为JSON对象结构；COMPLEX参数按其Fields定义输出为嵌套对象
+func AssembleJsonValues(arguments []flux.Argument, ctx flux.Context) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(arguments))
+	for _, arg := range arguments {
+		if flux.ArgumentTypeComplex == arg.Type && len(arg.Fields) > 0 && nil == arg.ValueLoader {
+			if fields, err := AssembleJsonValues(arg.Fields, ctx); nil != err {
+				return nil, err
+			} else {
+				values[arg.Name] = fields
+			}
+		} else if val, err := arg.Resolve(ctx); nil != err {
+			return nil, err
+		} else {
+			values[arg.Name] = val
+		}
+	}
+	return values, nil
+}
+
+// AssembleMultipartBody 将参数封装为multipart/form-data；[]byte和io.Reader类型的参数值以文件字段输出
+func AssembleMultipartBody(arguments []flux.Argument, ctx flux.Context) (io.Reader, string, error) {
+	buffer := new(bytes.Buffer)
+	writer := multipart.NewWriter(buffer)
+	for _, arg := range arguments {
+		val, err := arg.Resolve(ctx)
+		if nil != err {
+			return nil, "", err
+		}
+		switch v := val.(type) {
+		case []byte:
+			err = writeMultipartFile(writer, arg.Name, bytes.NewReader(v))
+		case io.Reader:
+			err = writeMultipartFile(writer, arg.Name, v)
+		default:
+			err = writer.WriteField(arg.Name, cast.ToString(v))
+		}
+		if nil != err {
+			return nil, "", fmt.Errorf("write multipart field: %s, err: %w", arg.Name, err)
+		}
+	}
+	if err := writer.Close(); nil != err {
+		return nil, "", err
+	}
+	return buffer, writer.FormDataContentType(), nil
+}
+
+func writeMultipartFile(writer *multipart.Writer, name string, reader io.Reader) error {
+	part, err := writer.CreateFormFile(name, name)
+	if nil != err {
+		return err
+	}
+	_, err = io.Copy(part, reader)
+	return err
+}
+
+func appendQuery(query, data string) string {
+	if query == "" {
+		return data
+	} else if data == "" {
+		return query
+	}
+	return query + "&" + data
+}
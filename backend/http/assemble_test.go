@@ -0,0 +1,106 @@
+package http
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDefaultArgumentAssemble(t *testing.T) {
+	ext.StoreArgumentLookupFunc(support.DefaultArgumentValueLookupFunc)
+	serializer := flux.NewJsonSerializer()
+	ext.StoreSerializer(ext.TypeNameSerializerDefault, serializer)
+	ext.StoreSerializer(ext.TypeNameSerializerJson, serializer)
+	arguments := []flux.Argument{
+		ext.NewStringArgument("username"),
+		func() flux.Argument {
+			arg := ext.NewComplexArgument("net.bytepowreed.test.POJO", "profile")
+			arg.Fields = []flux.Argument{
+				ext.NewStringArgument("username"),
+				ext.NewIntegerArgument("year"),
+			}
+			return arg
+		}(),
+	}
+	cases := []struct {
+		mode                string
+		method              string
+		expectedContentType string
+		expectedQuery       string
+		expectedBody        string
+	}{
+		{
+			mode:                "",
+			method:              http.MethodPost,
+			expectedContentType: ContentTypeForm,
+			expectedBody:        "profile=&username=yongjiachen",
+		},
+		{
+			mode:                BodyModeJSON,
+			method:              http.MethodPost,
+			expectedContentType: ContentTypeJSON,
+			expectedBody:        `{"profile":{"username":"yongjiachen","year":2020},"username":"yongjiachen"}`,
+		},
+		{
+			mode:          BodyModeJSON,
+			method:        http.MethodGet,
+			expectedQuery: "profile=&username=yongjiachen",
+		},
+		{
+			mode:                BodyModeRaw,
+			method:              http.MethodPut,
+			expectedContentType: "text/plain",
+			expectedQuery:       "profile=&username=yongjiachen",
+			expectedBody:        "raw-body",
+		},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		ctx := support.NewValuesContext(map[string]interface{}{
+			"username":     "yongjiachen",
+			"year":         2020,
+			"Content-Type": "text/plain",
+		})
+		service := &flux.BackendService{
+			Scheme:     "http",
+			RemoteHost: "localhost:8080",
+			Interface:  "/test",
+			Method:     tcase.method,
+			Arguments:  arguments,
+			EmbeddedExtensions: flux.EmbeddedExtensions{
+				Extensions: map[string]interface{}{ExtKeyBodyMode: tcase.mode},
+			},
+		}
+		inURL, _ := url.Parse("http://localhost/in")
+		req, err := DefaultArgumentAssemble(service, inURL, ioutil.NopCloser(strings.NewReader("raw-body")), ctx)
+		assert.Nil(err)
+		assert.Equal(tcase.expectedContentType, req.Header.Get("Content-Type"), "content-type, mode: "+tcase.mode)
+		assert.Equal(tcase.expectedQuery, req.URL.RawQuery, "query, mode: "+tcase.mode)
+		if tcase.expectedBody != "" {
+			data, err := ioutil.ReadAll(req.Body)
+			assert.Nil(err)
+			assert.Equal(tcase.expectedBody, string(data), "body, mode: "+tcase.mode)
+		}
+	}
+}
+
+func TestAssembleMultipartBody(t *testing.T) {
+	ext.StoreArgumentLookupFunc(support.DefaultArgumentValueLookupFunc)
+	ctx := support.NewValuesContext(map[string]interface{}{
+		"username": "yongjiachen",
+	})
+	body, contentType, err := AssembleMultipartBody([]flux.Argument{ext.NewStringArgument("username")}, ctx)
+	assert := assert2.New(t)
+	assert.Nil(err)
+	assert.True(strings.HasPrefix(contentType, "multipart/form-data; boundary="))
+	req, _ := http.NewRequest(http.MethodPost, "http://localhost/", body)
+	req.Header.Set("Content-Type", contentType)
+	assert.Nil(req.ParseMultipartForm(1024))
+	assert.Equal("yongjiachen", req.FormValue("username"))
+}
@@ -0,0 +1,186 @@
+package http
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/support"
+	"github.com/spf13/cast"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// 负载均衡策略
+const (
+	BalancerRoundRobin     = "round-robin"
+	BalancerWeighted       = "weighted"
+	BalancerLeastRequests  = "least-requests"
+	BalancerConsistentHash = "consistent-hash"
+)
+
+const (
+	// 一致性Hash环上每单位权重的虚拟节点数
+	hashVirtualNodes = 40
+)
+
+// UpstreamHost 上游集群中的服务地址及其运行状态
+type UpstreamHost struct {
+	active       int64 // 进行中的请求数
+	failures     int64 // 连续失败次数
+	ejections    int64 // 驱逐次数
+	ejectedUntil int64 // 驱逐截止时间
+	latency      int64 // 平均响应时间
+	unhealthy    int32 // 主动健康检查结果
+	current      int   // 平滑加权轮询的当前权重
+	mu           sync.Mutex
+	Address      string
+	Weight       int
+}
+
+func NewUpstreamHost(address string, weight int) *UpstreamHost {
+	if weight <= 0 {
+		weight = 1
+	}
+	return &UpstreamHost{Address: address, Weight: weight}
+}
+
+// Active 返回当前进行中的请求数
+func (h *UpstreamHost) Active() int64 {
+	return atomic.LoadInt64(&h.active)
+}
+
+func (h *UpstreamHost) acquire() {
+	atomic.AddInt64(&h.active, 1)
+}
+
+func (h *UpstreamHost) release() {
+	atomic.AddInt64(&h.active, -1)
+}
+
+// Balancer 负载均衡策略：从候选地址列表中选择一个地址；候选列表不为空
+type Balancer interface {
+	Select(hosts []*UpstreamHost, ctx flux.Context) *UpstreamHost
+}
+
+// NewBalancer 根据集群定义的策略名称创建负载均衡实例；未知策略按轮询处理
+func NewBalancer(cluster *flux.UpstreamCluster, hosts []*UpstreamHost) Balancer {
+	switch cluster.Balancer {
+	case BalancerWeighted:
+		return new(WeightedBalancer)
+	case BalancerLeastRequests:
+		return new(LeastRequestsBalancer)
+	case BalancerConsistentHash:
+		return NewConsistentHashBalancer(cluster.HashLookup, hosts)
+	default:
+		return new(RoundRobinBalancer)
+	}
+}
+
+// RoundRobinBalancer 轮询
+type RoundRobinBalancer struct {
+	next uint64
+}
+
+func (b *RoundRobinBalancer) Select(hosts []*UpstreamHost, _ flux.Context) *UpstreamHost {
+	n := atomic.AddUint64(&b.next, 1)
+	return hosts[(n-1)%uint64(len(hosts))]
+}
+
+// WeightedBalancer 平滑加权轮询
+// Ref: https://github.com/phusion/nginx/commit/27e94984486058d73157038f7950a0a36ecc6e35
+type WeightedBalancer struct {
+	mu sync.Mutex
+}
+
+func (b *WeightedBalancer) Select(hosts []*UpstreamHost, _ flux.Context) *UpstreamHost {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var best *UpstreamHost
+	total := 0
+	for _, h := range hosts {
+		h.current += h.Weight
+		total += h.Weight
+		if nil == best || h.current > best.current {
+			best = h
+		}
+	}
+	best.current -= total
+	return best
+}
+
+// LeastRequestsBalancer 选择进行中请求数（按权重折算）最少的地址；相同时轮询
+type LeastRequestsBalancer struct {
+	next uint64
+}
+
+func (b *LeastRequestsBalancer) Select(hosts []*UpstreamHost, _ flux.Context) *UpstreamHost {
+	size := len(hosts)
+	offset := int(atomic.AddUint64(&b.next, 1) % uint64(size))
+	best := hosts[offset]
+	for i := 1; i < size; i++ {
+		h := hosts[(offset+i)%size]
+		// h.active/h.weight < best.active/best.weight
+		if h.Active()*int64(best.Weight) < best.Active()*int64(h.Weight) {
+			best = h
+		}
+	}
+	return best
+}
+
+// ConsistentHashBalancer 根据Lookup表达式查找的请求值，在一致性Hash环上选择地址；
+// 查找不到请求值时，按轮询处理。
+type ConsistentHashBalancer struct {
+	lookup   string
+	ring     []uint32
+	nodes    map[uint32]*UpstreamHost
+	fallback RoundRobinBalancer
+}
+
+func NewConsistentHashBalancer(lookup string, hosts []*UpstreamHost) *ConsistentHashBalancer {
+	b := &ConsistentHashBalancer{
+		lookup: lookup,
+		ring:   make([]uint32, 0, len(hosts)*hashVirtualNodes),
+		nodes:  make(map[uint32]*UpstreamHost, len(hosts)*hashVirtualNodes),
+	}
+	for _, h := range hosts {
+		for i := 0; i < h.Weight*hashVirtualNodes; i++ {
+			hash := crc32.ChecksumIEEE([]byte(h.Address + "#" + strconv.Itoa(i)))
+			if _, ok := b.nodes[hash]; ok {
+				continue
+			}
+			b.nodes[hash] = h
+			b.ring = append(b.ring, hash)
+		}
+	}
+	sort.Slice(b.ring, func(i, j int) bool {
+		return b.ring[i] < b.ring[j]
+	})
+	return b
+}
+
+func (b *ConsistentHashBalancer) Select(hosts []*UpstreamHost, ctx flux.Context) *UpstreamHost {
+	key := ""
+	if "" != b.lookup && nil != ctx {
+		if v, err := support.LookupContextByExpr(b.lookup, ctx); nil == err {
+			key = cast.ToString(v)
+		}
+	}
+	if "" == key || len(b.ring) == 0 {
+		return b.fallback.Select(hosts, ctx)
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(b.ring), func(i int) bool {
+		return b.ring[i] >= hash
+	})
+	// 沿Hash环查找第一个属于候选列表的地址
+	for i := 0; i < len(b.ring); i++ {
+		node := b.nodes[b.ring[(start+i)%len(b.ring)]]
+		for _, h := range hosts {
+			if h == node {
+				return h
+			}
+		}
+	}
+	return b.fallback.Select(hosts, ctx)
+}
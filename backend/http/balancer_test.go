@@ -0,0 +1,130 @@
+package http
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestHosts() []*UpstreamHost {
+	return []*UpstreamHost{
+		NewUpstreamHost("10.0.0.1:8080", 5),
+		NewUpstreamHost("10.0.0.2:8080", 1),
+		NewUpstreamHost("10.0.0.3:8080", 1),
+	}
+}
+
+func TestBalancer_Select(t *testing.T) {
+	cases := []struct {
+		balancer string
+		expected map[string]int
+	}{
+		{
+			balancer: BalancerRoundRobin,
+			expected: map[string]int{"10.0.0.1:8080": 7, "10.0.0.2:8080": 7, "10.0.0.3:8080": 7},
+		},
+		{
+			balancer: BalancerWeighted,
+			expected: map[string]int{"10.0.0.1:8080": 15, "10.0.0.2:8080": 3, "10.0.0.3:8080": 3},
+		},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		hosts := newTestHosts()
+		balancer := NewBalancer(&flux.UpstreamCluster{Balancer: tcase.balancer}, hosts)
+		counts := make(map[string]int)
+		for i := 0; i < 21; i++ {
+			counts[balancer.Select(hosts, nil).Address]++
+		}
+		assert.Equal(tcase.expected, counts, tcase.balancer)
+	}
+}
+
+func TestLeastRequestsBalancer_Select(t *testing.T) {
+	hosts := newTestHosts()
+	hosts[0].active = 20
+	hosts[1].active = 3
+	hosts[2].active = 1
+	balancer := new(LeastRequestsBalancer)
+	assert := assert2.New(t)
+	for i := 0; i < 3; i++ {
+		assert.Equal("10.0.0.3:8080", balancer.Select(hosts, nil).Address)
+	}
+}
+
+func TestConsistentHashBalancer_Select(t *testing.T) {
+	ext.StoreArgumentLookupFunc(support.DefaultArgumentValueLookupFunc)
+	hosts := newTestHosts()
+	balancer := NewConsistentHashBalancer("query:uid", hosts)
+	assert := assert2.New(t)
+	for _, uid := range []string{"1001", "1002", "abc", "xyz"} {
+		ctx := support.NewValuesContext(map[string]interface{}{"uid": uid})
+		selected := balancer.Select(hosts, ctx)
+		for i := 0; i < 5; i++ {
+			assert.Same(selected, balancer.Select(hosts, ctx), "uid: "+uid)
+		}
+		// 选中的地址不可用时，转移到环上的其它地址
+		others := make([]*UpstreamHost, 0)
+		for _, h := range hosts {
+			if h != selected {
+				others = append(others, h)
+			}
+		}
+		assert.NotSame(selected, balancer.Select(others, ctx), "uid: "+uid)
+	}
+}
+
+func TestParseUpstreamHost(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected flux.UpstreamHost
+		err      bool
+	}{
+		{text: "10.0.0.1:8080", expected: flux.UpstreamHost{Address: "10.0.0.1:8080", Weight: 1}},
+		{text: " 10.0.0.1:8080@3 ", expected: flux.UpstreamHost{Address: "10.0.0.1:8080", Weight: 3}},
+		{text: "10.0.0.1:8080@0", err: true},
+		{text: "10.0.0.1:8080@x", err: true},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		host, err := ParseUpstreamHost(tcase.text)
+		if tcase.err {
+			assert.NotNil(err, tcase.text)
+		} else {
+			assert.Nil(err, tcase.text)
+			assert.Equal(tcase.expected, host, tcase.text)
+		}
+	}
+}
+
+func TestUpstreamClusters_Select(t *testing.T) {
+	ext.StoreUpstreamCluster(flux.UpstreamCluster{
+		Name:  "test-cluster",
+		Hosts: []flux.UpstreamHost{{Address: "10.0.0.1:8080"}, {Address: "10.0.0.2:8080"}},
+	})
+	defer ext.RemoveUpstreamCluster("test-cluster")
+	upstreams := NewUpstreamClusters()
+	assert := assert2.New(t)
+	_, found := upstreams.Select("10.0.0.1:8080", nil)
+	assert.False(found)
+	first, found := upstreams.Select("test-cluster", nil)
+	assert.True(found)
+	assert.Equal("10.0.0.1:8080", first.Address)
+	first.eject(OutlierOptions{BaseEjectionTime: time.Minute, MaxEjectionTime: time.Minute}, time.Now())
+	// 更新集群定义后，复制原有地址的状态，不修改原集群的地址
+	ext.StoreUpstreamCluster(flux.UpstreamCluster{
+		Name:  "test-cluster",
+		Hosts: []flux.UpstreamHost{{Address: "10.0.0.1:8080", Weight: 5}, {Address: "10.0.0.3:8080"}},
+	})
+	hosts, _ := upstreams.Hosts("test-cluster")
+	assert.NotSame(first, hosts[0])
+	assert.Equal("10.0.0.1:8080", hosts[0].Address)
+	assert.Equal(5, hosts[0].Weight)
+	assert.Equal(1, first.Weight)
+	assert.False(hosts[0].Available(time.Now()))
+	assert.Equal(int64(1), hosts[0].State(time.Now()).Ejections)
+	assert.Equal("10.0.0.3:8080", hosts[1].Address)
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	ConfigKeyTimeout               = "timeout"
+	ConfigKeyConnectTimeout        = "connect-timeout"
+	ConfigKeyResponseHeaderTimeout = "response-header-timeout"
+	ConfigKeyIdleConnTimeout       = "idle-conn-timeout"
+	ConfigKeyTLSHandshakeTimeout   = "tls-handshake-timeout"
+	ConfigKeyMaxIdleConns          = "max-idle-conns"
+	ConfigKeyMaxIdleConnsPerHost   = "max-idle-conns-per-host"
+	ConfigKeyMaxConnsPerHost       = "max-conns-per-host"
+	ConfigKeyKeepAliveEnable       = "keep-alive-enable"
+	ConfigKeyKeepAlive             = "keep-alive"
+	ConfigKeyTLSCertFile           = "tls-cert-file"
+	ConfigKeyTLSKeyFile            = "tls-key-file"
+	ConfigKeyTLSCAFiles            = "tls-ca-files"
+	ConfigKeyTLSInsecureSkipVerify = "tls-insecure-skip-verify"
+	ConfigKeyProxyURL              = "proxy-url"
+)
+
+// NewHttpClientOf 根据配置构建HttpClient；请求超时由BackendService的rpc-timeout或配置项timeout控制；
+// 未配置 proxy-url 时，使用环境变量 HTTP_PROXY/HTTPS_PROXY/NO_PROXY 的代理配置。
+func NewHttpClientOf(config *flux.Configuration) (*http.Client, error) {
+	tlsConfig, err := NewTLSConfigOf(config)
+	if nil != err {
+		return nil, err
+	}
+	proxy := http.ProxyFromEnvironment
+	if purl := config.GetString(ConfigKeyProxyURL); "" != purl {
+		u, err := url.Parse(purl)
+		if nil != err {
+			return nil, fmt.Errorf("illegal proxy-url: %s, err: %w", purl, err)
+		}
+		proxy = http.ProxyURL(u)
+	}
+	dialer := &net.Dialer{
+		Timeout:   config.GetDuration(ConfigKeyConnectTimeout),
+		KeepAlive: config.GetDuration(ConfigKeyKeepAlive),
+	}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   config.GetDuration(ConfigKeyTLSHandshakeTimeout),
+		ResponseHeaderTimeout: config.GetDuration(ConfigKeyResponseHeaderTimeout),
+		IdleConnTimeout:       config.GetDuration(ConfigKeyIdleConnTimeout),
+		MaxIdleConns:          config.GetInt(ConfigKeyMaxIdleConns),
+		MaxIdleConnsPerHost:   config.GetInt(ConfigKeyMaxIdleConnsPerHost),
+		MaxConnsPerHost:       config.GetInt(ConfigKeyMaxConnsPerHost),
+		DisableKeepAlives:     !config.GetBool(ConfigKeyKeepAliveEnable),
+		ForceAttemptHTTP2:     true,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{
+		Transport: transport,
+	}, nil
+}
+
+// NewTLSConfigOf 根据配置构建客户端TLS配置：客户端证书、自定义CA证书
+func NewTLSConfigOf(config *flux.Configuration) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.GetBool(ConfigKeyTLSInsecureSkipVerify),
+	}
+	certFile, keyFile := config.GetString(ConfigKeyTLSCertFile), config.GetString(ConfigKeyTLSKeyFile)
+	if "" != certFile || "" != keyFile {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if nil != err {
+			return nil, fmt.Errorf("load tls cert, cert: %s, key: %s, err: %w", certFile, keyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cafiles := config.GetStringSlice(ConfigKeyTLSCAFiles); len(cafiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if nil != err || nil == pool {
+			pool = x509.NewCertPool()
+		}
+		for _, file := range cafiles {
+			data, err := ioutil.ReadFile(file)
+			if nil != err {
+				return nil, fmt.Errorf("read tls ca, file: %s, err: %w", file, err)
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("illegal tls ca, file: %s", file)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
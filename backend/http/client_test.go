@@ -0,0 +1,80 @@
+package http
+
+import (
+	"encoding/pem"
+	"github.com/bytepowered/flux"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestClientConfig(values map[string]interface{}) *flux.Configuration {
+	config := flux.NewConfiguration(nil)
+	config.SetDefaults(NewBackendTransportService().defaults)
+	for k, v := range values {
+		config.Set(k, v)
+	}
+	return config
+}
+
+func TestNewHttpClientOf(t *testing.T) {
+	assert := assert2.New(t)
+	client, err := NewHttpClientOf(newTestClientConfig(map[string]interface{}{
+		ConfigKeyResponseHeaderTimeout: "3s",
+		ConfigKeyMaxConnsPerHost:       20,
+		ConfigKeyKeepAliveEnable:       false,
+		ConfigKeyTLSInsecureSkipVerify: true,
+		ConfigKeyProxyURL:              "http://proxy.local:3128",
+	}))
+	assert.Nil(err)
+	transport := client.Transport.(*http.Transport)
+	assert.Equal(time.Second*3, transport.ResponseHeaderTimeout)
+	assert.Equal(time.Second*90, transport.IdleConnTimeout)
+	assert.Equal(100, transport.MaxIdleConns)
+	assert.Equal(10, transport.MaxIdleConnsPerHost)
+	assert.Equal(20, transport.MaxConnsPerHost)
+	assert.True(transport.DisableKeepAlives)
+	assert.True(transport.TLSClientConfig.InsecureSkipVerify)
+	req, _ := http.NewRequest(http.MethodGet, "http://backend.local/", nil)
+	proxy, err := transport.Proxy(req)
+	assert.Nil(err)
+	assert.Equal("proxy.local:3128", proxy.Host)
+}
+
+func TestNewTLSConfigOf_CAFiles(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "flux-http-ca")
+	assert := assert2.New(t)
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	cafile := filepath.Join(dir, "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.Nil(ioutil.WriteFile(cafile, data, 0600))
+	// 未信任的证书
+	client, err := NewHttpClientOf(newTestClientConfig(nil))
+	assert.Nil(err)
+	_, err = client.Get(server.URL)
+	assert.NotNil(err)
+	// 自定义CA证书
+	client, err = NewHttpClientOf(newTestClientConfig(map[string]interface{}{
+		ConfigKeyTLSCAFiles: []string{cafile},
+	}))
+	assert.Nil(err)
+	resp, err := client.Get(server.URL)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+	// 无效的CA证书文件
+	_, err = NewHttpClientOf(newTestClientConfig(map[string]interface{}{
+		ConfigKeyTLSCAFiles: []string{filepath.Join(dir, "not-exists.pem")},
+	}))
+	assert.NotNil(err)
+}
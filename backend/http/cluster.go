@@ -0,0 +1,173 @@
+package http
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ConfigKeyClusters = "clusters"
+)
+
+// upstreamCluster 上游集群的运行时状态
+type upstreamCluster struct {
+	define   *flux.UpstreamCluster
+	hosts    []*UpstreamHost
+	balancer Balancer
+}
+
+// UpstreamClusters 管理上游集群的负载均衡及地址状态；集群定义来自 ext.LoadUpstreamCluster
+type UpstreamClusters struct {
+	clusters sync.Map // name -> *upstreamCluster
+	mu       sync.Mutex
+	outlier  OutlierOptions
+}
+
+func NewUpstreamClusters() *UpstreamClusters {
+	return NewUpstreamClustersWith(OutlierOptions{})
+}
+
+func NewUpstreamClustersWith(outlier OutlierOptions) *UpstreamClusters {
+	return &UpstreamClusters{outlier: outlier}
+}
+
+// Select 如果name为已定义的集群名称，按集群负载均衡策略，从可用地址中选择一个地址；
+// 全部地址均不可用时，从全部地址中选择。返回的 found 标识name是否为集群名称。
+func (u *UpstreamClusters) Select(name string, ctx flux.Context) (host *UpstreamHost, found bool) {
+	cluster, ok := u.load(name)
+	if !ok {
+		return nil, false
+	}
+	if len(cluster.hosts) == 0 {
+		return nil, true
+	}
+	now := time.Now()
+	candidates := make([]*UpstreamHost, 0, len(cluster.hosts))
+	for _, h := range cluster.hosts {
+		if h.Available(now) {
+			candidates = append(candidates, h)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = cluster.hosts
+	}
+	return cluster.balancer.Select(candidates, ctx), true
+}
+
+// Report 记录地址的请求结果，用于异常地址检测
+func (u *UpstreamClusters) Report(host *UpstreamHost, success bool, elapsed time.Duration) {
+	host.report(u.outlier, success, elapsed)
+}
+
+// States 返回全部上游集群的地址状态快照
+func (u *UpstreamClusters) States() map[string][]UpstreamHostState {
+	now := time.Now()
+	out := make(map[string][]UpstreamHostState, 8)
+	for name := range ext.LoadUpstreamClusters() {
+		hosts, _ := u.Hosts(name)
+		states := make([]UpstreamHostState, 0, len(hosts))
+		for _, h := range hosts {
+			states = append(states, h.State(now))
+		}
+		out[name] = states
+	}
+	return out
+}
+
+// Hosts 返回集群当前的地址列表
+func (u *UpstreamClusters) Hosts(name string) ([]*UpstreamHost, bool) {
+	cluster, ok := u.load(name)
+	if !ok {
+		return nil, false
+	}
+	return cluster.hosts, true
+}
+
+func (u *UpstreamClusters) load(name string) (*upstreamCluster, bool) {
+	define, ok := ext.LoadUpstreamCluster(name)
+	if !ok {
+		return nil, false
+	}
+	if v, ok := u.clusters.Load(name); ok && v.(*upstreamCluster).define == define {
+		return v.(*upstreamCluster), true
+	}
+	// 集群定义已更新，重建运行时状态；复制原有地址的状态，新旧集群不共享地址实例
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var previous []*UpstreamHost
+	if v, ok := u.clusters.Load(name); ok {
+		if v.(*upstreamCluster).define == define {
+			return v.(*upstreamCluster), true
+		}
+		previous = v.(*upstreamCluster).hosts
+	}
+	hosts := make([]*UpstreamHost, 0, len(define.Hosts))
+	for _, h := range define.Hosts {
+		host := NewUpstreamHost(h.Address, h.Weight)
+		for _, p := range previous {
+			if p.Address == host.Address {
+				host.copyState(p)
+				break
+			}
+		}
+		hosts = append(hosts, host)
+	}
+	cluster := &upstreamCluster{
+		define:   define,
+		hosts:    hosts,
+		balancer: NewBalancer(define, hosts),
+	}
+	u.clusters.Store(name, cluster)
+	return cluster, true
+}
+
+// NewUpstreamClustersOf 解析配置中定义的上游集群，配置格式：
+// [BACKEND.HTTP.CLUSTERS.cluster-name]
+// balancer = "weighted"
+// hash-lookup = "header:X-User-Id"
+// hosts = ["10.0.0.1:8080@3", "10.0.0.2:8080"]
+func NewUpstreamClustersOf(config *flux.Configuration) ([]flux.UpstreamCluster, error) {
+	clusters := make([]flux.UpstreamCluster, 0)
+	for name := range config.GetStringMap(ConfigKeyClusters) {
+		sub := config.Sub(ConfigKeyClusters + "." + name)
+		cluster := flux.UpstreamCluster{
+			Name:       name,
+			Balancer:   sub.GetString("balancer"),
+			HashLookup: sub.GetString("hash-lookup"),
+		}
+		for _, addr := range sub.GetStringSlice("hosts") {
+			host, err := ParseUpstreamHost(addr)
+			if nil != err {
+				return nil, fmt.Errorf("cluster: %s, err: %w", name, err)
+			}
+			cluster.Hosts = append(cluster.Hosts, host)
+		}
+		if !cluster.IsValid() {
+			return nil, fmt.Errorf("cluster: %s, hosts is empty", name)
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
+
+// ParseUpstreamHost 解析地址定义：host:port@weight，权重可省略
+func ParseUpstreamHost(text string) (flux.UpstreamHost, error) {
+	text = strings.TrimSpace(text)
+	weight := 1
+	if idx := strings.LastIndex(text, "@"); idx > 0 {
+		w, err := strconv.Atoi(text[idx+1:])
+		if nil != err || w <= 0 {
+			return flux.UpstreamHost{}, fmt.Errorf("illegal host weight: %s", text)
+		}
+		text, weight = text[:idx], w
+	}
+	if "" == text {
+		return flux.UpstreamHost{}, fmt.Errorf("illegal host address: %s", text)
+	}
+	return flux.UpstreamHost{Address: text, Weight: weight}, nil
+}
@@ -0,0 +1,229 @@
+package http
+
+import (
+	"context"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ConfigKeyOutlierConsecutiveFailures = "outlier-consecutive-failures"
+	ConfigKeyOutlierBaseEjectionTime    = "outlier-base-ejection-time"
+	ConfigKeyOutlierMaxEjectionTime     = "outlier-max-ejection-time"
+	ConfigKeyOutlierSlowThreshold       = "outlier-slow-threshold"
+	ConfigKeyHealthCheckPath            = "health-check-path"
+	ConfigKeyHealthCheckScheme          = "health-check-scheme"
+	ConfigKeyHealthCheckInterval        = "health-check-interval"
+	ConfigKeyHealthCheckTimeout         = "health-check-timeout"
+)
+
+// OutlierOptions 上游地址异常检测（被动）配置
+type OutlierOptions struct {
+	ConsecutiveFailures int           // 连续失败多少次后驱逐；0表示关闭
+	BaseEjectionTime    time.Duration // 基础驱逐时长；每次驱逐时长按 基础时长*2^(驱逐次数) 增长
+	MaxEjectionTime     time.Duration // 最大驱逐时长
+	SlowThreshold       time.Duration // 响应时间超过此值视为失败；0表示不检测
+}
+
+// UpstreamHostState 上游地址的状态快照，用于Debug查询
+type UpstreamHostState struct {
+	Address      string    `json:"address"`
+	Weight       int       `json:"weight"`
+	Active       int64     `json:"active"`
+	Failures     int64     `json:"failures"`
+	Ejections    int64     `json:"ejections"`
+	Ejected      bool      `json:"ejected"`
+	EjectedUntil time.Time `json:"ejectedUntil,omitempty"`
+	Healthy      bool      `json:"healthy"`
+	LatencyMs    float64   `json:"latencyMs"`
+}
+
+// Available 判断地址当前是否可用：未被驱逐，且主动健康检查未标记为不健康
+func (h *UpstreamHost) Available(now time.Time) bool {
+	return atomic.LoadInt32(&h.unhealthy) == 0 && now.UnixNano() >= atomic.LoadInt64(&h.ejectedUntil)
+}
+
+// State 返回地址的状态快照
+func (h *UpstreamHost) State(now time.Time) UpstreamHostState {
+	until := atomic.LoadInt64(&h.ejectedUntil)
+	state := UpstreamHostState{
+		Address:   h.Address,
+		Weight:    h.Weight,
+		Active:    h.Active(),
+		Failures:  atomic.LoadInt64(&h.failures),
+		Ejections: atomic.LoadInt64(&h.ejections),
+		Ejected:   now.UnixNano() < until,
+		Healthy:   atomic.LoadInt32(&h.unhealthy) == 0,
+		LatencyMs: float64(atomic.LoadInt64(&h.latency)) / float64(time.Millisecond),
+	}
+	if state.Ejected {
+		state.EjectedUntil = time.Unix(0, until)
+	}
+	return state
+}
+
+// copyState 复制地址的异常检测及健康检查状态；进行中的请求数及加权轮询的当前权重不复制
+func (h *UpstreamHost) copyState(from *UpstreamHost) {
+	atomic.StoreInt64(&h.failures, atomic.LoadInt64(&from.failures))
+	atomic.StoreInt64(&h.ejections, atomic.LoadInt64(&from.ejections))
+	atomic.StoreInt64(&h.ejectedUntil, atomic.LoadInt64(&from.ejectedUntil))
+	atomic.StoreInt64(&h.latency, atomic.LoadInt64(&from.latency))
+	atomic.StoreInt32(&h.unhealthy, atomic.LoadInt32(&from.unhealthy))
+}
+
+// report 记录一次请求结果，连续失败达到阈值时驱逐地址
+func (h *UpstreamHost) report(opts OutlierOptions, success bool, elapsed time.Duration) {
+	// 平均延迟：EWMA，alpha = 1/8
+	for {
+		old := atomic.LoadInt64(&h.latency)
+		val := int64(elapsed)
+		if old > 0 {
+			val = old + (int64(elapsed)-old)/8
+		}
+		if atomic.CompareAndSwapInt64(&h.latency, old, val) {
+			break
+		}
+	}
+	if opts.SlowThreshold > 0 && elapsed > opts.SlowThreshold {
+		success = false
+	}
+	if success {
+		atomic.StoreInt64(&h.failures, 0)
+		return
+	}
+	failures := atomic.AddInt64(&h.failures, 1)
+	if opts.ConsecutiveFailures <= 0 || failures < int64(opts.ConsecutiveFailures) {
+		return
+	}
+	h.eject(opts, time.Now())
+}
+
+func (h *UpstreamHost) eject(opts OutlierOptions, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	until := atomic.LoadInt64(&h.ejectedUntil)
+	if now.UnixNano() < until {
+		return
+	}
+	// 上次驱逐结束后，持续正常超过最大驱逐时长，重置驱逐次数
+	if until > 0 && now.Sub(time.Unix(0, until)) > opts.MaxEjectionTime {
+		atomic.StoreInt64(&h.ejections, 0)
+	}
+	ejections := atomic.AddInt64(&h.ejections, 1)
+	duration := opts.BaseEjectionTime
+	for i := int64(1); i < ejections && duration < opts.MaxEjectionTime; i++ {
+		duration *= 2
+	}
+	if duration > opts.MaxEjectionTime {
+		duration = opts.MaxEjectionTime
+	}
+	atomic.StoreInt64(&h.ejectedUntil, now.Add(duration).UnixNano())
+	atomic.StoreInt64(&h.failures, 0)
+	logger.Warnw("Http upstream host ejected", "address", h.Address, "ejections", ejections, "duration", duration)
+}
+
+// HealthChecker 主动健康检查：定时请求上游集群各地址的健康检查路径
+type HealthChecker struct {
+	path      string
+	scheme    string
+	interval  time.Duration
+	timeout   time.Duration
+	client    *http.Client
+	upstreams *UpstreamClusters
+	stop      chan struct{}
+	once      sync.Once
+}
+
+func NewHealthChecker(upstreams *UpstreamClusters, client *http.Client, config *flux.Configuration) *HealthChecker {
+	return &HealthChecker{
+		path:      config.GetString(ConfigKeyHealthCheckPath),
+		scheme:    config.GetString(ConfigKeyHealthCheckScheme),
+		interval:  config.GetDuration(ConfigKeyHealthCheckInterval),
+		timeout:   config.GetDuration(ConfigKeyHealthCheckTimeout),
+		client:    client,
+		upstreams: upstreams,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Enabled 是否配置了健康检查路径
+func (c *HealthChecker) Enabled() bool {
+	return "" != c.path && c.interval > 0
+}
+
+// Start 启动健康检查
+func (c *HealthChecker) Start() {
+	if !c.Enabled() {
+		return
+	}
+	logger.Infow("Http upstream health checker starting", "path", c.path, "interval", c.interval)
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.CheckAll()
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止健康检查
+func (c *HealthChecker) Stop() {
+	c.once.Do(func() {
+		close(c.stop)
+	})
+}
+
+// CheckAll 检查全部上游集群的地址
+func (c *HealthChecker) CheckAll() {
+	var wg sync.WaitGroup
+	for name := range ext.LoadUpstreamClusters() {
+		hosts, _ := c.upstreams.Hosts(name)
+		for _, host := range hosts {
+			wg.Add(1)
+			go func(host *UpstreamHost) {
+				defer wg.Done()
+				c.Check(host)
+			}(host)
+		}
+	}
+	wg.Wait()
+}
+
+// Check 检查单个地址；响应状态码为2xx时视为健康
+func (c *HealthChecker) Check(host *UpstreamHost) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	healthy := false
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.scheme+"://"+host.Address+c.path, nil)
+	if nil == err {
+		req.Header.Set("User-Agent", "FluxGo/HealthCheck/v1")
+		var resp *http.Response
+		if resp, err = c.client.Do(req); nil == err {
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			_ = resp.Body.Close()
+			healthy = resp.StatusCode >= 200 && resp.StatusCode < 300
+		}
+	}
+	if healthy {
+		if atomic.SwapInt32(&host.unhealthy, 0) == 1 {
+			logger.Infow("Http upstream host healthy", "address", host.Address)
+		}
+	} else {
+		if atomic.SwapInt32(&host.unhealthy, 1) == 0 {
+			logger.Warnw("Http upstream host unhealthy", "address", host.Address, "error", err)
+		}
+	}
+	return healthy
+}
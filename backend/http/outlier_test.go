@@ -0,0 +1,97 @@
+package http
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpstreamHost_Eject(t *testing.T) {
+	opts := OutlierOptions{
+		ConsecutiveFailures: 2,
+		BaseEjectionTime:    time.Second,
+		MaxEjectionTime:     time.Second * 3,
+	}
+	host := NewUpstreamHost("10.0.0.1:8080", 1)
+	assert := assert2.New(t)
+	now := time.Now()
+	cases := []time.Duration{time.Second, time.Second * 2, time.Second * 3, time.Second * 3}
+	for i, expected := range cases {
+		now = now.Add(time.Millisecond * 500)
+		host.failures = int64(opts.ConsecutiveFailures)
+		host.eject(opts, now)
+		assert.False(host.Available(now), "ejected: %d", i)
+		assert.True(host.Available(now.Add(expected)), "ejection duration: %d", i)
+		assert.False(host.Available(now.Add(expected-time.Millisecond)), "ejection duration: %d", i)
+		now = now.Add(expected)
+	}
+	assert.Equal(int64(4), host.State(now).Ejections)
+}
+
+func TestUpstreamClusters_SelectOutlier(t *testing.T) {
+	ext.StoreUpstreamCluster(flux.UpstreamCluster{
+		Name:  "test-outlier",
+		Hosts: []flux.UpstreamHost{{Address: "10.0.0.1:8080"}, {Address: "10.0.0.2:8080"}},
+	})
+	defer ext.RemoveUpstreamCluster("test-outlier")
+	upstreams := NewUpstreamClustersWith(OutlierOptions{
+		ConsecutiveFailures: 3,
+		BaseEjectionTime:    time.Minute,
+		MaxEjectionTime:     time.Minute * 5,
+	})
+	hosts, _ := upstreams.Hosts("test-outlier")
+	assert := assert2.New(t)
+	for i := 0; i < 3; i++ {
+		upstreams.Report(hosts[0], false, time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		selected, _ := upstreams.Select("test-outlier", nil)
+		assert.Same(hosts[1], selected)
+	}
+	// 全部地址不可用时，从全部地址中选择
+	for i := 0; i < 3; i++ {
+		upstreams.Report(hosts[1], false, time.Millisecond)
+	}
+	selected, found := upstreams.Select("test-outlier", nil)
+	assert.True(found)
+	assert.NotNil(selected)
+	states := upstreams.States()["test-outlier"]
+	assert.True(states[0].Ejected)
+	assert.True(states[1].Ejected)
+}
+
+func TestHealthChecker_Check(t *testing.T) {
+	healthy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" && healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	config := flux.NewConfigurationOf("test-health-check")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyHealthCheckPath:     "/health",
+		ConfigKeyHealthCheckScheme:   "http",
+		ConfigKeyHealthCheckInterval: "1s",
+		ConfigKeyHealthCheckTimeout:  "1s",
+	})
+	checker := NewHealthChecker(NewUpstreamClusters(), http.DefaultClient, config)
+	host := NewUpstreamHost(strings.TrimPrefix(server.URL, "http://"), 1)
+	assert := assert2.New(t)
+	assert.True(checker.Enabled())
+	assert.True(checker.Check(host))
+	assert.True(host.Available(time.Now()))
+	healthy = false
+	assert.False(checker.Check(host))
+	assert.False(host.Available(time.Now()))
+	healthy = true
+	assert.True(checker.Check(host))
+	assert.True(host.Available(time.Now()))
+}
@@ -1,14 +1,18 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"github.com/bytepowered/flux"
 	"github.com/bytepowered/flux/backend"
 	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
 	"github.com/spf13/cast"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -29,23 +33,46 @@ type BackendTransportService struct {
 	httpClient        *http.Client
 	responseCodecFunc flux.BackendResponseCodecFunc
 	argAssembleFunc   ArgumentsAssembleFunc
+	upstreams         *UpstreamClusters
+	healthChecker     *HealthChecker
+	retryer           *backend.Retryer
+	defaults          map[string]interface{}
+	timeout           time.Duration
+	customClient      bool
 }
 
 func NewBackendTransportService() *BackendTransportService {
-	return &BackendTransportService{
-		httpClient: &http.Client{
-			Timeout: time.Second * 10,
-		},
-		responseCodecFunc: NewBackendResponseCodecFunc(),
-	}
+	return NewBackendTransportServiceWith()
 }
 
 func NewBackendTransportServiceWith(opts ...Option) *BackendTransportService {
 	bts := &BackendTransportService{
-		httpClient: &http.Client{
-			Timeout: time.Second * 10,
-		},
+		httpClient:        &http.Client{},
 		responseCodecFunc: NewBackendResponseCodecFunc(),
+		argAssembleFunc:   DefaultArgumentAssemble,
+		upstreams:         NewUpstreamClusters(),
+		timeout:           time.Second * 10,
+		defaults: map[string]interface{}{
+			ConfigKeyTimeout:                    "10s",
+			ConfigKeyConnectTimeout:             "5s",
+			ConfigKeyResponseHeaderTimeout:      "0s",
+			ConfigKeyIdleConnTimeout:            "90s",
+			ConfigKeyTLSHandshakeTimeout:        "10s",
+			ConfigKeyMaxIdleConns:               100,
+			ConfigKeyMaxIdleConnsPerHost:        10,
+			ConfigKeyMaxConnsPerHost:            0,
+			ConfigKeyKeepAliveEnable:            true,
+			ConfigKeyKeepAlive:                  "30s",
+			ConfigKeyTLSInsecureSkipVerify:      false,
+			ConfigKeyOutlierConsecutiveFailures: 5,
+			ConfigKeyOutlierBaseEjectionTime:    "30s",
+			ConfigKeyOutlierMaxEjectionTime:     "5m",
+			ConfigKeyOutlierSlowThreshold:       "0s",
+			ConfigKeyHealthCheckPath:            "",
+			ConfigKeyHealthCheckScheme:          "http",
+			ConfigKeyHealthCheckInterval:        "10s",
+			ConfigKeyHealthCheckTimeout:         "2s",
+		},
 	}
 	for _, opt := range opts {
 		opt(bts)
@@ -53,10 +80,18 @@ func NewBackendTransportServiceWith(opts ...Option) *BackendTransportService {
 	return bts
 }
 
-// WithHttpClient 用于配置HttpClient客户端
+// WithHttpClient 用于配置HttpClient客户端；配置后，Init时不再根据配置构建HttpClient
 func WithHttpClient(client *http.Client) Option {
 	return func(s *BackendTransportService) {
 		s.httpClient = client
+		s.customClient = true
+	}
+}
+
+// WithDefaults 用于配置默认配置值
+func WithDefaults(defaults map[string]interface{}) Option {
+	return func(service *BackendTransportService) {
+		service.defaults = defaults
 	}
 }
 
@@ -74,6 +109,63 @@ func WithArgumentAssembleFunc(fun ArgumentsAssembleFunc) Option {
 	}
 }
 
+// Init 根据配置构建HttpClient；加载配置中定义的上游集群，以及上游地址异常检测、健康检查、重试配置
+func (b *BackendTransportService) Init(config *flux.Configuration) error {
+	logger.Info("Http backend transport initializing")
+	config.SetDefaults(b.defaults)
+	if t := config.GetDuration(ConfigKeyTimeout); t > 0 {
+		b.timeout = t
+	}
+	if !b.customClient {
+		client, err := NewHttpClientOf(config)
+		if nil != err {
+			return err
+		}
+		b.httpClient = client
+	}
+	b.upstreams = NewUpstreamClustersWith(OutlierOptions{
+		ConsecutiveFailures: config.GetInt(ConfigKeyOutlierConsecutiveFailures),
+		BaseEjectionTime:    config.GetDuration(ConfigKeyOutlierBaseEjectionTime),
+		MaxEjectionTime:     config.GetDuration(ConfigKeyOutlierMaxEjectionTime),
+		SlowThreshold:       config.GetDuration(ConfigKeyOutlierSlowThreshold),
+	})
+	b.healthChecker = NewHealthChecker(b.upstreams, b.httpClient, config)
+	b.retryer = backend.NewRetryerOf(config)
+	ext.StoreDebugQueryFunc("/debug/upstreams", func(_ *http.Request) interface{} {
+		return b.upstreams.States()
+	})
+	clusters, err := NewUpstreamClustersOf(config)
+	if nil != err {
+		return err
+	}
+	for _, cluster := range clusters {
+		logger.Infow("Http backend transport load cluster", "cluster-name", cluster.Name, "hosts", cluster.Hosts)
+		ext.StoreUpstreamCluster(cluster)
+	}
+	return nil
+}
+
+// Startup 启动上游地址健康检查
+func (b *BackendTransportService) Startup() error {
+	if nil != b.healthChecker {
+		b.healthChecker.Start()
+	}
+	return nil
+}
+
+// Shutdown 停止上游地址健康检查
+func (b *BackendTransportService) Shutdown(_ context.Context) error {
+	if nil != b.healthChecker {
+		b.healthChecker.Stop()
+	}
+	return nil
+}
+
+// Upstreams 返回上游集群的负载均衡状态
+func (b *BackendTransportService) Upstreams() *UpstreamClusters {
+	return b.upstreams
+}
+
 func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
 	return b.responseCodecFunc
 }
@@ -82,8 +174,16 @@ func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
 	return backend.DoExchangeTransport(ctx, b)
 }
 
+// InvokeCodec 执行后端服务并解析响应；BackendService配置了rpc-retries时，按重试策略重试；
+// 流式请求Body只能读取一次，不重试
 func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
-	// panic("implement me")
+	if nil != b.retryer && !IsRequestStreaming(ctx) {
+		return b.retryer.InvokeCodec(ctx, service, b.invokeCodec)
+	}
+	return b.invokeCodec(ctx, service)
+}
+
+func (b *BackendTransportService) invokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
 	raw, serr := b.Invoke(ctx, service)
 	if nil != serr {
 		return nil, serr
@@ -103,7 +203,15 @@ func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.Bac
 
 func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
 	inurl, _ := ctx.Request().RequestURL()
-	body, _ := ctx.Request().RequestBodyReader()
+	body, err := RequestBodyOf(ctx, &service)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadRequest,
+			ErrorCode:  flux.ErrorCodeRequestInvalid,
+			Message:    flux.ErrorMessageRequestPrepare,
+			Internal:   fmt.Errorf("read request body, err: %w", err),
+		}
+	}
 	newRequest, err := b.argAssembleFunc(&service, inurl, body, ctx)
 	if nil != err {
 		return nil, &flux.ServeError{
@@ -113,21 +221,86 @@ func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendS
 			Internal:   err,
 		}
 	}
+	// 流式转发原始Body时，保留原请求的Content-Length，避免使用chunked编码
+	if nil != body && IsRequestStreaming(ctx) && newRequest.ContentLength == 0 {
+		if length, err := strconv.ParseInt(ctx.Request().HeaderValue(flux.HeaderContentLength), 10, 64); nil == err && length > 0 {
+			newRequest.ContentLength = length
+		}
+	}
 	return b.ExecuteRequest(newRequest, service, ctx)
 }
 
-func (b *BackendTransportService) ExecuteRequest(newRequest *http.Request, _ flux.BackendService, ctx flux.Context) (interface{}, *flux.ServeError) {
-	// Header透传以及传递AttrValues
+// RequestBodyOf 返回转发到后端的请求Body：参数封装为Body时，不读取请求Body；
+// Endpoint声明为流式请求时，返回原始Body流，不缓存数据
+func RequestBodyOf(ctx flux.Context, service *flux.BackendService) (io.ReadCloser, error) {
+	if len(service.Arguments) > 0 && http.MethodGet != service.Method && BodyModeRaw != BodyModeOf(service) {
+		return nil, nil
+	}
+	if IsRequestStreaming(ctx) {
+		if holder, ok := ctx.(flux.WebContextHolder); ok {
+			if streamer, ok := holder.WebContext().(flux.WebBodyStreamer); ok {
+				return streamer.RequestBodyStream()
+			}
+		}
+	}
+	return ctx.Request().RequestBodyReader()
+}
+
+// IsRequestStreaming 返回Endpoint是否声明为流式请求
+func IsRequestStreaming(ctx flux.Context) bool {
+	return ctx.Endpoint().ExtBool(flux.EndpointExtKeyRequestStreaming)
+}
+
+func (b *BackendTransportService) ExecuteRequest(newRequest *http.Request, service flux.BackendService, ctx flux.Context) (interface{}, *flux.ServeError) {
+	// Header透传以及传递AttrValues；保留参数封装时指定的Content-Type
+	contentType := newRequest.Header.Get("Content-Type")
 	if header, writable := ctx.Request().HeaderValues(); writable {
 		newRequest.Header = header.Clone()
 	} else {
 		newRequest.Header = header
 	}
+	if "" != contentType {
+		newRequest.Header.Set("Content-Type", contentType)
+	}
 	for k, v := range ctx.Attributes() {
 		newRequest.Header.Set(k, cast.ToString(v))
 	}
-	resp, err := b.httpClient.Do(newRequest)
+	// RemoteHost指向上游集群时，按集群负载均衡策略选择地址
+	upstream, isCluster := b.upstreams.Select(newRequest.URL.Host, ctx)
+	if isCluster {
+		if nil == upstream {
+			return nil, &flux.ServeError{
+				StatusCode: flux.StatusServiceUnavailable,
+				ErrorCode:  flux.ErrorCodeGatewayBackend,
+				Message:    flux.ErrorMessageHttpNoUpstreamHost,
+				Internal:   fmt.Errorf("no available host, cluster: %s", newRequest.URL.Host),
+			}
+		}
+		newRequest.URL.Host = upstream.Address
+		newRequest.Host = upstream.Address
+		upstream.acquire()
+	}
+	release := func() {
+		if nil != upstream {
+			upstream.release()
+		}
+	}
+	// rpc-timeout从请求开始计时；流式响应在收到响应Header后停止计时，读取Body不受rpc-timeout限制
+	timeout := b.timeoutOf(service)
+	toctx, cancel := context.WithCancel(newRequest.Context())
+	timer := time.AfterFunc(timeout, cancel)
+	start := time.Now()
+	resp, err := b.httpClient.Do(newRequest.WithContext(toctx))
+	// 记录上游地址的请求结果；请求端取消的请求不计入
+	if nil != upstream && nil == ctx.Context().Err() {
+		b.upstreams.Report(upstream, nil == err && resp.StatusCode < http.StatusInternalServerError, time.Since(start))
+	}
 	if nil != err {
+		if !timer.Stop() {
+			err = fmt.Errorf("rpc timeout: %s, err: %w", timeout, err)
+		}
+		cancel()
+		release()
 		msg := flux.ErrorMessageHttpInvokeFailed
 		if uErr, ok := err.(*url.Error); ok {
 			msg = fmt.Sprintf("HTTPEX:REMOTE_ERROR:%s", uErr.Error())
@@ -139,5 +312,47 @@ func (b *BackendTransportService) ExecuteRequest(newRequest *http.Request, _ flu
 			Internal:   err,
 		}
 	}
+	if IsResponseStreaming(ctx, resp) {
+		timer.Stop()
+	}
+	// 响应Body读取完成后，才释放超时Context及上游地址的请求计数
+	resp.Body = &releaseReadCloser{ReadCloser: resp.Body, release: func() {
+		timer.Stop()
+		cancel()
+		release()
+	}}
 	return resp, nil
 }
+
+// IsResponseStreaming 返回后端响应是否为流式响应：Endpoint声明为流式响应，或者按网关流式响应的判定规则
+// （response-streaming-content-types/response-streaming-content-length）判定为流式数据
+func IsResponseStreaming(ctx flux.Context, resp *http.Response) bool {
+	if ctx.Endpoint().ExtBool(flux.EndpointExtKeyResponseStreaming) {
+		return true
+	}
+	return ext.IsStreamingContent(resp.Header)
+}
+
+func (b *BackendTransportService) timeoutOf(service flux.BackendService) time.Duration {
+	to := service.AttrRpcTimeout()
+	if to == "" {
+		return b.timeout
+	}
+	timeout, err := time.ParseDuration(to)
+	if err != nil || timeout <= 0 {
+		logger.Warnw("Illegal endpoint rpc-timeout", "timeout", to)
+		timeout = b.timeout
+	}
+	return timeout
+}
+
+type releaseReadCloser struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (c *releaseReadCloser) Close() error {
+	defer c.once.Do(c.release)
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type testEndpointContext struct {
+	*support.ValuesContext
+	endpoint flux.Endpoint
+}
+
+func (c *testEndpointContext) Endpoint() flux.Endpoint {
+	return c.endpoint
+}
+
+func TestBackendTransportService_ExecuteRequestStreaming(t *testing.T) {
+	// 分多次输出Body，总时长超过rpc-timeout
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		if length := r.URL.Query().Get("length"); "" != length {
+			w.Header().Set("Content-Length", length)
+		}
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 4; i++ {
+			_, _ = fmt.Fprintf(w, "data: %d\n\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	// 按Content-Length判定时，超过阈值的响应为流式响应
+	defer ext.StoreStreamingContentLength(ext.LoadStreamingContentLength())
+	ext.StoreStreamingContentLength(16)
+	transport := NewBackendTransportService()
+	service := flux.BackendService{}
+	service.Attributes = []flux.Attribute{{Tag: flux.ServiceAttrTagRpcTimeout, Name: "RpcTimeout", Value: "100ms"}}
+	streaming := flux.Endpoint{}
+	streaming.Extensions = map[string]interface{}{flux.EndpointExtKeyResponseStreaming: true}
+	cases := []struct {
+		ctype    string
+		length   string
+		endpoint flux.Endpoint
+		timeout  bool
+	}{
+		{ctype: "text/event-stream; charset=utf-8"},
+		{ctype: "application/octet-stream"},
+		{ctype: "video/mp4"},
+		{ctype: "application/json", endpoint: streaming},
+		{ctype: "application/json", length: "36"},
+		{ctype: "application/json", timeout: true},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		ctx := &testEndpointContext{
+			ValuesContext: support.NewValuesContext(map[string]interface{}{}).(*support.ValuesContext),
+			endpoint:      tcase.endpoint,
+		}
+		req, err := http.NewRequest(http.MethodGet, server.URL+"?type="+url.QueryEscape(tcase.ctype)+"&length="+tcase.length, nil)
+		assert.Nil(err)
+		raw, serr := transport.ExecuteRequest(req, service, ctx)
+		assert.Nil(serr, tcase.ctype)
+		resp := raw.(*http.Response)
+		data, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if tcase.timeout {
+			assert.NotNil(err, tcase.ctype)
+			continue
+		}
+		assert.Nil(err, tcase.ctype)
+		assert.Equal("data: 0\n\ndata: 1\n\ndata: 2\n\ndata: 3\n\n", string(data), tcase.ctype)
+	}
+}
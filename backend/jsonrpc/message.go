@@ -0,0 +1,122 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bytepowered/flux"
+	jsoniter "github.com/json-iterator/go"
+	"net/http"
+)
+
+const (
+	Version = "2.0"
+)
+
+// JSON-RPC 2.0 预定义错误码
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// 数值以json.Number解析，避免大整数丢失精度
+var _json = jsoniter.Config{
+	EscapeHTML:             true,
+	SortMapKeys:            true,
+	ValidateJsonRawMessage: true,
+	UseNumber:              true,
+}.Froze()
+
+// Request JSON-RPC请求；Id为空时为通知请求，后端不返回响应
+type Request struct {
+	Version string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	Id      interface{} `json:"id,omitempty"`
+}
+
+// Response JSON-RPC响应
+type Response struct {
+	Version string              `json:"jsonrpc"`
+	Result  jsoniter.RawMessage `json:"result,omitempty"`
+	Error   *Error              `json:"error,omitempty"`
+	Id      jsoniter.RawMessage `json:"id"`
+}
+
+// Error JSON-RPC错误对象
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error, code: %d, message: %s", e.Code, e.Message)
+}
+
+// StatusCodeOf 返回JSON-RPC错误码对应的Http状态码
+func StatusCodeOf(code int) int {
+	switch code {
+	case CodeInvalidParams:
+		return flux.StatusBadRequest
+	case CodeMethodNotFound:
+		return flux.StatusNotFound
+	case CodeParseError, CodeInvalidRequest:
+		return flux.StatusBadGateway
+	default:
+		return flux.StatusServerError
+	}
+}
+
+// NewServeError 将JSON-RPC错误对象转换为ServeError；RPC错误码作为ErrorCode，错误消息作为Message
+func NewServeError(method string, err *Error) *flux.ServeError {
+	serr := &flux.ServeError{
+		StatusCode: StatusCodeOf(err.Code),
+		ErrorCode:  err.Code,
+		Message:    err.Message,
+		Internal:   fmt.Errorf("jsonrpc method: %s, err: %w", method, err),
+	}
+	if nil != err.Data {
+		serr.PutExtraTrace("jsonrpc-data", err.Data)
+	}
+	return serr
+}
+
+// DecodeResult 解析响应的result数据；未返回result时为nil
+func DecodeResult(resp Response) (interface{}, error) {
+	if len(resp.Result) == 0 {
+		return nil, nil
+	}
+	var out interface{}
+	if err := _json.Unmarshal(resp.Result, &out); nil != err {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DecodeResponses 解析单个或批量响应
+func DecodeResponses(data []byte) ([]Response, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && '[' == trimmed[0] {
+		out := make([]Response, 0)
+		return out, _json.Unmarshal(trimmed, &out)
+	}
+	one := Response{}
+	if err := _json.Unmarshal(data, &one); nil != err {
+		return nil, err
+	}
+	return []Response{one}, nil
+}
+
+func newInvokeError(status int, err error) *flux.ServeError {
+	if status < http.StatusBadRequest {
+		status = flux.StatusBadGateway
+	}
+	return &flux.ServeError{
+		StatusCode: status,
+		ErrorCode:  flux.ErrorCodeGatewayBackend,
+		Message:    flux.ErrorMessageJsonRpcInvokeFailed,
+		Internal:   err,
+	}
+}
@@ -0,0 +1,391 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	fluxhttp "github.com/bytepowered/flux/backend/http"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/spf13/cast"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// ExtKeyParams BackendService扩展属性：参数传递方式，named（默认）或 positional
+	ExtKeyParams = "jsonrpc-params"
+	// ExtKeyNotification BackendService扩展属性：以通知方式调用，不等待后端返回结果
+	ExtKeyNotification = "jsonrpc-notification"
+	// ExtKeyBatch BackendService扩展属性：批量调用定义，JSON数组或JSON文本
+	ExtKeyBatch = "jsonrpc-batch"
+)
+
+const (
+	ParamsNamed      = "named"      // 参数以 名称->值 的对象传递
+	ParamsPositional = "positional" // 参数按定义顺序以数组传递
+)
+
+func init() {
+	ext.StoreBackendTransport(flux.ProtoJsonRpc, NewBackendTransportService())
+}
+
+var _ flux.BackendTransport = new(BackendTransportService)
+
+type (
+	// Option 配置函数
+	Option func(service *BackendTransportService)
+)
+
+// BatchCall 批量调用中的单个调用定义
+type BatchCall struct {
+	Name         string   `json:"name"`         // 调用名称，作为响应Body的键；未定义时使用方法名
+	Method       string   `json:"method"`       // JSON-RPC方法名
+	Params       []string `json:"params"`       // 参数名称列表；未定义时使用BackendService的全部参数
+	Notification bool     `json:"notification"` // 通知调用，不返回结果
+}
+
+// Result 后端调用结果；通知调用时无返回值
+type Result struct {
+	Notification bool
+	Value        interface{}
+}
+
+// BackendTransportService JSON-RPC 2.0 over Http：以 BackendService.Method 作为RPC方法名，
+// 解析后的参数按名称或位置组成params，请求发送到 Scheme://RemoteHost/Interface。
+type BackendTransportService struct {
+	httpClient        *http.Client
+	responseCodecFunc flux.BackendResponseCodecFunc
+	defaults          map[string]interface{}
+	timeout           time.Duration
+	customClient      bool
+	sequence          int64
+}
+
+// WithHttpClient 用于配置HttpClient客户端；配置后，Init时不再根据配置构建HttpClient
+func WithHttpClient(client *http.Client) Option {
+	return func(service *BackendTransportService) {
+		service.httpClient = client
+		service.customClient = true
+	}
+}
+
+// WithResponseCodecFunc 用于配置响应数据解析实现函数
+func WithResponseCodecFunc(fun flux.BackendResponseCodecFunc) Option {
+	return func(service *BackendTransportService) {
+		service.responseCodecFunc = fun
+	}
+}
+
+// WithDefaults 用于配置默认配置值
+func WithDefaults(defaults map[string]interface{}) Option {
+	return func(service *BackendTransportService) {
+		service.defaults = defaults
+	}
+}
+
+func NewBackendTransportService() *BackendTransportService {
+	return NewBackendTransportServiceWith()
+}
+
+func NewBackendTransportServiceWith(opts ...Option) *BackendTransportService {
+	bts := &BackendTransportService{
+		httpClient:        &http.Client{},
+		responseCodecFunc: NewBackendResponseCodecFunc(),
+		timeout:           time.Second * 10,
+		defaults: map[string]interface{}{
+			fluxhttp.ConfigKeyTimeout:             "10s",
+			fluxhttp.ConfigKeyConnectTimeout:      "5s",
+			fluxhttp.ConfigKeyIdleConnTimeout:     "90s",
+			fluxhttp.ConfigKeyTLSHandshakeTimeout: "10s",
+			fluxhttp.ConfigKeyMaxIdleConns:        100,
+			fluxhttp.ConfigKeyMaxIdleConnsPerHost: 10,
+			fluxhttp.ConfigKeyKeepAliveEnable:     true,
+			fluxhttp.ConfigKeyKeepAlive:           "30s",
+		},
+	}
+	for _, opt := range opts {
+		opt(bts)
+	}
+	return bts
+}
+
+// Init 根据配置构建HttpClient；配置项与HTTP协议的BackendTransport相同
+func (b *BackendTransportService) Init(config *flux.Configuration) error {
+	logger.Info("JsonRpc backend transport initializing")
+	config.SetDefaults(b.defaults)
+	if t := config.GetDuration(fluxhttp.ConfigKeyTimeout); t > 0 {
+		b.timeout = t
+	}
+	if !b.customClient {
+		client, err := fluxhttp.NewHttpClientOf(config)
+		if nil != err {
+			return err
+		}
+		b.httpClient = client
+	}
+	return nil
+}
+
+func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return b.responseCodecFunc
+}
+
+func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
+	return backend.DoExchangeTransport(ctx, b)
+}
+
+func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	raw, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	result, err := b.GetResponseCodecFunc()(ctx, raw)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   fmt.Errorf("decode jsonrpc response, err: %w", err),
+		}
+	}
+	return result, nil
+}
+
+// Invoke 执行JSON-RPC调用：BackendService定义了批量调用时，以批量请求发送，结果为 调用名称->结果 的Map；
+// RPC返回error对象时，转换为ServeError
+func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	calls, err := CallsOf(service)
+	if nil != err {
+		return nil, newAssembleError(err)
+	}
+	requests := make([]Request, len(calls))
+	for i, call := range calls {
+		params, err := ParamsOf(service, call.Params, ctx)
+		if nil != err {
+			return nil, newAssembleError(err)
+		}
+		requests[i] = Request{Version: Version, Method: call.Method, Params: params}
+		if !call.Notification {
+			requests[i].Id = atomic.AddInt64(&b.sequence, 1)
+		}
+	}
+	_, batch := service.Ext(ExtKeyBatch)
+	var payload interface{} = requests
+	if !batch {
+		payload = requests[0]
+	}
+	responses, serr := b.Call(ctx, service, payload)
+	if nil != serr {
+		return nil, serr
+	}
+	indexed := make(map[string]Response, len(responses))
+	for _, resp := range responses {
+		id := string(bytes.TrimSpace(resp.Id))
+		// 后端无法解析请求时，返回id为null的错误响应
+		if nil != resp.Error && ("" == id || "null" == id) {
+			return nil, NewServeError(calls[0].Method, resp.Error)
+		}
+		indexed[id] = resp
+	}
+	results := make(map[string]interface{}, len(calls))
+	notification := true
+	for i, call := range calls {
+		if call.Notification {
+			continue
+		}
+		notification = false
+		resp, ok := indexed[strconv.FormatInt(requests[i].Id.(int64), 10)]
+		if !ok {
+			return nil, newInvokeError(flux.StatusBadGateway, fmt.Errorf("jsonrpc response not found, method: %s, id: %d", call.Method, requests[i].Id))
+		}
+		if nil != resp.Error {
+			serr := NewServeError(call.Method, resp.Error)
+			serr.PutExtraTrace("jsonrpc-call", call.Name)
+			return nil, serr
+		}
+		value, err := DecodeResult(resp)
+		if nil != err {
+			return nil, &flux.ServeError{
+				StatusCode: flux.StatusBadGateway,
+				ErrorCode:  flux.ErrorCodeGatewayBackend,
+				Message:    flux.ErrorMessageJsonRpcDecodeFailed,
+				Internal:   fmt.Errorf("decode jsonrpc result, method: %s, err: %w", call.Method, err),
+			}
+		}
+		results[call.Name] = value
+	}
+	if notification {
+		return &Result{Notification: true}, nil
+	}
+	if batch {
+		return &Result{Value: results}, nil
+	}
+	return &Result{Value: results[calls[0].Name]}, nil
+}
+
+// Call 发送JSON-RPC请求，返回解析后的响应列表；全部为通知请求时，不解析响应
+func (b *BackendTransportService) Call(ctx flux.Context, service flux.BackendService, payload interface{}) ([]Response, *flux.ServeError) {
+	data, err := _json.Marshal(payload)
+	if nil != err {
+		return nil, newAssembleError(fmt.Errorf("marshal jsonrpc request, err: %w", err))
+	}
+	endpoint := &url.URL{Scheme: service.Scheme, Host: service.RemoteHost, Path: service.Interface}
+	if "" == endpoint.Scheme {
+		endpoint.Scheme = "http"
+	}
+	toctx, cancel := context.WithTimeout(ctx.Context(), b.timeoutOf(service))
+	defer cancel()
+	request, err := http.NewRequestWithContext(toctx, http.MethodPost, endpoint.String(), bytes.NewReader(data))
+	if nil != err {
+		return nil, newAssembleError(fmt.Errorf("new jsonrpc request, url: %s, err: %w", endpoint, err))
+	}
+	request.Header.Set(flux.HeaderContentType, flux.MIMEApplicationJSON)
+	request.Header.Set(flux.HeaderAccept, flux.MIMEApplicationJSON)
+	request.Header.Set("User-Agent", "FluxGo/Backend/v1")
+	for k, v := range ctx.Attributes() {
+		request.Header.Set(k, cast.ToString(v))
+	}
+	resp, err := b.httpClient.Do(request)
+	if nil != err {
+		return nil, newInvokeError(flux.StatusBadGateway, fmt.Errorf("jsonrpc call, url: %s, err: %w", endpoint, err))
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if nil != err {
+		return nil, newInvokeError(flux.StatusBadGateway, fmt.Errorf("read jsonrpc response, url: %s, err: %w", endpoint, err))
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if resp.StatusCode >= http.StatusBadRequest {
+			return nil, newInvokeError(resp.StatusCode, fmt.Errorf("jsonrpc call, url: %s, status: %d", endpoint, resp.StatusCode))
+		}
+		return []Response{}, nil
+	}
+	responses, err := DecodeResponses(body)
+	if nil != err {
+		// 非JSON-RPC响应，按Http状态码返回错误
+		return nil, newInvokeError(resp.StatusCode, fmt.Errorf("decode jsonrpc response, url: %s, status: %d, err: %w", endpoint, resp.StatusCode, err))
+	}
+	return responses, nil
+}
+
+func (b *BackendTransportService) timeoutOf(service flux.BackendService) time.Duration {
+	to := service.AttrRpcTimeout()
+	if to == "" {
+		return b.timeout
+	}
+	timeout, err := time.ParseDuration(to)
+	if err != nil || timeout <= 0 {
+		logger.Warnw("Illegal jsonrpc rpc-timeout", "timeout", to)
+		timeout = b.timeout
+	}
+	return timeout
+}
+
+// CallsOf 返回BackendService定义的调用列表：定义了批量调用时返回批量调用定义；否则返回以 Method 为方法名的单个调用
+func CallsOf(service flux.BackendService) ([]BatchCall, error) {
+	v, ok := service.Ext(ExtKeyBatch)
+	if !ok {
+		return []BatchCall{{
+			Name:         service.Method,
+			Method:       service.Method,
+			Notification: service.ExtBool(ExtKeyNotification),
+		}}, nil
+	}
+	calls := make([]BatchCall, 0)
+	var err error
+	if text, ok := v.(string); ok {
+		err = _json.UnmarshalFromString(text, &calls)
+	} else if data, merr := _json.Marshal(v); nil == merr {
+		err = _json.Unmarshal(data, &calls)
+	} else {
+		err = merr
+	}
+	if nil != err {
+		return nil, fmt.Errorf("decode jsonrpc batch, service: %s, err: %w", service.ServiceID(), err)
+	}
+	if len(calls) == 0 {
+		return nil, fmt.Errorf("jsonrpc batch is empty, service: %s", service.ServiceID())
+	}
+	names := make(map[string]bool, len(calls))
+	for i := range calls {
+		if "" == calls[i].Method {
+			return nil, fmt.Errorf("jsonrpc batch call requires method, service: %s", service.ServiceID())
+		}
+		if "" == calls[i].Name {
+			calls[i].Name = calls[i].Method
+		}
+		if names[calls[i].Name] {
+			return nil, fmt.Errorf("duplicated jsonrpc batch call, name: %s", calls[i].Name)
+		}
+		names[calls[i].Name] = true
+	}
+	return calls, nil
+}
+
+// ParamsOf 解析参数值，按BackendService定义的参数传递方式，返回params对象或数组；未定义参数时返回nil
+func ParamsOf(service flux.BackendService, names []string, ctx flux.Context) (interface{}, error) {
+	arguments := service.Arguments
+	if len(names) > 0 {
+		arguments = make([]flux.Argument, 0, len(names))
+		for _, name := range names {
+			found := false
+			for _, arg := range service.Arguments {
+				if name == arg.Name {
+					arguments, found = append(arguments, arg), true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("jsonrpc argument not found, name: %s", name)
+			}
+		}
+	}
+	if len(arguments) == 0 {
+		return nil, nil
+	}
+	values, err := fluxhttp.AssembleJsonValues(arguments, ctx)
+	if nil != err {
+		return nil, err
+	}
+	if ParamsPositional == service.ExtString(ExtKeyParams) {
+		positional := make([]interface{}, len(arguments))
+		for i, arg := range arguments {
+			positional[i] = values[arg.Name]
+		}
+		return positional, nil
+	}
+	return values, nil
+}
+
+func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return func(ctx flux.Context, value interface{}) (*flux.BackendResponse, error) {
+		result, ok := value.(*Result)
+		if !ok {
+			return nil, fmt.Errorf("unknown jsonrpc result: %T", value)
+		}
+		if result.Notification {
+			return &flux.BackendResponse{StatusCode: http.StatusNoContent, Headers: http.Header{}}, nil
+		}
+		return &flux.BackendResponse{
+			StatusCode: flux.StatusOK,
+			Headers:    http.Header{},
+			Body:       result.Value,
+		}, nil
+	}
+}
+
+func newAssembleError(err error) *flux.ServeError {
+	return &flux.ServeError{
+		StatusCode: flux.StatusServerError,
+		ErrorCode:  flux.ErrorCodeGatewayInternal,
+		Message:    flux.ErrorMessageJsonRpcAssembleFailed,
+		Internal:   err,
+	}
+}
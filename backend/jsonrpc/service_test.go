@@ -0,0 +1,155 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func init() {
+	ext.StoreArgumentLookupFunc(support.DefaultArgumentValueLookupFunc)
+	serializer := flux.NewJsonSerializer()
+	ext.StoreSerializer(ext.TypeNameSerializerDefault, serializer)
+	ext.StoreSerializer(ext.TypeNameSerializerJson, serializer)
+}
+
+// newTestRpcServer 模拟JSON-RPC服务：add 返回参数之和，fail 返回错误；通知请求不返回响应
+func newTestRpcServer(notified chan<- string) *httptest.Server {
+	handle := func(req map[string]interface{}) map[string]interface{} {
+		id, ok := req["id"]
+		if !ok {
+			notified <- req["method"].(string)
+			return nil
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+		switch req["method"] {
+		case "add":
+			sum := 0.0
+			switch params := req["params"].(type) {
+			case []interface{}:
+				for _, p := range params {
+					sum += p.(float64)
+				}
+			case map[string]interface{}:
+				sum = params["a"].(float64) + params["b"].(float64)
+			}
+			resp["result"] = sum
+		case "fail":
+			resp["error"] = map[string]interface{}{"code": CodeInvalidParams, "message": "invalid params", "data": "a"}
+		default:
+			resp["error"] = map[string]interface{}{"code": CodeMethodNotFound, "message": "method not found"}
+		}
+		return resp
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		var out interface{}
+		if strings.HasPrefix(string(data), "[") {
+			reqs := make([]map[string]interface{}, 0)
+			_ = json.Unmarshal(data, &reqs)
+			resps := make([]map[string]interface{}, 0)
+			// 批量响应顺序与请求顺序无关
+			for i := len(reqs) - 1; i >= 0; i-- {
+				if resp := handle(reqs[i]); nil != resp {
+					resps = append(resps, resp)
+				}
+			}
+			if len(resps) > 0 {
+				out = resps
+			}
+		} else {
+			req := make(map[string]interface{})
+			_ = json.Unmarshal(data, &req)
+			if resp := handle(req); nil != resp {
+				out = resp
+			}
+		}
+		if nil == out {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+}
+
+func newTestRpcService(host, method string, extensions map[string]interface{}) flux.BackendService {
+	service := flux.BackendService{
+		Scheme:     "http",
+		RemoteHost: host,
+		Interface:  "/rpc",
+		Method:     method,
+		Arguments:  []flux.Argument{ext.NewIntegerArgument("a"), ext.NewIntegerArgument("b")},
+	}
+	service.Extensions = extensions
+	return service
+}
+
+func TestBackendTransportService_InvokeCodec(t *testing.T) {
+	notified := make(chan string, 4)
+	server := newTestRpcServer(notified)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	transport := NewBackendTransportServiceWith(WithHttpClient(server.Client()))
+	ctx := support.NewValuesContext(map[string]interface{}{"a": 1, "b": 2})
+	cases := []struct {
+		name       string
+		method     string
+		extensions map[string]interface{}
+		status     int
+		body       interface{}
+		errorCode  interface{}
+	}{
+		{name: "named", method: "add", status: http.StatusOK, body: json.Number("3")},
+		{name: "positional", method: "add", extensions: map[string]interface{}{ExtKeyParams: ParamsPositional},
+			status: http.StatusOK, body: json.Number("3")},
+		{name: "error", method: "fail", status: http.StatusBadRequest, errorCode: CodeInvalidParams},
+		{name: "not-found", method: "unknown", status: http.StatusNotFound, errorCode: CodeMethodNotFound},
+		{name: "notification", method: "add", extensions: map[string]interface{}{ExtKeyNotification: true},
+			status: http.StatusNoContent},
+		{name: "batch", method: "batch", extensions: map[string]interface{}{
+			ExtKeyBatch:  `[{"name":"sum","method":"add"},{"name":"one","method":"add","params":["a","a"]},{"method":"log","notification":true}]`,
+			ExtKeyParams: ParamsPositional},
+			status: http.StatusOK, body: map[string]interface{}{"sum": json.Number("3"), "one": json.Number("2")}},
+		{name: "batch-error", method: "batch", extensions: map[string]interface{}{
+			ExtKeyBatch: []interface{}{map[string]interface{}{"method": "add"}, map[string]interface{}{"method": "fail"}}},
+			status: http.StatusBadRequest, errorCode: CodeInvalidParams},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		resp, serr := transport.InvokeCodec(ctx, newTestRpcService(host, tcase.method, tcase.extensions))
+		if nil != tcase.errorCode {
+			assert.NotNil(serr, tcase.name)
+			assert.Equal(tcase.status, serr.StatusCode, tcase.name)
+			assert.Equal(tcase.errorCode, serr.ErrorCode, tcase.name)
+			continue
+		}
+		assert.Nil(serr, tcase.name)
+		assert.Equal(tcase.status, resp.StatusCode, tcase.name)
+		assert.Equal(tcase.body, resp.Body, tcase.name)
+	}
+	assert.Equal("add", <-notified)
+	assert.Equal("log", <-notified)
+}
+
+func TestParamsOf(t *testing.T) {
+	ctx := support.NewValuesContext(map[string]interface{}{"a": 1, "b": 2})
+	service := newTestRpcService("localhost", "add", nil)
+	assert := assert2.New(t)
+	params, err := ParamsOf(service, nil, ctx)
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{"a": 1, "b": 2}, params)
+	service.Extensions = map[string]interface{}{ExtKeyParams: ParamsPositional}
+	params, err = ParamsOf(service, []string{"b"}, ctx)
+	assert.Nil(err)
+	assert.Equal([]interface{}{2}, params)
+	_, err = ParamsOf(service, []string{"c"}, ctx)
+	assert.NotNil(err)
+}
@@ -0,0 +1,161 @@
+package local
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"net/http"
+	"runtime/debug"
+)
+
+func init() {
+	ext.StoreBackendTransport(flux.ProtoLocal, NewBackendTransportService())
+}
+
+var (
+	_ flux.BackendTransport = new(BackendTransportService)
+)
+
+type (
+	// Option 配置函数
+	Option func(service *BackendTransportService)
+	// ArgumentsAssembleFunc 解析参数值，作为处理函数的args参数
+	ArgumentsAssembleFunc func(arguments []flux.Argument, ctx flux.Context) (map[string]interface{}, error)
+)
+
+// BackendTransportService 在网关进程内执行后端服务的BackendTransport：
+// 处理函数通过 ext.StoreLocalHandler 注册，BackendService以Interface和Method绑定处理函数；
+// 与其它协议的后端服务一样，请求经过Selector、Filter链并记录Metrics。
+type BackendTransportService struct {
+	responseCodecFunc flux.BackendResponseCodecFunc
+	argAssembleFunc   ArgumentsAssembleFunc
+}
+
+// WithResponseCodecFunc 用于配置响应数据解析实现函数
+func WithResponseCodecFunc(fun flux.BackendResponseCodecFunc) Option {
+	return func(service *BackendTransportService) {
+		service.responseCodecFunc = fun
+	}
+}
+
+// WithArgumentsAssembleFunc 用于配置参数解析函数
+func WithArgumentsAssembleFunc(fun ArgumentsAssembleFunc) Option {
+	return func(service *BackendTransportService) {
+		service.argAssembleFunc = fun
+	}
+}
+
+func NewBackendTransportService() *BackendTransportService {
+	return NewBackendTransportServiceWith()
+}
+
+func NewBackendTransportServiceWith(opts ...Option) *BackendTransportService {
+	bts := &BackendTransportService{
+		responseCodecFunc: NewBackendResponseCodecFunc(),
+		argAssembleFunc:   DefaultArgAssembleFunc,
+	}
+	for _, opt := range opts {
+		opt(bts)
+	}
+	return bts
+}
+
+func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return b.responseCodecFunc
+}
+
+func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
+	return backend.DoExchangeTransport(ctx, b)
+}
+
+func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	raw, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	result, err := b.GetResponseCodecFunc()(ctx, raw)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   fmt.Errorf("decode local response, err: %w", err),
+		}
+	}
+	return result, nil
+}
+
+func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	handler, ok := ext.LoadLocalHandler(service.Interface, service.Method)
+	if !ok {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayEndpoint,
+			Message:    flux.ErrorMessageLocalHandlerNotFound,
+			Internal:   fmt.Errorf("local handler not found, service: %s", service.ServiceID()),
+		}
+	}
+	args, err := b.argAssembleFunc(service.Arguments, ctx)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadRequest,
+			ErrorCode:  flux.ErrorCodeRequestInvalid,
+			Message:    flux.ErrorMessageLocalAssembleFailed,
+			Internal:   err,
+		}
+	}
+	return b.DoInvoke(ctx, service, handler, args)
+}
+
+// DoInvoke 执行处理函数；处理函数Panic时返回错误，不影响网关进程
+func (b *BackendTransportService) DoInvoke(ctx flux.Context, service flux.BackendService,
+	handler flux.LocalHandlerFunc, args map[string]interface{}) (resp *flux.BackendResponse, serr *flux.ServeError) {
+	defer func() {
+		if r := recover(); nil != r {
+			logger.TraceContext(ctx).Errorw("BACKEND:LOCAL:PANIC",
+				"service-id", service.ServiceID(), "error", r, "stack", string(debug.Stack()))
+			resp, serr = nil, &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  flux.ErrorCodeGatewayBackend,
+				Message:    flux.ErrorMessageLocalHandlerPanic,
+				Internal:   fmt.Errorf("local handler panic, service: %s, err: %v", service.ServiceID(), r),
+			}
+		}
+	}()
+	return handler(ctx, args)
+}
+
+// DefaultArgAssembleFunc 默认参数解析：以参数名作为Key，解析后的参数值作为Value
+func DefaultArgAssembleFunc(arguments []flux.Argument, ctx flux.Context) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(arguments))
+	for _, arg := range arguments {
+		val, err := arg.Resolve(ctx)
+		if nil != err {
+			return nil, fmt.Errorf("resolve argument: %s, err: %w", arg.Name, err)
+		}
+		values[arg.Name] = val
+	}
+	return values, nil
+}
+
+// NewBackendResponseCodecFunc 处理函数已返回BackendResponse，仅补全空值；未返回响应时为 204 No Content
+func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return func(ctx flux.Context, value interface{}) (*flux.BackendResponse, error) {
+		resp, ok := value.(*flux.BackendResponse)
+		if !ok {
+			return nil, fmt.Errorf("unknown local response: %T", value)
+		}
+		if nil == resp {
+			return &flux.BackendResponse{StatusCode: http.StatusNoContent, Headers: make(http.Header, 0)}, nil
+		}
+		if resp.StatusCode <= 0 {
+			resp.StatusCode = flux.StatusOK
+		}
+		if nil == resp.Headers {
+			resp.Headers = make(http.Header, 0)
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,72 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func init() {
+	ext.StoreLoggerFactory(func(context.Context) flux.Logger {
+		return logger.SimpleLogger()
+	})
+	ext.StoreArgumentLookupFunc(support.DefaultArgumentValueLookupFunc)
+	ext.StoreLocalHandler("local.ConfigService", "get", func(ctx flux.Context, args map[string]interface{}) (*flux.BackendResponse, *flux.ServeError) {
+		if "" == args["key"] {
+			return nil, &flux.ServeError{StatusCode: flux.StatusBadRequest, ErrorCode: flux.ErrorCodeRequestInvalid, Message: "KEY_REQUIRED"}
+		}
+		return &flux.BackendResponse{Body: map[string]interface{}{"key": args["key"], "size": args["size"]}}, nil
+	})
+	ext.StoreLocalHandler("local.ConfigService", "touch", func(ctx flux.Context, args map[string]interface{}) (*flux.BackendResponse, *flux.ServeError) {
+		return nil, nil
+	})
+	ext.StoreLocalHandler("local.ConfigService", "panic", func(ctx flux.Context, args map[string]interface{}) (*flux.BackendResponse, *flux.ServeError) {
+		panic(fmt.Errorf("handler panic"))
+	})
+}
+
+func TestBackendTransportService_InvokeCodec(t *testing.T) {
+	transport := NewBackendTransportService()
+	cases := []struct {
+		name    string
+		method  string
+		values  map[string]interface{}
+		status  int
+		body    interface{}
+		message string
+	}{
+		{name: "ok", method: "get", values: map[string]interface{}{"key": "feature.x", "size": 10},
+			status: http.StatusOK, body: map[string]interface{}{"key": "feature.x", "size": 10}},
+		{name: "handler-error", method: "get", values: map[string]interface{}{"key": ""},
+			status: http.StatusBadRequest, message: "KEY_REQUIRED"},
+		{name: "no-content", method: "touch", status: http.StatusNoContent},
+		{name: "panic", method: "panic", status: http.StatusInternalServerError, message: flux.ErrorMessageLocalHandlerPanic},
+		{name: "not-found", method: "unknown", status: http.StatusInternalServerError, message: flux.ErrorMessageLocalHandlerNotFound},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		service := flux.BackendService{
+			Interface: "local.ConfigService",
+			Method:    tcase.method,
+			Arguments: []flux.Argument{ext.NewStringArgument("key"), ext.NewIntegerArgument("size")},
+		}
+		ctx := support.NewValuesContext(tcase.values)
+		resp, serr := transport.InvokeCodec(ctx, service)
+		if "" != tcase.message {
+			assert.NotNil(serr, tcase.name)
+			assert.Equal(tcase.status, serr.StatusCode, tcase.name)
+			assert.Equal(tcase.message, serr.Message, tcase.name)
+			continue
+		}
+		assert.Nil(serr, tcase.name)
+		assert.Equal(tcase.status, resp.StatusCode, tcase.name)
+		assert.Equal(tcase.body, resp.Body, tcase.name)
+		assert.NotNil(resp.Headers, tcase.name)
+	}
+}
@@ -0,0 +1,161 @@
+package mock
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/logger"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockDefinition 模拟响应定义
+type MockDefinition struct {
+	StatusCode   int               `json:"statusCode"`   // 响应状态码；默认200
+	Headers      map[string]string `json:"headers"`      // 响应Header
+	Body         interface{}       `json:"body"`         // 响应Body模板；字符串或JSON对象，可通过 {{json .Args.name}} 引用已解析的参数值
+	Delay        string            `json:"delay"`        // 响应延迟：固定延迟 100ms，或随机范围 100ms~300ms
+	ErrorRate    float64           `json:"errorRate"`    // 错误注入比例，取值[0, 1]
+	ErrorStatus  int               `json:"errorStatus"`  // 注入错误的响应状态码；默认500
+	ErrorMessage string            `json:"errorMessage"` // 注入错误的错误消息
+}
+
+// DelayOf 返回本次响应的延迟时长
+func (d MockDefinition) DelayOf() (time.Duration, error) {
+	if "" == d.Delay {
+		return 0, nil
+	}
+	parts := strings.SplitN(d.Delay, "~", 2)
+	min, err := time.ParseDuration(strings.TrimSpace(parts[0]))
+	if nil != err {
+		return 0, fmt.Errorf("illegal mock delay: %s, err: %w", d.Delay, err)
+	}
+	if len(parts) == 1 {
+		return min, nil
+	}
+	max, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if nil != err || max < min {
+		return 0, fmt.Errorf("illegal mock delay: %s", d.Delay)
+	}
+	if max == min {
+		return min, nil
+	}
+	return min + time.Duration(rand.Int63n(int64(max-min))), nil
+}
+
+// Failure 按错误注入比例，判断本次响应是否注入错误
+func (d MockDefinition) Failure() bool {
+	return d.ErrorRate > 0 && rand.Float64() < d.ErrorRate
+}
+
+// DefinitionDir 从目录加载模拟响应定义：目录下每个 *.json 文件为 ServiceId -> MockDefinition 的JSON对象；
+// 文件修改后，在下次查询时重新加载。
+type DefinitionDir struct {
+	dir         string
+	interval    time.Duration
+	definitions map[string]MockDefinition
+	modTimes    map[string]time.Time
+	checkedAt   time.Time
+	mu          sync.RWMutex
+}
+
+func NewDefinitionDir(dir string, interval time.Duration) *DefinitionDir {
+	return &DefinitionDir{
+		dir:         dir,
+		interval:    interval,
+		definitions: make(map[string]MockDefinition),
+		modTimes:    make(map[string]time.Time),
+	}
+}
+
+// Lookup 查询服务的模拟响应定义
+func (d *DefinitionDir) Lookup(serviceIds ...string) (MockDefinition, bool) {
+	if "" == d.dir {
+		return MockDefinition{}, false
+	}
+	d.reloadIfModified()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, id := range serviceIds {
+		if def, ok := d.definitions[id]; ok && "" != id {
+			return def, true
+		}
+	}
+	return MockDefinition{}, false
+}
+
+// Load 加载目录下全部定义文件
+func (d *DefinitionDir) Load() error {
+	files, err := filepath.Glob(filepath.Join(d.dir, "*.json"))
+	if nil != err {
+		return err
+	}
+	definitions := make(map[string]MockDefinition)
+	modTimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if nil != err {
+			return err
+		}
+		data, err := ioutil.ReadFile(file)
+		if nil != err {
+			return err
+		}
+		defs := make(map[string]MockDefinition)
+		if err := _json.Unmarshal(data, &defs); nil != err {
+			return fmt.Errorf("decode mock definitions, file: %s, err: %w", file, err)
+		}
+		for id, def := range defs {
+			definitions[id] = def
+		}
+		modTimes[file] = info.ModTime()
+	}
+	d.mu.Lock()
+	d.definitions, d.modTimes, d.checkedAt = definitions, modTimes, time.Now()
+	d.mu.Unlock()
+	logger.Infow("Mock backend transport load definitions", "dir", d.dir, "files", len(files), "definitions", len(definitions))
+	return nil
+}
+
+func (d *DefinitionDir) reloadIfModified() {
+	if d.interval <= 0 {
+		return
+	}
+	d.mu.RLock()
+	expired := time.Since(d.checkedAt) >= d.interval
+	d.mu.RUnlock()
+	if !expired {
+		return
+	}
+	d.mu.Lock()
+	d.checkedAt = time.Now()
+	d.mu.Unlock()
+	if !d.modified() {
+		return
+	}
+	if err := d.Load(); nil != err {
+		logger.Warnw("Mock backend transport reload definitions", "dir", d.dir, "error", err)
+	}
+}
+
+func (d *DefinitionDir) modified() bool {
+	files, _ := filepath.Glob(filepath.Join(d.dir, "*.json"))
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if len(files) != len(d.modTimes) {
+		return true
+	}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if nil != err {
+			return true
+		}
+		if mt, ok := d.modTimes[file]; !ok || !mt.Equal(info.ModTime()) {
+			return true
+		}
+	}
+	return false
+}
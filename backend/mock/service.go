@@ -0,0 +1,371 @@
+package mock
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	jsoniter "github.com/json-iterator/go"
+	"net/http"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	ConfigKeyMockDir        = "mock-dir"
+	ConfigKeyReloadInterval = "reload-interval"
+)
+
+const (
+	// ExtKeyMock BackendService扩展属性：模拟响应定义，JSON对象或JSON文本
+	ExtKeyMock = "mock"
+)
+
+func init() {
+	ext.StoreBackendTransport(flux.ProtoMock, NewBackendTransportService())
+}
+
+var (
+	_     flux.BackendTransport = new(BackendTransportService)
+	_json                       = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+type (
+	// Option 配置函数
+	Option func(service *BackendTransportService)
+)
+
+// MockResponse 模拟后端返回的原始响应
+type MockResponse struct {
+	StatusCode int
+	Headers    http.Header
+	Body       []byte
+}
+
+// mockTemplate 服务的Body模板编译结果
+type mockTemplate struct {
+	text string      // Body定义的文本，定义变更时重新编译
+	body interface{} // 字符串Body编译为 *template.Template；JSON对象Body的每个字符串值编译为 *template.Template
+}
+
+// BackendTransportService 返回模拟响应的BackendService：响应定义来自BackendService扩展属性或模拟定义目录，
+// 用于后端服务未就绪时的前端联调；后端服务就绪后，修改服务协议即可切换到真实后端。
+type BackendTransportService struct {
+	responseCodecFunc flux.BackendResponseCodecFunc
+	defaults          map[string]interface{}
+	definitions       *DefinitionDir
+	templateFuncs     template.FuncMap
+	templates         sync.Map // service-id -> *mockTemplate
+}
+
+// WithResponseCodecFunc 用于配置响应数据解析实现函数
+func WithResponseCodecFunc(fun flux.BackendResponseCodecFunc) Option {
+	return func(service *BackendTransportService) {
+		service.responseCodecFunc = fun
+	}
+}
+
+// WithTemplateFuncs 用于配置Body模板的自定义函数
+func WithTemplateFuncs(funcs template.FuncMap) Option {
+	return func(service *BackendTransportService) {
+		for name, f := range funcs {
+			service.templateFuncs[name] = f
+		}
+	}
+}
+
+// WithDefaults 用于配置默认配置值
+func WithDefaults(defaults map[string]interface{}) Option {
+	return func(service *BackendTransportService) {
+		service.defaults = defaults
+	}
+}
+
+func NewBackendTransportService() *BackendTransportService {
+	return NewBackendTransportServiceWith()
+}
+
+func NewBackendTransportServiceWith(opts ...Option) *BackendTransportService {
+	bts := &BackendTransportService{
+		responseCodecFunc: NewBackendResponseCodecFunc(),
+		definitions:       NewDefinitionDir("", 0),
+		defaults: map[string]interface{}{
+			ConfigKeyMockDir:        "",
+			ConfigKeyReloadInterval: "2s",
+		},
+		templateFuncs: template.FuncMap{
+			// json 输出JSON编码的值，字符串值带引号并转义
+			"json": func(v interface{}) (string, error) {
+				return _json.MarshalToString(v)
+			},
+			"now": time.Now,
+		},
+	}
+	for _, opt := range opts {
+		opt(bts)
+	}
+	return bts
+}
+
+// Init 加载模拟定义目录
+func (b *BackendTransportService) Init(config *flux.Configuration) error {
+	logger.Info("Mock backend transport initializing")
+	config.SetDefaults(b.defaults)
+	b.definitions = NewDefinitionDir(config.GetString(ConfigKeyMockDir), config.GetDuration(ConfigKeyReloadInterval))
+	if dir := config.GetString(ConfigKeyMockDir); "" != dir {
+		return b.definitions.Load()
+	}
+	return nil
+}
+
+func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return b.responseCodecFunc
+}
+
+func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
+	return backend.DoExchangeTransport(ctx, b)
+}
+
+func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	raw, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	result, err := b.GetResponseCodecFunc()(ctx, raw)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   fmt.Errorf("decode mock response, err: %w", err),
+		}
+	}
+	return result, nil
+}
+
+func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	def, err := b.LookupDefinition(service)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayEndpoint,
+			Message:    flux.ErrorMessageMockNotFound,
+			Internal:   err,
+		}
+	}
+	delay, err := def.DelayOf()
+	if nil != err {
+		logger.TraceContext(ctx).Warnw("BACKEND:MOCK:DELAY", "service-id", service.ServiceID(), "error", err)
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Context().Done():
+			timer.Stop()
+			return nil, &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  flux.ErrorCodeGatewayBackend,
+				Message:    flux.ErrorMessageMockInjectedError,
+				Internal:   ctx.Context().Err(),
+			}
+		}
+	}
+	if def.Failure() {
+		status := def.ErrorStatus
+		if status <= 0 {
+			status = flux.StatusServerError
+		}
+		msg := def.ErrorMessage
+		if "" == msg {
+			msg = flux.ErrorMessageMockInjectedError
+		}
+		return nil, &flux.ServeError{
+			StatusCode: status,
+			ErrorCode:  flux.ErrorCodeGatewayBackend,
+			Message:    msg,
+			Internal:   fmt.Errorf("mock injected error, service: %s", service.ServiceID()),
+		}
+	}
+	body, err := b.RenderBody(def, service, ctx)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageMockRenderFailed,
+			Internal:   err,
+		}
+	}
+	status := def.StatusCode
+	if status <= 0 {
+		status = flux.StatusOK
+	}
+	headers := make(http.Header, len(def.Headers))
+	for k, v := range def.Headers {
+		headers.Set(k, v)
+	}
+	return &MockResponse{StatusCode: status, Headers: headers, Body: body}, nil
+}
+
+// LookupDefinition 查找服务的模拟响应定义：优先使用BackendService扩展属性，其次为模拟定义目录
+func (b *BackendTransportService) LookupDefinition(service flux.BackendService) (MockDefinition, error) {
+	if v, ok := service.Ext(ExtKeyMock); ok && nil != v {
+		def := MockDefinition{}
+		var err error
+		if text, ok := v.(string); ok {
+			err = _json.UnmarshalFromString(text, &def)
+		} else if data, merr := _json.Marshal(v); nil == merr {
+			err = _json.Unmarshal(data, &def)
+		} else {
+			err = merr
+		}
+		if nil != err {
+			return def, fmt.Errorf("decode mock extension, service: %s, err: %w", service.ServiceID(), err)
+		}
+		return def, nil
+	}
+	if def, ok := b.definitions.Lookup(service.ServiceID(), service.AliasId); ok {
+		return def, nil
+	}
+	return MockDefinition{}, fmt.Errorf("mock definition not found, service: %s", service.ServiceID())
+}
+
+// RenderBody 渲染响应Body模板；模板数据：Args 已解析的参数值，Request 请求信息，Service 后端服务定义。
+// 字符串Body按文本输出，引用参数值时使用 {{json .Args.name}} 转义；JSON对象Body逐个渲染字符串值，输出时按JSON转义。
+func (b *BackendTransportService) RenderBody(def MockDefinition, service flux.BackendService, ctx flux.Context) ([]byte, error) {
+	if nil == def.Body {
+		return []byte{}, nil
+	}
+	compiled, err := b.templateOf(service.ServiceID(), def.Body)
+	if nil != err {
+		return nil, err
+	}
+	args := make(map[string]interface{}, len(service.Arguments))
+	for _, arg := range service.Arguments {
+		value, err := arg.Resolve(ctx)
+		if nil != err {
+			return nil, fmt.Errorf("resolve argument: %s, err: %w", arg.Name, err)
+		}
+		args[arg.Name] = value
+	}
+	data := map[string]interface{}{
+		"Args": args,
+		"Request": map[string]interface{}{
+			"Id":     ctx.RequestId(),
+			"Method": ctx.Method(),
+			"URI":    ctx.RequestURI(),
+		},
+		"Service": service,
+	}
+	if tpl, ok := compiled.(*template.Template); ok {
+		buf := new(bytes.Buffer)
+		err := tpl.Execute(buf, data)
+		return buf.Bytes(), err
+	}
+	value, err := renderValue(compiled, data)
+	if nil != err {
+		return nil, err
+	}
+	return _json.Marshal(value)
+}
+
+// templateOf 返回服务Body模板的编译结果；每个服务只编译一次，Body定义变更时重新编译
+func (b *BackendTransportService) templateOf(name string, body interface{}) (interface{}, error) {
+	text, ok := body.(string)
+	if !ok {
+		data, err := _json.Marshal(body)
+		if nil != err {
+			return nil, err
+		}
+		text = string(data)
+	}
+	if v, ok := b.templates.Load(name); ok && v.(*mockTemplate).text == text {
+		return v.(*mockTemplate).body, nil
+	}
+	compiled, err := b.compile(name, body)
+	if nil != err {
+		return nil, err
+	}
+	b.templates.Store(name, &mockTemplate{text: text, body: compiled})
+	return compiled, nil
+}
+
+func (b *BackendTransportService) compile(name string, body interface{}) (interface{}, error) {
+	switch v := body.(type) {
+	case string:
+		return template.New(name).Funcs(b.templateFuncs).Parse(v)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, iv := range v {
+			c, err := b.compile(name, iv)
+			if nil != err {
+				return nil, err
+			}
+			out[k] = c
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, iv := range v {
+			c, err := b.compile(name, iv)
+			if nil != err {
+				return nil, err
+			}
+			out[i] = c
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// renderValue 渲染JSON对象Body中的模板字符串值
+func renderValue(value interface{}, data interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case *template.Template:
+		buf := new(bytes.Buffer)
+		if err := v.Execute(buf, data); nil != err {
+			return nil, err
+		}
+		return buf.String(), nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, iv := range v {
+			r, err := renderValue(iv, data)
+			if nil != err {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, iv := range v {
+			r, err := renderValue(iv, data)
+			if nil != err {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return func(ctx flux.Context, value interface{}) (*flux.BackendResponse, error) {
+		resp, ok := value.(*MockResponse)
+		if !ok {
+			return nil, fmt.Errorf("unknown mock response: %T", value)
+		}
+		return &flux.BackendResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    resp.Headers,
+			Body:       bytes.NewReader(resp.Body),
+		}, nil
+	}
+}
@@ -0,0 +1,174 @@
+package mock
+
+import (
+	"context"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func init() {
+	ext.StoreLoggerFactory(func(context.Context) flux.Logger {
+		return logger.SimpleLogger()
+	})
+	ext.StoreArgumentLookupFunc(support.DefaultArgumentValueLookupFunc)
+	serializer := flux.NewJsonSerializer()
+	ext.StoreSerializer(ext.TypeNameSerializerDefault, serializer)
+	ext.StoreSerializer(ext.TypeNameSerializerJson, serializer)
+}
+
+func newTestMockService(mock interface{}) flux.BackendService {
+	service := flux.BackendService{
+		ServiceId: "test.mock.UserService:get",
+		Interface: "test.mock.UserService",
+		Method:    "get",
+		Arguments: []flux.Argument{
+			ext.NewStringArgument("username"),
+			ext.NewIntegerArgument("year"),
+		},
+	}
+	if nil != mock {
+		service.Extensions = map[string]interface{}{ExtKeyMock: mock}
+	}
+	return service
+}
+
+func readMockBody(t *testing.T, resp *flux.BackendResponse) string {
+	data, err := ioutil.ReadAll(resp.Body.(io.Reader))
+	assert2.Nil(t, err)
+	return string(data)
+}
+
+func TestBackendTransportService_InvokeCodec(t *testing.T) {
+	cases := []struct {
+		mock           interface{}
+		expectedStatus int
+		expectedBody   string
+		expectedHeader string
+	}{
+		{
+			mock: map[string]interface{}{
+				"statusCode": 201,
+				"headers":    map[string]interface{}{"X-Mock": "true"},
+				"body":       map[string]interface{}{"username": "{{.Args.username}}", "year": "{{.Args.year}}"},
+			},
+			expectedStatus: 201,
+			expectedBody:   `{"username":"yong\"jiachen","year":"2020"}`,
+			expectedHeader: "true",
+		},
+		{
+			mock:           `{"body": "{\"user\": {{json .Args.username}}, \"args\": {{json .Args}}}"}`,
+			expectedStatus: 200,
+			expectedBody:   `{"user": "yong\"jiachen", "args": {"username":"yong\"jiachen","year":2020}}`,
+		},
+		{
+			mock:           map[string]interface{}{"body": []interface{}{"{{.Args.username}}", 1}},
+			expectedStatus: 200,
+			expectedBody:   `["yong\"jiachen",1]`,
+		},
+		{
+			mock:           map[string]interface{}{"delay": "5ms~10ms"},
+			expectedStatus: 200,
+			expectedBody:   "",
+		},
+	}
+	transport := NewBackendTransportService()
+	assert := assert2.New(t)
+	// 参数值包含JSON特殊字符，输出时转义
+	ctx := support.NewValuesContext(map[string]interface{}{
+		"username": `yong"jiachen`,
+		"year":     2020,
+	})
+	for _, tcase := range cases {
+		resp, serr := transport.InvokeCodec(ctx, newTestMockService(tcase.mock))
+		assert.Nil(serr)
+		assert.Equal(tcase.expectedStatus, resp.StatusCode)
+		assert.Equal(tcase.expectedBody, readMockBody(t, resp))
+		assert.Equal(tcase.expectedHeader, resp.Headers.Get("X-Mock"))
+	}
+	// 错误注入
+	_, serr := transport.InvokeCodec(ctx, newTestMockService(map[string]interface{}{
+		"errorRate": 1, "errorStatus": 503,
+	}))
+	assert.NotNil(serr)
+	assert.Equal(503, serr.StatusCode)
+	assert.Equal(flux.ErrorMessageMockInjectedError, serr.Message)
+	// 未定义模拟响应
+	_, serr = transport.InvokeCodec(ctx, newTestMockService(nil))
+	assert.NotNil(serr)
+	assert.Equal(flux.ErrorMessageMockNotFound, serr.Message)
+}
+
+func TestBackendTransportService_TemplateCache(t *testing.T) {
+	transport := NewBackendTransportService()
+	assert := assert2.New(t)
+	ctx := support.NewValuesContext(map[string]interface{}{"username": "yongjiachen"})
+	service := newTestMockService(`{"body": "hello {{json .Args.username}}"}`)
+	for i := 0; i < 2; i++ {
+		resp, serr := transport.InvokeCodec(ctx, service)
+		assert.Nil(serr)
+		assert.Equal(`hello "yongjiachen"`, readMockBody(t, resp))
+	}
+	// 同一服务的模板只编译一次
+	cached, ok := transport.templates.Load(service.ServiceID())
+	assert.True(ok)
+	_, serr := transport.InvokeCodec(ctx, service)
+	assert.Nil(serr)
+	current, _ := transport.templates.Load(service.ServiceID())
+	assert.Same(cached, current)
+	// Body定义变更时重新编译
+	resp, serr := transport.InvokeCodec(ctx, newTestMockService(`{"body": "bye {{json .Args.username}}"}`))
+	assert.Nil(serr)
+	assert.Equal(`bye "yongjiachen"`, readMockBody(t, resp))
+	current, _ = transport.templates.Load(service.ServiceID())
+	assert.NotSame(cached, current)
+}
+
+func TestBackendTransportService_MockDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-mock")
+	assert := assert2.New(t)
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "users.json")
+	assert.Nil(ioutil.WriteFile(file, []byte(`{"test.mock.UserService:get": {"body": "v1"}}`), 0600))
+	config := flux.NewConfiguration(nil)
+	config.Set(ConfigKeyMockDir, dir)
+	config.Set(ConfigKeyReloadInterval, "1ms")
+	transport := NewBackendTransportService()
+	assert.Nil(transport.Init(config))
+	ctx := support.NewValuesContext(map[string]interface{}{})
+	resp, serr := transport.InvokeCodec(ctx, newTestMockService(nil))
+	assert.Nil(serr)
+	assert.Equal("v1", readMockBody(t, resp))
+	// 定义文件修改后重新加载
+	assert.Nil(ioutil.WriteFile(file, []byte(`{"test.mock.UserService:get": {"body": "v2"}}`), 0600))
+	assert.Nil(os.Chtimes(file, time.Now(), time.Now().Add(time.Second)))
+	time.Sleep(time.Millisecond * 5)
+	resp, serr = transport.InvokeCodec(ctx, newTestMockService(nil))
+	assert.Nil(serr)
+	assert.Equal("v2", readMockBody(t, resp))
+}
+
+func TestMockDefinition_DelayOf(t *testing.T) {
+	assert := assert2.New(t)
+	delay, err := MockDefinition{Delay: "100ms"}.DelayOf()
+	assert.Nil(err)
+	assert.Equal(time.Millisecond*100, delay)
+	for i := 0; i < 10; i++ {
+		delay, err = MockDefinition{Delay: "100ms ~ 200ms"}.DelayOf()
+		assert.Nil(err)
+		assert.True(delay >= time.Millisecond*100 && delay < time.Millisecond*200)
+	}
+	_, err = MockDefinition{Delay: "200ms~100ms"}.DelayOf()
+	assert.NotNil(err)
+	_, err = MockDefinition{Delay: "abc"}.DelayOf()
+	assert.NotNil(err)
+}
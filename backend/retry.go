@@ -0,0 +1,311 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/logger"
+	"github.com/spf13/cast"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	ConfigKeyRetryBackoff             = "retry-backoff"
+	ConfigKeyRetryMaxBackoff          = "retry-max-backoff"
+	ConfigKeyRetryJitter              = "retry-jitter"
+	ConfigKeyRetryOnStatus            = "retry-on-status"
+	ConfigKeyRetryOnErrorCodes        = "retry-on-error-codes"
+	ConfigKeyRetryIdempotentMethods   = "retry-idempotent-methods"
+	ConfigKeyRetryBudgetRatio         = "retry-budget-ratio"
+	ConfigKeyRetryBudgetMinPerSecond  = "retry-budget-min-per-second"
+	ConfigKeyRetryBudgetWindowSeconds = "retry-budget-window-seconds"
+)
+
+// InvokeCodecFunc 执行后端服务并解析响应结果的函数
+type InvokeCodecFunc func(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError)
+
+// RetryPolicy 重试策略。最大重试次数由BackendService的rpc-retries属性指定，总尝试次数为 1+rpc-retries。
+type RetryPolicy struct {
+	Backoff           time.Duration // 首次重试的退避时长；按 Backoff*2^(n-1) 增长
+	MaxBackoff        time.Duration // 最大退避时长
+	Jitter            float64       // 退避时长的随机抖动比例，取值[0, 1]
+	RetryOnStatus     []int         // 需要重试的响应状态码
+	RetryOnErrorCodes []string      // 需要重试的ServeError.ErrorCode
+	IdempotentMethods []string      // Http协议中可重试的幂等方法；连接建立失败时不受此限制
+}
+
+// RetryPolicyDefaults 重试策略的默认配置值
+func RetryPolicyDefaults() map[string]interface{} {
+	return map[string]interface{}{
+		ConfigKeyRetryBackoff:             "50ms",
+		ConfigKeyRetryMaxBackoff:          "1s",
+		ConfigKeyRetryJitter:              0.2,
+		ConfigKeyRetryOnStatus:            []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		ConfigKeyRetryOnErrorCodes:        []string{},
+		ConfigKeyRetryIdempotentMethods:   []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE", "TRACE"},
+		ConfigKeyRetryBudgetRatio:         0.2,
+		ConfigKeyRetryBudgetMinPerSecond:  10,
+		ConfigKeyRetryBudgetWindowSeconds: 10,
+	}
+}
+
+// NewRetryPolicyOf 根据配置构建重试策略
+func NewRetryPolicyOf(config *flux.Configuration) RetryPolicy {
+	methods := config.GetStringSlice(ConfigKeyRetryIdempotentMethods)
+	for i, m := range methods {
+		methods[i] = strings.ToUpper(m)
+	}
+	return RetryPolicy{
+		Backoff:           config.GetDuration(ConfigKeyRetryBackoff),
+		MaxBackoff:        config.GetDuration(ConfigKeyRetryMaxBackoff),
+		Jitter:            config.GetFloat64(ConfigKeyRetryJitter),
+		RetryOnStatus:     config.GetIntSlice(ConfigKeyRetryOnStatus),
+		RetryOnErrorCodes: config.GetStringSlice(ConfigKeyRetryOnErrorCodes),
+		IdempotentMethods: methods,
+	}
+}
+
+// BackoffOf 返回第N次重试（从1开始）的退避时长
+func (p RetryPolicy) BackoffOf(retry int) time.Duration {
+	backoff := p.Backoff
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || backoff < p.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if p.Jitter > 0 && backoff > 0 {
+		delta := float64(backoff) * p.Jitter
+		backoff = time.Duration(float64(backoff) - delta + rand.Float64()*2*delta)
+	}
+	return backoff
+}
+
+// Retryable 判断本次调用结果是否可以重试：
+// 1. 连接建立失败，请求未发送到后端，总是可以重试；
+// 2. 响应状态码、错误码命中重试条件，并且服务是幂等的；
+func (p RetryPolicy) Retryable(service flux.BackendService, resp *flux.BackendResponse, serr *flux.ServeError) bool {
+	if nil != serr && IsConnectError(serr.Internal) {
+		return true
+	}
+	if !p.Idempotent(service) {
+		return false
+	}
+	if nil != serr {
+		return p.matchStatus(serr.StatusCode) || p.matchErrorCode(serr.GetErrorCode())
+	}
+	return nil != resp && p.matchStatus(resp.StatusCode)
+}
+
+// Idempotent 判断服务是否幂等；Http协议按请求方法判断，其它RPC协议配置了rpc-retries即视为幂等
+func (p RetryPolicy) Idempotent(service flux.BackendService) bool {
+	if !strings.EqualFold(flux.ProtoHttp, service.AttrRpcProto()) {
+		return true
+	}
+	method := strings.ToUpper(service.Method)
+	for _, m := range p.IdempotentMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (p RetryPolicy) matchStatus(status int) bool {
+	for _, s := range p.RetryOnStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (p RetryPolicy) matchErrorCode(code string) bool {
+	if "" == code {
+		return false
+	}
+	for _, c := range p.RetryOnErrorCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// IsConnectError 判断错误是否为连接建立失败：拨号错误、连接被拒绝
+func IsConnectError(err error) bool {
+	if nil == err {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && "dial" == opErr.Op
+}
+
+// RetryBudget 重试预算：在滑动时间窗口内，重试次数不超过 请求数*Ratio 与 MinPerSecond*窗口秒数 中的较大值，
+// 避免后端故障时重试放大请求流量。
+type RetryBudget struct {
+	ratio        float64
+	minPerSecond int64
+	buckets      []budgetBucket
+	mu           sync.Mutex
+}
+
+type budgetBucket struct {
+	second   int64
+	requests int64
+	retries  int64
+}
+
+func NewRetryBudget(ratio float64, minPerSecond int, windowSeconds int) *RetryBudget {
+	if windowSeconds <= 0 {
+		windowSeconds = 10
+	}
+	return &RetryBudget{
+		ratio:        ratio,
+		minPerSecond: int64(minPerSecond),
+		buckets:      make([]budgetBucket, windowSeconds),
+	}
+}
+
+// Request 记录一次请求
+func (b *RetryBudget) Request(now time.Time) {
+	b.mu.Lock()
+	b.bucketOf(now.Unix()).requests++
+	b.mu.Unlock()
+}
+
+// TryRetry 尝试获取一次重试额度；预算耗尽时返回false
+func (b *RetryBudget) TryRetry(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	second := now.Unix()
+	var requests, retries int64
+	for i := range b.buckets {
+		if second-b.buckets[i].second < int64(len(b.buckets)) {
+			requests += b.buckets[i].requests
+			retries += b.buckets[i].retries
+		}
+	}
+	allowed := int64(float64(requests) * b.ratio)
+	if min := b.minPerSecond * int64(len(b.buckets)); allowed < min {
+		allowed = min
+	}
+	if retries >= allowed {
+		return false
+	}
+	b.bucketOf(second).retries++
+	return true
+}
+
+func (b *RetryBudget) bucketOf(second int64) *budgetBucket {
+	bucket := &b.buckets[second%int64(len(b.buckets))]
+	if bucket.second != second {
+		*bucket = budgetBucket{second: second}
+	}
+	return bucket
+}
+
+// Retryer 重试执行器，可被任意BackendTransport使用；重试预算按BackendService.ServiceID隔离。
+type Retryer struct {
+	policy  RetryPolicy
+	budgets sync.Map
+	factory func() *RetryBudget
+}
+
+func NewRetryer(policy RetryPolicy, budget func() *RetryBudget) *Retryer {
+	return &Retryer{
+		policy:  policy,
+		factory: budget,
+	}
+}
+
+// NewRetryerOf 根据配置构建重试执行器；未配置的重试参数使用默认值
+func NewRetryerOf(config *flux.Configuration) *Retryer {
+	for k, v := range RetryPolicyDefaults() {
+		if !config.IsSet(k) {
+			config.SetDefault(k, v)
+		}
+	}
+	ratio := config.GetFloat64(ConfigKeyRetryBudgetRatio)
+	minPerSecond := config.GetInt(ConfigKeyRetryBudgetMinPerSecond)
+	window := config.GetInt(ConfigKeyRetryBudgetWindowSeconds)
+	return NewRetryer(NewRetryPolicyOf(config), func() *RetryBudget {
+		return NewRetryBudget(ratio, minPerSecond, window)
+	})
+}
+
+// Policy 返回重试策略
+func (r *Retryer) Policy() RetryPolicy {
+	return r.policy
+}
+
+// MaxAttemptsOf 返回服务的最大尝试次数：1+rpc-retries
+func (r *Retryer) MaxAttemptsOf(service flux.BackendService) int {
+	retries := service.AttrRpcRetries()
+	if "" == retries {
+		return 1
+	}
+	n, err := cast.ToIntE(retries)
+	if nil != err || n < 0 {
+		logger.Warnw("Illegal backend rpc-retries", "retries", retries)
+		return 1
+	}
+	return 1 + n
+}
+
+// InvokeCodec 按重试策略执行后端服务；每次尝试通过 ctx.AddMetric 记录耗时
+func (r *Retryer) InvokeCodec(ctx flux.Context, service flux.BackendService, invoke InvokeCodecFunc) (*flux.BackendResponse, *flux.ServeError) {
+	attempts := r.MaxAttemptsOf(service)
+	if attempts <= 1 {
+		return invoke(ctx, service)
+	}
+	budget := r.budgetOf(service.ServiceID())
+	budget.Request(time.Now())
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		resp, serr := invoke(ctx, service)
+		ctx.AddMetric(fmt.Sprintf("M-Attempt-%d", attempt), time.Since(start))
+		if attempt >= attempts || !r.policy.Retryable(service, resp, serr) || nil != ctx.Context().Err() {
+			return resp, serr
+		}
+		if !budget.TryRetry(time.Now()) {
+			logger.TraceContext(ctx).Warnw("BACKEND:RETRY:BUDGET_EXHAUSTED", "service-id", service.ServiceID(), "attempt", attempt)
+			return resp, serr
+		}
+		backoff := r.policy.BackoffOf(attempt)
+		logger.TraceContext(ctx).Infow("BACKEND:RETRY", "service-id", service.ServiceID(), "attempt", attempt, "backoff", backoff)
+		if backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-ctx.Context().Done():
+				timer.Stop()
+				return resp, serr
+			}
+		}
+		// 丢弃本次响应，释放Body资源
+		if nil != resp {
+			if closer, ok := resp.Body.(io.Closer); ok {
+				_ = closer.Close()
+			}
+		}
+	}
+}
+
+func (r *Retryer) budgetOf(serviceId string) *RetryBudget {
+	if v, ok := r.budgets.Load(serviceId); ok {
+		return v.(*RetryBudget)
+	}
+	v, _ := r.budgets.LoadOrStore(serviceId, r.factory())
+	return v.(*RetryBudget)
+}
@@ -0,0 +1,151 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func newTestRetryService(proto, method string, retries string) flux.BackendService {
+	return flux.BackendService{
+		ServiceId: "test.retry:" + method,
+		Interface: "/test/retry",
+		Method:    method,
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{
+				{Tag: flux.ServiceAttrTagRpcProto, Name: "RpcProto", Value: proto},
+				{Tag: flux.ServiceAttrTagRpcRetries, Name: "RpcRetries", Value: retries},
+			},
+		},
+	}
+}
+
+type metricsContext struct {
+	*support.ValuesContext
+	metrics []flux.Metric
+}
+
+func (c *metricsContext) AddMetric(name string, elapsed time.Duration) {
+	c.metrics = append(c.metrics, flux.Metric{Name: name, Elapsed: elapsed})
+}
+
+func newTestRetryer(ratio float64, minPerSecond int) *Retryer {
+	config := flux.NewConfiguration(nil)
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyRetryBackoff:            "1ms",
+		ConfigKeyRetryMaxBackoff:         "4ms",
+		ConfigKeyRetryOnErrorCodes:       []string{"BACKEND:BUSY"},
+		ConfigKeyRetryBudgetRatio:        ratio,
+		ConfigKeyRetryBudgetMinPerSecond: minPerSecond,
+	})
+	return NewRetryerOf(config)
+}
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	connErr := &flux.ServeError{
+		StatusCode: flux.StatusServerError,
+		Internal:   &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED},
+	}
+	cases := []struct {
+		service  flux.BackendService
+		resp     *flux.BackendResponse
+		serr     *flux.ServeError
+		expected bool
+	}{
+		{service: newTestRetryService(flux.ProtoHttp, "POST", "2"), serr: connErr, expected: true},
+		{service: newTestRetryService(flux.ProtoHttp, "GET", "2"), resp: &flux.BackendResponse{StatusCode: http.StatusBadGateway}, expected: true},
+		{service: newTestRetryService(flux.ProtoHttp, "POST", "2"), resp: &flux.BackendResponse{StatusCode: http.StatusBadGateway}, expected: false},
+		{service: newTestRetryService(flux.ProtoHttp, "GET", "2"), resp: &flux.BackendResponse{StatusCode: http.StatusOK}, expected: false},
+		{service: newTestRetryService(flux.ProtoHttp, "GET", "2"), resp: &flux.BackendResponse{StatusCode: http.StatusInternalServerError}, expected: false},
+		{service: newTestRetryService(flux.ProtoGRPC, "SayHello", "2"), serr: &flux.ServeError{StatusCode: http.StatusServiceUnavailable}, expected: true},
+		{service: newTestRetryService(flux.ProtoGRPC, "SayHello", "2"), serr: &flux.ServeError{StatusCode: flux.StatusServerError, ErrorCode: "BACKEND:BUSY"}, expected: true},
+		{service: newTestRetryService(flux.ProtoGRPC, "SayHello", "2"), serr: &flux.ServeError{StatusCode: flux.StatusServerError, Internal: errors.New("timeout")}, expected: false},
+	}
+	policy := newTestRetryer(1, 1).Policy()
+	assert := assert2.New(t)
+	for i, tcase := range cases {
+		assert.Equal(tcase.expected, policy.Retryable(tcase.service, tcase.resp, tcase.serr), "case: %d", i)
+	}
+}
+
+func TestRetryPolicy_BackoffOf(t *testing.T) {
+	policy := RetryPolicy{Backoff: time.Millisecond * 10, MaxBackoff: time.Millisecond * 50}
+	assert := assert2.New(t)
+	assert.Equal(time.Millisecond*10, policy.BackoffOf(1))
+	assert.Equal(time.Millisecond*20, policy.BackoffOf(2))
+	assert.Equal(time.Millisecond*40, policy.BackoffOf(3))
+	assert.Equal(time.Millisecond*50, policy.BackoffOf(4))
+	policy.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		backoff := policy.BackoffOf(2)
+		assert.True(backoff >= time.Millisecond*10 && backoff <= time.Millisecond*30, "backoff: %s", backoff)
+	}
+}
+
+func TestRetryer_InvokeCodec(t *testing.T) {
+	ext.StoreLoggerFactory(func(context.Context) flux.Logger {
+		return logger.SimpleLogger()
+	})
+	retryer := newTestRetryer(1, 1)
+	assert := assert2.New(t)
+	// 第3次尝试成功
+	calls := 0
+	ctx := &metricsContext{ValuesContext: support.NewValuesContext(map[string]interface{}{}).(*support.ValuesContext)}
+	resp, serr := retryer.InvokeCodec(ctx, newTestRetryService(flux.ProtoHttp, "GET", "3"),
+		func(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+			calls++
+			if calls < 3 {
+				return &flux.BackendResponse{StatusCode: http.StatusServiceUnavailable}, nil
+			}
+			return &flux.BackendResponse{StatusCode: http.StatusOK}, nil
+		})
+	assert.Nil(serr)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(3, calls)
+	assert.Equal([]string{"M-Attempt-1", "M-Attempt-2", "M-Attempt-3"}, []string{ctx.metrics[0].Name, ctx.metrics[1].Name, ctx.metrics[2].Name})
+	// 最大尝试次数
+	calls = 0
+	resp, _ = retryer.InvokeCodec(ctx, newTestRetryService(flux.ProtoHttp, "GET", "1"),
+		func(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+			calls++
+			return &flux.BackendResponse{StatusCode: http.StatusBadGateway}, nil
+		})
+	assert.Equal(http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(2, calls)
+	// 未配置重试
+	calls = 0
+	_, _ = retryer.InvokeCodec(ctx, newTestRetryService(flux.ProtoHttp, "GET", ""),
+		func(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+			calls++
+			return &flux.BackendResponse{StatusCode: http.StatusBadGateway}, nil
+		})
+	assert.Equal(1, calls)
+}
+
+func TestRetryBudget_TryRetry(t *testing.T) {
+	budget := NewRetryBudget(0.5, 0, 10)
+	now := time.Unix(1000, 0)
+	assert := assert2.New(t)
+	for i := 0; i < 10; i++ {
+		budget.Request(now)
+	}
+	for i := 0; i < 5; i++ {
+		assert.True(budget.TryRetry(now), "retry: %d", i)
+	}
+	assert.False(budget.TryRetry(now))
+	// 时间窗口滑动后，预算恢复
+	later := now.Add(time.Second * 10)
+	budget.Request(later)
+	budget.Request(later)
+	assert.True(budget.TryRetry(later))
+	assert.False(budget.TryRetry(later))
+}
@@ -0,0 +1,250 @@
+package websocket
+
+import (
+	gorilla "github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DirectionUpstream   = "upstream"   // 客户端 -> 后端
+	DirectionDownstream = "downstream" // 后端 -> 客户端
+)
+
+// Metrics WebSocket连接的统计指标
+type Metrics struct {
+	ConnectionActive   *prometheus.GaugeVec
+	ConnectionTotal    *prometheus.CounterVec
+	ConnectionDuration *prometheus.HistogramVec
+	MessageTotal       *prometheus.CounterVec
+	MessageBytes       *prometheus.CounterVec
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		ConnectionActive: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "flux",
+			Subsystem: "websocket",
+			Name:      "connection_active",
+			Help:      "Number of active websocket connections",
+		}, []string{"Interface"}),
+		ConnectionTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "flux",
+			Subsystem: "websocket",
+			Name:      "connection_total",
+			Help:      "Number of websocket connections",
+		}, []string{"Interface"}),
+		ConnectionDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "flux",
+			Subsystem: "websocket",
+			Name:      "connection_duration",
+			Help:      "Lifetime of websocket connections in seconds",
+			Buckets:   []float64{1, 5, 10, 30, 60, 300, 600, 1800, 3600},
+		}, []string{"Interface"}),
+		MessageTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "flux",
+			Subsystem: "websocket",
+			Name:      "message_total",
+			Help:      "Number of relayed websocket messages",
+		}, []string{"Interface", "Direction"}),
+		MessageBytes: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "flux",
+			Subsystem: "websocket",
+			Name:      "message_bytes",
+			Help:      "Bytes of relayed websocket messages",
+		}, []string{"Interface", "Direction"}),
+	}
+}
+
+// ConnectionStats 单个WebSocket连接的统计数据
+type ConnectionStats struct {
+	RequestId          string    `json:"requestId"`
+	Interface          string    `json:"interface"`
+	Upstream           string    `json:"upstream"`
+	StartTime          time.Time `json:"startTime"`
+	LastActiveTime     time.Time `json:"lastActiveTime"`
+	UpstreamMessages   int64     `json:"upstreamMessages"`
+	UpstreamBytes      int64     `json:"upstreamBytes"`
+	DownstreamMessages int64     `json:"downstreamMessages"`
+	DownstreamBytes    int64     `json:"downstreamBytes"`
+}
+
+// Connection 客户端与后端之间的WebSocket转发连接
+type Connection struct {
+	requestId    string
+	iface        string
+	upstreamUrl  string
+	client       *gorilla.Conn
+	upstream     *gorilla.Conn
+	start        time.Time
+	active       int64 // UnixNano
+	upMessages   int64
+	upBytes      int64
+	downMessages int64
+	downBytes    int64
+	writeTimeout time.Duration
+	metrics      *Metrics
+}
+
+func NewConnection(requestId, iface, upstreamUrl string, client, upstream *gorilla.Conn, writeTimeout time.Duration, metrics *Metrics) *Connection {
+	now := time.Now()
+	return &Connection{
+		requestId:    requestId,
+		iface:        iface,
+		upstreamUrl:  upstreamUrl,
+		client:       client,
+		upstream:     upstream,
+		start:        now,
+		active:       now.UnixNano(),
+		writeTimeout: writeTimeout,
+		metrics:      metrics,
+	}
+}
+
+// Relay 双向转发数据帧，直到任意一端关闭、空闲超时或请求取消
+func (c *Connection) Relay(done <-chan struct{}, idleTimeout time.Duration) error {
+	c.keepalive(c.client)
+	c.keepalive(c.upstream)
+	errc := make(chan error, 2)
+	go func() {
+		errc <- c.copyFrames(c.upstream, c.client, DirectionUpstream)
+	}()
+	go func() {
+		errc <- c.copyFrames(c.client, c.upstream, DirectionDownstream)
+	}()
+	var ticker *time.Ticker
+	var ticks <-chan time.Time
+	if idleTimeout > 0 {
+		ticker = time.NewTicker(checkIntervalOf(idleTimeout))
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	var err error
+loop:
+	for {
+		select {
+		case err = <-errc:
+			break loop
+		case <-done:
+			c.closeBoth(gorilla.CloseGoingAway, "gateway shutdown")
+			break loop
+		case <-ticks:
+			if time.Since(c.LastActiveTime()) >= idleTimeout {
+				c.closeBoth(gorilla.CloseGoingAway, "idle timeout")
+				break loop
+			}
+		}
+	}
+	// 一端结束后，关闭两端连接，另一个转发协程随之退出
+	_ = c.client.Close()
+	_ = c.upstream.Close()
+	return err
+}
+
+// Stats 返回连接的统计数据
+func (c *Connection) Stats() ConnectionStats {
+	return ConnectionStats{
+		RequestId:          c.requestId,
+		Interface:          c.iface,
+		Upstream:           c.upstreamUrl,
+		StartTime:          c.start,
+		LastActiveTime:     c.LastActiveTime(),
+		UpstreamMessages:   atomic.LoadInt64(&c.upMessages),
+		UpstreamBytes:      atomic.LoadInt64(&c.upBytes),
+		DownstreamMessages: atomic.LoadInt64(&c.downMessages),
+		DownstreamBytes:    atomic.LoadInt64(&c.downBytes),
+	}
+}
+
+// LastActiveTime 返回最后一次收到数据帧的时间
+func (c *Connection) LastActiveTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.active))
+}
+
+func (c *Connection) copyFrames(dst, src *gorilla.Conn, direction string) error {
+	for {
+		mt, data, err := src.ReadMessage()
+		if nil != err {
+			// 向另一端转发关闭帧
+			code, text := closeCodeOf(err)
+			_ = dst.WriteControl(gorilla.CloseMessage, gorilla.FormatCloseMessage(code, text), time.Now().Add(c.writeTimeout))
+			if gorilla.IsCloseError(err, gorilla.CloseNormalClosure, gorilla.CloseGoingAway, gorilla.CloseNoStatusReceived) {
+				return nil
+			}
+			return err
+		}
+		c.touch()
+		if c.writeTimeout > 0 {
+			_ = dst.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+		}
+		if err := dst.WriteMessage(mt, data); nil != err {
+			return err
+		}
+		size := int64(len(data))
+		if DirectionUpstream == direction {
+			atomic.AddInt64(&c.upMessages, 1)
+			atomic.AddInt64(&c.upBytes, size)
+		} else {
+			atomic.AddInt64(&c.downMessages, 1)
+			atomic.AddInt64(&c.downBytes, size)
+		}
+		if nil != c.metrics {
+			c.metrics.MessageTotal.WithLabelValues(c.iface, direction).Inc()
+			c.metrics.MessageBytes.WithLabelValues(c.iface, direction).Add(float64(size))
+		}
+	}
+}
+
+// keepalive Ping帧作为连接活跃信号，并回复Pong
+func (c *Connection) keepalive(conn *gorilla.Conn) {
+	conn.SetPingHandler(func(data string) error {
+		c.touch()
+		err := conn.WriteControl(gorilla.PongMessage, []byte(data), time.Now().Add(c.writeTimeout))
+		if ne, ok := err.(net.Error); ok && ne.Temporary() {
+			return nil
+		}
+		if err == gorilla.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+}
+
+func (c *Connection) touch() {
+	atomic.StoreInt64(&c.active, time.Now().UnixNano())
+}
+
+func (c *Connection) closeBoth(code int, text string) {
+	msg := gorilla.FormatCloseMessage(code, text)
+	deadline := time.Now().Add(c.writeTimeout)
+	_ = c.client.WriteControl(gorilla.CloseMessage, msg, deadline)
+	_ = c.upstream.WriteControl(gorilla.CloseMessage, msg, deadline)
+}
+
+// closeCodeOf 返回需要转发的关闭码；1005/1006/1015为保留码，不能在关闭帧中发送
+func closeCodeOf(err error) (int, string) {
+	if ce, ok := err.(*gorilla.CloseError); ok {
+		switch ce.Code {
+		case gorilla.CloseNoStatusReceived:
+			return gorilla.CloseNormalClosure, ""
+		case gorilla.CloseAbnormalClosure, gorilla.CloseTLSHandshake:
+			return gorilla.CloseGoingAway, ""
+		default:
+			return ce.Code, ce.Text
+		}
+	}
+	return gorilla.CloseGoingAway, ""
+}
+
+func checkIntervalOf(idle time.Duration) time.Duration {
+	interval := idle / 4
+	if interval < time.Millisecond*10 {
+		interval = time.Millisecond * 10
+	} else if interval > time.Second*5 {
+		interval = time.Second * 5
+	}
+	return interval
+}
@@ -0,0 +1,371 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	gorilla "github.com/gorilla/websocket"
+	"github.com/spf13/cast"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ConfigKeyHandshakeTimeout = "handshake-timeout"
+	ConfigKeyIdleTimeout      = "idle-timeout"
+	ConfigKeyWriteTimeout     = "write-timeout"
+	ConfigKeyReadBufferSize   = "read-buffer-size"
+	ConfigKeyWriteBufferSize  = "write-buffer-size"
+	ConfigKeyMaxMessageSize   = "max-message-size"
+	ConfigKeyAllowedOrigins   = "allowed-origins"
+)
+
+const (
+	// ExtKeyIdleTimeout BackendService扩展属性：连接空闲超时，覆盖全局配置
+	ExtKeyIdleTimeout = "websocket-idle-timeout"
+)
+
+// 握手时由WebSocket客户端生成，不能转发到后端的请求Header
+var handshakeHeaders = map[string]bool{
+	"Upgrade":                  true,
+	"Connection":               true,
+	"Sec-Websocket-Key":        true,
+	"Sec-Websocket-Version":    true,
+	"Sec-Websocket-Extensions": true,
+	"Sec-Websocket-Accept":     true,
+	"Host":                     true,
+	"Content-Length":           true,
+}
+
+var metrics = newMetrics()
+
+func init() {
+	ext.StoreBackendTransport(flux.ProtoWebSocket, NewBackendTransportService())
+}
+
+var (
+	_ flux.BackendTransport = new(BackendTransportService)
+	_ flux.Shutdowner       = new(BackendTransportService)
+)
+
+type (
+	// Option 配置函数
+	Option func(service *BackendTransportService)
+)
+
+// BackendTransportService WebSocket转发BackendService：在Filter链（包括权限验证）执行完成后，
+// 与后端Http服务建立WebSocket连接，再升级客户端连接，并双向转发数据帧。
+type BackendTransportService struct {
+	dialer       *gorilla.Dialer
+	upgrader     *gorilla.Upgrader
+	defaults     map[string]interface{}
+	idleTimeout  time.Duration
+	writeTimeout time.Duration
+	maxMessage   int64
+	connections  sync.Map // requestId -> *Connection
+	shutdown     chan struct{}
+}
+
+// WithDefaults 用于配置默认配置值
+func WithDefaults(defaults map[string]interface{}) Option {
+	return func(service *BackendTransportService) {
+		service.defaults = defaults
+	}
+}
+
+// WithDialer 用于配置连接后端的WebSocket Dialer
+func WithDialer(dialer *gorilla.Dialer) Option {
+	return func(service *BackendTransportService) {
+		service.dialer = dialer
+	}
+}
+
+func NewBackendTransportService() *BackendTransportService {
+	return NewBackendTransportServiceWith()
+}
+
+func NewBackendTransportServiceWith(opts ...Option) *BackendTransportService {
+	bts := &BackendTransportService{
+		defaults: map[string]interface{}{
+			ConfigKeyHandshakeTimeout: "10s",
+			ConfigKeyIdleTimeout:      "60s",
+			ConfigKeyWriteTimeout:     "10s",
+			ConfigKeyReadBufferSize:   4096,
+			ConfigKeyWriteBufferSize:  4096,
+			ConfigKeyMaxMessageSize:   0,
+		},
+		idleTimeout:  time.Second * 60,
+		writeTimeout: time.Second * 10,
+		shutdown:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(bts)
+	}
+	if nil == bts.dialer {
+		bts.dialer = &gorilla.Dialer{Proxy: http.ProxyFromEnvironment, HandshakeTimeout: time.Second * 10}
+	}
+	bts.upgrader = newUpgrader(4096, 4096, nil)
+	return bts
+}
+
+// Init 读取超时、缓冲区及允许的Origin配置
+func (b *BackendTransportService) Init(config *flux.Configuration) error {
+	logger.Info("WebSocket backend transport initializing")
+	config.SetDefaults(b.defaults)
+	b.idleTimeout = config.GetDuration(ConfigKeyIdleTimeout)
+	if t := config.GetDuration(ConfigKeyWriteTimeout); t > 0 {
+		b.writeTimeout = t
+	}
+	if t := config.GetDuration(ConfigKeyHandshakeTimeout); t > 0 {
+		b.dialer.HandshakeTimeout = t
+	}
+	b.dialer.ReadBufferSize = config.GetInt(ConfigKeyReadBufferSize)
+	b.dialer.WriteBufferSize = config.GetInt(ConfigKeyWriteBufferSize)
+	b.maxMessage = config.GetInt64(ConfigKeyMaxMessageSize)
+	b.upgrader = newUpgrader(b.dialer.ReadBufferSize, b.dialer.WriteBufferSize, config.GetStringSlice(ConfigKeyAllowedOrigins))
+	b.upgrader.HandshakeTimeout = b.dialer.HandshakeTimeout
+	ext.StoreDebugQueryFunc("/debug/websockets", func(_ *http.Request) interface{} {
+		return b.Connections()
+	})
+	return nil
+}
+
+// Shutdown 向全部活跃连接发送关闭帧
+func (b *BackendTransportService) Shutdown(_ context.Context) error {
+	select {
+	case <-b.shutdown:
+	default:
+		close(b.shutdown)
+	}
+	return nil
+}
+
+func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return func(ctx flux.Context, value interface{}) (*flux.BackendResponse, error) {
+		return nil, fmt.Errorf("websocket transport not support response codec")
+	}
+}
+
+// Invoke WebSocket连接不支持请求-响应式调用
+func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	return nil, &flux.ServeError{
+		StatusCode: flux.StatusServerError,
+		ErrorCode:  flux.ErrorCodeGatewayEndpoint,
+		Message:    flux.ErrorMessageWebSocketNotSupported,
+		Internal:   fmt.Errorf("websocket transport not support invoke, service: %s", service.ServiceID()),
+	}
+}
+
+func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	_, serr := b.Invoke(ctx, service)
+	return nil, serr
+}
+
+// Exchange 建立后端WebSocket连接，升级客户端连接，然后双向转发数据帧直到连接关闭
+func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
+	holder, ok := ctx.(flux.WebContextHolder)
+	if !ok {
+		return newNotSupportedError(fmt.Errorf("context not support web context: %T", ctx))
+	}
+	webc := holder.WebContext()
+	request, err := webc.HttpRequest()
+	if nil != err {
+		return newNotSupportedError(err)
+	}
+	writer, err := webc.HttpResponseWriter()
+	if nil != err {
+		return newNotSupportedError(err)
+	}
+	if !gorilla.IsWebSocketUpgrade(request) {
+		return &flux.ServeError{
+			StatusCode: flux.StatusBadRequest,
+			ErrorCode:  flux.ErrorCodeRequestInvalid,
+			Message:    flux.ErrorMessageWebSocketNotSupported,
+			Internal:   fmt.Errorf("request is not websocket upgrade"),
+		}
+	}
+	service := ctx.Service()
+	// 先连接后端；后端握手失败时，客户端仍可收到Http错误响应
+	upstreamUrl, err := UpstreamURLOf(service, request.URL, ctx)
+	if nil != err {
+		return &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageWebSocketDialFailed,
+			Internal:   err,
+		}
+	}
+	upstream, resp, err := b.dialer.DialContext(ctx.Context(), upstreamUrl, UpstreamHeaderOf(request.Header, ctx))
+	if nil != err {
+		status := flux.StatusBadGateway
+		if nil != resp && resp.StatusCode >= http.StatusBadRequest {
+			status = resp.StatusCode
+		}
+		return &flux.ServeError{
+			StatusCode: status,
+			ErrorCode:  flux.ErrorCodeGatewayBackend,
+			Message:    flux.ErrorMessageWebSocketDialFailed,
+			Internal:   fmt.Errorf("dial upstream: %s, err: %w", upstreamUrl, err),
+		}
+	}
+	responseHeader := make(http.Header)
+	if protocol := upstream.Subprotocol(); "" != protocol {
+		responseHeader.Set("Sec-WebSocket-Protocol", protocol)
+	}
+	var upgradeStatus int
+	upgrader := *b.upgrader
+	upgrader.Error = func(_ http.ResponseWriter, _ *http.Request, status int, _ error) {
+		upgradeStatus = status
+	}
+	// 会话期间不占用过滤器链：请求结束后Context被回收，转发协程使用升级前复制的请求数据
+	relayctx, release := relayContextOf(ctx)
+	client, err := upgrader.Upgrade(writer, request, responseHeader)
+	if nil != err {
+		release()
+		_ = upstream.Close()
+		if upgradeStatus == 0 {
+			// 连接已被接管，无法再写入Http错误响应
+			ctx.SetValue(flux.ValueKeyResponseHijacked, true)
+			logger.TraceContext(ctx).Warnw("BACKEND:WEBSOCKET:UPGRADE", "error", err)
+			return nil
+		}
+		return &flux.ServeError{
+			StatusCode: upgradeStatus,
+			ErrorCode:  flux.ErrorCodeRequestInvalid,
+			Message:    flux.ErrorMessageWebSocketUpgradeFailed,
+			Internal:   err,
+		}
+	}
+	ctx.SetValue(flux.ValueKeyResponseHijacked, true)
+	ctx.Response().SetStatusCode(http.StatusSwitchingProtocols)
+	if b.maxMessage > 0 {
+		client.SetReadLimit(b.maxMessage)
+		upstream.SetReadLimit(b.maxMessage)
+	}
+	idle := b.idleTimeout
+	if v := service.ExtString(ExtKeyIdleTimeout); "" != v {
+		if d, err := time.ParseDuration(v); nil == err {
+			idle = d
+		}
+	}
+	conn := NewConnection(ctx.RequestId(), service.Interface, upstreamUrl, client, upstream, b.writeTimeout, metrics)
+	b.connections.Store(conn.requestId, conn)
+	metrics.ConnectionTotal.WithLabelValues(service.Interface).Inc()
+	metrics.ConnectionActive.WithLabelValues(service.Interface).Inc()
+	go func() {
+		defer release()
+		b.relay(relayctx, conn, idle)
+	}()
+	return nil
+}
+
+func (b *BackendTransportService) relay(ctx flux.Context, conn *Connection, idle time.Duration) {
+	iface := conn.iface
+	defer func() {
+		b.connections.Delete(conn.requestId)
+		metrics.ConnectionActive.WithLabelValues(iface).Dec()
+	}()
+	err := conn.Relay(b.shutdown, idle)
+	stats := conn.Stats()
+	elapsed := time.Since(stats.StartTime)
+	metrics.ConnectionDuration.WithLabelValues(iface).Observe(elapsed.Seconds())
+	ctx.AddMetric("M-WebSocket", elapsed)
+	logger.TraceContext(ctx).Infow("BACKEND:WEBSOCKET:CLOSED",
+		"upstream", stats.Upstream, "elapses", elapsed.String(),
+		"upstream-messages", stats.UpstreamMessages, "upstream-bytes", stats.UpstreamBytes,
+		"downstream-messages", stats.DownstreamMessages, "downstream-bytes", stats.DownstreamBytes,
+		"error", err)
+}
+
+// relayContextOf 返回转发协程使用的Context：复制请求数据，不设超时
+func relayContextOf(ctx flux.Context) (flux.Context, context.CancelFunc) {
+	if snapshotter, ok := ctx.(flux.ContextSnapshotter); ok {
+		return snapshotter.Snapshot(0)
+	}
+	return ctx, func() {}
+}
+
+// Connections 返回全部活跃连接的统计数据
+func (b *BackendTransportService) Connections() []ConnectionStats {
+	out := make([]ConnectionStats, 0)
+	b.connections.Range(func(_, v interface{}) bool {
+		out = append(out, v.(*Connection).Stats())
+		return true
+	})
+	return out
+}
+
+// UpstreamURLOf 构建后端WebSocket地址：Scheme为http/ws时使用ws，https/wss时使用wss；
+// 请求Query参数及BackendService定义的参数，拼接到地址Query中
+func UpstreamURLOf(service flux.BackendService, inURL *url.URL, ctx flux.Context) (string, error) {
+	scheme := "ws"
+	switch strings.ToLower(service.Scheme) {
+	case "https", "wss":
+		scheme = "wss"
+	}
+	query := inURL.Query()
+	for _, arg := range service.Arguments {
+		value, err := arg.Resolve(ctx)
+		if nil != err {
+			return "", fmt.Errorf("resolve argument: %s, err: %w", arg.Name, err)
+		}
+		if nil != value {
+			query.Set(arg.Name, cast.ToString(value))
+		}
+	}
+	u := &url.URL{
+		Scheme:   scheme,
+		Host:     service.RemoteHost,
+		Path:     service.Interface,
+		RawQuery: query.Encode(),
+	}
+	return u.String(), nil
+}
+
+// UpstreamHeaderOf 返回转发到后端的握手请求Header；排除WebSocket握手相关Header，并附加请求ID
+func UpstreamHeaderOf(in http.Header, ctx flux.Context) http.Header {
+	header := make(http.Header, len(in)+1)
+	for k, vs := range in {
+		if handshakeHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		header[k] = append([]string(nil), vs...)
+	}
+	header.Set(flux.XRequestId, ctx.RequestId())
+	return header
+}
+
+func newUpgrader(readBufferSize, writeBufferSize int, origins []string) *gorilla.Upgrader {
+	upgrader := &gorilla.Upgrader{
+		ReadBufferSize:  readBufferSize,
+		WriteBufferSize: writeBufferSize,
+	}
+	// 未配置时CheckOrigin为nil，使用gorilla默认的同源检查
+	if len(origins) == 0 {
+		return upgrader
+	}
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[strings.ToLower(o)] = true
+	}
+	upgrader.CheckOrigin = func(r *http.Request) bool {
+		// 非浏览器客户端不发送Origin，与gorilla默认检查一致，允许握手
+		origin := r.Header.Get(flux.HeaderOrigin)
+		return "" == origin || allowed["*"] || allowed[strings.ToLower(origin)]
+	}
+	return upgrader
+}
+
+func newNotSupportedError(err error) *flux.ServeError {
+	return &flux.ServeError{
+		StatusCode: flux.StatusServerError,
+		ErrorCode:  flux.ErrorCodeGatewayInternal,
+		Message:    flux.ErrorMessageWebSocketNotSupported,
+		Internal:   err,
+	}
+}
@@ -0,0 +1,205 @@
+package websocket
+
+import (
+	"context"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/server"
+	"github.com/bytepowered/flux/webecho"
+	gorilla "github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	ext.StoreLoggerFactory(func(context.Context) flux.Logger {
+		return logger.SimpleLogger()
+	})
+}
+
+// newTestUpstream 回显消息的后端WebSocket服务
+func newTestUpstream() *httptest.Server {
+	upgrader := gorilla.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if nil != err {
+			return
+		}
+		defer conn.Close()
+		prefix := r.Header.Get(flux.XRequestId) + ":" + r.URL.Query().Get("room") + ":"
+		for {
+			mt, data, err := conn.ReadMessage()
+			if nil != err {
+				return
+			}
+			if err := conn.WriteMessage(mt, append([]byte(prefix), data...)); nil != err {
+				return
+			}
+		}
+	}))
+}
+
+func newTestGateway(transport *BackendTransportService, upstream string, serrs chan<- *flux.ServeError) *httptest.Server {
+	endpoint := &flux.Endpoint{
+		HttpMethod:  http.MethodGet,
+		HttpPattern: "/ws",
+		Service: flux.BackendService{
+			Scheme:     "http",
+			RemoteHost: strings.TrimPrefix(upstream, "http://"),
+			Interface:  "/chat",
+		},
+	}
+	e := echo.New()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var webc flux.WebContext
+		_ = webecho.RepeatableBodyReader(func(c echo.Context) error {
+			webc = webecho.NewAdaptWebContext(c, webecho.DefaultRequestBodyDecoder)
+			return nil
+		})(e.NewContext(r, w))
+		ctx := server.DefaultContextFactory().(*server.DefaultContext)
+		ctx.Reattach("req-001", webc, endpoint)
+		serr := transport.Exchange(ctx)
+		// 与Server一致：Exchange返回后即回收Context
+		ctx.Release()
+		if nil != serr {
+			w.WriteHeader(serr.StatusCode)
+		}
+		serrs <- serr
+	}))
+}
+
+// waitConnections 等待活跃连接数量变为期望值
+func waitConnections(transport *BackendTransportService, expected int) bool {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if len(transport.Connections()) == expected {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestBackendTransportService_Exchange(t *testing.T) {
+	upstream := newTestUpstream()
+	defer upstream.Close()
+	transport := NewBackendTransportService()
+	assert := assert2.New(t)
+	assert.Nil(transport.Init(flux.NewConfiguration(nil)))
+	serrs := make(chan *flux.ServeError, 1)
+	gateway := newTestGateway(transport, upstream.URL, serrs)
+	defer gateway.Close()
+	client, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(gateway.URL, "http")+"/ws?room=r1", nil)
+	assert.Nil(err)
+	// 握手完成后Exchange即返回，不阻塞过滤器链
+	select {
+	case serr := <-serrs:
+		assert.Nil(serr)
+	case <-time.After(time.Second):
+		assert.Fail("exchange blocked by relay")
+	}
+	for _, msg := range []string{"hello", "world"} {
+		assert.Nil(client.WriteMessage(gorilla.TextMessage, []byte(msg)))
+		_, data, err := client.ReadMessage()
+		assert.Nil(err)
+		assert.Equal("req-001:r1:"+msg, string(data))
+	}
+	stats := transport.Connections()
+	assert.Equal(1, len(stats))
+	assert.Equal(int64(2), stats[0].UpstreamMessages)
+	assert.Equal(int64(10), stats[0].UpstreamBytes)
+	assert.Equal(int64(2), stats[0].DownstreamMessages)
+	// 客户端关闭后，转发结束
+	assert.Nil(client.WriteMessage(gorilla.CloseMessage, gorilla.FormatCloseMessage(gorilla.CloseNormalClosure, "")))
+	assert.True(waitConnections(transport, 0), "relay not finished")
+}
+
+func TestBackendTransportService_IdleTimeout(t *testing.T) {
+	upstream := newTestUpstream()
+	defer upstream.Close()
+	transport := NewBackendTransportService()
+	config := flux.NewConfiguration(nil)
+	config.Set(ConfigKeyIdleTimeout, "50ms")
+	assert := assert2.New(t)
+	assert.Nil(transport.Init(config))
+	serrs := make(chan *flux.ServeError, 1)
+	gateway := newTestGateway(transport, upstream.URL, serrs)
+	defer gateway.Close()
+	client, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(gateway.URL, "http")+"/ws", nil)
+	assert.Nil(err)
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = client.ReadMessage()
+	assert.True(gorilla.IsCloseError(err, gorilla.CloseGoingAway), "error: %v", err)
+	assert.Nil(<-serrs)
+	assert.True(waitConnections(transport, 0), "relay not finished")
+}
+
+func TestBackendTransportService_NotUpgrade(t *testing.T) {
+	upstream := newTestUpstream()
+	defer upstream.Close()
+	transport := NewBackendTransportService()
+	serrs := make(chan *flux.ServeError, 1)
+	gateway := newTestGateway(transport, upstream.URL, serrs)
+	defer gateway.Close()
+	resp, err := http.Get(gateway.URL + "/ws")
+	assert := assert2.New(t)
+	assert.Nil(err)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	serr := <-serrs
+	assert.Equal(flux.ErrorMessageWebSocketNotSupported, serr.Message)
+}
+
+func TestNewUpgrader_CheckOrigin(t *testing.T) {
+	cases := []struct {
+		origins []string
+		origin  string
+		allowed bool
+	}{
+		{origins: nil, origin: "http://gateway.com", allowed: true},
+		{origins: nil, origin: "http://evil.com", allowed: false},
+		{origins: nil, origin: "", allowed: true},
+		{origins: []string{"*"}, origin: "http://evil.com", allowed: true},
+		{origins: []string{"http://app.com"}, origin: "http://APP.com", allowed: true},
+		{origins: []string{"http://app.com"}, origin: "http://evil.com", allowed: false},
+		{origins: []string{"http://app.com"}, origin: "", allowed: true},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		upgrader := newUpgrader(1024, 1024, tcase.origins)
+		req := httptest.NewRequest(http.MethodGet, "http://gateway.com/ws", nil)
+		if "" != tcase.origin {
+			req.Header.Set(flux.HeaderOrigin, tcase.origin)
+		}
+		rec := httptest.NewRecorder()
+		req.Header.Set("Connection", "upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-Websocket-Version", "13")
+		req.Header.Set("Sec-Websocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		_, err := upgrader.Upgrade(rec, req, nil)
+		// httptest.ResponseRecorder不支持Hijack：通过Origin检查后返回Hijack错误，而不是403
+		assert.Equal(tcase.allowed, http.StatusForbidden != rec.Code, "origins: %v, origin: %s, err: %v", tcase.origins, tcase.origin, err)
+	}
+}
+
+func TestUpstreamHeaderOf(t *testing.T) {
+	in := http.Header{}
+	in.Set("Authorization", "Bearer token")
+	in.Set("Sec-WebSocket-Key", "key")
+	in.Set("Sec-WebSocket-Protocol", "chat")
+	in.Set("Upgrade", "websocket")
+	ctx := server.DefaultContextFactory().(*server.DefaultContext)
+	ctx.Reattach("req-002", webecho.NewAdaptWebContext(echo.New().NewContext(httptest.NewRequest("GET", "/", nil), nil), nil), &flux.Endpoint{})
+	out := UpstreamHeaderOf(in, ctx)
+	assert := assert2.New(t)
+	assert.Equal("Bearer token", out.Get("Authorization"))
+	assert.Equal("chat", out.Get("Sec-WebSocket-Protocol"))
+	assert.Equal("", out.Get("Sec-WebSocket-Key"))
+	assert.Equal("", out.Get("Upgrade"))
+	assert.Equal("req-002", out.Get(flux.XRequestId))
+}
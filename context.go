@@ -18,6 +18,11 @@ const (
 	XJwtToken     = "X-Jwt-Token"
 )
 
+const (
+	// ValueKeyResponseHijacked 请求连接已被BackendTransport接管（如WebSocket），Server不再写入响应数据
+	ValueKeyResponseHijacked = "$flux.response.hijacked"
+)
+
 // Request 定义请求参数读取接口
 type RequestReader interface {
 	// Method 返回请求的HttpMethod
@@ -36,6 +41,12 @@ type RequestReader interface {
 	// 注意：部分Web框架返回只读url.URL
 	RequestURL() (url *url.URL, writable bool)
 
+	// RemoteAddr 返回请求连接的远程地址，格式为 ip:port
+	RemoteAddr() string
+
+	// ClientIP 返回客户端IP；请求经过可信代理时，从X-Forwarded-For解析客户端的真实IP
+	ClientIP() string
+
 	// RequestBodyReader 返回可重复读取的Reader接口；
 	RequestBodyReader() (io.ReadCloser, error)
 
@@ -74,6 +85,9 @@ type RequestReader interface {
 	CookieValue(name string) (cookie *http.Cookie, ok bool)
 }
 
+// ClientIPResolveFunc 根据请求连接的远程地址和请求Header，解析客户端IP
+type ClientIPResolveFunc func(remoteAddr string, header http.Header) string
+
 // ResponseWriter 是写入响应数据的接口
 type ResponseWriter interface {
 	// SetStatusCode 设置Http响应状态码
@@ -182,6 +196,18 @@ type Context interface {
 	GetLogger() Logger
 }
 
+// WebContextHolder 可选接口：返回请求关联的WebContext；
+// 用于需要直接操作Http连接的BackendTransport，如WebSocket
+type WebContextHolder interface {
+	WebContext() WebContext
+}
+
+// ContextSnapshotter 可选接口：复制请求数据，返回独立超时的Context；
+// 请求结束并回收Context后，仍可在后台安全地使用，如异步刷新缓存；timeout不大于0时不设超时
+type ContextSnapshotter interface {
+	Snapshot(timeout time.Duration) (Context, context.CancelFunc)
+}
+
 // Metrics 请求路由的的统计数据
 type Metric struct {
 	Name    string        `json:"name"`
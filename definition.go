@@ -59,10 +59,38 @@ const (
 
 // Support protocols
 const (
-	ProtoDubbo = "DUBBO"
-	ProtoGRPC  = "GRPC"
-	ProtoHttp  = "HTTP"
-	ProtoEcho  = "ECHO"
+	ProtoDubbo     = "DUBBO"
+	ProtoGRPC      = "GRPC"
+	ProtoHttp      = "HTTP"
+	ProtoEcho      = "ECHO"
+	ProtoMock      = "MOCK"
+	ProtoComposite = "COMPOSITE"
+	ProtoWebSocket = "WEBSOCKET"
+	ProtoJsonRpc   = "JSONRPC"
+	ProtoLocal     = "LOCAL"
+)
+
+// Endpoint扩展属性
+const (
+	EndpointExtKeyRequestStreaming  = "request-streaming"  // 请求Body以流式转发到后端，不缓存
+	EndpointExtKeyRequestBodyLimit  = "request-body-limit" // 请求Body大小限制，例如 500M；覆盖全局配置 body-limit
+	EndpointExtKeyResponseStreaming = "response-streaming" // 响应Body以流式输出到客户端，不缓存
+	EndpointExtKeyGraphQLField      = "graphql-field"      // GraphQL字段名称；BackendService配置此属性时，作为GraphQL字段开放
+	EndpointExtKeyGraphQLOperation  = "graphql-operation"  // GraphQL操作类型：query/mutation；默认GET为query，其它为mutation
+	EndpointExtKeyGraphQLDisabled   = "graphql-disabled"   // 不作为GraphQL字段开放
+	EndpointExtKeyMirrorService     = "mirror-service"     // 流量镜像的影子BackendService的ServiceId
+	EndpointExtKeyMirrorPercent     = "mirror-percent"     // 流量镜像的采样百分比：0-100；默认100
+	EndpointExtKeyMirrorTimeout     = "mirror-timeout"     // 影子服务调用超时；覆盖全局配置 mirror-timeout
+	EndpointExtKeyMirrorDiff        = "mirror-diff"        // 是否比较主服务与影子服务的响应状态码和Body
+	EndpointExtKeyRateLimit         = "ratelimit-limit"    // 限流：时间窗口内允许的请求数量；覆盖RateLimitFilter配置
+	EndpointExtKeyRateLimitWindow   = "ratelimit-window"   // 限流：时间窗口，例如 1s、1m；覆盖RateLimitFilter配置
+	EndpointExtKeyResponseCacheTTL  = "response-cache-ttl" // 响应缓存时长，例如 1h；0s表示不缓存；覆盖ResponseCacheFilter配置
+	EndpointExtKeyIPAccessRule      = "ipaccess-rule"      // IP访问控制：引用的命名规则IPAccessRule的名称
+	EndpointExtKeyIPAccessAllow     = "ipaccess-allow"     // IP访问控制：允许访问的CIDR列表
+	EndpointExtKeyIPAccessDeny      = "ipaccess-deny"      // IP访问控制：拒绝访问的CIDR列表
+	EndpointExtKeyRequestSchema     = "request-schema"     // 请求Body的JSON Schema：JSON对象或JSON文本
+	EndpointExtKeyResponseSchema    = "response-schema"    // 响应Body的JSON Schema：JSON对象或JSON文本
+	EndpointExtKeySchemaMode        = "schema-mode"        // 响应Body校验失败的处理方式：off/log/reject；覆盖JsonSchemaFilter配置
 )
 
 // ServiceAttributes
@@ -95,12 +123,41 @@ type Argument struct {
 	HttpName  string     `json:"httpName"`  // 映射Http的参数Key
 	HttpScope string     `json:"httpScope"` // 映射Http参数值域
 	Fields    []Argument `json:"fields"`    // 子结构字段
+	// 参数校验规则
+	Validation ArgumentValidation `json:"validation"`
 	// helper
 	ValueLoader   func() MTValue     `json:"-"`
 	LookupFunc    ArgumentLookupFunc `json:"-"`
 	ValueResolver MTValueResolver    `json:"-"`
 }
 
+// 参数校验的格式类型
+const (
+	ArgumentFormatEmail = "email"
+	ArgumentFormatPhone = "phone"
+	ArgumentFormatDate  = "date"
+)
+
+// ArgumentValidation 定义参数的校验规则；未设置的规则不校验
+type ArgumentValidation struct {
+	Required  bool          `json:"required"`  // 参数值不能为空
+	Default   interface{}   `json:"default"`   // 参数值为空时的默认值
+	Min       *float64      `json:"min"`       // 数值的最小值
+	Max       *float64      `json:"max"`       // 数值的最大值
+	MinLength *int          `json:"minLength"` // 字符串或列表的最小长度
+	MaxLength *int          `json:"maxLength"` // 字符串或列表的最大长度
+	Pattern   string        `json:"pattern"`   // 字符串的正则表达式
+	Enum      []interface{} `json:"enum"`      // 枚举值列表
+	Format    string        `json:"format"`    // 字符串格式：email, phone, date
+}
+
+// ArgumentFieldError 定义参数校验失败的字段错误
+type ArgumentFieldError struct {
+	Field   string `json:"field"`   // 参数名称；子结构字段以 . 连接
+	Rule    string `json:"rule"`    // 校验失败的规则
+	Message string `json:"message"` // 错误描述
+}
+
 // Attribute 定义服务的属性信息
 type Attribute struct {
 	Tag   uint8       `json:"tag"`
@@ -183,6 +240,15 @@ func (e EmbeddedExtensions) ExtInt(name string) int {
 	}
 }
 
+func (e EmbeddedExtensions) ExtStringSlice(name string) []string {
+	v, ok := e.Extensions[name]
+	if ok {
+		return cast.ToStringSlice(v)
+	} else {
+		return nil
+	}
+}
+
 // BackendService 定义连接上游目标服务的信息
 type BackendService struct {
 	AliasId    string     `json:"aliasId"`    // Service别名
@@ -278,3 +344,47 @@ type BackendServiceEvent struct {
 	EventType EventType
 	Service   BackendService
 }
+
+// UpstreamHost 定义上游集群中的服务地址及权重
+type UpstreamHost struct {
+	Address string `json:"address"` // 服务地址：host:port
+	Weight  int    `json:"weight"`  // 权重；小于等于0时按1处理
+}
+
+// UpstreamCluster 定义命名的上游服务集群；BackendService.RemoteHost 可指向集群名称
+type UpstreamCluster struct {
+	Name       string         `json:"name"`       // 集群名称
+	Balancer   string         `json:"balancer"`   // 负载均衡策略
+	HashLookup string         `json:"hashLookup"` // 一致性Hash策略的Lookup表达式
+	Hosts      []UpstreamHost `json:"hosts"`      // 服务地址列表
+}
+
+func (c UpstreamCluster) IsValid() bool {
+	return "" != c.Name && len(c.Hosts) > 0
+}
+
+// UpstreamClusterEvent 定义从注册中心接收到的上游集群数据变更
+type UpstreamClusterEvent struct {
+	EventType EventType
+	Cluster   UpstreamCluster
+}
+
+// IPAccessRuleGlobal 全局IP访问规则的名称；作用于全部Endpoint
+const IPAccessRuleGlobal = "global"
+
+// IPAccessRule 定义命名的IP访问规则；Deny优先于Allow，Allow为空时不限制
+type IPAccessRule struct {
+	Name  string   `json:"name"`  // 规则名称
+	Allow []string `json:"allow"` // 允许访问的CIDR列表
+	Deny  []string `json:"deny"`  // 拒绝访问的CIDR列表
+}
+
+func (r IPAccessRule) IsValid() bool {
+	return "" != r.Name
+}
+
+// IPAccessRuleEvent 定义从注册中心接收到的IP访问规则数据变更
+type IPAccessRuleEvent struct {
+	EventType EventType
+	Rule      IPAccessRule
+}
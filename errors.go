@@ -1,15 +1,24 @@
 package flux
 
-import "net/http"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
 
 const (
 	ErrorCodeGatewayInternal  = "GATEWAY:INTERNAL"
 	ErrorCodeGatewayBackend   = "GATEWAY:BACKEND"
 	ErrorCodeGatewayEndpoint  = "GATEWAY:ENDPOINT"
 	ErrorCodeGatewayCircuited = "GATEWAY:CIRCUITED"
+	ErrorCodeGatewayOverload  = "GATEWAY:OVERLOAD"
 	ErrorCodeRequestInvalid   = "REQUEST:INVALID"
 	ErrorCodeRequestNotFound  = "REQUEST:NOT_FOUND"
+	ErrorCodeRequestTooLarge  = "REQUEST:TOO_LARGE"
 	ErrorCodePermissionDenied = "PERMISSION:ACCESS_DENIED"
+	ErrorCodeJwtInvalid       = "JWT:INVALID"
+	ErrorCodeRateLimited      = "REQUEST:RATE_LIMITED"
+	ErrorCodeIPAccessDenied   = "REQUEST:IP_ACCESS_DENIED"
 )
 
 const (
@@ -23,19 +32,78 @@ const (
 
 	ErrorMessageHttpInvokeFailed   = "BACKEND:HT:INVOKE"
 	ErrorMessageHttpAssembleFailed = "BACKEND:HT:ASSEMBLE"
+	ErrorMessageHttpNoUpstreamHost = "BACKEND:HT:NO_UPSTREAM_HOST"
+
+	ErrorMessageGrpcInvokeFailed   = "BACKEND:GR:INVOKE"
+	ErrorMessageGrpcAssembleFailed = "BACKEND:GR:ASSEMBLE"
+	ErrorMessageGrpcMethodNotFound = "BACKEND:GR:METHOD_NOT_FOUND"
+
+	ErrorMessageMockNotFound      = "BACKEND:MO:NOT_FOUND"
+	ErrorMessageMockRenderFailed  = "BACKEND:MO:RENDER"
+	ErrorMessageMockInjectedError = "BACKEND:MO:INJECTED_ERROR"
+
+	ErrorMessageCompositeInvalidDefinition = "BACKEND:CO:INVALID_DEFINITION"
+	ErrorMessageCompositeServiceNotFound   = "BACKEND:CO:SERVICE_NOT_FOUND"
+	ErrorMessageCompositeCallFailed        = "BACKEND:CO:CALL_FAILED"
+	ErrorMessageCompositeRenderFailed      = "BACKEND:CO:RENDER"
+
+	ErrorMessageWebSocketNotSupported  = "BACKEND:WS:NOT_SUPPORTED"
+	ErrorMessageWebSocketDialFailed    = "BACKEND:WS:DIAL"
+	ErrorMessageWebSocketUpgradeFailed = "BACKEND:WS:UPGRADE"
+
+	ErrorMessageJsonRpcInvokeFailed   = "BACKEND:JR:INVOKE"
+	ErrorMessageJsonRpcAssembleFailed = "BACKEND:JR:ASSEMBLE"
+	ErrorMessageJsonRpcDecodeFailed   = "BACKEND:JR:DECODE"
+
+	ErrorMessageLocalHandlerNotFound = "BACKEND:LO:NOT_FOUND"
+	ErrorMessageLocalAssembleFailed  = "BACKEND:LO:ASSEMBLE"
+	ErrorMessageLocalHandlerPanic    = "BACKEND:LO:PANIC"
 
 	ErrorMessageHystrixCircuited = "HYSTRIX:CIRCUITED"
 
+	ErrorMessageConcurrencyLimited = "CONCURRENCY:LIMITED"
+
 	ErrorMessagePermissionAccessDenied    = "PERMISSION:ACCESS_DENIED"
 	ErrorMessagePermissionServiceNotFound = "PERMISSION:SERVICE:NOT_FOUND"
 	ErrorMessagePermissionVerifyError     = "PERMISSION:VERIFY:ERROR"
 
+	ErrorMessageJwtMissingToken  = "JWT:TOKEN:MISSING"
+	ErrorMessageJwtMalformed     = "JWT:TOKEN:MALFORMED"
+	ErrorMessageJwtSignature     = "JWT:TOKEN:SIGNATURE"
+	ErrorMessageJwtExpired       = "JWT:TOKEN:EXPIRED"
+	ErrorMessageJwtNotValidYet   = "JWT:TOKEN:NOT_VALID_YET"
+	ErrorMessageJwtAudience      = "JWT:TOKEN:AUDIENCE"
+	ErrorMessageJwtKeyNotFound   = "JWT:KEY:NOT_FOUND"
+	ErrorMessageJwtKeyLoadFailed = "JWT:KEY:LOAD"
+
+	ErrorMessageRateLimitExceeded = "RATELIMIT:EXCEEDED"
+
+	ErrorMessageIPAccessDenied = "IPACCESS:DENIED"
+
+	ErrorMessageArgumentValidateFailed = "ARGUMENT:VALIDATE:FAILED"
+
+	ErrorMessageSchemaRequestInvalid  = "SCHEMA:REQUEST:INVALID"
+	ErrorMessageSchemaResponseInvalid = "SCHEMA:RESPONSE:INVALID"
+	ErrorMessageSchemaCompileFailed   = "SCHEMA:COMPILE:FAILED"
+
+	ErrorMessageGraphQLInvalidRequest = "GRAPHQL:INVALID_REQUEST"
+	ErrorMessageGraphQLSchemaInvalid  = "GRAPHQL:SCHEMA:INVALID"
+	ErrorMessageGraphQLResolvePanic   = "GRAPHQL:RESOLVE:PANIC"
+
 	ErrorMessageEndpointVersionNotFound  = "ENDPOINT:VERSION:NOT_FOUND"
 	ErrorMessageWebServerResponseMarshal = "SERVER:RESPONSE:MARSHAL"
 	ErrorMessageWebServerRequestNotFound = "SERVER:REQUEST:NOT_FOUND"
 
-	ErrorMessageRequestPrepare = "REQUEST:BODY:PREPARE"
-	ErrorMessageRequestParsing = "REQUEST:BODY:PARSING"
+	ErrorMessageRequestPrepare  = "REQUEST:BODY:PREPARE"
+	ErrorMessageRequestParsing  = "REQUEST:BODY:PARSING"
+	ErrorMessageRequestTooLarge = "REQUEST:BODY:TOO_LARGE"
+)
+
+var (
+	// ErrRequestBodyTooLarge 读取请求Body时，超过Body大小限制
+	ErrRequestBodyTooLarge = errors.New("request body too large")
+	// ErrRequestBodyConsumed 流式请求Body已被读取，无法再次读取
+	ErrRequestBodyConsumed = errors.New("request body already consumed by streaming")
 )
 
 var (
@@ -45,3 +113,13 @@ var (
 		Message:    ErrorMessageWebServerRequestNotFound,
 	}
 )
+
+// NewRequestTooLargeError 返回请求Body超过大小限制的错误
+func NewRequestTooLargeError(limit int64, err error) *ServeError {
+	return &ServeError{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		ErrorCode:  ErrorCodeRequestTooLarge,
+		Message:    ErrorMessageRequestTooLarge,
+		Internal:   fmt.Errorf("request body limit: %d bytes, err: %w", limit, err),
+	}
+}
@@ -0,0 +1,27 @@
+package ext
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/pkg"
+)
+
+// 客户端IP解析函数；默认返回请求连接的远程IP，不信任任何代理Header
+var (
+	clientIPResolveFunc flux.ClientIPResolveFunc = func(remoteAddr string, _ http.Header) string {
+		if host, _, err := net.SplitHostPort(remoteAddr); nil == err {
+			return host
+		}
+		return remoteAddr
+	}
+)
+
+func StoreClientIPResolveFunc(f flux.ClientIPResolveFunc) {
+	clientIPResolveFunc = pkg.RequireNotNil(f, "ClientIPResolveFunc is nil").(flux.ClientIPResolveFunc)
+}
+
+func LoadClientIPResolveFunc() flux.ClientIPResolveFunc {
+	return clientIPResolveFunc
+}
@@ -0,0 +1,42 @@
+package ext
+
+import (
+	"sync"
+
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/pkg"
+)
+
+var (
+	clustersMap = new(sync.Map)
+)
+
+// StoreUpstreamCluster store upstream cluster; 每次更新均保存为新的实例
+func StoreUpstreamCluster(cluster flux.UpstreamCluster) {
+	name := pkg.RequireNotEmpty(cluster.Name, "UpstreamCluster name is empty")
+	clustersMap.Store(name, &cluster)
+}
+
+// LoadUpstreamCluster load upstream cluster by name; 返回的实例为只读
+func LoadUpstreamCluster(name string) (*flux.UpstreamCluster, bool) {
+	v, ok := clustersMap.Load(name)
+	if ok {
+		return v.(*flux.UpstreamCluster), true
+	}
+	return nil, false
+}
+
+// LoadUpstreamClusters load all upstream clusters
+func LoadUpstreamClusters() map[string]flux.UpstreamCluster {
+	out := make(map[string]flux.UpstreamCluster, 8)
+	clustersMap.Range(func(key, value interface{}) bool {
+		out[key.(string)] = *(value.(*flux.UpstreamCluster))
+		return true
+	})
+	return out
+}
+
+// RemoveUpstreamCluster remove upstream cluster by name
+func RemoveUpstreamCluster(name string) {
+	clustersMap.Delete(name)
+}
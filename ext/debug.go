@@ -0,0 +1,37 @@
+package ext
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/bytepowered/flux/pkg"
+)
+
+type (
+	// DebugQueryFunc Debug服务的查询函数；返回值将被序列化为JSON响应
+	DebugQueryFunc func(request *http.Request) interface{}
+)
+
+var (
+	debugQueryFuncs    = make(map[string]DebugQueryFunc, 8)
+	debugQueryFuncLock sync.RWMutex
+)
+
+// StoreDebugQueryFunc 注册Debug服务的查询接口；仅在开启Debug特性时生效
+func StoreDebugQueryFunc(pattern string, f DebugQueryFunc) {
+	pattern = pkg.RequireNotEmpty(pattern, "DebugQueryFunc pattern is empty")
+	debugQueryFuncLock.Lock()
+	defer debugQueryFuncLock.Unlock()
+	debugQueryFuncs[pattern] = pkg.RequireNotNil(f, "DebugQueryFunc is nil").(DebugQueryFunc)
+}
+
+// LoadDebugQueryFuncs 获取已注册的Debug服务查询接口
+func LoadDebugQueryFuncs() map[string]DebugQueryFunc {
+	debugQueryFuncLock.RLock()
+	defer debugQueryFuncLock.RUnlock()
+	out := make(map[string]DebugQueryFunc, len(debugQueryFuncs))
+	for k, v := range debugQueryFuncs {
+		out[k] = v
+	}
+	return out
+}
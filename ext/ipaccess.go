@@ -0,0 +1,42 @@
+package ext
+
+import (
+	"sync"
+
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/pkg"
+)
+
+var (
+	ipAccessRulesMap = new(sync.Map)
+)
+
+// StoreIPAccessRule store ip access rule; 每次更新均保存为新的实例
+func StoreIPAccessRule(rule flux.IPAccessRule) {
+	name := pkg.RequireNotEmpty(rule.Name, "IPAccessRule name is empty")
+	ipAccessRulesMap.Store(name, &rule)
+}
+
+// LoadIPAccessRule load ip access rule by name; 返回的实例为只读
+func LoadIPAccessRule(name string) (*flux.IPAccessRule, bool) {
+	v, ok := ipAccessRulesMap.Load(name)
+	if ok {
+		return v.(*flux.IPAccessRule), true
+	}
+	return nil, false
+}
+
+// LoadIPAccessRules load all ip access rules
+func LoadIPAccessRules() map[string]flux.IPAccessRule {
+	out := make(map[string]flux.IPAccessRule, 8)
+	ipAccessRulesMap.Range(func(key, value interface{}) bool {
+		out[key.(string)] = *(value.(*flux.IPAccessRule))
+		return true
+	})
+	return out
+}
+
+// RemoveIPAccessRule remove ip access rule by name
+func RemoveIPAccessRule(name string) {
+	ipAccessRulesMap.Delete(name)
+}
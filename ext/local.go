@@ -0,0 +1,53 @@
+package ext
+
+import (
+	"sync"
+
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/pkg"
+)
+
+var (
+	localHandlers    = make(map[string]flux.LocalHandlerFunc, 8)
+	localHandlerLock sync.RWMutex
+)
+
+// StoreLocalHandler 注册进程内后端服务处理函数；LOCAL协议的BackendService通过Interface和Method绑定处理函数
+func StoreLocalHandler(iface, method string, handler flux.LocalHandlerFunc) {
+	key := LocalHandlerKey(
+		pkg.RequireNotEmpty(iface, "LocalHandler interface is empty"),
+		pkg.RequireNotEmpty(method, "LocalHandler method is empty"))
+	localHandlerLock.Lock()
+	defer localHandlerLock.Unlock()
+	localHandlers[key] = pkg.RequireNotNil(handler, "LocalHandlerFunc is nil").(flux.LocalHandlerFunc)
+}
+
+// LoadLocalHandler 根据Interface和Method查找进程内后端服务处理函数
+func LoadLocalHandler(iface, method string) (flux.LocalHandlerFunc, bool) {
+	localHandlerLock.RLock()
+	defer localHandlerLock.RUnlock()
+	handler, ok := localHandlers[LocalHandlerKey(iface, method)]
+	return handler, ok
+}
+
+// LoadLocalHandlers 获取已注册的进程内后端服务处理函数，Key为 Interface:Method
+func LoadLocalHandlers() map[string]flux.LocalHandlerFunc {
+	localHandlerLock.RLock()
+	defer localHandlerLock.RUnlock()
+	out := make(map[string]flux.LocalHandlerFunc, len(localHandlers))
+	for k, v := range localHandlers {
+		out[k] = v
+	}
+	return out
+}
+
+// RemoveLocalHandler 删除进程内后端服务处理函数
+func RemoveLocalHandler(iface, method string) {
+	localHandlerLock.Lock()
+	defer localHandlerLock.Unlock()
+	delete(localHandlers, LocalHandlerKey(iface, method))
+}
+
+func LocalHandlerKey(iface, method string) string {
+	return iface + ":" + method
+}
@@ -31,6 +31,16 @@ func LoadBackendService(serviceID string) (flux.BackendService, bool) {
 	return serviceNotFound, false
 }
 
+// LoadBackendServices load all backend services, key by serviceId
+func LoadBackendServices() map[string]flux.BackendService {
+	out := make(map[string]flux.BackendService, 16)
+	servicesMap.Range(func(key, value interface{}) bool {
+		out[key.(string)] = value.(flux.BackendService)
+		return true
+	})
+	return out
+}
+
 // RemoveBackendService remove backend service by serviceId
 func RemoveBackendService(serviceID string) {
 	servicesMap.Delete(serviceID)
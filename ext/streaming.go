@@ -0,0 +1,61 @@
+package ext
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bytepowered/flux"
+)
+
+// 流式响应判定配置；网关输出响应与后端Transport读取响应使用相同的判定规则
+var (
+	// 按Content-Type判定为流式响应的MIME类型；以 / 结尾表示匹配主类型
+	streamingContentTypes = []string{
+		"text/event-stream",
+		"application/octet-stream",
+		"application/x-ndjson",
+		"application/stream+json",
+		"multipart/x-mixed-replace",
+		"video/",
+		"audio/",
+	}
+	// Content-Length超过此值时判定为流式响应；0表示不按长度判定
+	streamingContentLength int64 = 1 << 20
+)
+
+func StoreStreamingContentTypes(ctypes []string) {
+	streamingContentTypes = ctypes
+}
+
+func LoadStreamingContentTypes() []string {
+	return streamingContentTypes
+}
+
+func StoreStreamingContentLength(length int64) {
+	streamingContentLength = length
+}
+
+func LoadStreamingContentLength() int64 {
+	return streamingContentLength
+}
+
+// IsStreamingContent 按响应Header判定是否为流式数据：Content-Type为流式类型，或者Content-Length超过阈值
+func IsStreamingContent(header http.Header) bool {
+	if ctype := header.Get(flux.HeaderContentType); "" != ctype {
+		if media, _, err := mime.ParseMediaType(ctype); nil == err {
+			for _, t := range streamingContentTypes {
+				if media == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(media, t)) {
+					return true
+				}
+			}
+		}
+	}
+	if streamingContentLength > 0 {
+		if length, err := strconv.ParseInt(header.Get(flux.HeaderContentLength), 10, 64); nil == err {
+			return length > streamingContentLength
+		}
+	}
+	return false
+}
@@ -14,6 +14,7 @@ type ServeError struct {
 	ErrorCode  interface{}            // 业务错误码
 	Header     http.Header            // 响应Header
 	Internal   error                  // 内部错误对象；错误对象不会被输出到请求端；
+	Details    interface{}            // 错误详情，例如参数校验的字段错误；输出到请求端；
 	ExtraTrace map[string]interface{} // 用于定义和跟踪的额外信息；额外信息不会被输出到请求端；
 }
 
@@ -66,3 +67,8 @@ type Filter interface {
 	// DoFilter 执行Filter链
 	DoFilter(next FilterHandler) FilterHandler
 }
+
+// EndpointListener Filter的可选扩展接口，接收Endpoint元数据变更事件，用于预处理或清理Endpoint相关的资源
+type EndpointListener interface {
+	OnHttpEndpointEvent(event HttpEndpointEvent)
+}
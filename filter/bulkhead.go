@@ -0,0 +1,184 @@
+package filter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	ConcurrencyAlgorithmAIMD     = "aimd"
+	ConcurrencyAlgorithmGradient = "gradient"
+)
+
+// ConcurrencyLimitAlgorithm 根据请求的响应时间和失败情况，计算新的并发限制
+type ConcurrencyLimitAlgorithm interface {
+	// Update 返回新的并发限制；rtt为请求响应时间，inflight为请求开始时的并发数量，dropped表示请求超时或过载失败
+	Update(limit float64, rtt time.Duration, inflight int, dropped bool) float64
+}
+
+// AIMDLimit 加性增、乘性减：请求失败或响应时间超过阈值时，按比例降低并发限制；并发限制被充分使用时，每次增加1
+type AIMDLimit struct {
+	BackoffRatio float64
+	Threshold    time.Duration
+}
+
+func (a *AIMDLimit) Update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	if dropped || (a.Threshold > 0 && rtt > a.Threshold) {
+		return limit * a.BackoffRatio
+	}
+	if float64(inflight)*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// GradientLimit 梯度算法：以长期平均响应时间与当前响应时间的比值作为梯度，响应时间上升时收缩并发限制；
+// 新的限制值为 limit*gradient + sqrt(limit)，按smoothing比例平滑更新。
+type GradientLimit struct {
+	Window    int     // 长期平均响应时间的样本窗口
+	Tolerance float64 // 允许当前响应时间超过长期平均值的倍数
+	Smoothing float64 // 平滑系数：0-1
+	longRtt   float64
+	samples   int
+}
+
+func (g *GradientLimit) Update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	short := float64(rtt)
+	if short <= 0 {
+		return limit
+	}
+	if g.samples < g.Window {
+		g.samples++
+	}
+	if 0 == g.longRtt {
+		g.longRtt = short
+	} else {
+		g.longRtt += (short - g.longRtt) / float64(g.samples)
+	}
+	// 长期平均值远大于当前值时，加速回落，避免负载下降后仍保持较高的基准
+	if g.longRtt/short > 2 {
+		g.longRtt *= 0.95
+	}
+	// 并发限制未被充分使用时，不增加限制
+	if !dropped && float64(inflight)*2 < limit {
+		return limit
+	}
+	gradient := math.Max(0.5, math.Min(1.0, g.Tolerance*g.longRtt/short))
+	if dropped {
+		gradient = 0.5
+	}
+	next := limit*gradient + math.Sqrt(limit)
+	return limit*(1-g.Smoothing) + next*g.Smoothing
+}
+
+// Bulkhead 单个后端服务的并发隔离舱：并发数量超过限制时，请求短暂排队等待，队列已满或等待超时则拒绝
+type Bulkhead struct {
+	algorithm ConcurrencyLimitAlgorithm
+	limit     float64
+	minLimit  float64
+	maxLimit  float64
+	queueSize int
+	inflight  int
+	waiters   []chan struct{}
+	observer  func(limit float64, inflight, queued int)
+	mu        sync.Mutex
+}
+
+func NewBulkhead(algorithm ConcurrencyLimitAlgorithm, initial, min, max, queueSize int) *Bulkhead {
+	return &Bulkhead{
+		algorithm: algorithm,
+		limit:     float64(initial),
+		minLimit:  float64(min),
+		maxLimit:  float64(max),
+		queueSize: queueSize,
+		waiters:   make([]chan struct{}, 0, queueSize),
+		observer:  func(float64, int, int) {},
+	}
+}
+
+// Acquire 获取并发配额；获取成功时返回释放函数，调用方在请求完成后必须调用
+func (b *Bulkhead) Acquire(ctx context.Context, wait time.Duration) (release func(rtt time.Duration, dropped bool), ok bool) {
+	b.mu.Lock()
+	if b.inflight < b.capacity() {
+		b.inflight++
+		release = b.releaser(b.inflight)
+		b.notify()
+		b.mu.Unlock()
+		return release, true
+	}
+	if wait <= 0 || len(b.waiters) >= b.queueSize {
+		b.mu.Unlock()
+		return nil, false
+	}
+	ch := make(chan struct{}, 1)
+	b.waiters = append(b.waiters, ch)
+	b.notify()
+	b.mu.Unlock()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ch:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, w := range b.waiters {
+		if w == ch {
+			b.waiters = append(b.waiters[:i], b.waiters[i+1:]...)
+			b.notify()
+			return nil, false
+		}
+	}
+	// 已被唤醒，配额已在唤醒前分配
+	return b.releaser(b.inflight), true
+}
+
+// Limit 返回当前并发限制
+func (b *Bulkhead) Limit() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.capacity()
+}
+
+// Inflight 返回当前并发数量
+func (b *Bulkhead) Inflight() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inflight
+}
+
+// capacity 返回当前并发限制；调用方须持有锁
+func (b *Bulkhead) capacity() int {
+	return int(b.limit)
+}
+
+func (b *Bulkhead) releaser(inflight int) func(time.Duration, bool) {
+	var once sync.Once
+	return func(rtt time.Duration, dropped bool) {
+		once.Do(func() {
+			b.release(rtt, inflight, dropped)
+		})
+	}
+}
+
+func (b *Bulkhead) release(rtt time.Duration, inflight int, dropped bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inflight--
+	b.limit = math.Max(b.minLimit, math.Min(b.maxLimit, b.algorithm.Update(b.limit, rtt, inflight, dropped)))
+	// 唤醒排队等待的请求；配额在唤醒前分配
+	for len(b.waiters) > 0 && b.inflight < b.capacity() {
+		ch := b.waiters[0]
+		b.waiters = b.waiters[1:]
+		b.inflight++
+		ch <- struct{}{}
+	}
+	b.notify()
+}
+
+func (b *Bulkhead) notify() {
+	b.observer(b.limit, b.inflight, len(b.waiters))
+}
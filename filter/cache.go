@@ -0,0 +1,105 @@
+package filter
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRUCache 限制容量的LRU缓存，每个缓存项有独立的过期时间；
+// 过期的缓存项不会主动删除，由调用方决定是否使用过期数据。
+type LRUCache struct {
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+	mu    sync.Mutex
+}
+
+type lruEntry struct {
+	key      string
+	value    interface{}
+	expireAt time.Time
+}
+
+func NewLRUCache(size int) *LRUCache {
+	if size <= 0 {
+		size = 1024
+	}
+	return &LRUCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+// Load 获取未过期的缓存值
+func (c *LRUCache) Load(key string) (interface{}, bool) {
+	value, expireAt, ok := c.Get(key)
+	if !ok || time.Now().After(expireAt) {
+		return nil, false
+	}
+	return value, true
+}
+
+// Get 获取缓存值及其过期时间，包括已过期的缓存项
+func (c *LRUCache) Get(key string) (value interface{}, expireAt time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, hit := c.items[key]; hit {
+		c.ll.MoveToFront(e)
+		entry := e.Value.(*lruEntry)
+		return entry.value, entry.expireAt, true
+	}
+	return nil, time.Time{}, false
+}
+
+// Store 设置缓存值；超过容量时淘汰最久未使用的缓存项
+func (c *LRUCache) Store(key string, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expireAt := time.Now().Add(ttl)
+	if e, hit := c.items[key]; hit {
+		c.ll.MoveToFront(e)
+		entry := e.Value.(*lruEntry)
+		entry.value, entry.expireAt = value, expireAt
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value, expireAt: expireAt})
+	for c.ll.Len() > c.size {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		delete(c.items, last.Value.(*lruEntry).key)
+	}
+}
+
+// Remove 删除缓存项
+func (c *LRUCache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, hit := c.items[key]; hit {
+		c.ll.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+// RemoveIf 删除满足条件的缓存项，返回删除的数量
+func (c *LRUCache) RemoveIf(match func(key string) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for key, e := range c.items {
+		if match(key) {
+			c.ll.Remove(e)
+			delete(c.items, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Len 返回缓存项数量
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
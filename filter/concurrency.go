@@ -0,0 +1,226 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeIdConcurrencyLimitFilter = "ConcurrencyLimitFilter"
+)
+
+// 并发限制配置
+const (
+	ConcurrencyConfigKeyAlgorithm         = "concurrency-algorithm"
+	ConcurrencyConfigKeyInitialLimit      = "concurrency-initial-limit"
+	ConcurrencyConfigKeyMinLimit          = "concurrency-min-limit"
+	ConcurrencyConfigKeyMaxLimit          = "concurrency-max-limit"
+	ConcurrencyConfigKeyQueueSize         = "concurrency-queue-size"
+	ConcurrencyConfigKeyQueueTimeout      = "concurrency-queue-timeout"
+	ConcurrencyConfigKeyBackoffRatio      = "concurrency-aimd-backoff-ratio"
+	ConcurrencyConfigKeyLatencyThreshold  = "concurrency-aimd-latency-threshold"
+	ConcurrencyConfigKeyGradientWindow    = "concurrency-gradient-window"
+	ConcurrencyConfigKeyGradientTolerance = "concurrency-gradient-tolerance"
+	ConcurrencyConfigKeyGradientSmoothing = "concurrency-gradient-smoothing"
+)
+
+var concurrencyMetrics = newConcurrencyMetrics()
+
+// ConcurrencyMetrics 后端服务并发限制的统计指标
+type ConcurrencyMetrics struct {
+	Limit    *prometheus.GaugeVec
+	Inflight *prometheus.GaugeVec
+	Queued   *prometheus.GaugeVec
+	Shed     *prometheus.CounterVec
+}
+
+func newConcurrencyMetrics() *ConcurrencyMetrics {
+	return &ConcurrencyMetrics{
+		Limit: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "flux",
+			Subsystem: "concurrency",
+			Name:      "limit",
+			Help:      "Current concurrency limit of backend service",
+		}, []string{"ServiceId"}),
+		Inflight: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "flux",
+			Subsystem: "concurrency",
+			Name:      "inflight",
+			Help:      "Number of in-flight requests of backend service",
+		}, []string{"ServiceId"}),
+		Queued: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "flux",
+			Subsystem: "concurrency",
+			Name:      "queued",
+			Help:      "Number of requests waiting for backend service",
+		}, []string{"ServiceId"}),
+		Shed: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "flux",
+			Subsystem: "concurrency",
+			Name:      "shed_total",
+			Help:      "Number of requests rejected by concurrency limit",
+		}, []string{"ServiceId"}),
+	}
+}
+
+func init() {
+	ext.StoreTypedFactory(TypeIdConcurrencyLimitFilter, func() interface{} {
+		return NewConcurrencyLimitFilter()
+	})
+}
+
+// ConcurrencyLimitFilter 按BackendService.ServiceID()隔离的自适应并发限制：
+// 每个后端服务有独立的并发隔离舱，根据请求响应时间和过载失败，使用AIMD或梯度算法调整并发限制；
+// 超过限制的请求短暂排队等待，队列已满或等待超时则返回503。
+type ConcurrencyLimitFilter struct {
+	Disabled     bool
+	SkipFunc     flux.FilterSkipper
+	DroppedFunc  func(ctx flux.Context, serr *flux.ServeError) bool
+	newAlgorithm func() ConcurrencyLimitAlgorithm
+	initialLimit int
+	minLimit     int
+	maxLimit     int
+	queueSize    int
+	queueTimeout time.Duration
+	bulkheads    sync.Map
+}
+
+func NewConcurrencyLimitFilter() *ConcurrencyLimitFilter {
+	return &ConcurrencyLimitFilter{}
+}
+
+func (c *ConcurrencyLimitFilter) TypeId() string {
+	return TypeIdConcurrencyLimitFilter
+}
+
+func (c *ConcurrencyLimitFilter) Init(config *flux.Configuration) error {
+	logger.Info("ConcurrencyLimit filter initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConcurrencyConfigKeyAlgorithm:         ConcurrencyAlgorithmAIMD,
+		ConcurrencyConfigKeyInitialLimit:      20,
+		ConcurrencyConfigKeyMinLimit:          1,
+		ConcurrencyConfigKeyMaxLimit:          200,
+		ConcurrencyConfigKeyQueueSize:         16,
+		ConcurrencyConfigKeyQueueTimeout:      "20ms",
+		ConcurrencyConfigKeyBackoffRatio:      0.9,
+		ConcurrencyConfigKeyLatencyThreshold:  "2s",
+		ConcurrencyConfigKeyGradientWindow:    100,
+		ConcurrencyConfigKeyGradientTolerance: 1.5,
+		ConcurrencyConfigKeyGradientSmoothing: 0.2,
+		ConfigKeyDisabled:                     false,
+	})
+	c.Disabled = config.GetBool(ConfigKeyDisabled)
+	if c.Disabled {
+		logger.Info("ConcurrencyLimit filter was DISABLED!!")
+		return nil
+	}
+	if nil == c.SkipFunc {
+		c.SkipFunc = func(_ flux.Context) bool {
+			return false
+		}
+	}
+	if nil == c.DroppedFunc {
+		c.DroppedFunc = DefaultConcurrencyDroppedFunc
+	}
+	c.initialLimit = config.GetInt(ConcurrencyConfigKeyInitialLimit)
+	c.minLimit = config.GetInt(ConcurrencyConfigKeyMinLimit)
+	c.maxLimit = config.GetInt(ConcurrencyConfigKeyMaxLimit)
+	if c.minLimit <= 0 || c.minLimit > c.initialLimit || c.initialLimit > c.maxLimit {
+		return fmt.Errorf("ConcurrencyLimitFilter requires 0 < %s <= %s <= %s",
+			ConcurrencyConfigKeyMinLimit, ConcurrencyConfigKeyInitialLimit, ConcurrencyConfigKeyMaxLimit)
+	}
+	c.queueSize = config.GetInt(ConcurrencyConfigKeyQueueSize)
+	c.queueTimeout = config.GetDuration(ConcurrencyConfigKeyQueueTimeout)
+	switch algorithm := strings.ToLower(config.GetString(ConcurrencyConfigKeyAlgorithm)); algorithm {
+	case ConcurrencyAlgorithmAIMD:
+		ratio := config.GetFloat64(ConcurrencyConfigKeyBackoffRatio)
+		threshold := config.GetDuration(ConcurrencyConfigKeyLatencyThreshold)
+		c.newAlgorithm = func() ConcurrencyLimitAlgorithm {
+			return &AIMDLimit{BackoffRatio: ratio, Threshold: threshold}
+		}
+	case ConcurrencyAlgorithmGradient:
+		window := config.GetInt(ConcurrencyConfigKeyGradientWindow)
+		tolerance := config.GetFloat64(ConcurrencyConfigKeyGradientTolerance)
+		smoothing := config.GetFloat64(ConcurrencyConfigKeyGradientSmoothing)
+		c.newAlgorithm = func() ConcurrencyLimitAlgorithm {
+			return &GradientLimit{Window: window, Tolerance: tolerance, Smoothing: smoothing}
+		}
+	default:
+		return fmt.Errorf("ConcurrencyLimitFilter unsupported algorithm: %s", algorithm)
+	}
+	return nil
+}
+
+func (c *ConcurrencyLimitFilter) DoFilter(next flux.FilterHandler) flux.FilterHandler {
+	if c.Disabled {
+		return next
+	}
+	return func(ctx flux.Context) *flux.ServeError {
+		if c.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		serviceId := ctx.Service().ServiceID()
+		bulkhead := c.BulkheadOf(serviceId)
+		release, ok := bulkhead.Acquire(ctx.Context(), c.queueTimeout)
+		ctx.AddMetric("M-"+c.TypeId(), ctx.ElapsedTime())
+		if !ok {
+			concurrencyMetrics.Shed.WithLabelValues(serviceId).Inc()
+			return &flux.ServeError{
+				StatusCode: flux.StatusServiceUnavailable,
+				ErrorCode:  flux.ErrorCodeGatewayOverload,
+				Message:    flux.ErrorMessageConcurrencyLimited,
+				Internal:   fmt.Errorf("concurrency limit exceeded, service: %s, limit: %d", serviceId, bulkhead.Limit()),
+			}
+		}
+		start := time.Now()
+		// 后续处理发生panic时，同样释放并发许可，并视为请求被丢弃
+		dropped := true
+		defer func() {
+			release(time.Since(start), dropped)
+		}()
+		serr := next(ctx)
+		dropped = c.DroppedFunc(ctx, serr)
+		return serr
+	}
+}
+
+// BulkheadOf 返回后端服务的并发隔离舱
+func (c *ConcurrencyLimitFilter) BulkheadOf(serviceId string) *Bulkhead {
+	if v, ok := c.bulkheads.Load(serviceId); ok {
+		return v.(*Bulkhead)
+	}
+	bulkhead := NewBulkhead(c.newAlgorithm(), c.initialLimit, c.minLimit, c.maxLimit, c.queueSize)
+	limit, inflight, queued := concurrencyMetrics.Limit.WithLabelValues(serviceId),
+		concurrencyMetrics.Inflight.WithLabelValues(serviceId), concurrencyMetrics.Queued.WithLabelValues(serviceId)
+	bulkhead.observer = func(l float64, i, q int) {
+		limit.Set(l)
+		inflight.Set(float64(i))
+		queued.Set(float64(q))
+	}
+	actual, loaded := c.bulkheads.LoadOrStore(serviceId, bulkhead)
+	if !loaded {
+		limit.Set(float64(c.initialLimit))
+	}
+	return actual.(*Bulkhead)
+}
+
+// DefaultConcurrencyDroppedFunc 请求超时、后端不可用或网关超时，视为后端服务过载
+func DefaultConcurrencyDroppedFunc(ctx flux.Context, serr *flux.ServeError) bool {
+	if nil == serr {
+		return false
+	}
+	switch serr.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return nil != ctx.Context().Err()
+	}
+}
@@ -0,0 +1,149 @@
+package filter
+
+import (
+	"context"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/support"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	assert2 "github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAIMDLimit_Update(t *testing.T) {
+	aimd := &AIMDLimit{BackoffRatio: 0.5, Threshold: time.Second}
+	cases := []struct {
+		rtt      time.Duration
+		inflight int
+		dropped  bool
+		expected float64
+	}{
+		{rtt: time.Millisecond, inflight: 5, expected: 11},
+		{rtt: time.Millisecond, inflight: 2, expected: 10},
+		{rtt: time.Millisecond, inflight: 10, dropped: true, expected: 5},
+		{rtt: 2 * time.Second, inflight: 10, expected: 5},
+	}
+	for _, tcase := range cases {
+		assert2.Equal(t, tcase.expected, aimd.Update(10, tcase.rtt, tcase.inflight, tcase.dropped))
+	}
+}
+
+func TestGradientLimit_Update(t *testing.T) {
+	assert := assert2.New(t)
+	gradient := &GradientLimit{Window: 10, Tolerance: 1.5, Smoothing: 0.5}
+	limit := 16.0
+	for i := 0; i < 10; i++ {
+		limit = gradient.Update(limit, 10*time.Millisecond, int(limit), false)
+	}
+	// 响应时间稳定时，限制增长
+	assert.True(limit > 16)
+	grown := limit
+	for i := 0; i < 5; i++ {
+		limit = gradient.Update(limit, 100*time.Millisecond, int(limit), false)
+	}
+	// 响应时间上升时，限制收缩
+	assert.True(limit < grown)
+	// 未充分使用时，不增加限制
+	assert.Equal(limit, gradient.Update(limit, 10*time.Millisecond, 1, false))
+}
+
+func TestBulkhead_Acquire(t *testing.T) {
+	assert := assert2.New(t)
+	bulkhead := NewBulkhead(&AIMDLimit{BackoffRatio: 0.5}, 2, 1, 4, 1)
+	r1, ok := bulkhead.Acquire(context.Background(), 0)
+	assert.True(ok)
+	_, ok = bulkhead.Acquire(context.Background(), 0)
+	assert.True(ok)
+	// 无等待时间，直接拒绝
+	_, ok = bulkhead.Acquire(context.Background(), 0)
+	assert.False(ok)
+	// 排队等待超时
+	_, ok = bulkhead.Acquire(context.Background(), 10*time.Millisecond)
+	assert.False(ok)
+	// 排队等待，释放后获得配额
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r3, ok := bulkhead.Acquire(context.Background(), time.Second)
+		assert.True(ok)
+		r3(time.Millisecond, false)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	// 队列已满
+	_, ok = bulkhead.Acquire(context.Background(), time.Second)
+	assert.False(ok)
+	r1(time.Millisecond, false)
+	r1(time.Millisecond, false)
+	wg.Wait()
+	assert.Equal(1, bulkhead.Inflight())
+	assert.Equal(4, bulkhead.Limit())
+}
+
+func TestConcurrencyLimitFilter_DoFilter(t *testing.T) {
+	assert := assert2.New(t)
+	config := flux.NewConfiguration(nil)
+	config.Set(ConcurrencyConfigKeyInitialLimit, 1)
+	config.Set(ConcurrencyConfigKeyQueueSize, 0)
+	filter := NewConcurrencyLimitFilter()
+	assert.Nil(filter.Init(config))
+	newContext := func(method string) flux.Context {
+		return support.NewValuesContext(map[string]interface{}{
+			"service": flux.BackendService{Interface: "test.ConcurrencyService", Method: method},
+		})
+	}
+	entered, done := make(chan struct{}), make(chan struct{})
+	slow := filter.DoFilter(func(flux.Context) *flux.ServeError {
+		close(entered)
+		<-done
+		return &flux.ServeError{StatusCode: flux.StatusServiceUnavailable}
+	})
+	fast := filter.DoFilter(func(flux.Context) *flux.ServeError {
+		return nil
+	})
+	go func() {
+		_ = slow(newContext("slow"))
+	}()
+	<-entered
+	shed := testutil.ToFloat64(concurrencyMetrics.Shed.WithLabelValues("test.ConcurrencyService:slow"))
+	assert.Equal(float64(1), testutil.ToFloat64(concurrencyMetrics.Inflight.WithLabelValues("test.ConcurrencyService:slow")))
+	serr := fast(newContext("slow"))
+	assert.NotNil(serr)
+	assert.Equal(flux.StatusServiceUnavailable, serr.StatusCode)
+	assert.Equal(flux.ErrorCodeGatewayOverload, serr.ErrorCode)
+	assert.Equal(shed+1, testutil.ToFloat64(concurrencyMetrics.Shed.WithLabelValues("test.ConcurrencyService:slow")))
+	// 不同服务的隔离舱互不影响
+	assert.Nil(fast(newContext("fast")))
+	close(done)
+	for i := 0; i < 100 && filter.BulkheadOf("test.ConcurrencyService:slow").Inflight() > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(float64(0), testutil.ToFloat64(concurrencyMetrics.Inflight.WithLabelValues("test.ConcurrencyService:slow")))
+	assert.Equal(float64(2), testutil.ToFloat64(concurrencyMetrics.Limit.WithLabelValues("test.ConcurrencyService:fast")))
+}
+
+func TestConcurrencyLimitFilter_Panic(t *testing.T) {
+	assert := assert2.New(t)
+	config := flux.NewConfiguration(nil)
+	config.Set(ConcurrencyConfigKeyInitialLimit, 2)
+	config.Set(ConcurrencyConfigKeyQueueSize, 0)
+	filter := NewConcurrencyLimitFilter()
+	assert.Nil(filter.Init(config))
+	ctx := support.NewValuesContext(map[string]interface{}{
+		"service": flux.BackendService{Interface: "test.ConcurrencyService", Method: "panic"},
+	})
+	panics := filter.DoFilter(func(flux.Context) *flux.ServeError {
+		panic("test panic")
+	})
+	assert.Panics(func() {
+		_ = panics(ctx)
+	})
+	// panic后释放并发许可，并视为请求被丢弃，降低并发上限
+	bulkhead := filter.BulkheadOf("test.ConcurrencyService:panic")
+	assert.Equal(0, bulkhead.Inflight())
+	assert.Equal(1, bulkhead.Limit())
+	assert.Nil(filter.DoFilter(func(flux.Context) *flux.ServeError {
+		return nil
+	})(ctx))
+}
@@ -0,0 +1,236 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/support"
+	"github.com/spf13/cast"
+	"net"
+	"strings"
+	"sync"
+)
+
+const (
+	TypeIdIPAccessFilter = "IPAccessFilter"
+)
+
+// IP访问控制配置
+const (
+	IPAccessConfigKeyAllow = "ipaccess-allow"
+	IPAccessConfigKeyDeny  = "ipaccess-deny"
+	IPAccessConfigKeyRules = "ipaccess-rules"
+)
+
+func init() {
+	ext.StoreTypedFactory(TypeIdIPAccessFilter, func() interface{} {
+		return NewIPAccessFilter()
+	})
+}
+
+// IPAccessFilter 根据客户端IP进行访问控制。访问规则包括：
+// 1. 全局规则：名称为 global 的IPAccessRule，作用于全部Endpoint；
+// 2. Endpoint规则：扩展属性 ipaccess-rule 引用的命名规则，以及扩展属性 ipaccess-allow/ipaccess-deny 定义的CIDR列表；
+// 命名规则可来自过滤器配置或注册中心，注册中心的规则变更实时生效；注册中心删除规则后，恢复使用过滤器配置的同名规则。
+// Endpoint引用的命名规则不存在时，拒绝访问。
+type IPAccessFilter struct {
+	Disabled bool
+	SkipFunc flux.FilterSkipper
+	// 过滤器配置的命名规则，与注册中心的规则分开保存
+	configRules map[string]*flux.IPAccessRule
+	// 命名规则的解析结果，按规则名称缓存；规则更新后为新的实例，重新解析
+	rules *sync.Map
+	// Endpoint扩展属性定义的CIDR列表的解析结果
+	inlines *LRUCache
+}
+
+type ipAccessRuleEntry struct {
+	rule     *flux.IPAccessRule
+	compiled *ipAccessCompiled
+}
+
+type ipAccessCompiled struct {
+	allow    []*net.IPNet
+	deny     []*net.IPNet
+	hasAllow bool
+}
+
+func NewIPAccessFilter() *IPAccessFilter {
+	return &IPAccessFilter{
+		rules:       new(sync.Map),
+		configRules: make(map[string]*flux.IPAccessRule),
+	}
+}
+
+func (f *IPAccessFilter) TypeId() string {
+	return TypeIdIPAccessFilter
+}
+
+func (f *IPAccessFilter) Init(config *flux.Configuration) error {
+	logger.Info("IPAccess filter initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyCacheSize: 1000,
+		ConfigKeyDisabled:  false,
+	})
+	f.Disabled = config.GetBool(ConfigKeyDisabled)
+	if f.Disabled {
+		logger.Info("IPAccess filter was DISABLED!!")
+		return nil
+	}
+	if nil == f.SkipFunc {
+		f.SkipFunc = func(_ flux.Context) bool {
+			return false
+		}
+	}
+	f.inlines = NewLRUCache(config.GetInt(ConfigKeyCacheSize))
+	// 配置的全局规则和命名规则；注册中心的同名规则优先
+	rules := make([]flux.IPAccessRule, 0, 4)
+	if config.IsSet(IPAccessConfigKeyAllow) || config.IsSet(IPAccessConfigKeyDeny) {
+		rules = append(rules, flux.IPAccessRule{
+			Name:  flux.IPAccessRuleGlobal,
+			Allow: config.GetStringSlice(IPAccessConfigKeyAllow),
+			Deny:  config.GetStringSlice(IPAccessConfigKeyDeny),
+		})
+	}
+	for name, v := range config.GetStringMap(IPAccessConfigKeyRules) {
+		values := cast.ToStringMap(v)
+		rules = append(rules, flux.IPAccessRule{
+			Name:  name,
+			Allow: cast.ToStringSlice(values["allow"]),
+			Deny:  cast.ToStringSlice(values["deny"]),
+		})
+	}
+	for _, rule := range rules {
+		for _, cidrs := range [][]string{rule.Allow, rule.Deny} {
+			if _, err := support.ParseCIDRs(cidrs); nil != err {
+				return fmt.Errorf("IPAccessFilter rule: %s, %w", rule.Name, err)
+			}
+		}
+		rule := rule
+		f.configRules[rule.Name] = &rule
+	}
+	return nil
+}
+
+func (f *IPAccessFilter) DoFilter(next flux.FilterHandler) flux.FilterHandler {
+	if f.Disabled {
+		return next
+	}
+	return func(ctx flux.Context) *flux.ServeError {
+		if f.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		clientIP := ctx.Request().ClientIP()
+		allowed := f.Allow(ctx.Endpoint(), net.ParseIP(clientIP))
+		ctx.AddMetric("M-"+f.TypeId(), ctx.ElapsedTime())
+		if !allowed {
+			return &flux.ServeError{
+				StatusCode: flux.StatusAccessDenied,
+				ErrorCode:  flux.ErrorCodeIPAccessDenied,
+				Message:    flux.ErrorMessageIPAccessDenied,
+				Internal:   fmt.Errorf("ip access denied, client-ip: %s", clientIP),
+			}
+		}
+		return next(ctx)
+	}
+}
+
+// Allow 判断客户端IP是否允许访问Endpoint：
+// 任意规则的Deny列表匹配时拒绝访问；Endpoint规则定义了Allow列表时，必须匹配Endpoint的Allow列表，否则必须匹配全局的Allow列表。
+func (f *IPAccessFilter) Allow(endpoint flux.Endpoint, ip net.IP) bool {
+	global := f.compiledRule(flux.IPAccessRuleGlobal)
+	scoped := make([]*ipAccessCompiled, 0, 2)
+	if name := endpoint.ExtString(flux.EndpointExtKeyIPAccessRule); "" != name {
+		c := f.compiledRule(name)
+		if nil == c {
+			logger.Warnw("IPAccess rule not found, access denied", "rule-name", name, "endpoint", endpoint.HttpPattern)
+			return false
+		}
+		scoped = append(scoped, c)
+	}
+	if c := f.compiledInline(endpoint); nil != c {
+		scoped = append(scoped, c)
+	}
+	for _, c := range append(scoped, global) {
+		if nil != c && support.ContainsIP(c.deny, ip) {
+			return false
+		}
+	}
+	scopedAllow := false
+	for _, c := range scoped {
+		if c.hasAllow {
+			scopedAllow = true
+			if support.ContainsIP(c.allow, ip) {
+				return true
+			}
+		}
+	}
+	if scopedAllow {
+		return false
+	}
+	if nil != global && global.hasAllow {
+		return support.ContainsIP(global.allow, ip)
+	}
+	return true
+}
+
+// compiledRule 返回命名规则的解析结果：注册中心的规则优先，其次为过滤器配置的规则
+func (f *IPAccessFilter) compiledRule(name string) *ipAccessCompiled {
+	rule, ok := ext.LoadIPAccessRule(name)
+	if !ok {
+		rule, ok = f.configRules[name]
+	}
+	if !ok {
+		f.rules.Delete(name)
+		return nil
+	}
+	if v, ok := f.rules.Load(name); ok && v.(*ipAccessRuleEntry).rule == rule {
+		return v.(*ipAccessRuleEntry).compiled
+	}
+	compiled := compileIPAccess(name, rule.Allow, rule.Deny)
+	f.rules.Store(name, &ipAccessRuleEntry{rule: rule, compiled: compiled})
+	return compiled
+}
+
+func (f *IPAccessFilter) compiledInline(endpoint flux.Endpoint) *ipAccessCompiled {
+	allow := endpoint.ExtStringSlice(flux.EndpointExtKeyIPAccessAllow)
+	deny := endpoint.ExtStringSlice(flux.EndpointExtKeyIPAccessDeny)
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+	key := strings.Join(allow, ",") + "|" + strings.Join(deny, ",")
+	if v, _, ok := f.inlines.Get(key); ok {
+		return v.(*ipAccessCompiled)
+	}
+	compiled := compileIPAccess(endpoint.HttpPattern, allow, deny)
+	// 解析结果不过期，由LRU容量淘汰
+	f.inlines.Store(key, compiled, 0)
+	return compiled
+}
+
+// compileIPAccess 解析CIDR列表；忽略无效的CIDR。Allow列表全部无效时，拒绝全部访问
+func compileIPAccess(name string, allow, deny []string) *ipAccessCompiled {
+	parse := func(cidrs []string) []*net.IPNet {
+		nets := make([]*net.IPNet, 0, len(cidrs))
+		for _, cidr := range cidrs {
+			for _, item := range strings.Split(cidr, ",") {
+				if "" == strings.TrimSpace(item) {
+					continue
+				}
+				parsed, err := support.ParseCIDRs([]string{item})
+				if nil != err {
+					logger.Warnw("IPAccess ignore invalid cidr", "rule", name, "error", err)
+					continue
+				}
+				nets = append(nets, parsed...)
+			}
+		}
+		return nets
+	}
+	return &ipAccessCompiled{
+		allow:    parse(allow),
+		deny:     parse(deny),
+		hasAllow: len(allow) > 0,
+	}
+}
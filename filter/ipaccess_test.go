@@ -0,0 +1,83 @@
+package filter
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestIPAccessFilter_Allow(t *testing.T) {
+	assert := assert2.New(t)
+	config := flux.NewConfiguration(nil)
+	config.Set(IPAccessConfigKeyAllow, []string{"10.0.0.0/8"})
+	config.Set(IPAccessConfigKeyDeny, []string{"10.0.0.66"})
+	config.Set(IPAccessConfigKeyRules, map[string]interface{}{
+		"partner": map[string]interface{}{"allow": []string{"8.8.8.0/24"}},
+	})
+	filter := NewIPAccessFilter()
+	assert.Nil(filter.Init(config))
+	endpoint := func(ext map[string]interface{}) flux.Endpoint {
+		e := flux.Endpoint{HttpMethod: "GET", HttpPattern: "/api/ip"}
+		e.Extensions = ext
+		return e
+	}
+	cases := []struct {
+		endpoint flux.Endpoint
+		ip       string
+		expect   bool
+	}{
+		{endpoint: endpoint(nil), ip: "10.1.2.3", expect: true},
+		{endpoint: endpoint(nil), ip: "10.0.0.66", expect: false},
+		{endpoint: endpoint(nil), ip: "8.8.8.8", expect: false},
+		{endpoint: endpoint(nil), ip: "invalid", expect: false},
+		// Endpoint引用的命名规则，覆盖全局Allow列表
+		{endpoint: endpoint(map[string]interface{}{flux.EndpointExtKeyIPAccessRule: "partner"}), ip: "8.8.8.8", expect: true},
+		{endpoint: endpoint(map[string]interface{}{flux.EndpointExtKeyIPAccessRule: "partner"}), ip: "10.1.2.3", expect: false},
+		// 引用的命名规则不存在时，拒绝访问
+		{endpoint: endpoint(map[string]interface{}{flux.EndpointExtKeyIPAccessRule: "missing"}), ip: "10.1.2.3", expect: false},
+		// 全局Deny列表仍然生效
+		{endpoint: endpoint(map[string]interface{}{flux.EndpointExtKeyIPAccessAllow: []interface{}{"10.0.0.0/24"}}), ip: "10.0.0.66", expect: false},
+		{endpoint: endpoint(map[string]interface{}{flux.EndpointExtKeyIPAccessDeny: "10.1.0.0/16, 10.2.0.0/16"}), ip: "10.2.3.4", expect: false},
+		{endpoint: endpoint(map[string]interface{}{flux.EndpointExtKeyIPAccessDeny: "10.1.0.0/16, 10.2.0.0/16"}), ip: "10.3.3.4", expect: true},
+	}
+	for _, tcase := range cases {
+		assert.Equal(tcase.expect, filter.Allow(tcase.endpoint, net.ParseIP(tcase.ip)), tcase.ip)
+	}
+	// 注册中心更新规则后实时生效
+	ext.StoreIPAccessRule(flux.IPAccessRule{Name: flux.IPAccessRuleGlobal, Deny: []string{"10.1.0.0/16"}})
+	assert.False(filter.Allow(endpoint(nil), net.ParseIP("10.1.2.3")))
+	assert.True(filter.Allow(endpoint(nil), net.ParseIP("8.8.8.8")))
+	// 注册中心删除规则后，恢复使用过滤器配置的规则
+	ext.RemoveIPAccessRule(flux.IPAccessRuleGlobal)
+	assert.True(filter.Allow(endpoint(nil), net.ParseIP("10.1.2.3")))
+	assert.False(filter.Allow(endpoint(nil), net.ParseIP("8.8.8.8")))
+	_, ok := ext.LoadIPAccessRule("partner")
+	assert.False(ok, "config rules should not be stored to registry rules")
+}
+
+func TestIPAccessFilter_DoFilter(t *testing.T) {
+	assert := assert2.New(t)
+	ext.StoreIPAccessRule(flux.IPAccessRule{Name: "office", Allow: []string{"192.168.0.0/16"}})
+	defer ext.RemoveIPAccessRule("office")
+	filter := NewIPAccessFilter()
+	assert.Nil(filter.Init(flux.NewConfiguration(nil)))
+	handler := filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+		return nil
+	})
+	endpoint := flux.Endpoint{HttpMethod: "GET", HttpPattern: "/api/office"}
+	endpoint.Extensions = map[string]interface{}{flux.EndpointExtKeyIPAccessRule: "office"}
+	for remote, allowed := range map[string]bool{"192.168.1.10:5000": true, "172.16.0.1:5000": false} {
+		values := support.NewValuesContext(map[string]interface{}{"remote-addr": remote}).(*support.ValuesContext)
+		serr := handler(&testEndpointContext{ValuesContext: values, endpoint: endpoint})
+		if allowed {
+			assert.Nil(serr, remote)
+		} else {
+			assert.NotNil(serr, remote)
+			assert.Equal(flux.StatusAccessDenied, serr.StatusCode)
+			assert.Equal(flux.ErrorCodeIPAccessDenied, serr.ErrorCode)
+		}
+	}
+}
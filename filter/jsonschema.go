@@ -0,0 +1,309 @@
+package filter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/pkg"
+	"github.com/xeipuuv/gojsonschema"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+const (
+	TypeIdJsonSchemaFilter = "JsonSchemaFilter"
+)
+
+// JSON Schema校验配置
+const (
+	JsonSchemaConfigKeyResponseMode = "schema-response-mode"
+	JsonSchemaConfigKeyMaxBodySize  = "schema-max-body-size"
+)
+
+// 响应Body校验失败的处理方式
+const (
+	JsonSchemaModeOff    = "off"
+	JsonSchemaModeLog    = "log"
+	JsonSchemaModeReject = "reject"
+)
+
+func init() {
+	ext.StoreTypedFactory(TypeIdJsonSchemaFilter, func() interface{} {
+		return NewJsonSchemaFilter()
+	})
+}
+
+var _ flux.EndpointListener = new(JsonSchemaFilter)
+
+// JsonSchemaFilter 按Endpoint扩展属性 request-schema/response-schema 定义的JSON Schema，校验请求Body和后端服务的响应Body；
+// Schema在Endpoint注册时编译并缓存。请求Body校验失败返回400；响应Body校验失败时按配置记录日志或返回502；
+// Schema编译失败时拒绝请求，返回500。流式请求（request-streaming）的Body不缓存，不校验请求Body。
+type JsonSchemaFilter struct {
+	Disabled     bool
+	SkipFunc     flux.FilterSkipper
+	responseMode string
+	maxBodySize  int64
+	schemas      sync.Map
+}
+
+// jsonSchemaEntry Endpoint编译后的Schema；未定义时为nil；err为Schema编译错误
+type jsonSchemaEntry struct {
+	request  *gojsonschema.Schema
+	response *gojsonschema.Schema
+	err      error
+}
+
+func NewJsonSchemaFilter() *JsonSchemaFilter {
+	return &JsonSchemaFilter{}
+}
+
+func (f *JsonSchemaFilter) TypeId() string {
+	return TypeIdJsonSchemaFilter
+}
+
+func (f *JsonSchemaFilter) Init(config *flux.Configuration) error {
+	logger.Info("JsonSchema filter initializing")
+	config.SetDefaults(map[string]interface{}{
+		JsonSchemaConfigKeyResponseMode: JsonSchemaModeOff,
+		JsonSchemaConfigKeyMaxBodySize:  "1M",
+		ConfigKeyDisabled:               false,
+	})
+	f.Disabled = config.GetBool(ConfigKeyDisabled)
+	if f.Disabled {
+		logger.Info("JsonSchema filter was DISABLED!!")
+		return nil
+	}
+	if nil == f.SkipFunc {
+		f.SkipFunc = func(_ flux.Context) bool {
+			return false
+		}
+	}
+	f.responseMode = strings.ToLower(config.GetString(JsonSchemaConfigKeyResponseMode))
+	if !isJsonSchemaMode(f.responseMode) {
+		return fmt.Errorf("JsonSchemaFilter unsupported response mode: %s", f.responseMode)
+	}
+	size, err := pkg.ParseByteSize(config.GetString(JsonSchemaConfigKeyMaxBodySize))
+	if nil != err {
+		return fmt.Errorf("JsonSchemaFilter config %s: %w", JsonSchemaConfigKeyMaxBodySize, err)
+	}
+	f.maxBodySize = size
+	return nil
+}
+
+// OnHttpEndpointEvent 在Endpoint注册或更新时编译Schema，删除时清除缓存
+func (f *JsonSchemaFilter) OnHttpEndpointEvent(event flux.HttpEndpointEvent) {
+	if f.Disabled {
+		return
+	}
+	key := jsonSchemaKeyOf(event.Endpoint)
+	if flux.EventTypeRemoved == event.EventType {
+		f.schemas.Delete(key)
+		return
+	}
+	f.schemas.Store(key, compileJsonSchemaEntry(event.Endpoint))
+}
+
+func (f *JsonSchemaFilter) DoFilter(next flux.FilterHandler) flux.FilterHandler {
+	if f.Disabled {
+		return next
+	}
+	return func(ctx flux.Context) *flux.ServeError {
+		if f.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		endpoint := ctx.Endpoint()
+		entry := f.entryOf(endpoint)
+		// Schema编译失败时拒绝请求，避免未经校验的请求到达后端服务
+		if nil != entry.err {
+			return &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  flux.ErrorCodeGatewayEndpoint,
+				Message:    flux.ErrorMessageSchemaCompileFailed,
+				Internal:   entry.err,
+			}
+		}
+		if nil == entry.request && nil == entry.response {
+			return next(ctx)
+		}
+		if nil != entry.request && !endpoint.ExtBool(flux.EndpointExtKeyRequestStreaming) {
+			serr := f.validateRequest(ctx, entry.request)
+			ctx.AddMetric("M-"+f.TypeId(), ctx.ElapsedTime())
+			if nil != serr {
+				return serr
+			}
+		}
+		serr := next(ctx)
+		if nil != serr || nil == entry.response {
+			return serr
+		}
+		mode := f.responseMode
+		if v := strings.ToLower(endpoint.ExtString(flux.EndpointExtKeySchemaMode)); isJsonSchemaMode(v) {
+			mode = v
+		}
+		if JsonSchemaModeOff == mode {
+			return nil
+		}
+		return f.validateResponse(ctx, entry.response, mode)
+	}
+}
+
+func (f *JsonSchemaFilter) validateRequest(ctx flux.Context, schema *gojsonschema.Schema) *flux.ServeError {
+	reader, err := ctx.Request().RequestBodyReader()
+	if nil != err {
+		return newJsonSchemaReadError(ctx, err)
+	}
+	var data []byte
+	if nil != reader {
+		data, err = ioutil.ReadAll(reader)
+		_ = reader.Close()
+		if nil != err {
+			return newJsonSchemaReadError(ctx, err)
+		}
+	}
+	if errs, err := validateJsonSchema(schema, data); nil != err || len(errs) > 0 {
+		return newJsonSchemaError(flux.StatusBadRequest, flux.ErrorCodeRequestInvalid, flux.ErrorMessageSchemaRequestInvalid, errs, err)
+	}
+	return nil
+}
+
+func (f *JsonSchemaFilter) validateResponse(ctx flux.Context, schema *gojsonschema.Schema, mode string) *flux.ServeError {
+	resp := ctx.Response()
+	var loader gojsonschema.JSONLoader
+	switch body := resp.Body().(type) {
+	case nil:
+		loader = gojsonschema.NewBytesLoader([]byte("null"))
+	case []byte:
+		loader = gojsonschema.NewBytesLoader(body)
+	case string:
+		loader = gojsonschema.NewStringLoader(body)
+	case io.Reader:
+		data, err := ioutil.ReadAll(io.LimitReader(body, f.maxBodySize+1))
+		if nil != err || int64(len(data)) > f.maxBodySize {
+			// 超过大小限制的Body不校验，继续读取剩余数据
+			resp.SetBody(&cacheBodyReader{Reader: io.MultiReader(bytes.NewReader(data), body), body: body})
+			return nil
+		}
+		if c, ok := body.(io.Closer); ok {
+			_ = c.Close()
+		}
+		resp.SetBody(bytes.NewReader(data))
+		loader = gojsonschema.NewBytesLoader(data)
+	default:
+		loader = gojsonschema.NewGoLoader(body)
+	}
+	errs, err := validateJsonSchemaLoader(schema, loader)
+	if nil == err && len(errs) == 0 {
+		return nil
+	}
+	logger.TraceContext(ctx).Warnw("JsonSchema response contract violation",
+		"endpoint", ctx.Endpoint().HttpPattern, "errors", errs, "error", err)
+	if JsonSchemaModeReject != mode {
+		return nil
+	}
+	return newJsonSchemaError(flux.StatusBadGateway, flux.ErrorCodeGatewayBackend, flux.ErrorMessageSchemaResponseInvalid, errs, err)
+}
+
+func (f *JsonSchemaFilter) entryOf(endpoint flux.Endpoint) *jsonSchemaEntry {
+	key := jsonSchemaKeyOf(endpoint)
+	if v, ok := f.schemas.Load(key); ok {
+		return v.(*jsonSchemaEntry)
+	}
+	// 未收到注册事件的Endpoint，首次请求时编译
+	entry := compileJsonSchemaEntry(endpoint)
+	f.schemas.Store(key, entry)
+	return entry
+}
+
+func jsonSchemaKeyOf(endpoint flux.Endpoint) string {
+	return strings.ToUpper(endpoint.HttpMethod) + ":" + endpoint.HttpPattern + "@" + endpoint.Version
+}
+
+func compileJsonSchemaEntry(endpoint flux.Endpoint) *jsonSchemaEntry {
+	entry := new(jsonSchemaEntry)
+	compile := func(name string) *gojsonschema.Schema {
+		v, ok := endpoint.Ext(name)
+		if !ok || nil == v {
+			return nil
+		}
+		var loader gojsonschema.JSONLoader
+		if text, ok := v.(string); ok {
+			loader = gojsonschema.NewStringLoader(text)
+		} else {
+			loader = gojsonschema.NewGoLoader(v)
+		}
+		schema, err := gojsonschema.NewSchema(loader)
+		if nil != err {
+			logger.Errorw("JsonSchema compile failed, reject requests",
+				"method", endpoint.HttpMethod, "pattern", endpoint.HttpPattern, "ext", name, "error", err)
+			entry.err = fmt.Errorf("compile json schema: %s, err: %w", name, err)
+			return nil
+		}
+		return schema
+	}
+	entry.request = compile(flux.EndpointExtKeyRequestSchema)
+	entry.response = compile(flux.EndpointExtKeyResponseSchema)
+	if nil != entry.request && endpoint.ExtBool(flux.EndpointExtKeyRequestStreaming) {
+		logger.Warnw("JsonSchema request body of streaming endpoint is not validated",
+			"method", endpoint.HttpMethod, "pattern", endpoint.HttpPattern)
+	}
+	return entry
+}
+
+// validateJsonSchema 校验JSON数据；空数据按null校验
+func validateJsonSchema(schema *gojsonschema.Schema, data []byte) ([]flux.ArgumentFieldError, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = []byte("null")
+	}
+	return validateJsonSchemaLoader(schema, gojsonschema.NewBytesLoader(data))
+}
+
+func validateJsonSchemaLoader(schema *gojsonschema.Schema, loader gojsonschema.JSONLoader) ([]flux.ArgumentFieldError, error) {
+	result, err := schema.Validate(loader)
+	if nil != err {
+		return nil, fmt.Errorf("invalid json document: %w", err)
+	}
+	errs := make([]flux.ArgumentFieldError, 0, len(result.Errors()))
+	for _, e := range result.Errors() {
+		errs = append(errs, flux.ArgumentFieldError{Field: e.Field(), Rule: e.Type(), Message: e.Description()})
+	}
+	return errs, nil
+}
+
+// newJsonSchemaReadError 读取请求Body失败；超过Body大小限制时返回413
+func newJsonSchemaReadError(ctx flux.Context, err error) *flux.ServeError {
+	if errors.Is(err, flux.ErrRequestBodyTooLarge) {
+		limit, _ := pkg.ParseByteSize(ctx.Endpoint().ExtString(flux.EndpointExtKeyRequestBodyLimit))
+		return flux.NewRequestTooLargeError(limit, err)
+	}
+	return &flux.ServeError{
+		StatusCode: flux.StatusBadRequest,
+		ErrorCode:  flux.ErrorCodeRequestInvalid,
+		Message:    flux.ErrorMessageSchemaRequestInvalid,
+		Internal:   err,
+	}
+}
+
+func newJsonSchemaError(status int, code, message string, errs []flux.ArgumentFieldError, err error) *flux.ServeError {
+	serr := &flux.ServeError{
+		StatusCode: status,
+		ErrorCode:  code,
+		Message:    message,
+		Internal:   err,
+	}
+	if len(errs) > 0 {
+		serr.Details = errs
+		if nil == serr.Internal {
+			serr.Internal = fmt.Errorf("json schema validation failed, errors: %d", len(errs))
+		}
+	}
+	return serr
+}
+
+func isJsonSchemaMode(mode string) bool {
+	return JsonSchemaModeOff == mode || JsonSchemaModeLog == mode || JsonSchemaModeReject == mode
+}
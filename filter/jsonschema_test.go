@@ -0,0 +1,217 @@
+package filter
+
+import (
+	"bytes"
+	"errors"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+const (
+	testRequestSchema = `{
+		"type": "object",
+		"required": ["name"],
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"age": {"type": "integer", "minimum": 0}
+		}
+	}`
+	testResponseSchema = `{
+		"type": "object",
+		"required": ["id"],
+		"properties": {"id": {"type": "integer"}}
+	}`
+)
+
+func newTestSchemaEndpoint(mode string) flux.Endpoint {
+	endpoint := flux.Endpoint{HttpMethod: "POST", HttpPattern: "/users", Version: "v1"}
+	endpoint.Extensions = map[string]interface{}{
+		flux.EndpointExtKeyRequestSchema:  testRequestSchema,
+		flux.EndpointExtKeyResponseSchema: testResponseSchema,
+		flux.EndpointExtKeySchemaMode:     mode,
+	}
+	return endpoint
+}
+
+func newTestSchemaContext(endpoint flux.Endpoint, body string) *testCacheContext {
+	values := map[string]interface{}{}
+	if "" != body {
+		values["body"] = ioutil.NopCloser(bytes.NewBufferString(body))
+	}
+	return &testCacheContext{
+		ValuesContext: support.NewValuesContext(values).(*support.ValuesContext),
+		endpoint:      endpoint,
+		response:      &testResponseWriter{headers: http.Header{}},
+	}
+}
+
+func TestJsonSchemaFilter_Request(t *testing.T) {
+	assert := assert2.New(t)
+	filter := NewJsonSchemaFilter()
+	assert.Nil(filter.Init(flux.NewConfiguration(nil)))
+	endpoint := newTestSchemaEndpoint("")
+	filter.OnHttpEndpointEvent(flux.HttpEndpointEvent{EventType: flux.EventTypeAdded, Endpoint: endpoint})
+	cases := []struct {
+		body   string
+		status int
+		fields []string
+	}{
+		{body: `{"name": "flux", "age": 1}`},
+		{body: `{"age": -1}`, status: flux.StatusBadRequest, fields: []string{"(root)", "age"}},
+		{body: `{"name": ""}`, status: flux.StatusBadRequest, fields: []string{"name"}},
+		{body: ``, status: flux.StatusBadRequest, fields: []string{"(root)"}},
+		{body: `{name`, status: flux.StatusBadRequest},
+	}
+	for _, tcase := range cases {
+		called := false
+		serr := filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+			called = true
+			return nil
+		})(newTestSchemaContext(endpoint, tcase.body))
+		if 0 == tcase.status {
+			assert.Nil(serr, tcase.body)
+			assert.True(called)
+			continue
+		}
+		assert.NotNil(serr, tcase.body)
+		assert.False(called)
+		assert.Equal(tcase.status, serr.StatusCode)
+		assert.Equal(flux.ErrorCodeRequestInvalid, serr.ErrorCode)
+		assert.Equal(flux.ErrorMessageSchemaRequestInvalid, serr.Message)
+		if len(tcase.fields) == 0 {
+			assert.Nil(serr.Details)
+			continue
+		}
+		fields := make([]string, 0)
+		for _, e := range serr.Details.([]flux.ArgumentFieldError) {
+			fields = append(fields, e.Field)
+		}
+		assert.ElementsMatch(tcase.fields, fields, tcase.body)
+	}
+}
+
+type testErrorBody struct {
+	err error
+}
+
+func (b *testErrorBody) Read([]byte) (int, error) {
+	return 0, b.err
+}
+
+func (b *testErrorBody) Close() error {
+	return nil
+}
+
+func TestJsonSchemaFilter_RequestBody(t *testing.T) {
+	assert := assert2.New(t)
+	filter := NewJsonSchemaFilter()
+	assert.Nil(filter.Init(flux.NewConfiguration(nil)))
+	next := func(ctx flux.Context) *flux.ServeError {
+		return nil
+	}
+	// 超过Body大小限制，返回413
+	endpoint := newTestSchemaEndpoint("")
+	endpoint.Extensions[flux.EndpointExtKeyRequestBodyLimit] = "1K"
+	ctx := newTestSchemaContext(endpoint, "")
+	ctx.SetValue("body", &testErrorBody{err: flux.ErrRequestBodyTooLarge})
+	serr := filter.DoFilter(next)(ctx)
+	assert.NotNil(serr)
+	assert.Equal(http.StatusRequestEntityTooLarge, serr.StatusCode)
+	assert.Equal(flux.ErrorCodeRequestTooLarge, serr.ErrorCode)
+	assert.True(errors.Is(serr.Internal, flux.ErrRequestBodyTooLarge))
+	// 流式请求不校验请求Body，Body保留给后端服务读取
+	streaming := newTestSchemaEndpoint("")
+	streaming.HttpPattern = "/users/streaming"
+	streaming.Extensions[flux.EndpointExtKeyRequestStreaming] = true
+	ctx = newTestSchemaContext(streaming, `{"age": -1}`)
+	assert.Nil(filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+		reader, err := ctx.Request().RequestBodyReader()
+		assert.Nil(err)
+		data, err := ioutil.ReadAll(reader)
+		assert.Nil(err)
+		assert.Equal(`{"age": -1}`, string(data))
+		return nil
+	})(ctx))
+}
+
+func TestJsonSchemaFilter_Response(t *testing.T) {
+	assert := assert2.New(t)
+	filter := NewJsonSchemaFilter()
+	assert.Nil(filter.Init(flux.NewConfiguration(nil)))
+	cases := []struct {
+		mode   string
+		body   interface{}
+		reject bool
+	}{
+		{mode: JsonSchemaModeReject, body: bytes.NewBufferString(`{"id": 1}`)},
+		{mode: JsonSchemaModeReject, body: map[string]interface{}{"id": 1}},
+		{mode: JsonSchemaModeReject, body: bytes.NewBufferString(`{"id": "1"}`), reject: true},
+		{mode: JsonSchemaModeReject, body: map[string]interface{}{"name": "flux"}, reject: true},
+		{mode: JsonSchemaModeLog, body: bytes.NewBufferString(`{"id": "1"}`)},
+		{mode: JsonSchemaModeOff, body: `{"id": "1"}`},
+		{mode: "", body: `{"id": "1"}`},
+	}
+	for _, tcase := range cases {
+		endpoint := newTestSchemaEndpoint(tcase.mode)
+		ctx := newTestSchemaContext(endpoint, `{"name": "flux"}`)
+		serr := filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+			ctx.Response().SetBody(tcase.body)
+			return nil
+		})(ctx)
+		if !tcase.reject {
+			assert.Nil(serr, tcase.mode)
+			continue
+		}
+		assert.NotNil(serr, tcase.mode)
+		assert.Equal(flux.StatusBadGateway, serr.StatusCode)
+		assert.Equal(flux.ErrorCodeGatewayBackend, serr.ErrorCode)
+		assert.Equal(flux.ErrorMessageSchemaResponseInvalid, serr.Message)
+		assert.NotEmpty(serr.Details)
+	}
+	// 校验后的响应Body可以继续读取
+	endpoint := newTestSchemaEndpoint(JsonSchemaModeLog)
+	ctx := newTestSchemaContext(endpoint, `{"name": "flux"}`)
+	assert.Nil(filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+		ctx.Response().SetBody(bytes.NewBufferString(`{"id": 1}`))
+		return nil
+	})(ctx))
+	assert.Equal(`{"id": 1}`, ctx.response.BodyString())
+}
+
+func TestJsonSchemaFilter_EndpointEvent(t *testing.T) {
+	assert := assert2.New(t)
+	filter := NewJsonSchemaFilter()
+	assert.Nil(filter.Init(flux.NewConfiguration(nil)))
+	endpoint := newTestSchemaEndpoint("")
+	filter.OnHttpEndpointEvent(flux.HttpEndpointEvent{EventType: flux.EventTypeAdded, Endpoint: endpoint})
+	entry := filter.entryOf(endpoint)
+	assert.NotNil(entry.request)
+	assert.NotNil(entry.response)
+	// 更新Endpoint时重新编译
+	updated := newTestSchemaEndpoint("")
+	updated.Extensions[flux.EndpointExtKeyResponseSchema] = `{"type": 1}`
+	filter.OnHttpEndpointEvent(flux.HttpEndpointEvent{EventType: flux.EventTypeUpdated, Endpoint: updated})
+	entry = filter.entryOf(endpoint)
+	assert.NotNil(entry.request)
+	assert.Nil(entry.response)
+	assert.NotNil(entry.err, "invalid schema should be reported")
+	// Schema编译失败时拒绝请求
+	called := false
+	serr := filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+		called = true
+		return nil
+	})(newTestSchemaContext(updated, `{"name": "flux"}`))
+	assert.False(called)
+	assert.NotNil(serr)
+	assert.Equal(flux.StatusServerError, serr.StatusCode)
+	assert.Equal(flux.ErrorCodeGatewayEndpoint, serr.ErrorCode)
+	assert.Equal(flux.ErrorMessageSchemaCompileFailed, serr.Message)
+	// 删除Endpoint时清除缓存
+	filter.OnHttpEndpointEvent(flux.HttpEndpointEvent{EventType: flux.EventTypeRemoved, Endpoint: endpoint})
+	_, ok := filter.schemas.Load(jsonSchemaKeyOf(endpoint))
+	assert.False(ok)
+}
@@ -0,0 +1,212 @@
+package filter
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/logger"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrJwksKeyNotFound = errors.New("jwks key not found")
+)
+
+// JsonWebKey JWK公钥定义，支持RSA、EC和oct类型
+type JsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// PublicKey 返回用于验证签名的密钥：*rsa.PublicKey, *ecdsa.PublicKey 或 []byte
+func (k JsonWebKey) PublicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJwkInt(k.N)
+		if nil != err {
+			return nil, fmt.Errorf("decode jwk.n, kid: %s, err: %w", k.Kid, err)
+		}
+		e, err := decodeJwkInt(k.E)
+		if nil != err {
+			return nil, fmt.Errorf("decode jwk.e, kid: %s, err: %w", k.Kid, err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported jwk.crv: %s, kid: %s", k.Crv, k.Kid)
+		}
+		x, err := decodeJwkInt(k.X)
+		if nil != err {
+			return nil, fmt.Errorf("decode jwk.x, kid: %s, err: %w", k.Kid, err)
+		}
+		y, err := decodeJwkInt(k.Y)
+		if nil != err {
+			return nil, fmt.Errorf("decode jwk.y, kid: %s, err: %w", k.Kid, err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		return decodeJwkBytes(k.K)
+	default:
+		return nil, fmt.Errorf("unsupported jwk.kty: %s, kid: %s", k.Kty, k.Kid)
+	}
+}
+
+// jwksKey 缓存的公钥，以及JWK声明的签名算法
+type jwksKey struct {
+	alg string
+	key interface{}
+}
+
+// JwksKeySet 从JWKS地址加载并缓存签名公钥；缓存超过刷新间隔，或查找不到Kid时重新加载，以支持密钥轮换
+type JwksKeySet struct {
+	uri        string
+	client     *http.Client
+	refresh    time.Duration
+	minRefresh time.Duration
+	keys       map[string]jwksKey
+	fetchAt    time.Time
+	mu         sync.RWMutex
+	fetchMu    sync.Mutex
+}
+
+func NewJwksKeySet(uri string, client *http.Client, refresh, minRefresh time.Duration) *JwksKeySet {
+	return &JwksKeySet{
+		uri:        uri,
+		client:     client,
+		refresh:    refresh,
+		minRefresh: minRefresh,
+		keys:       make(map[string]jwksKey),
+	}
+}
+
+// Lookup 根据Kid查找公钥，同时返回JWK声明的签名算法（可能为空）；Kid为空并且只有一个公钥时，返回该公钥
+func (s *JwksKeySet) Lookup(ctx context.Context, kid string) (key interface{}, alg string, err error) {
+	found, ok, fresh := s.lookup(kid)
+	if ok && fresh {
+		return found.key, found.alg, nil
+	}
+	// 缓存过期，或者未知Kid（可能密钥已轮换）时重新加载
+	if err := s.Refresh(ctx, !ok); nil != err {
+		if ok {
+			logger.Warnw("JWKS refresh failed, use cached keys", "uri", s.uri, "error", err)
+			return found.key, found.alg, nil
+		}
+		return nil, "", err
+	}
+	if found, ok, _ = s.lookup(kid); ok {
+		return found.key, found.alg, nil
+	}
+	return nil, "", fmt.Errorf("%w, kid: %s", ErrJwksKeyNotFound, kid)
+}
+
+// Refresh 重新加载JWKS；force为false时，仅在缓存过期后加载；两次加载的间隔不小于minRefresh
+func (s *JwksKeySet) Refresh(ctx context.Context, force bool) error {
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+	s.mu.RLock()
+	since := time.Since(s.fetchAt)
+	s.mu.RUnlock()
+	if since < s.minRefresh || (!force && since < s.refresh) {
+		return nil
+	}
+	keys, err := s.fetch(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetchAt = time.Now()
+	if nil != err {
+		return err
+	}
+	s.keys = keys
+	return nil
+}
+
+func (s *JwksKeySet) lookup(kid string) (key jwksKey, ok bool, fresh bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fresh = !s.fetchAt.IsZero() && time.Since(s.fetchAt) < s.refresh
+	if "" == kid && len(s.keys) == 1 {
+		for _, key = range s.keys {
+			return key, true, fresh
+		}
+	}
+	key, ok = s.keys[kid]
+	return key, ok, fresh
+}
+
+func (s *JwksKeySet) fetch(ctx context.Context) (map[string]jwksKey, error) {
+	req, err := http.NewRequest(http.MethodGet, s.uri, nil)
+	if nil != err {
+		return nil, err
+	}
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if nil != err {
+		return nil, fmt.Errorf("fetch jwks, uri: %s, err: %w", s.uri, err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if nil != err {
+		return nil, fmt.Errorf("read jwks, uri: %s, err: %w", s.uri, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks, uri: %s, status: %d", s.uri, resp.StatusCode)
+	}
+	set := struct {
+		Keys []JsonWebKey `json:"keys"`
+	}{}
+	if err := _json.Unmarshal(data, &set); nil != err {
+		return nil, fmt.Errorf("decode jwks, uri: %s, err: %w", s.uri, err)
+	}
+	keys := make(map[string]jwksKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if "" != jwk.Use && "sig" != jwk.Use {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if nil != err {
+			logger.Warnw("JWKS ignore illegal key", "uri", s.uri, "error", err)
+			continue
+		}
+		keys[jwk.Kid] = jwksKey{alg: jwk.Alg, key: key}
+	}
+	return keys, nil
+}
+
+func decodeJwkBytes(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func decodeJwkInt(value string) (*big.Int, error) {
+	data, err := decodeJwkBytes(value)
+	if nil != err {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
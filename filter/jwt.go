@@ -0,0 +1,428 @@
+package filter
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/support"
+	"github.com/golang-jwt/jwt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/cast"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	TypeIdJwtVerificationFilter = "JwtVerificationFilter"
+)
+
+// JWT验证配置
+const (
+	JwtConfigKeyLookupToken       = "jwt-lookup-token"
+	JwtConfigKeyIssuerKey         = "jwt-issuer-key"
+	JwtConfigKeySubjectKey        = "jwt-subject-key"
+	JwtConfigKeyAlgorithms        = "jwt-algorithms"
+	JwtConfigKeyAudience          = "jwt-audience"
+	JwtConfigKeyLeeway            = "jwt-leeway"
+	JwtConfigKeyRequireExpiration = "jwt-require-exp"
+	JwtConfigKeySecretKey         = "jwt-secret-key"
+	JwtConfigKeyJwksUri           = "jwks-uri"
+	JwtConfigKeyJwksRefresh       = "jwks-refresh-interval"
+	JwtConfigKeyJwksMinRefresh    = "jwks-min-refresh-interval"
+	JwtConfigKeyJwksTimeout       = "jwks-timeout"
+)
+
+// 加载签名密钥的后端服务配置
+const (
+	UpstreamConfigKeyProtocol = "upstream-protocol"
+	UpstreamConfigKeyHost     = "upstream-host"
+	UpstreamConfigKeyUri      = "upstream-uri"
+	UpstreamConfigKeyMethod   = "upstream-method"
+	UpstreamConfigKeyTimeout  = "upstream-timeout"
+)
+
+const (
+	// JwtValueKeyClaims 验证通过后，Token的全部Claims以此Key设置到Context.Value
+	JwtValueKeyClaims = "jwt-claims"
+)
+
+const (
+	jwtAuthorizationBearerPrefix = "bearer"
+)
+
+var (
+	_json = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+func init() {
+	ext.StoreTypedFactory(TypeIdJwtVerificationFilter, func() interface{} {
+		return NewJwtVerificationFilter()
+	})
+}
+
+// JwtVerificationFilter 读取并验证请求的JWT：支持HS/RS/PS/ES签名算法；
+// 签名密钥来自JWKS地址、Http/Dubbo等后端服务或静态配置；验证exp/nbf/aud声明。
+// 验证通过后，设置 X-Jwt-Subject/X-Jwt-Issuer/X-Jwt-Token 到Attributes，全部Claims以 jwt-claims 设置到Context.Value。
+type JwtVerificationFilter struct {
+	Disabled   bool
+	SkipFunc   flux.FilterSkipper
+	lookup     string
+	issuerKey  string
+	subjectKey string
+	algorithms []string
+	audience   []string
+	leeway     time.Duration
+	requireExp bool
+	secretKey  string
+	jwks       *JwksKeySet
+	upstream   *flux.BackendService
+	keyCache   *LRUCache
+	keyTTL     time.Duration
+}
+
+func NewJwtVerificationFilter() *JwtVerificationFilter {
+	return &JwtVerificationFilter{}
+}
+
+func (j *JwtVerificationFilter) TypeId() string {
+	return TypeIdJwtVerificationFilter
+}
+
+func (j *JwtVerificationFilter) Init(config *flux.Configuration) error {
+	logger.Info("JwtVerification filter initializing")
+	config.SetDefaults(map[string]interface{}{
+		JwtConfigKeyLookupToken:    "header:" + flux.HeaderAuthorization,
+		JwtConfigKeyIssuerKey:      "iss",
+		JwtConfigKeySubjectKey:     "sub",
+		JwtConfigKeyLeeway:         "0s",
+		JwtConfigKeyJwksRefresh:    "10m",
+		JwtConfigKeyJwksMinRefresh: "30s",
+		JwtConfigKeyJwksTimeout:    "5s",
+		UpstreamConfigKeyTimeout:   "5s",
+		ConfigKeyCacheExpiration:   "10m",
+		ConfigKeyCacheSize:         1024,
+		ConfigKeyDisabled:          false,
+	})
+	j.Disabled = config.GetBool(ConfigKeyDisabled)
+	if j.Disabled {
+		logger.Info("JwtVerification filter was DISABLED!!")
+		return nil
+	}
+	if nil == j.SkipFunc {
+		j.SkipFunc = func(_ flux.Context) bool {
+			return false
+		}
+	}
+	j.lookup = config.GetString(JwtConfigKeyLookupToken)
+	if _, _, ok := support.ParseLookupExpr(j.lookup); !ok {
+		j.lookup = flux.ScopeHeader + ":" + j.lookup
+	}
+	j.issuerKey = config.GetString(JwtConfigKeyIssuerKey)
+	j.subjectKey = config.GetString(JwtConfigKeySubjectKey)
+	j.algorithms = config.GetStringSlice(JwtConfigKeyAlgorithms)
+	j.audience = config.GetStringSlice(JwtConfigKeyAudience)
+	j.leeway = config.GetDuration(JwtConfigKeyLeeway)
+	j.requireExp = config.GetBool(JwtConfigKeyRequireExpiration)
+	j.secretKey = config.GetString(JwtConfigKeySecretKey)
+	// JWKS
+	if uri := config.GetString(JwtConfigKeyJwksUri); "" != uri {
+		client := &http.Client{Timeout: config.GetDuration(JwtConfigKeyJwksTimeout)}
+		j.jwks = NewJwksKeySet(uri, client,
+			config.GetDuration(JwtConfigKeyJwksRefresh), config.GetDuration(JwtConfigKeyJwksMinRefresh))
+	}
+	// 后端服务加载签名密钥
+	if proto := strings.ToUpper(config.GetString(UpstreamConfigKeyProtocol)); "" != proto {
+		service, err := NewJwtUpstreamService(proto, config.GetString(UpstreamConfigKeyHost),
+			config.GetString(UpstreamConfigKeyUri), config.GetString(UpstreamConfigKeyMethod))
+		if nil != err {
+			return err
+		}
+		service.Attributes = append(service.Attributes, flux.Attribute{
+			Tag: flux.ServiceAttrTagRpcTimeout, Name: "RpcTimeout", Value: config.GetString(UpstreamConfigKeyTimeout),
+		})
+		j.upstream = &service
+		if !config.GetBool(ConfigKeyCacheDisabled) {
+			j.keyCache = NewLRUCache(config.GetInt(ConfigKeyCacheSize))
+			j.keyTTL = config.GetDuration(ConfigKeyCacheExpiration)
+		}
+	}
+	if nil == j.jwks && nil == j.upstream && "" == j.secretKey {
+		return fmt.Errorf("JwtVerificationFilter requires one of: %s, %s, %s",
+			JwtConfigKeyJwksUri, UpstreamConfigKeyProtocol, JwtConfigKeySecretKey)
+	}
+	return nil
+}
+
+func (j *JwtVerificationFilter) DoFilter(next flux.FilterHandler) flux.FilterHandler {
+	if j.Disabled {
+		return next
+	}
+	return func(ctx flux.Context) *flux.ServeError {
+		if j.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		defer func() {
+			ctx.AddMetric("M-"+j.TypeId(), ctx.ElapsedTime())
+		}()
+		if serr := j.Verify(ctx); nil != serr {
+			return serr
+		}
+		return next(ctx)
+	}
+}
+
+// Verify 读取并验证Token；通过后将Claims设置到Context
+func (j *JwtVerificationFilter) Verify(ctx flux.Context) *flux.ServeError {
+	value, err := support.LookupContextByExpr(j.lookup, ctx)
+	tokenString := strings.TrimSpace(cast.ToString(value))
+	if prefix := len(jwtAuthorizationBearerPrefix); len(tokenString) >= prefix &&
+		jwtAuthorizationBearerPrefix == strings.ToLower(tokenString[:prefix]) {
+		tokenString = strings.TrimSpace(tokenString[prefix:])
+	}
+	if nil != err || "" == tokenString {
+		return newJwtServeError(flux.ErrorMessageJwtMissingToken, err)
+	}
+	parser := &jwt.Parser{ValidMethods: j.algorithms, UseJSONNumber: true, SkipClaimsValidation: true}
+	claims := jwt.MapClaims{}
+	_, err = parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return j.LookupKey(ctx, token)
+	})
+	if nil != err {
+		if verr, ok := err.(*jwt.ValidationError); ok {
+			if serr, ok := verr.Inner.(*flux.ServeError); ok {
+				return serr
+			}
+			if verr.Errors&jwt.ValidationErrorMalformed != 0 {
+				return newJwtServeError(flux.ErrorMessageJwtMalformed, err)
+			}
+		}
+		return newJwtServeError(flux.ErrorMessageJwtSignature, err)
+	}
+	if serr := j.VerifyClaims(claims, time.Now()); nil != serr {
+		return serr
+	}
+	ctx.SetAttribute(flux.XJwtSubject, cast.ToString(claims[j.subjectKey]))
+	ctx.SetAttribute(flux.XJwtIssuer, cast.ToString(claims[j.issuerKey]))
+	ctx.SetAttribute(flux.XJwtToken, tokenString)
+	ctx.SetValue(JwtValueKeyClaims, map[string]interface{}(claims))
+	return nil
+}
+
+// VerifyClaims 验证exp/nbf/aud声明；exp和nbf允许leeway的时间误差
+func (j *JwtVerificationFilter) VerifyClaims(claims jwt.MapClaims, now time.Time) *flux.ServeError {
+	if exp, ok := claims["exp"]; ok {
+		if at, err := jwtNumericDate(exp); nil != err {
+			return newJwtServeError(flux.ErrorMessageJwtMalformed, fmt.Errorf("illegal exp: %v", exp))
+		} else if now.Add(-j.leeway).Unix() > at {
+			return newJwtServeError(flux.ErrorMessageJwtExpired, fmt.Errorf("token expired at: %d", at))
+		}
+	} else if j.requireExp {
+		return newJwtServeError(flux.ErrorMessageJwtExpired, errors.New("token without exp"))
+	}
+	if nbf, ok := claims["nbf"]; ok {
+		if at, err := jwtNumericDate(nbf); nil != err {
+			return newJwtServeError(flux.ErrorMessageJwtMalformed, fmt.Errorf("illegal nbf: %v", nbf))
+		} else if now.Add(j.leeway).Unix() < at {
+			return newJwtServeError(flux.ErrorMessageJwtNotValidYet, fmt.Errorf("token not valid before: %d", at))
+		}
+	}
+	if len(j.audience) > 0 && !jwtAudienceMatch(claims["aud"], j.audience) {
+		return newJwtServeError(flux.ErrorMessageJwtAudience, fmt.Errorf("token audience not accepted: %v", claims["aud"]))
+	}
+	return nil
+}
+
+// LookupKey 查找Token的签名验证密钥：JWKS优先，其次为后端服务，最后为静态密钥
+func (j *JwtVerificationFilter) LookupKey(ctx flux.Context, token *jwt.Token) (interface{}, error) {
+	var key interface{}
+	if nil != j.jwks {
+		kid, _ := token.Header["kid"].(string)
+		found, alg, err := j.jwks.Lookup(ctx.Context(), kid)
+		if nil != err {
+			if errors.Is(err, ErrJwksKeyNotFound) {
+				return nil, newJwtServeError(flux.ErrorMessageJwtKeyNotFound, err)
+			}
+			return nil, newJwtKeyLoadError(err)
+		}
+		// JWK声明了签名算法时，Token必须使用相同的算法
+		if "" != alg && alg != token.Method.Alg() {
+			return nil, fmt.Errorf("jwk alg: %s not match token alg: %s, kid: %s", alg, token.Method.Alg(), kid)
+		}
+		key = found
+	} else if nil != j.upstream {
+		claims, _ := token.Claims.(jwt.MapClaims)
+		loaded, serr := j.LoadUpstreamKey(ctx, cast.ToString(claims[j.issuerKey]), cast.ToString(claims[j.subjectKey]), claims)
+		if nil != serr {
+			return nil, serr
+		}
+		key = loaded
+	} else {
+		key = j.secretKey
+	}
+	return jwtVerifyKeyOf(token.Method, key)
+}
+
+// LoadUpstreamKey 通过后端服务加载签名密钥，按 issuer/subject 缓存
+func (j *JwtVerificationFilter) LoadUpstreamKey(ctx flux.Context, issuer, subject string, claims jwt.MapClaims) (string, *flux.ServeError) {
+	cacheKey := issuer + "/" + subject
+	if nil != j.keyCache {
+		if v, ok := j.keyCache.Load(cacheKey); ok {
+			return v.(string), nil
+		}
+	}
+	service := *j.upstream
+	if flux.ProtoHttp == service.AttrRpcProto() {
+		text, _ := _json.MarshalToString(claims)
+		service.Arguments = []flux.Argument{ext.NewStringArgumentWith("issuer", issuer),
+			ext.NewStringArgumentWith("subject", subject), ext.NewStringArgumentWith("claims", text)}
+	} else {
+		service.Arguments = []flux.Argument{ext.NewStringArgumentWith("issuer", issuer),
+			ext.NewStringArgumentWith("subject", subject),
+			ext.NewPrimitiveArgumentWithLoader(flux.JavaUtilMapClassName, "claims", func() flux.MTValue {
+				return flux.WrapObjectMTValue(map[string]interface{}(claims))
+			})}
+	}
+	resp, serr := backend.DoInvokeCodec(ctx, service)
+	if nil != serr {
+		return "", newJwtKeyLoadError(serr)
+	}
+	if resp.StatusCode != flux.StatusOK {
+		return "", newJwtKeyLoadError(fmt.Errorf("load jwt key, status: %d", resp.StatusCode))
+	}
+	key, err := jwtBodyString(resp.Body)
+	if nil != err {
+		return "", newJwtKeyLoadError(err)
+	}
+	if "" == key {
+		return "", newJwtServeError(flux.ErrorMessageJwtKeyNotFound, fmt.Errorf("empty jwt key, issuer: %s, subject: %s", issuer, subject))
+	}
+	if nil != j.keyCache {
+		j.keyCache.Store(cacheKey, key, j.keyTTL)
+	}
+	return key, nil
+}
+
+// NewJwtUpstreamService 构建加载签名密钥的后端服务；Http协议的uri可以为完整的URL地址
+func NewJwtUpstreamService(proto, host, uri, method string) (flux.BackendService, error) {
+	if "" == uri {
+		return flux.BackendService{}, fmt.Errorf("JwtVerificationFilter config %s is empty", UpstreamConfigKeyUri)
+	}
+	service := flux.BackendService{RemoteHost: host, Interface: uri, Method: method}
+	if flux.ProtoHttp == proto {
+		if u, err := url.Parse(uri); nil == err && "" != u.Host {
+			service.Scheme, service.RemoteHost, service.Interface = u.Scheme, u.Host, u.RequestURI()
+		}
+		if "" == service.Method {
+			service.Method = http.MethodPost
+		}
+	}
+	service.Attributes = []flux.Attribute{{Tag: flux.ServiceAttrTagRpcProto, Name: "RpcProto", Value: proto}}
+	return service, nil
+}
+
+// jwtVerifyKeyOf 按签名算法转换验证密钥：HS使用字节密钥，RS/PS/ES使用PEM格式或JWKS的公钥。
+// PEM格式的密钥只能用于RS/PS/ES算法，防止以公钥作为HMAC密钥伪造Token（算法混淆攻击）。
+func jwtVerifyKeyOf(method jwt.SigningMethod, key interface{}) (interface{}, error) {
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		var secret []byte
+		switch k := key.(type) {
+		case string:
+			secret = []byte(k)
+		case []byte:
+			secret = k
+		}
+		if block, _ := pem.Decode(secret); nil != block {
+			return nil, fmt.Errorf("jwt pem key not allowed for algorithm: %s", method.Alg())
+		}
+		if len(secret) > 0 {
+			return secret, nil
+		}
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		switch k := key.(type) {
+		case string:
+			return jwt.ParseRSAPublicKeyFromPEM([]byte(k))
+		case *rsa.PublicKey:
+			return k, nil
+		}
+	case *jwt.SigningMethodECDSA:
+		switch k := key.(type) {
+		case string:
+			return jwt.ParseECPublicKeyFromPEM([]byte(k))
+		case *ecdsa.PublicKey:
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("jwt key type: %T not match algorithm: %s", key, method.Alg())
+}
+
+// jwtNumericDate 解析exp/nbf的NumericDate值，单位为秒，允许小数
+func jwtNumericDate(value interface{}) (int64, error) {
+	seconds, err := cast.ToFloat64E(cast.ToString(value))
+	return int64(seconds), err
+}
+
+func jwtAudienceMatch(aud interface{}, accepted []string) bool {
+	values := make([]string, 0, 1)
+	switch v := aud.(type) {
+	case string:
+		values = append(values, v)
+	case []interface{}:
+		for _, a := range v {
+			values = append(values, cast.ToString(a))
+		}
+	}
+	for _, v := range values {
+		for _, a := range accepted {
+			if v == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jwtBodyString(body interface{}) (string, error) {
+	switch b := body.(type) {
+	case string:
+		return strings.TrimSpace(b), nil
+	case []byte:
+		return strings.TrimSpace(string(b)), nil
+	case io.Reader:
+		if c, ok := b.(io.Closer); ok {
+			defer c.Close()
+		}
+		data, err := ioutil.ReadAll(b)
+		return strings.TrimSpace(string(data)), err
+	default:
+		return cast.ToStringE(body)
+	}
+}
+
+func newJwtServeError(message string, err error) *flux.ServeError {
+	return &flux.ServeError{
+		StatusCode: flux.StatusUnauthorized,
+		ErrorCode:  flux.ErrorCodeJwtInvalid,
+		Message:    message,
+		Internal:   err,
+	}
+}
+
+func newJwtKeyLoadError(err error) *flux.ServeError {
+	return &flux.ServeError{
+		StatusCode: flux.StatusServerError,
+		ErrorCode:  flux.ErrorCodeGatewayInternal,
+		Message:    flux.ErrorMessageJwtKeyLoadFailed,
+		Internal:   err,
+	}
+}
@@ -0,0 +1,250 @@
+package filter
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/bytepowered/flux"
+	_ "github.com/bytepowered/flux/backend/local"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/support"
+	"github.com/golang-jwt/jwt"
+	assert2 "github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func init() {
+	ext.StoreLoggerFactory(func(context.Context) flux.Logger {
+		return logger.SimpleLogger()
+	})
+	ext.StoreArgumentLookupFunc(support.DefaultArgumentValueLookupFunc)
+}
+
+func newTestJwtFilter(t *testing.T, values map[string]interface{}) *JwtVerificationFilter {
+	config := flux.NewConfiguration(nil)
+	for k, v := range values {
+		config.Set(k, v)
+	}
+	filter := NewJwtVerificationFilter()
+	assert2.Nil(t, filter.Init(config))
+	return filter
+}
+
+func signTestJwt(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if "" != kid {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	assert2.Nil(t, err)
+	return signed
+}
+
+func doTestJwtVerify(filter *JwtVerificationFilter, token string) (flux.Context, *flux.ServeError) {
+	ctx := support.NewValuesContext(map[string]interface{}{flux.HeaderAuthorization: "Bearer " + token})
+	return ctx, filter.DoFilter(func(flux.Context) *flux.ServeError {
+		return nil
+	})(ctx)
+}
+
+func TestJwtVerificationFilter_HMAC(t *testing.T) {
+	filter := newTestJwtFilter(t, map[string]interface{}{
+		JwtConfigKeySecretKey:  "s3cret",
+		JwtConfigKeyAudience:   []string{"gateway"},
+		JwtConfigKeyAlgorithms: []string{"HS256"},
+		JwtConfigKeyLeeway:     "5s",
+	})
+	now := time.Now().Unix()
+	cases := []struct {
+		name    string
+		token   string
+		message string
+	}{
+		{name: "ok", token: signTestJwt(t, jwt.SigningMethodHS256, "", []byte("s3cret"),
+			jwt.MapClaims{"iss": "flux", "sub": "u1", "aud": []string{"app", "gateway"}, "exp": now + 60, "role": "admin"})},
+		{name: "leeway", token: signTestJwt(t, jwt.SigningMethodHS256, "", []byte("s3cret"),
+			jwt.MapClaims{"sub": "u1", "aud": "gateway", "exp": now - 2, "nbf": now + 2})},
+		{name: "missing", token: "", message: flux.ErrorMessageJwtMissingToken},
+		{name: "malformed", token: "a.b", message: flux.ErrorMessageJwtMalformed},
+		{name: "signature", token: signTestJwt(t, jwt.SigningMethodHS256, "", []byte("other"),
+			jwt.MapClaims{"sub": "u1", "aud": "gateway"}), message: flux.ErrorMessageJwtSignature},
+		{name: "algorithm", token: signTestJwt(t, jwt.SigningMethodHS512, "", []byte("s3cret"),
+			jwt.MapClaims{"sub": "u1", "aud": "gateway"}), message: flux.ErrorMessageJwtSignature},
+		{name: "expired", token: signTestJwt(t, jwt.SigningMethodHS256, "", []byte("s3cret"),
+			jwt.MapClaims{"sub": "u1", "aud": "gateway", "exp": now - 60}), message: flux.ErrorMessageJwtExpired},
+		{name: "nbf", token: signTestJwt(t, jwt.SigningMethodHS256, "", []byte("s3cret"),
+			jwt.MapClaims{"sub": "u1", "aud": "gateway", "nbf": now + 60}), message: flux.ErrorMessageJwtNotValidYet},
+		{name: "audience", token: signTestJwt(t, jwt.SigningMethodHS256, "", []byte("s3cret"),
+			jwt.MapClaims{"sub": "u1", "aud": "other"}), message: flux.ErrorMessageJwtAudience},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		ctx, serr := doTestJwtVerify(filter, tcase.token)
+		if "" != tcase.message {
+			assert.NotNil(serr, tcase.name)
+			assert.Equal(flux.StatusUnauthorized, serr.StatusCode, tcase.name)
+			assert.Equal(flux.ErrorCodeJwtInvalid, serr.ErrorCode, tcase.name)
+			assert.Equal(tcase.message, serr.Message, tcase.name)
+			continue
+		}
+		assert.Nil(serr, tcase.name)
+		assert.Equal("u1", ctx.GetAttributeString(flux.XJwtSubject, ""), tcase.name)
+		assert.Equal(tcase.token, ctx.GetAttributeString(flux.XJwtToken, ""), tcase.name)
+		claims, ok := ctx.GetValue(JwtValueKeyClaims)
+		assert.True(ok, tcase.name)
+		assert.Equal("u1", claims.(map[string]interface{})["sub"], tcase.name)
+	}
+}
+
+func newTestJwks(keys map[string]interface{}) map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(keys))
+	encode := func(b []byte) string {
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	for kid, key := range keys {
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			out = append(out, map[string]interface{}{"kty": "RSA", "kid": kid, "use": "sig",
+				"n": encode(k.N.Bytes()), "e": encode(big.NewInt(int64(k.E)).Bytes())})
+		case *ecdsa.PublicKey:
+			out = append(out, map[string]interface{}{"kty": "EC", "kid": kid, "crv": "P-256",
+				"x": encode(k.X.Bytes()), "y": encode(k.Y.Bytes())})
+		}
+	}
+	return map[string]interface{}{"keys": out}
+}
+
+func TestJwtVerificationFilter_Jwks(t *testing.T) {
+	assert := assert2.New(t)
+	rsaKey1, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(err)
+	rsaKey2, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(err)
+	var rotated, fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		keys := map[string]interface{}{"k1": &rsaKey1.PublicKey, "ec": &ecKey.PublicKey}
+		if atomic.LoadInt32(&rotated) > 0 {
+			keys = map[string]interface{}{"k2": &rsaKey2.PublicKey, "ec": &ecKey.PublicKey}
+		}
+		data, _ := _json.Marshal(newTestJwks(keys))
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+	filter := newTestJwtFilter(t, map[string]interface{}{
+		JwtConfigKeyJwksUri:        server.URL,
+		JwtConfigKeyJwksMinRefresh: "0s",
+	})
+	claims := jwt.MapClaims{"iss": "flux", "sub": "u2"}
+	_, serr := doTestJwtVerify(filter, signTestJwt(t, jwt.SigningMethodRS256, "k1", rsaKey1, claims))
+	assert.Nil(serr)
+	_, serr = doTestJwtVerify(filter, signTestJwt(t, jwt.SigningMethodES256, "ec", ecKey, claims))
+	assert.Nil(serr)
+	assert.Equal(int32(1), atomic.LoadInt32(&fetches))
+	// 密钥轮换：未知Kid时重新加载JWKS
+	atomic.StoreInt32(&rotated, 1)
+	_, serr = doTestJwtVerify(filter, signTestJwt(t, jwt.SigningMethodRS256, "k2", rsaKey2, claims))
+	assert.Nil(serr)
+	assert.Equal(int32(2), atomic.LoadInt32(&fetches))
+	_, serr = doTestJwtVerify(filter, signTestJwt(t, jwt.SigningMethodRS256, "k1", rsaKey1, claims))
+	assert.NotNil(serr)
+	assert.Equal(flux.ErrorMessageJwtKeyNotFound, serr.Message)
+	// 密钥与算法不匹配
+	_, serr = doTestJwtVerify(filter, signTestJwt(t, jwt.SigningMethodHS256, "ec", []byte("ec"), claims))
+	assert.NotNil(serr)
+	assert.Equal(flux.ErrorMessageJwtSignature, serr.Message)
+}
+
+func TestJwtVerificationFilter_Upstream(t *testing.T) {
+	assert := assert2.New(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(err)
+	der, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	assert.Nil(err)
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	var loads int32
+	ext.StoreLocalHandler("test.JwtCertService", "getCertKey", func(ctx flux.Context, args map[string]interface{}) (*flux.BackendResponse, *flux.ServeError) {
+		atomic.AddInt32(&loads, 1)
+		if "flux" != args["issuer"] || "u3" != args["subject"] || "admin" != args["claims"].(map[string]interface{})["role"] {
+			return &flux.BackendResponse{StatusCode: flux.StatusOK, Body: ""}, nil
+		}
+		return &flux.BackendResponse{StatusCode: flux.StatusOK, Body: pemKey}, nil
+	})
+	filter := newTestJwtFilter(t, map[string]interface{}{
+		UpstreamConfigKeyProtocol: flux.ProtoLocal,
+		UpstreamConfigKeyUri:      "test.JwtCertService",
+		UpstreamConfigKeyMethod:   "getCertKey",
+	})
+	token := signTestJwt(t, jwt.SigningMethodES256, "", ecKey, jwt.MapClaims{"iss": "flux", "sub": "u3", "role": "admin"})
+	for i := 0; i < 2; i++ {
+		ctx, serr := doTestJwtVerify(filter, token)
+		assert.Nil(serr)
+		assert.Equal("flux", ctx.GetAttributeString(flux.XJwtIssuer, ""))
+	}
+	// 签名密钥按 issuer/subject 缓存
+	assert.Equal(int32(1), atomic.LoadInt32(&loads))
+	_, serr := doTestJwtVerify(filter, signTestJwt(t, jwt.SigningMethodES256, "", ecKey, jwt.MapClaims{"iss": "flux", "sub": "u4"}))
+	assert.NotNil(serr)
+	assert.Equal(flux.ErrorMessageJwtKeyNotFound, serr.Message)
+}
+
+func TestJwtVerificationFilter_AlgorithmConfusion(t *testing.T) {
+	assert := assert2.New(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(err)
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	assert.Nil(err)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	claims := jwt.MapClaims{"iss": "flux", "sub": "u5"}
+	// 未配置jwt-algorithms：以PEM公钥作为HMAC密钥签名的Token必须被拒绝
+	filter := newTestJwtFilter(t, map[string]interface{}{
+		JwtConfigKeySecretKey: string(pemKey),
+	})
+	_, serr := doTestJwtVerify(filter, signTestJwt(t, jwt.SigningMethodRS256, "", rsaKey, claims))
+	assert.Nil(serr)
+	_, serr = doTestJwtVerify(filter, signTestJwt(t, jwt.SigningMethodHS256, "", pemKey, claims))
+	assert.NotNil(serr)
+	assert.Equal(flux.ErrorMessageJwtSignature, serr.Message)
+	// JWK声明的alg与Token的签名算法不一致
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jwks := newTestJwks(map[string]interface{}{"k1": &rsaKey.PublicKey})
+		jwks["keys"].([]map[string]interface{})[0]["alg"] = "RS256"
+		data, _ := _json.Marshal(jwks)
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+	filter = newTestJwtFilter(t, map[string]interface{}{
+		JwtConfigKeyJwksUri: server.URL,
+	})
+	_, serr = doTestJwtVerify(filter, signTestJwt(t, jwt.SigningMethodRS256, "k1", rsaKey, claims))
+	assert.Nil(serr)
+	_, serr = doTestJwtVerify(filter, signTestJwt(t, jwt.SigningMethodPS256, "k1", rsaKey, claims))
+	assert.NotNil(serr)
+	assert.Equal(flux.ErrorMessageJwtSignature, serr.Message)
+}
+
+func TestNewJwtUpstreamService(t *testing.T) {
+	assert := assert2.New(t)
+	service, err := NewJwtUpstreamService(flux.ProtoHttp, "", "http://foo.bar.com:8080/jwt?v=1", "")
+	assert.Nil(err)
+	assert.Equal("http", service.Scheme)
+	assert.Equal("foo.bar.com:8080", service.RemoteHost)
+	assert.Equal("/jwt?v=1", service.Interface)
+	assert.Equal(http.MethodPost, service.Method)
+	assert.Equal(flux.ProtoHttp, service.AttrRpcProto())
+	_, err = NewJwtUpstreamService(flux.ProtoDubbo, "", "", "getCertKey")
+	assert.NotNil(err)
+}
@@ -0,0 +1,142 @@
+package filter
+
+import (
+	"errors"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestPermissionFilter(t *testing.T, verify PermissionVerifyFunc, values map[string]interface{}) *PermissionFilter {
+	config := flux.NewConfiguration(nil)
+	for k, v := range values {
+		config.Set(k, v)
+	}
+	filter := NewPermissionFilter(PermissionConfig{VerifyFunc: verify})
+	assert2.Nil(t, filter.Init(config))
+	return filter
+}
+
+func newTestPermissionContext(subject string, permissions ...string) *testEndpointContext {
+	values := support.NewValuesContext(map[string]interface{}{}).(*support.ValuesContext)
+	values.SetAttribute(flux.XJwtSubject, subject)
+	endpoint := flux.Endpoint{HttpMethod: http.MethodGet, HttpPattern: "/api/permission", Permissions: permissions}
+	return &testEndpointContext{ValuesContext: values, endpoint: endpoint}
+}
+
+func TestPermissionFilter_Cache(t *testing.T) {
+	assert := assert2.New(t)
+	ext.StoreBackendServiceById("test.permission.a", flux.BackendService{Interface: "test.PermissionService", Method: "a"})
+	ext.StoreBackendServiceById("test.permission.b", flux.BackendService{Interface: "test.PermissionService", Method: "b"})
+	calls := 0
+	var verifyErr error
+	filter := newTestPermissionFilter(t, func(services []flux.BackendService, ctx flux.Context) (PermissionVerifyReport, error) {
+		calls++
+		subject, _ := ctx.GetAttribute(flux.XJwtSubject)
+		return NewPermissionVerifyReport("admin" == subject, "", ""), verifyErr
+	}, map[string]interface{}{
+		ConfigKeyCacheDisabled:                   false,
+		ConfigKeyCacheExpiration:                 "1m",
+		PermissionConfigKeyCacheDeniedExpiration: "20ms",
+	})
+	handler := filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+		return nil
+	})
+	cases := []struct {
+		subject     string
+		permissions []string
+		denied      bool
+		calls       int
+	}{
+		{subject: "admin", permissions: []string{"test.permission.a"}, calls: 1},
+		{subject: "admin", permissions: []string{"test.permission.a"}, calls: 1},
+		{subject: "admin", permissions: []string{"test.permission.a", "test.permission.b"}, calls: 2},
+		{subject: "guest", permissions: []string{"test.permission.a"}, denied: true, calls: 3},
+		{subject: "guest", permissions: []string{"test.permission.a"}, denied: true, calls: 3},
+	}
+	for _, tcase := range cases {
+		serr := handler(newTestPermissionContext(tcase.subject, tcase.permissions...))
+		assert.Equal(tcase.denied, nil != serr, tcase.subject)
+		assert.Equal(tcase.calls, calls, tcase.subject)
+	}
+	// 拒绝结果的缓存时长较短
+	time.Sleep(30 * time.Millisecond)
+	assert.NotNil(handler(newTestPermissionContext("guest", "test.permission.a")))
+	assert.Equal(4, calls)
+	assert.Nil(handler(newTestPermissionContext("admin", "test.permission.a")))
+	assert.Equal(4, calls)
+	// 验证错误不缓存
+	verifyErr = errors.New("verify failed")
+	assert.NotNil(handler(newTestPermissionContext("user", "test.permission.a")))
+	verifyErr = nil
+	assert.NotNil(handler(newTestPermissionContext("user", "test.permission.a")))
+	assert.Equal(6, calls)
+}
+
+func TestPermissionFilter_CacheDisabled(t *testing.T) {
+	assert := assert2.New(t)
+	ext.StoreBackendServiceById("test.permission.a", flux.BackendService{Interface: "test.PermissionService", Method: "a"})
+	calls := 0
+	filter := newTestPermissionFilter(t, func(services []flux.BackendService, ctx flux.Context) (PermissionVerifyReport, error) {
+		calls++
+		return NewPermissionVerifyReport(true, "", ""), nil
+	}, nil)
+	handler := filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+		return nil
+	})
+	for i := 1; i <= 3; i++ {
+		assert.Nil(handler(newTestPermissionContext("admin", "test.permission.a")))
+		assert.Equal(i, calls)
+	}
+}
+
+func TestPermissionFilter_CacheKey(t *testing.T) {
+	assert := assert2.New(t)
+	ext.StoreBackendServiceById("test.permission.order", flux.BackendService{
+		Interface: "test.PermissionService", Method: "order",
+		Arguments: []flux.Argument{ext.NewStringArgument("orderId")},
+	})
+	calls := 0
+	filter := newTestPermissionFilter(t, func(services []flux.BackendService, ctx flux.Context) (PermissionVerifyReport, error) {
+		calls++
+		return NewPermissionVerifyReport(true, "", ""), nil
+	}, map[string]interface{}{
+		ConfigKeyCacheDisabled: false,
+	})
+	handler := filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+		return nil
+	})
+	newContext := func(subject, orderId string) flux.Context {
+		ctx := newTestPermissionContext(subject, "test.permission.order")
+		ctx.ValuesContext = support.NewValuesContext(map[string]interface{}{
+			"path-values": url.Values{"orderId": []string{orderId}},
+		}).(*support.ValuesContext)
+		if "" != subject {
+			ctx.SetAttribute(flux.XJwtSubject, subject)
+		}
+		return ctx
+	}
+	cases := []struct {
+		subject string
+		orderId string
+		calls   int
+	}{
+		{subject: "u1", orderId: "1", calls: 1},
+		{subject: "u1", orderId: "1", calls: 1},
+		// 权限服务的参数值不同，不使用缓存的验证结果
+		{subject: "u1", orderId: "2", calls: 2},
+		{subject: "u2", orderId: "1", calls: 3},
+		// 未认证请求的Lookup值为空，不缓存
+		{subject: "", orderId: "1", calls: 4},
+		{subject: "", orderId: "1", calls: 5},
+	}
+	for _, tcase := range cases {
+		assert.Nil(handler(newContext(tcase.subject, tcase.orderId)))
+		assert.Equal(tcase.calls, calls, "subject: %s, order: %s", tcase.subject, tcase.orderId)
+	}
+}
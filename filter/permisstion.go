@@ -8,13 +8,23 @@ import (
 	"github.com/bytepowered/flux/ext"
 	"github.com/bytepowered/flux/logger"
 	"github.com/bytepowered/flux/pkg"
+	"github.com/bytepowered/flux/support"
+	"github.com/spf13/cast"
 	"net/http"
+	"strings"
+	"time"
 )
 
 const (
 	TypeIdPermissionV2Filter = "PermissionFilter"
 )
 
+// 权限验证结果缓存配置；验证通过的缓存时长为 cache-expiration
+const (
+	PermissionConfigKeyCacheLookupKeys       = "permission-cache-lookup-keys"
+	PermissionConfigKeyCacheDeniedExpiration = "permission-cache-denied-expiration"
+)
+
 type (
 	// PermissionVerifyReport 权限验证结果报告
 	PermissionVerifyReport struct {
@@ -50,15 +60,25 @@ func NewPermissionFilter(c PermissionConfig) *PermissionFilter {
 	}
 }
 
-// PermissionFilter 提供基于Endpoint.Permission元数据的权限验证
+// PermissionFilter 提供基于Endpoint.Permission元数据的权限验证；
+// 开启缓存后，按Endpoint、权限服务ID、权限服务参数值和Lookup表达式读取的请求数据缓存验证结果，验证通过和拒绝分别使用不同的缓存时长。
 type PermissionFilter struct {
-	Disabled bool
-	Configs  PermissionConfig
+	Disabled   bool
+	Configs    PermissionConfig
+	cache      *LRUCache
+	lookupKeys []string
+	successTTL time.Duration
+	deniedTTL  time.Duration
 }
 
 func (p *PermissionFilter) Init(config *flux.Configuration) error {
 	config.SetDefaults(map[string]interface{}{
-		ConfigKeyDisabled: false,
+		ConfigKeyDisabled:                        false,
+		ConfigKeyCacheDisabled:                   true,
+		ConfigKeyCacheSize:                       10000,
+		ConfigKeyCacheExpiration:                 "5m",
+		PermissionConfigKeyCacheDeniedExpiration: "10s",
+		PermissionConfigKeyCacheLookupKeys:       []string{"attr:" + flux.XJwtSubject},
 	})
 	p.Disabled = config.GetBool(ConfigKeyDisabled)
 	if p.Disabled {
@@ -73,6 +93,19 @@ func (p *PermissionFilter) Init(config *flux.Configuration) error {
 	if pkg.IsNil(p.Configs.VerifyFunc) {
 		return fmt.Errorf("PermissionFilter.VerifyFunc is nil")
 	}
+	if config.GetBool(ConfigKeyCacheDisabled) {
+		logger.Info("Endpoint PermissionFilter cache was DISABLED!!")
+		return nil
+	}
+	p.lookupKeys = config.GetStringSlice(PermissionConfigKeyCacheLookupKeys)
+	for _, key := range p.lookupKeys {
+		if _, _, ok := support.ParseLookupExpr(key); !ok {
+			return fmt.Errorf("PermissionFilter illegal lookup expr: %s", key)
+		}
+	}
+	p.successTTL = config.GetDuration(ConfigKeyCacheExpiration)
+	p.deniedTTL = config.GetDuration(PermissionConfigKeyCacheDeniedExpiration)
+	p.cache = NewLRUCache(config.GetInt(ConfigKeyCacheSize))
 	return nil
 }
 
@@ -95,13 +128,16 @@ func (p *PermissionFilter) DoFilter(next flux.FilterHandler) flux.FilterHandler
 			return next(ctx)
 		}
 		services := make([]flux.BackendService, 0, 1+size)
+		ids := make([]string, 0, 1+size)
 		// Define permission first
 		if endpoint.Permission.IsValid() {
 			services = append(services, endpoint.Permission)
+			ids = append(ids, endpoint.Permission.ServiceID())
 		}
 		for _, id := range endpoint.Permissions {
 			if srv, ok := ext.LoadBackendService(id); ok {
 				services = append(services, srv)
+				ids = append(ids, id)
 			} else {
 				return &flux.ServeError{
 					StatusCode: flux.StatusServerError,
@@ -111,7 +147,7 @@ func (p *PermissionFilter) DoFilter(next flux.FilterHandler) flux.FilterHandler
 				}
 			}
 		}
-		report, err := p.Configs.VerifyFunc(services, ctx)
+		report, err := p.verify(ctx, services, ids)
 		ctx.AddMetric("M-"+p.TypeId(), ctx.ElapsedTime())
 		if nil != err {
 			if serr, ok := err.(*flux.ServeError); ok {
@@ -135,6 +171,66 @@ func (p *PermissionFilter) DoFilter(next flux.FilterHandler) flux.FilterHandler
 	}
 }
 
+// verify 执行权限验证；开启缓存时优先读取缓存的验证结果，验证过程发生错误时不缓存
+func (p *PermissionFilter) verify(ctx flux.Context, services []flux.BackendService, ids []string) (PermissionVerifyReport, error) {
+	if nil == p.cache {
+		return p.Configs.VerifyFunc(services, ctx)
+	}
+	key, ok := p.cacheKey(ctx, services, ids)
+	if !ok {
+		return p.Configs.VerifyFunc(services, ctx)
+	}
+	if v, ok := p.cache.Load(key); ok {
+		return v.(PermissionVerifyReport), nil
+	}
+	report, err := p.Configs.VerifyFunc(services, ctx)
+	if nil != err {
+		return report, err
+	}
+	ttl := p.successTTL
+	if !report.Success {
+		ttl = p.deniedTTL
+	}
+	if ttl > 0 {
+		p.cache.Store(key, report, ttl)
+	}
+	return report, nil
+}
+
+// cacheKey 按Endpoint、权限服务ID、权限服务的参数值和Lookup表达式读取的请求数据构建缓存Key；
+// 参数解析失败，或者Lookup读取失败、读取值为空时，返回false，不使用缓存。
+func (p *PermissionFilter) cacheKey(ctx flux.Context, services []flux.BackendService, ids []string) (string, bool) {
+	endpoint := ctx.Endpoint()
+	parts := make([]string, 0, 2+len(services)+len(p.lookupKeys))
+	parts = append(parts, endpoint.HttpMethod+":"+endpoint.HttpPattern, strings.Join(ids, ","))
+	for _, service := range services {
+		values := make(map[string]interface{}, len(service.Arguments))
+		for _, arg := range service.Arguments {
+			value, err := arg.Resolve(ctx)
+			if nil != err {
+				logger.TraceContext(ctx).Warnw("Permission resolve cache key argument failed", "argument", arg.Name, "error", err)
+				return "", false
+			}
+			values[arg.Name] = value
+		}
+		text, err := _json.MarshalToString(values)
+		if nil != err {
+			logger.TraceContext(ctx).Warnw("Permission encode cache key arguments failed", "service", service.ServiceID(), "error", err)
+			return "", false
+		}
+		parts = append(parts, text)
+	}
+	for _, key := range p.lookupKeys {
+		value, err := support.LookupContextByExpr(key, ctx)
+		if nil != err || "" == cast.ToString(value) {
+			logger.TraceContext(ctx).Debugw("Permission lookup cache key empty, skip cache", "lookup", key, "error", err)
+			return "", false
+		}
+		parts = append(parts, cast.ToString(value))
+	}
+	return strings.Join(parts, "|"), true
+}
+
 // InvokeCodec 执行权限验证的后端服务，获取响应结果；
 func (p *PermissionFilter) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
 	return backend.DoInvokeCodec(ctx, service)
@@ -0,0 +1,194 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/support"
+	"github.com/spf13/cast"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeIdRateLimitFilter = "RateLimitFilter"
+)
+
+// 限流配置
+const (
+	RateLimitConfigKeyAlgorithm = "ratelimit-algorithm"
+	RateLimitConfigKeyLimit     = "ratelimit-limit"
+	RateLimitConfigKeyWindow    = "ratelimit-window"
+	RateLimitConfigKeyBurst     = "ratelimit-burst"
+	RateLimitConfigKeyKeys      = "ratelimit-keys"
+)
+
+// 限流Key的组成部分；除以下固定值外，为Lookup表达式，例如 header:X-App-Key
+const (
+	RateLimitKeyEndpoint    = "endpoint"
+	RateLimitKeyApplication = "application"
+)
+
+func init() {
+	ext.StoreTypedFactory(TypeIdRateLimitFilter, func() interface{} {
+		return NewRateLimitFilter()
+	})
+}
+
+// RateLimitFilter 按Endpoint、应用或Lookup表达式读取的Key进行请求限流，支持令牌桶和滑动窗口算法；
+// 响应 RateLimit-Limit/RateLimit-Remaining/RateLimit-Reset Header；超过限制时返回429，并响应 Retry-After Header。
+// Endpoint可通过扩展属性 ratelimit-limit/ratelimit-window 覆盖限流配置。
+type RateLimitFilter struct {
+	Disabled  bool
+	SkipFunc  flux.FilterSkipper
+	algorithm string
+	limit     int
+	window    time.Duration
+	burst     int
+	keys      []string
+	limiters  *LRUCache
+	mu        sync.Mutex
+}
+
+func NewRateLimitFilter() *RateLimitFilter {
+	return &RateLimitFilter{}
+}
+
+func (r *RateLimitFilter) TypeId() string {
+	return TypeIdRateLimitFilter
+}
+
+func (r *RateLimitFilter) Init(config *flux.Configuration) error {
+	logger.Info("RateLimit filter initializing")
+	config.SetDefaults(map[string]interface{}{
+		RateLimitConfigKeyAlgorithm: RateLimitAlgorithmTokenBucket,
+		RateLimitConfigKeyLimit:     100,
+		RateLimitConfigKeyWindow:    "1s",
+		RateLimitConfigKeyBurst:     0,
+		RateLimitConfigKeyKeys:      []string{RateLimitKeyEndpoint},
+		ConfigKeyCacheSize:          10000,
+		ConfigKeyDisabled:           false,
+	})
+	r.Disabled = config.GetBool(ConfigKeyDisabled)
+	if r.Disabled {
+		logger.Info("RateLimit filter was DISABLED!!")
+		return nil
+	}
+	if nil == r.SkipFunc {
+		r.SkipFunc = func(_ flux.Context) bool {
+			return false
+		}
+	}
+	r.algorithm = strings.ToLower(config.GetString(RateLimitConfigKeyAlgorithm))
+	if RateLimitAlgorithmTokenBucket != r.algorithm && RateLimitAlgorithmSlidingWindow != r.algorithm {
+		return fmt.Errorf("RateLimitFilter unsupported algorithm: %s", r.algorithm)
+	}
+	r.limit = config.GetInt(RateLimitConfigKeyLimit)
+	r.window = config.GetDuration(RateLimitConfigKeyWindow)
+	if r.limit <= 0 || r.window <= 0 {
+		return fmt.Errorf("RateLimitFilter requires positive %s and %s", RateLimitConfigKeyLimit, RateLimitConfigKeyWindow)
+	}
+	r.burst = config.GetInt(RateLimitConfigKeyBurst)
+	r.keys = config.GetStringSlice(RateLimitConfigKeyKeys)
+	for _, key := range r.keys {
+		if RateLimitKeyEndpoint == key || RateLimitKeyApplication == key {
+			continue
+		}
+		if _, _, ok := support.ParseLookupExpr(key); !ok {
+			return fmt.Errorf("RateLimitFilter illegal lookup expr: %s", key)
+		}
+	}
+	r.limiters = NewLRUCache(config.GetInt(ConfigKeyCacheSize))
+	return nil
+}
+
+func (r *RateLimitFilter) DoFilter(next flux.FilterHandler) flux.FilterHandler {
+	if r.Disabled {
+		return next
+	}
+	return func(ctx flux.Context) *flux.ServeError {
+		if r.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		result := r.Allow(ctx, time.Now())
+		ctx.AddMetric("M-"+r.TypeId(), ctx.ElapsedTime())
+		if !result.Allowed {
+			header := http.Header{}
+			r.setHeaders(header.Set, result)
+			return &flux.ServeError{
+				StatusCode: flux.StatusTooManyRequests,
+				ErrorCode:  flux.ErrorCodeRateLimited,
+				Message:    flux.ErrorMessageRateLimitExceeded,
+				Header:     header,
+			}
+		}
+		serr := next(ctx)
+		// 后端服务响应时会替换全部Header，限流Header在其后设置
+		if resp := ctx.Response(); nil != resp {
+			r.setHeaders(resp.SetHeader, result)
+		}
+		return serr
+	}
+}
+
+// Allow 检查当前请求是否超过限流配额
+func (r *RateLimitFilter) Allow(ctx flux.Context, now time.Time) RateLimitResult {
+	endpoint := ctx.Endpoint()
+	limit, window := r.limit, r.window
+	if v := endpoint.ExtInt(flux.EndpointExtKeyRateLimit); v > 0 {
+		limit = v
+	}
+	if v, err := time.ParseDuration(endpoint.ExtString(flux.EndpointExtKeyRateLimitWindow)); nil == err && v > 0 {
+		window = v
+	}
+	parts := make([]string, 0, len(r.keys)+1)
+	for _, key := range r.keys {
+		switch key {
+		case RateLimitKeyEndpoint:
+			parts = append(parts, endpoint.HttpMethod+":"+endpoint.HttpPattern)
+		case RateLimitKeyApplication:
+			parts = append(parts, endpoint.Application)
+		default:
+			value, err := support.LookupContextByExpr(key, ctx)
+			if nil != err {
+				logger.TraceContext(ctx).Warnw("RateLimit lookup key failed", "lookup", key, "error", err)
+			}
+			parts = append(parts, cast.ToString(value))
+		}
+	}
+	parts = append(parts, strconv.Itoa(limit)+"/"+window.String())
+	return r.limiterOf(strings.Join(parts, "|"), limit, window).Allow(now)
+}
+
+func (r *RateLimitFilter) limiterOf(key string, limit int, window time.Duration) RateLimiter {
+	if v, _, ok := r.limiters.Get(key); ok {
+		return v.(RateLimiter)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v, _, ok := r.limiters.Get(key); ok {
+		return v.(RateLimiter)
+	}
+	limiter := NewRateLimiter(r.algorithm, limit, window, r.burst)
+	// 限流器不过期，由LRU容量淘汰
+	r.limiters.Store(key, limiter, 0)
+	return limiter
+}
+
+func (r *RateLimitFilter) setHeaders(set func(name, value string), result RateLimitResult) {
+	set(flux.HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+	set(flux.HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+	set(flux.HeaderRateLimitReset, strconv.Itoa(ceilSeconds(result.Reset)))
+	if !result.Allowed {
+		set(flux.HeaderRetryAfter, strconv.Itoa(int(math.Max(1, float64(ceilSeconds(result.RetryAfter))))))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
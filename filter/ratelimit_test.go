@@ -0,0 +1,129 @@
+package filter
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type testEndpointContext struct {
+	*support.ValuesContext
+	endpoint flux.Endpoint
+}
+
+func (c *testEndpointContext) Endpoint() flux.Endpoint {
+	return c.endpoint
+}
+
+func newTestRateLimitFilter(t *testing.T, values map[string]interface{}) *RateLimitFilter {
+	config := flux.NewConfiguration(nil)
+	for k, v := range values {
+		config.Set(k, v)
+	}
+	filter := NewRateLimitFilter()
+	assert2.Nil(t, filter.Init(config))
+	return filter
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	assert := assert2.New(t)
+	limiter := NewTokenBucketLimiter(2, time.Second, 3)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		result := limiter.Allow(now)
+		assert.True(result.Allowed)
+		assert.Equal(2-i, result.Remaining)
+	}
+	result := limiter.Allow(now)
+	assert.False(result.Allowed)
+	assert.Equal(500*time.Millisecond, result.RetryAfter)
+	assert.Equal(1500*time.Millisecond, result.Reset)
+	// 每秒补充2个令牌
+	assert.True(limiter.Allow(now.Add(500 * time.Millisecond)).Allowed)
+	assert.False(limiter.Allow(now.Add(500 * time.Millisecond)).Allowed)
+	result = limiter.Allow(now.Add(5 * time.Second))
+	assert.True(result.Allowed)
+	assert.Equal(2, result.Remaining)
+}
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	assert := assert2.New(t)
+	limiter := NewSlidingWindowLimiter(4, time.Second)
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		assert.True(limiter.Allow(now).Allowed)
+	}
+	result := limiter.Allow(now.Add(200 * time.Millisecond))
+	assert.False(result.Allowed)
+	assert.Equal(800*time.Millisecond, result.Reset)
+	assert.Equal(800*time.Millisecond, result.RetryAfter)
+	// 下一个窗口的起始，前一个窗口的请求仍占满配额
+	result = limiter.Allow(now.Add(time.Second))
+	assert.False(result.Allowed)
+	assert.Equal(250*time.Millisecond, result.RetryAfter)
+	result = limiter.Allow(now.Add(1250 * time.Millisecond))
+	assert.True(result.Allowed)
+	assert.Equal(0, result.Remaining)
+	assert.False(limiter.Allow(now.Add(1250 * time.Millisecond)).Allowed)
+	// 超过两个窗口后计数清零
+	result = limiter.Allow(now.Add(3 * time.Second))
+	assert.True(result.Allowed)
+	assert.Equal(3, result.Remaining)
+}
+
+func TestRateLimitFilter_DoFilter(t *testing.T) {
+	cases := []struct {
+		algorithm string
+	}{
+		{algorithm: RateLimitAlgorithmTokenBucket},
+		{algorithm: RateLimitAlgorithmSlidingWindow},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		filter := newTestRateLimitFilter(t, map[string]interface{}{
+			RateLimitConfigKeyAlgorithm: tcase.algorithm,
+			RateLimitConfigKeyLimit:     2,
+			RateLimitConfigKeyWindow:    "1m",
+			RateLimitConfigKeyKeys:      []string{RateLimitKeyEndpoint, "header:X-App-Key"},
+		})
+		handler := filter.DoFilter(func(flux.Context) *flux.ServeError {
+			return nil
+		})
+		for i := 0; i < 2; i++ {
+			assert.Nil(handler(support.NewValuesContext(map[string]interface{}{"X-App-Key": "app1"})), tcase.algorithm)
+		}
+		serr := handler(support.NewValuesContext(map[string]interface{}{"X-App-Key": "app1"}))
+		assert.NotNil(serr, tcase.algorithm)
+		assert.Equal(flux.StatusTooManyRequests, serr.StatusCode, tcase.algorithm)
+		assert.Equal(flux.ErrorCodeRateLimited, serr.ErrorCode, tcase.algorithm)
+		assert.Equal("2", serr.Header.Get(flux.HeaderRateLimitLimit), tcase.algorithm)
+		assert.Equal("0", serr.Header.Get(flux.HeaderRateLimitRemaining), tcase.algorithm)
+		assert.NotEqual("", serr.Header.Get(flux.HeaderRetryAfter), tcase.algorithm)
+		// 不同的Key独立限流
+		assert.Nil(handler(support.NewValuesContext(map[string]interface{}{"X-App-Key": "app2"})), tcase.algorithm)
+		// Endpoint扩展属性覆盖限流配置
+		endpoint := flux.Endpoint{HttpMethod: "GET", HttpPattern: "/api/limited"}
+		endpoint.Extensions = map[string]interface{}{flux.EndpointExtKeyRateLimit: 1}
+		values := support.NewValuesContext(map[string]interface{}{"X-App-Key": "app1"}).(*support.ValuesContext)
+		ctx := &testEndpointContext{ValuesContext: values, endpoint: endpoint}
+		assert.Nil(handler(ctx), tcase.algorithm)
+		assert.NotNil(handler(ctx), tcase.algorithm)
+	}
+}
+
+func TestRateLimitFilter_Init(t *testing.T) {
+	cases := []map[string]interface{}{
+		{RateLimitConfigKeyAlgorithm: "leaky-bucket"},
+		{RateLimitConfigKeyLimit: 0},
+		{RateLimitConfigKeyKeys: []string{"X-App-Key"}},
+	}
+	for _, values := range cases {
+		config := flux.NewConfiguration(nil)
+		for k, v := range values {
+			config.Set(k, v)
+		}
+		assert2.NotNil(t, NewRateLimitFilter().Init(config), values)
+	}
+}
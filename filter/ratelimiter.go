@@ -0,0 +1,140 @@
+package filter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	RateLimitAlgorithmTokenBucket   = "token-bucket"
+	RateLimitAlgorithmSlidingWindow = "sliding-window"
+)
+
+// RateLimitResult 限流检查结果
+type RateLimitResult struct {
+	Allowed    bool          // 是否允许通过
+	Limit      int           // 时间窗口内允许的请求数量
+	Remaining  int           // 剩余可用的请求数量
+	Reset      time.Duration // 配额完全恢复的剩余时间
+	RetryAfter time.Duration // 被拒绝时，建议的重试等待时间
+}
+
+// RateLimiter 限流器
+type RateLimiter interface {
+	// Allow 尝试获取一个请求配额
+	Allow(now time.Time) RateLimitResult
+}
+
+// NewRateLimiter 根据算法名称创建限流器；未知算法使用令牌桶
+func NewRateLimiter(algorithm string, limit int, window time.Duration, burst int) RateLimiter {
+	if RateLimitAlgorithmSlidingWindow == algorithm {
+		return NewSlidingWindowLimiter(limit, window)
+	}
+	return NewTokenBucketLimiter(limit, window, burst)
+}
+
+// TokenBucketLimiter 令牌桶限流：令牌以 limit/window 的速率补充，桶容量为burst，允许短时突发
+type TokenBucketLimiter struct {
+	limit  int
+	burst  float64
+	rate   float64 // 每秒补充的令牌数量
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+func NewTokenBucketLimiter(limit int, window time.Duration, burst int) *TokenBucketLimiter {
+	if burst <= 0 {
+		burst = limit
+	}
+	return &TokenBucketLimiter{
+		limit:  limit,
+		burst:  float64(burst),
+		rate:   float64(limit) / window.Seconds(),
+		tokens: float64(burst),
+	}
+}
+
+func (b *TokenBucketLimiter) Allow(now time.Time) RateLimitResult {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	result := RateLimitResult{Limit: b.limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = b.durationOf(1 - b.tokens)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = b.durationOf(b.burst - b.tokens)
+	return result
+}
+
+func (b *TokenBucketLimiter) durationOf(tokens float64) time.Duration {
+	return time.Duration(tokens / b.rate * float64(time.Second))
+}
+
+// SlidingWindowLimiter 滑动窗口限流：按前一个窗口计数的剩余占比与当前窗口计数之和，估算最近一个窗口内的请求数量
+type SlidingWindowLimiter struct {
+	limit    int
+	window   time.Duration
+	start    time.Time
+	previous int
+	current  int
+	mu       sync.Mutex
+}
+
+func NewSlidingWindowLimiter(limit int, window time.Duration) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{
+		limit:  limit,
+		window: window,
+	}
+}
+
+func (w *SlidingWindowLimiter) Allow(now time.Time) RateLimitResult {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.advance(now)
+	elapsed := now.Sub(w.start)
+	weight := float64(w.window-elapsed) / float64(w.window)
+	estimated := float64(w.previous)*weight + float64(w.current)
+	result := RateLimitResult{Limit: w.limit, Reset: w.window - elapsed}
+	if estimated+1 <= float64(w.limit) {
+		w.current++
+		result.Allowed = true
+		result.Remaining = int(float64(w.limit) - estimated - 1)
+		return result
+	}
+	// 等待前一个窗口的计数占比下降，直至可以容纳一个请求；最长等待至当前窗口结束
+	result.RetryAfter = result.Reset
+	if w.previous > 0 {
+		over := estimated + 1 - float64(w.limit)
+		if wait := time.Duration(over / float64(w.previous) * float64(w.window)); wait < result.RetryAfter {
+			result.RetryAfter = wait
+		}
+	}
+	return result
+}
+
+func (w *SlidingWindowLimiter) advance(now time.Time) {
+	if w.start.IsZero() {
+		w.start = now
+		return
+	}
+	passed := int(now.Sub(w.start) / w.window)
+	if passed <= 0 {
+		return
+	}
+	if passed == 1 {
+		w.previous = w.current
+	} else {
+		w.previous = 0
+	}
+	w.current = 0
+	w.start = w.start.Add(time.Duration(passed) * w.window)
+}
@@ -0,0 +1,422 @@
+package filter
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/pkg"
+	"github.com/bytepowered/flux/support"
+	"github.com/spf13/cast"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeIdResponseCacheFilter = "ResponseCacheFilter"
+)
+
+// 响应缓存配置
+const (
+	ResponseCacheConfigKeyLookupKeys           = "response-cache-lookup-keys"
+	ResponseCacheConfigKeyMethods              = "response-cache-methods"
+	ResponseCacheConfigKeyStatusCodes          = "response-cache-status-codes"
+	ResponseCacheConfigKeyStaleWhileRevalidate = "response-cache-stale-while-revalidate"
+	ResponseCacheConfigKeyStaleIfError         = "response-cache-stale-if-error"
+	ResponseCacheConfigKeyMaxBodySize          = "response-cache-max-body-size"
+	ResponseCacheConfigKeyRefreshTimeout       = "response-cache-refresh-timeout"
+)
+
+// X-Cache 响应Header的值
+const (
+	ResponseCacheHit   = "HIT"
+	ResponseCacheMiss  = "MISS"
+	ResponseCacheStale = "STALE"
+)
+
+var (
+	// 全部ResponseCacheFilter实例，共用Debug查询接口
+	responseCacheFilters   = make([]*ResponseCacheFilter, 0, 1)
+	responseCacheFiltersMu sync.Mutex
+)
+
+func init() {
+	ext.StoreTypedFactory(TypeIdResponseCacheFilter, func() interface{} {
+		return NewResponseCacheFilter()
+	})
+}
+
+// ResponseCacheEntry 缓存的后端服务响应
+type ResponseCacheEntry struct {
+	StatusCode      int
+	Headers         http.Header
+	Body            interface{}
+	Raw             bool // Body为原始字节数据：读取自io.Reader，或者序列化后的JSON数据
+	Shared          bool // 响应声明了public或s-maxage，可以返回给携带身份凭证的请求
+	StoredAt        time.Time
+	FreshUntil      time.Time
+	RevalidateUntil time.Time // 过期后，在此时间前返回旧数据，并在后台刷新
+	StaleErrorUntil time.Time // 过期后，在此时间前后端服务错误时返回旧数据
+}
+
+// ResponseCacheFilter 缓存后端服务的响应：缓存Key由Endpoint、版本号和Lookup表达式读取的请求数据组成；
+// 缓存时长可由Endpoint扩展属性 response-cache-ttl 覆盖，并遵循后端响应的Cache-Control；
+// 支持过期后返回旧数据并在后台刷新（stale-while-revalidate），以及后端服务错误时返回旧数据（stale-if-error）。
+// 携带Authorization或Cookie的请求，只缓存和使用声明了public或s-maxage的响应。
+type ResponseCacheFilter struct {
+	Disabled        bool
+	SkipFunc        flux.FilterSkipper
+	lookupKeys      []string
+	methods         map[string]bool
+	statusCodes     map[int]bool
+	ttl             time.Duration
+	staleRevalidate time.Duration
+	staleIfError    time.Duration
+	maxBodySize     int64
+	refreshTimeout  time.Duration
+	cache           *LRUCache
+	refreshing      sync.Map
+}
+
+func NewResponseCacheFilter() *ResponseCacheFilter {
+	return &ResponseCacheFilter{}
+}
+
+func (r *ResponseCacheFilter) TypeId() string {
+	return TypeIdResponseCacheFilter
+}
+
+func (r *ResponseCacheFilter) Init(config *flux.Configuration) error {
+	logger.Info("ResponseCache filter initializing")
+	config.SetDefaults(map[string]interface{}{
+		ResponseCacheConfigKeyLookupKeys:           []string{"request:uri"},
+		ResponseCacheConfigKeyMethods:              []string{http.MethodGet, http.MethodHead},
+		ResponseCacheConfigKeyStatusCodes:          []int{flux.StatusOK},
+		ResponseCacheConfigKeyStaleWhileRevalidate: "0s",
+		ResponseCacheConfigKeyStaleIfError:         "0s",
+		ResponseCacheConfigKeyMaxBodySize:          "1M",
+		ResponseCacheConfigKeyRefreshTimeout:       "5s",
+		ConfigKeyCacheExpiration:                   "1m",
+		ConfigKeyCacheSize:                         4096,
+		ConfigKeyCacheDisabled:                     false,
+		ConfigKeyDisabled:                          false,
+	})
+	r.Disabled = config.GetBool(ConfigKeyDisabled) || config.GetBool(ConfigKeyCacheDisabled)
+	if r.Disabled {
+		logger.Info("ResponseCache filter was DISABLED!!")
+		return nil
+	}
+	if nil == r.SkipFunc {
+		r.SkipFunc = func(_ flux.Context) bool {
+			return false
+		}
+	}
+	r.lookupKeys = config.GetStringSlice(ResponseCacheConfigKeyLookupKeys)
+	for _, key := range r.lookupKeys {
+		if _, _, ok := support.ParseLookupExpr(key); !ok {
+			return fmt.Errorf("ResponseCacheFilter illegal lookup expr: %s", key)
+		}
+	}
+	r.methods = make(map[string]bool)
+	for _, method := range config.GetStringSlice(ResponseCacheConfigKeyMethods) {
+		r.methods[strings.ToUpper(method)] = true
+	}
+	r.statusCodes = make(map[int]bool)
+	for _, code := range cast.ToIntSlice(config.Get(ResponseCacheConfigKeyStatusCodes)) {
+		r.statusCodes[code] = true
+	}
+	r.ttl = config.GetDuration(ConfigKeyCacheExpiration)
+	r.staleRevalidate = config.GetDuration(ResponseCacheConfigKeyStaleWhileRevalidate)
+	r.staleIfError = config.GetDuration(ResponseCacheConfigKeyStaleIfError)
+	size, err := pkg.ParseByteSize(config.GetString(ResponseCacheConfigKeyMaxBodySize))
+	if nil != err {
+		return fmt.Errorf("ResponseCacheFilter config %s: %w", ResponseCacheConfigKeyMaxBodySize, err)
+	}
+	r.maxBodySize = size
+	r.refreshTimeout = config.GetDuration(ResponseCacheConfigKeyRefreshTimeout)
+	r.cache = NewLRUCache(config.GetInt(ConfigKeyCacheSize))
+	responseCacheFiltersMu.Lock()
+	defer responseCacheFiltersMu.Unlock()
+	if len(responseCacheFilters) == 0 {
+		ext.StoreDebugQueryFunc("/debug/response-cache", func(request *http.Request) interface{} {
+			responseCacheFiltersMu.Lock()
+			filters := append([]*ResponseCacheFilter(nil), responseCacheFilters...)
+			responseCacheFiltersMu.Unlock()
+			return queryResponseCaches(request, filters...)
+		})
+	}
+	responseCacheFilters = append(responseCacheFilters, r)
+	return nil
+}
+
+func (r *ResponseCacheFilter) DoFilter(next flux.FilterHandler) flux.FilterHandler {
+	if r.Disabled {
+		return next
+	}
+	return func(ctx flux.Context) *flux.ServeError {
+		if r.SkipFunc(ctx) || nil == ctx.Response() || !r.methods[ctx.Method()] {
+			return next(ctx)
+		}
+		endpoint := ctx.Endpoint()
+		ttl := r.ttl
+		if v := endpoint.ExtString(flux.EndpointExtKeyResponseCacheTTL); "" != v {
+			if d, err := time.ParseDuration(v); nil == err {
+				ttl = d
+			}
+		}
+		if ttl <= 0 || endpoint.ExtBool(flux.EndpointExtKeyResponseStreaming) {
+			return next(ctx)
+		}
+		key := r.KeyOf(ctx)
+		now := time.Now()
+		// 携带身份凭证的请求，只使用可共享的缓存数据
+		credentialed := IsCredentialedRequest(ctx)
+		var stale *ResponseCacheEntry
+		if v, _, ok := r.cache.Get(key); ok && (!credentialed || v.(*ResponseCacheEntry).Shared) {
+			entry := v.(*ResponseCacheEntry)
+			if now.Before(entry.FreshUntil) {
+				r.writeEntry(ctx, entry, ResponseCacheHit, now)
+				return nil
+			}
+			if snapshotter, ok := ctx.(flux.ContextSnapshotter); ok && now.Before(entry.RevalidateUntil) {
+				r.writeEntry(ctx, entry, ResponseCacheStale, now)
+				r.revalidate(ctx, snapshotter, key, ttl, credentialed)
+				return nil
+			}
+			if now.Before(entry.StaleErrorUntil) {
+				stale = entry
+			}
+		}
+		serr := next(ctx)
+		resp := ctx.Response()
+		if nil != stale && (nil != serr || resp.StatusCode() >= http.StatusInternalServerError) {
+			logger.TraceContext(ctx).Warnw("ResponseCache serve stale on error", "cache-key", key, "error", serr)
+			if c, ok := resp.Body().(io.Closer); ok && nil == serr {
+				_ = c.Close()
+			}
+			r.writeEntry(ctx, stale, ResponseCacheStale, now)
+			return nil
+		}
+		if nil != serr {
+			return serr
+		}
+		if entry, ok := r.newEntry(resp.StatusCode(), resp.HeaderValues(), resp.Body(), ttl, now, credentialed); ok {
+			r.cache.Store(key, entry, entry.expiration(now))
+			// 已读取的Body，以缓存数据的副本继续输出
+			if _, ok := resp.Body().(io.Reader); ok && entry.Raw {
+				resp.SetBody(bytes.NewReader(entry.Body.([]byte)))
+			}
+		} else if nil != entry {
+			// 未缓存时，恢复已读取的Body数据
+			resp.SetBody(entry.Body)
+		}
+		resp.SetHeader(flux.HeaderXCache, ResponseCacheMiss)
+		return nil
+	}
+}
+
+// KeyOf 构建请求的缓存Key：Endpoint + 版本号 + Lookup表达式读取的值
+func (r *ResponseCacheFilter) KeyOf(ctx flux.Context) string {
+	endpoint := ctx.Endpoint()
+	var sb strings.Builder
+	sb.WriteString(endpoint.HttpMethod + ":" + endpoint.HttpPattern + "@" + endpoint.Version)
+	for _, expr := range r.lookupKeys {
+		value, err := support.LookupContextByExpr(expr, ctx)
+		if nil != err {
+			logger.TraceContext(ctx).Warnw("ResponseCache lookup key failed", "lookup", expr, "error", err)
+		}
+		sb.WriteString("|")
+		sb.WriteString(cast.ToString(value))
+	}
+	return sb.String()
+}
+
+// Purge 删除Key前缀匹配的缓存项；前缀为*时删除全部；返回删除的数量
+func (r *ResponseCacheFilter) Purge(prefix string) int {
+	return r.cache.RemoveIf(func(key string) bool {
+		return "*" == prefix || strings.HasPrefix(key, prefix)
+	})
+}
+
+// DebugQuery 查询缓存数量；POST请求的参数purge指定删除的缓存Key前缀，例如 GET:/api/users
+func (r *ResponseCacheFilter) DebugQuery(request *http.Request) interface{} {
+	return queryResponseCaches(request, r)
+}
+
+// IsCredentialedRequest 返回请求是否携带身份凭证：Authorization或Cookie
+func IsCredentialedRequest(ctx flux.Context) bool {
+	return "" != ctx.Request().HeaderValue(flux.HeaderAuthorization) || "" != ctx.Request().HeaderValue(flux.HeaderCookie)
+}
+
+func queryResponseCaches(request *http.Request, filters ...*ResponseCacheFilter) interface{} {
+	prefix := request.URL.Query().Get("purge")
+	if "" != prefix && http.MethodPost != request.Method {
+		return map[string]string{"error": "purge requires POST method"}
+	}
+	size, purged := 0, 0
+	for _, f := range filters {
+		if "" != prefix {
+			purged += f.Purge(prefix)
+		}
+		size += f.cache.Len()
+	}
+	return map[string]int{"size": size, "purged": purged}
+}
+
+func (r *ResponseCacheFilter) revalidate(ctx flux.Context, snapshotter flux.ContextSnapshotter, key string, ttl time.Duration, credentialed bool) {
+	if _, loaded := r.refreshing.LoadOrStore(key, true); loaded {
+		return
+	}
+	snapshot, cancel := snapshotter.Snapshot(r.refreshTimeout)
+	go func() {
+		defer func() {
+			cancel()
+			r.refreshing.Delete(key)
+			if e := recover(); nil != e {
+				logger.Trace(snapshot.RequestId()).Errorw("ResponseCache revalidate panic", "cache-key", key, "error", e, "stack", string(debug.Stack()))
+			}
+		}()
+		resp, serr := backend.DoInvokeCodec(snapshot, snapshot.Service())
+		if nil != serr {
+			logger.Trace(snapshot.RequestId()).Warnw("ResponseCache revalidate failed", "cache-key", key, "error", serr)
+			return
+		}
+		now := time.Now()
+		if entry, ok := r.newEntry(resp.StatusCode, resp.Headers, resp.Body, ttl, now, credentialed); ok {
+			r.cache.Store(key, entry, entry.expiration(now))
+		} else if c, ok := resp.Body.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}()
+}
+
+// newEntry 按响应状态码、Cache-Control和Body大小判断是否可缓存；携带身份凭证的请求，只缓存声明了public或s-maxage的响应。
+// Body为io.Reader时读取全部数据，不可缓存时返回的Entry.Body为可继续读取的原始数据；
+// Body为对象时缓存序列化后的JSON数据，避免多个请求共享同一个对象。
+func (r *ResponseCacheFilter) newEntry(status int, header http.Header, body interface{}, ttl time.Duration, now time.Time, credentialed bool) (*ResponseCacheEntry, bool) {
+	if !r.statusCodes[status] || "" != header.Get(flux.HeaderSetCookie) {
+		return nil, false
+	}
+	directives := parseCacheControl(header.Get(flux.HeaderCacheControl))
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[d]; ok {
+			return nil, false
+		}
+	}
+	_, public := directives["public"]
+	_, smaxage := directives["s-maxage"]
+	if credentialed && !public && !smaxage {
+		return nil, false
+	}
+	if v, ok := directives["s-maxage"]; ok {
+		ttl = time.Duration(cast.ToInt64(v)) * time.Second
+	} else if v, ok := directives["max-age"]; ok {
+		ttl = time.Duration(cast.ToInt64(v)) * time.Second
+	}
+	if ttl <= 0 {
+		return nil, false
+	}
+	revalidate, staleIfError := r.staleRevalidate, r.staleIfError
+	if v, ok := directives["stale-while-revalidate"]; ok {
+		revalidate = time.Duration(cast.ToInt64(v)) * time.Second
+	}
+	if v, ok := directives["stale-if-error"]; ok {
+		staleIfError = time.Duration(cast.ToInt64(v)) * time.Second
+	}
+	entry := &ResponseCacheEntry{
+		StatusCode:      status,
+		Headers:         header.Clone(),
+		Body:            body,
+		Shared:          public || smaxage,
+		StoredAt:        now,
+		FreshUntil:      now.Add(ttl),
+		RevalidateUntil: now.Add(ttl + revalidate),
+		StaleErrorUntil: now.Add(ttl + staleIfError),
+	}
+	switch b := body.(type) {
+	case nil, string:
+		// 不可变数据，直接缓存
+	case io.Reader:
+		data, err := ioutil.ReadAll(io.LimitReader(b, r.maxBodySize+1))
+		if nil != err || int64(len(data)) > r.maxBodySize {
+			entry.Body = &cacheBodyReader{Reader: io.MultiReader(bytes.NewReader(data), b), body: b}
+			return entry, false
+		}
+		if c, ok := b.(io.Closer); ok {
+			_ = c.Close()
+		}
+		entry.Body, entry.Raw = data, true
+	default:
+		data, err := ext.JSONMarshal(b)
+		if nil != err || int64(len(data)) > r.maxBodySize {
+			return entry, false
+		}
+		entry.Body, entry.Raw = data, true
+	}
+	return entry, true
+}
+
+func (r *ResponseCacheFilter) writeEntry(ctx flux.Context, entry *ResponseCacheEntry, state string, now time.Time) {
+	resp := ctx.Response()
+	header := entry.Headers.Clone()
+	if nil == header {
+		header = http.Header{}
+	}
+	header.Set(flux.HeaderAge, strconv.Itoa(int(now.Sub(entry.StoredAt).Seconds())))
+	header.Set(flux.HeaderXCache, state)
+	resp.SetStatusCode(entry.StatusCode)
+	resp.SetHeaders(header)
+	if entry.Raw {
+		resp.SetBody(bytes.NewReader(entry.Body.([]byte)))
+	} else {
+		resp.SetBody(entry.Body)
+	}
+}
+
+// expiration 缓存项的保留时长，包含可返回旧数据的时间
+func (e *ResponseCacheEntry) expiration(now time.Time) time.Duration {
+	until := e.RevalidateUntil
+	if e.StaleErrorUntil.After(until) {
+		until = e.StaleErrorUntil
+	}
+	return until.Sub(now)
+}
+
+// cacheBodyReader 未缓存的Body：先读取已读出的数据，再读取剩余数据；关闭时关闭原始Body
+type cacheBodyReader struct {
+	io.Reader
+	body io.Reader
+}
+
+func (c *cacheBodyReader) Close() error {
+	if closer, ok := c.body.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if "" == part {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		name := strings.ToLower(strings.TrimSpace(kv[0]))
+		if len(kv) == 2 {
+			directives[name] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		} else {
+			directives[name] = ""
+		}
+	}
+	return directives
+}
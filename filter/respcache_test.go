@@ -0,0 +1,253 @@
+package filter
+
+import (
+	"context"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testResponseWriter struct {
+	status  int
+	headers http.Header
+	body    interface{}
+}
+
+func (w *testResponseWriter) SetStatusCode(status int)       { w.status = status }
+func (w *testResponseWriter) StatusCode() int                { return w.status }
+func (w *testResponseWriter) HeaderValues() http.Header      { return w.headers }
+func (w *testResponseWriter) AddHeader(name, value string)   { w.headers.Add(name, value) }
+func (w *testResponseWriter) SetHeader(name, value string)   { w.headers.Set(name, value) }
+func (w *testResponseWriter) SetHeaders(headers http.Header) { w.headers = headers }
+func (w *testResponseWriter) SetBody(body interface{})       { w.body = body }
+func (w *testResponseWriter) Body() interface{}              { return w.body }
+func (w *testResponseWriter) BodyString() string {
+	if r, ok := w.body.(io.Reader); ok {
+		data, _ := ioutil.ReadAll(r)
+		return string(data)
+	}
+	return w.body.(string)
+}
+
+type testCacheContext struct {
+	*support.ValuesContext
+	endpoint flux.Endpoint
+	response *testResponseWriter
+}
+
+func (c *testCacheContext) Endpoint() flux.Endpoint {
+	return c.endpoint
+}
+
+func (c *testCacheContext) Response() flux.ResponseWriter {
+	return c.response
+}
+
+func (c *testCacheContext) Snapshot(_ time.Duration) (flux.Context, context.CancelFunc) {
+	return support.NewValuesContext(map[string]interface{}{"service": c.ValuesContext.Service()}), func() {}
+}
+
+func newTestCacheContext(uri string, ttl string) *testCacheContext {
+	return newTestCacheContextWith(uri, ttl, nil)
+}
+
+func newTestCacheContextWith(uri string, ttl string, headers map[string]interface{}) *testCacheContext {
+	service := flux.BackendService{Interface: "test.CacheService", Method: "get"}
+	service.Attributes = []flux.Attribute{{Tag: flux.ServiceAttrTagRpcProto, Name: "RpcProto", Value: flux.ProtoLocal}}
+	endpoint := flux.Endpoint{HttpMethod: http.MethodGet, HttpPattern: "/api/cache", Version: "v1", Service: service}
+	if "" != ttl {
+		endpoint.Extensions = map[string]interface{}{flux.EndpointExtKeyResponseCacheTTL: ttl}
+	}
+	values := map[string]interface{}{
+		"method": http.MethodGet, "request-uri": uri, "service": service,
+	}
+	for k, v := range headers {
+		values[k] = v
+	}
+	return &testCacheContext{ValuesContext: support.NewValuesContext(values).(*support.ValuesContext), endpoint: endpoint,
+		response: &testResponseWriter{status: flux.StatusOK, headers: http.Header{}}}
+}
+
+func newTestResponseCacheFilter(t *testing.T, values map[string]interface{}) *ResponseCacheFilter {
+	config := flux.NewConfiguration(nil)
+	for k, v := range values {
+		config.Set(k, v)
+	}
+	filter := NewResponseCacheFilter()
+	assert2.Nil(t, filter.Init(config))
+	return filter
+}
+
+func TestResponseCacheFilter_HitAndMiss(t *testing.T) {
+	assert := assert2.New(t)
+	filter := newTestResponseCacheFilter(t, nil)
+	var calls int32
+	cacheControl := ""
+	handler := filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+		n := atomic.AddInt32(&calls, 1)
+		ctx.Response().SetHeaders(http.Header{flux.HeaderCacheControl: []string{cacheControl}})
+		ctx.Response().SetBody(ioutil.NopCloser(strings.NewReader("body-" + ctx.RequestURI() + "-" + strconv.Itoa(int(n)))))
+		return nil
+	})
+	cases := []struct {
+		uri          string
+		ttl          string
+		cacheControl string
+		xcache       string
+		body         string
+		calls        int32
+	}{
+		{uri: "/api/cache?id=1", xcache: ResponseCacheMiss, body: "body-/api/cache?id=1-1", calls: 1},
+		{uri: "/api/cache?id=1", xcache: ResponseCacheHit, body: "body-/api/cache?id=1-1", calls: 1},
+		{uri: "/api/cache?id=2", xcache: ResponseCacheMiss, body: "body-/api/cache?id=2-2", calls: 2},
+		{uri: "/api/cache?id=3", cacheControl: "no-store", xcache: ResponseCacheMiss, body: "body-/api/cache?id=3-3", calls: 3},
+		{uri: "/api/cache?id=3", cacheControl: "max-age=0", xcache: ResponseCacheMiss, body: "body-/api/cache?id=3-4", calls: 4},
+		{uri: "/api/cache?id=1", ttl: "0s", xcache: "", body: "body-/api/cache?id=1-5", calls: 5},
+	}
+	for _, tcase := range cases {
+		cacheControl = tcase.cacheControl
+		ctx := newTestCacheContext(tcase.uri, tcase.ttl)
+		assert.Nil(handler(ctx), tcase.uri)
+		assert.Equal(tcase.xcache, ctx.response.headers.Get(flux.HeaderXCache), tcase.uri)
+		assert.Equal(tcase.body, ctx.response.BodyString(), tcase.uri)
+		assert.Equal(tcase.calls, atomic.LoadInt32(&calls), tcase.uri)
+	}
+	// 清除缓存：只允许POST请求
+	request := httptest.NewRequest(http.MethodGet, "/debug/response-cache?purge=GET:/api/cache@v1", nil)
+	assert.Equal(map[string]string{"error": "purge requires POST method"}, filter.DebugQuery(request))
+	request = httptest.NewRequest(http.MethodPost, "/debug/response-cache?purge=GET:/api/cache@v1", nil)
+	assert.Equal(map[string]int{"size": 0, "purged": 2}, filter.DebugQuery(request))
+}
+
+func TestResponseCacheFilter_Credentialed(t *testing.T) {
+	assert := assert2.New(t)
+	filter := newTestResponseCacheFilter(t, nil)
+	var calls int32
+	cacheControl := ""
+	handler := filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+		n := atomic.AddInt32(&calls, 1)
+		ctx.Response().SetHeaders(http.Header{flux.HeaderCacheControl: []string{cacheControl}})
+		ctx.Response().SetBody("body-" + strconv.Itoa(int(n)))
+		return nil
+	})
+	authorized := map[string]interface{}{flux.HeaderAuthorization: "Bearer u1"}
+	cookie := map[string]interface{}{flux.HeaderCookie: "session=u2"}
+	cases := []struct {
+		uri          string
+		headers      map[string]interface{}
+		cacheControl string
+		xcache       string
+		body         string
+	}{
+		// 携带身份凭证的请求，不缓存未声明public的响应
+		{uri: "/api/me", headers: authorized, xcache: ResponseCacheMiss, body: "body-1"},
+		{uri: "/api/me", headers: cookie, xcache: ResponseCacheMiss, body: "body-2"},
+		{uri: "/api/me", xcache: ResponseCacheMiss, body: "body-3"},
+		// 匿名请求缓存的数据，不返回给携带身份凭证的请求
+		{uri: "/api/me", headers: authorized, xcache: ResponseCacheMiss, body: "body-4"},
+		{uri: "/api/me", xcache: ResponseCacheHit, body: "body-3"},
+		{uri: "/api/public", headers: authorized, cacheControl: "public", xcache: ResponseCacheMiss, body: "body-5"},
+		{uri: "/api/public", headers: cookie, xcache: ResponseCacheHit, body: "body-5"},
+		{uri: "/api/public", xcache: ResponseCacheHit, body: "body-5"},
+	}
+	for _, tcase := range cases {
+		cacheControl = tcase.cacheControl
+		ctx := newTestCacheContextWith(tcase.uri, "", tcase.headers)
+		assert.Nil(handler(ctx), tcase.uri)
+		assert.Equal(tcase.xcache, ctx.response.headers.Get(flux.HeaderXCache), tcase.body)
+		assert.Equal(tcase.body, ctx.response.BodyString(), tcase.body)
+	}
+}
+
+func TestResponseCacheFilter_ObjectBody(t *testing.T) {
+	assert := assert2.New(t)
+	ext.StoreSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	filter := newTestResponseCacheFilter(t, nil)
+	handler := filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+		ctx.Response().SetBody(map[string]interface{}{"id": 1})
+		return nil
+	})
+	ctx := newTestCacheContext("/api/object", "")
+	assert.Nil(handler(ctx))
+	// 修改原始响应对象，不影响缓存数据
+	ctx.response.body.(map[string]interface{})["id"] = 2
+	ctx = newTestCacheContext("/api/object", "")
+	assert.Nil(handler(ctx))
+	assert.Equal(ResponseCacheHit, ctx.response.headers.Get(flux.HeaderXCache))
+	assert.Equal(`{"id":1}`, ctx.response.BodyString())
+}
+
+func TestResponseCacheFilter_StaleIfError(t *testing.T) {
+	assert := assert2.New(t)
+	filter := newTestResponseCacheFilter(t, map[string]interface{}{
+		ResponseCacheConfigKeyStaleIfError: "1m",
+	})
+	failed := false
+	handler := filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+		if failed {
+			return &flux.ServeError{StatusCode: flux.StatusBadGateway, Message: "failed"}
+		}
+		ctx.Response().SetBody("cached")
+		return nil
+	})
+	assert.Nil(handler(newTestCacheContext("/api/cache", "10ms")))
+	time.Sleep(20 * time.Millisecond)
+	failed = true
+	ctx := newTestCacheContext("/api/cache", "10ms")
+	assert.Nil(handler(ctx))
+	assert.Equal(ResponseCacheStale, ctx.response.headers.Get(flux.HeaderXCache))
+	assert.Equal("cached", ctx.response.BodyString())
+	// 未缓存的请求，返回原始错误
+	assert.NotNil(handler(newTestCacheContext("/api/other", "10ms")))
+}
+
+func TestResponseCacheFilter_StaleWhileRevalidate(t *testing.T) {
+	assert := assert2.New(t)
+	var refreshes int32
+	ext.StoreLocalHandler("test.CacheService", "get", func(ctx flux.Context, args map[string]interface{}) (*flux.BackendResponse, *flux.ServeError) {
+		atomic.AddInt32(&refreshes, 1)
+		return &flux.BackendResponse{StatusCode: flux.StatusOK, Body: "refreshed"}, nil
+	})
+	filter := newTestResponseCacheFilter(t, map[string]interface{}{
+		ResponseCacheConfigKeyStaleWhileRevalidate: "1m",
+	})
+	handler := filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+		ctx.Response().SetBody("origin")
+		return nil
+	})
+	assert.Nil(handler(newTestCacheContext("/api/cache", "10ms")))
+	time.Sleep(20 * time.Millisecond)
+	ctx := newTestCacheContext("/api/cache", "10ms")
+	assert.Nil(handler(ctx))
+	assert.Equal(ResponseCacheStale, ctx.response.headers.Get(flux.HeaderXCache))
+	assert.Equal("origin", ctx.response.BodyString())
+	// 后台刷新完成后，返回新数据
+	for i := 0; i < 100 && atomic.LoadInt32(&refreshes) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 100; i++ {
+		ctx = newTestCacheContext("/api/cache", "10ms")
+		assert.Nil(handler(ctx))
+		if ResponseCacheHit == ctx.response.headers.Get(flux.HeaderXCache) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(ResponseCacheHit, ctx.response.headers.Get(flux.HeaderXCache))
+	assert.Equal("refreshed", ctx.response.BodyString())
+}
+
+func TestParseCacheControl(t *testing.T) {
+	directives := parseCacheControl(`public, max-age=60, S-MaxAge="120", stale-while-revalidate=30`)
+	assert2.Equal(t, map[string]string{"public": "", "max-age": "60", "s-maxage": "120", "stale-while-revalidate": "30"}, directives)
+}
@@ -0,0 +1,83 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+)
+
+const (
+	TypeIdArgumentValidationFilter = "ArgumentValidationFilter"
+)
+
+func init() {
+	ext.StoreTypedFactory(TypeIdArgumentValidationFilter, func() interface{} {
+		return NewArgumentValidationFilter()
+	})
+}
+
+// ArgumentValidationFilter 在调用后端服务前，按Argument定义的校验规则检查请求参数；
+// 全部校验失败的字段错误合并为一个400错误返回，字段错误列表输出在错误详情中。
+type ArgumentValidationFilter struct {
+	Disabled bool
+	SkipFunc flux.FilterSkipper
+}
+
+func NewArgumentValidationFilter() *ArgumentValidationFilter {
+	return &ArgumentValidationFilter{}
+}
+
+func (f *ArgumentValidationFilter) TypeId() string {
+	return TypeIdArgumentValidationFilter
+}
+
+func (f *ArgumentValidationFilter) Init(config *flux.Configuration) error {
+	logger.Info("ArgumentValidation filter initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyDisabled: false,
+	})
+	f.Disabled = config.GetBool(ConfigKeyDisabled)
+	if f.Disabled {
+		logger.Info("ArgumentValidation filter was DISABLED!!")
+		return nil
+	}
+	if nil == f.SkipFunc {
+		f.SkipFunc = func(_ flux.Context) bool {
+			return false
+		}
+	}
+	return nil
+}
+
+func (f *ArgumentValidationFilter) DoFilter(next flux.FilterHandler) flux.FilterHandler {
+	if f.Disabled {
+		return next
+	}
+	return func(ctx flux.Context) *flux.ServeError {
+		if f.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		errs := ValidateArguments(ctx, ctx.Service().Arguments)
+		ctx.AddMetric("M-"+f.TypeId(), ctx.ElapsedTime())
+		if len(errs) > 0 {
+			return &flux.ServeError{
+				StatusCode: flux.StatusBadRequest,
+				ErrorCode:  flux.ErrorCodeRequestInvalid,
+				Message:    flux.ErrorMessageArgumentValidateFailed,
+				Internal:   fmt.Errorf("argument validation failed, errors: %d", len(errs)),
+				Details:    errs,
+			}
+		}
+		return next(ctx)
+	}
+}
+
+// ValidateArguments 校验参数列表，返回全部校验失败的字段错误
+func ValidateArguments(ctx flux.Context, arguments []flux.Argument) []flux.ArgumentFieldError {
+	errs := make([]flux.ArgumentFieldError, 0)
+	for _, arg := range arguments {
+		errs = append(errs, arg.Validate(ctx)...)
+	}
+	return errs
+}
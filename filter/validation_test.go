@@ -0,0 +1,46 @@
+package filter
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/support"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+)
+
+func TestArgumentValidationFilter(t *testing.T) {
+	assert := assert2.New(t)
+	filter := NewArgumentValidationFilter()
+	assert.Nil(filter.Init(flux.NewConfiguration(nil)))
+	max := 100.0
+	id := ext.NewLongArgument("id")
+	id.Validation = flux.ArgumentValidation{Required: true, Max: &max}
+	email := ext.NewStringArgument("email")
+	email.Validation = flux.ArgumentValidation{Required: true, Format: flux.ArgumentFormatEmail}
+	service := flux.BackendService{Interface: "test.ValidationService", Method: "get", Arguments: []flux.Argument{id, email}}
+	handler := filter.DoFilter(func(ctx flux.Context) *flux.ServeError {
+		return nil
+	})
+	cases := []struct {
+		query  url.Values
+		errors []flux.ArgumentFieldError
+	}{
+		{query: url.Values{"id": []string{"1"}, "email": []string{"a@b.io"}}},
+		{query: url.Values{"id": []string{"101"}}, errors: []flux.ArgumentFieldError{
+			{Field: "id", Rule: "max", Message: "must be less than or equal to 100"},
+			{Field: "email", Rule: "required", Message: "must not be empty"},
+		}},
+	}
+	for _, tcase := range cases {
+		serr := handler(support.NewValuesContext(map[string]interface{}{"service": service, "query-values": tcase.query}))
+		if len(tcase.errors) == 0 {
+			assert.Nil(serr)
+			continue
+		}
+		assert.NotNil(serr)
+		assert.Equal(flux.StatusBadRequest, serr.StatusCode)
+		assert.Equal(flux.ErrorCodeRequestInvalid, serr.ErrorCode)
+		assert.Equal(tcase.errors, serr.Details)
+	}
+}
@@ -8,6 +8,9 @@ require (
 	github.com/apache/dubbo-go-hessian2 v1.7.0
 	github.com/bwmarrin/snowflake v0.3.0
 	github.com/dubbogo/go-zookeeper v1.0.1
+	github.com/golang-jwt/jwt v3.2.2+incompatible
+	github.com/gorilla/websocket v1.4.2
+	github.com/graphql-go/graphql v0.8.1
 	github.com/jinzhu/copier v0.0.0-20190924061706-b57f9002281a // indirect
 	github.com/json-iterator/go v1.1.9
 	github.com/labstack/echo/v4 v4.1.16
@@ -15,9 +18,11 @@ require (
 	github.com/prometheus/client_golang v1.1.0
 	github.com/spf13/cast v1.3.0
 	github.com/spf13/viper v1.7.1
-	github.com/stretchr/testify v1.5.1
+	github.com/stretchr/testify v1.7.0
+	github.com/xeipuuv/gojsonschema v1.2.0
 	go.uber.org/zap v1.15.0
-	golang.org/x/net v0.0.0-20200602114024-627f9648deb9 // indirect
 	golang.org/x/sys v0.0.0-20200602225109-6fdc65e7d980 // indirect
+	google.golang.org/grpc v1.43.0
+	google.golang.org/protobuf v1.27.1
 	gopkg.in/yaml.v2 v2.3.0 // indirect
 )
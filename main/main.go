@@ -2,9 +2,16 @@ package main
 
 import (
 	"github.com/bytepowered/flux"
+	_ "github.com/bytepowered/flux/backend/composite"
 	_ "github.com/bytepowered/flux/backend/dubbo"
 	_ "github.com/bytepowered/flux/backend/echo"
+	_ "github.com/bytepowered/flux/backend/grpc"
 	_ "github.com/bytepowered/flux/backend/http"
+	_ "github.com/bytepowered/flux/backend/jsonrpc"
+	_ "github.com/bytepowered/flux/backend/local"
+	_ "github.com/bytepowered/flux/backend/mock"
+	_ "github.com/bytepowered/flux/backend/websocket"
+	_ "github.com/bytepowered/flux/filter"
 	"github.com/bytepowered/flux/server"
 	_ "github.com/bytepowered/flux/webecho"
 )
@@ -0,0 +1,33 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseByteSize 解析字节大小字符串，支持单位：B, K/KB, M/MB, G/GB，不区分大小写；例如：100K, 2MB, 1024
+func ParseByteSize(size string) (int64, error) {
+	text := strings.ToUpper(strings.TrimSpace(size))
+	if "" == text {
+		return 0, fmt.Errorf("empty byte size")
+	}
+	text = strings.TrimSuffix(text, "B")
+	multiple := int64(1)
+	switch {
+	case strings.HasSuffix(text, "K"):
+		multiple = 1 << 10
+	case strings.HasSuffix(text, "M"):
+		multiple = 1 << 20
+	case strings.HasSuffix(text, "G"):
+		multiple = 1 << 30
+	}
+	if multiple > 1 {
+		text = text[:len(text)-1]
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	if nil != err || value < 0 {
+		return 0, fmt.Errorf("illegal byte size: %s", size)
+	}
+	return value * multiple, nil
+}
@@ -0,0 +1,30 @@
+package pkg
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	cases := []struct {
+		size     string
+		expected int64
+		err      bool
+	}{
+		{size: "1024", expected: 1024},
+		{size: "100K", expected: 100 << 10},
+		{size: "100kb", expected: 100 << 10},
+		{size: "2M", expected: 2 << 20},
+		{size: "1 GB", expected: 1 << 30},
+		{size: "10B", expected: 10},
+		{size: "", err: true},
+		{size: "abc", err: true},
+		{size: "-1K", err: true},
+	}
+	assert := assert.New(t)
+	for _, tcase := range cases {
+		size, err := ParseByteSize(tcase.size)
+		assert.Equal(tcase.err, nil != err, tcase.size)
+		assert.Equal(tcase.expected, size, tcase.size)
+	}
+}
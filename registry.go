@@ -11,3 +11,13 @@ type EndpointRegistry interface {
 	WatchHttpEndpoints() (<-chan HttpEndpointEvent, error)
 	WatchBackendServices() (<-chan BackendServiceEvent, error)
 }
+
+// UpstreamClusterRegistry 上游集群元数据事件监听；EndpointRegistry的可选扩展接口
+type UpstreamClusterRegistry interface {
+	WatchUpstreamClusters() (<-chan UpstreamClusterEvent, error)
+}
+
+// IPAccessRuleRegistry IP访问规则元数据事件监听；EndpointRegistry的可选扩展接口
+type IPAccessRuleRegistry interface {
+	WatchIPAccessRules() (<-chan IPAccessRuleEvent, error)
+}
@@ -0,0 +1,48 @@
+package registry
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/remoting"
+)
+
+var (
+	invalidUpstreamClusterEvent = flux.UpstreamClusterEvent{}
+)
+
+func NewUpstreamClusterEvent(bytes []byte, etype remoting.EventType) (fxEvt flux.UpstreamClusterEvent, ok bool) {
+	// Check json text
+	size := len(bytes)
+	if size < len("{\"k\":0}") || (bytes[0] != '{' && bytes[size-1] != '}') {
+		logger.Infow("Invalid cluster event data.size", "data", string(bytes))
+		return invalidUpstreamClusterEvent, false
+	}
+	cluster := flux.UpstreamCluster{}
+	if err := ext.JSONUnmarshal(bytes, &cluster); nil != err {
+		logger.Warnw("Invalid cluster data",
+			"event-type", etype, "data", string(bytes), "error", err)
+		return invalidUpstreamClusterEvent, false
+	}
+	logger.Infow("Received cluster event",
+		"event-type", etype, "cluster-name", cluster.Name, "data", string(bytes))
+	// 检查有效性
+	if !cluster.IsValid() {
+		logger.Warnw("illegal upstream cluster", "cluster", cluster)
+		return invalidUpstreamClusterEvent, false
+	}
+	event := flux.UpstreamClusterEvent{
+		Cluster: cluster,
+	}
+	switch etype {
+	case remoting.EventTypeNodeAdd:
+		event.EventType = flux.EventTypeAdded
+	case remoting.EventTypeNodeDelete:
+		event.EventType = flux.EventTypeRemoved
+	case remoting.EventTypeNodeUpdate:
+		event.EventType = flux.EventTypeUpdated
+	default:
+		return invalidUpstreamClusterEvent, false
+	}
+	return event, true
+}
@@ -13,12 +13,16 @@ import (
 
 const (
 	// 在ZK注册的根节点。需要与客户端的注册保持一致。
-	zkRegistryHttpEndpointPath   = "/flux-endpoint"
-	zkRegistryBackendServicePath = "/flux-service"
+	zkRegistryHttpEndpointPath    = "/flux-endpoint"
+	zkRegistryBackendServicePath  = "/flux-service"
+	zkRegistryUpstreamClusterPath = "/flux-cluster"
+	zkRegistryIPAccessRulePath    = "/flux-ipaccess"
 )
 
 var (
-	_ flux.EndpointRegistry = new(DefaultRegistry)
+	_ flux.EndpointRegistry        = new(DefaultRegistry)
+	_ flux.UpstreamClusterRegistry = new(DefaultRegistry)
+	_ flux.IPAccessRuleRegistry    = new(DefaultRegistry)
 )
 
 type (
@@ -31,8 +35,12 @@ type DefaultRegistry struct {
 	globalAlias    map[string]string
 	endpointPath   string
 	servicePath    string
+	clusterPath    string
+	ipaccessPath   string
 	endpointEvents chan flux.HttpEndpointEvent
 	serviceEvents  chan flux.BackendServiceEvent
+	clusterEvents  chan flux.UpstreamClusterEvent
+	ipaccessEvents chan flux.IPAccessRuleEvent
 	retrievers     []*zk.ZookeeperRetriever
 }
 
@@ -54,6 +62,8 @@ func NewDefaultRegistryFactoryWith(opts ...Option) ext.EndpointRegistryFactory {
 		r := &DefaultRegistry{
 			endpointEvents: make(chan flux.HttpEndpointEvent, 4),
 			serviceEvents:  make(chan flux.BackendServiceEvent, 4),
+			clusterEvents:  make(chan flux.UpstreamClusterEvent, 4),
+			ipaccessEvents: make(chan flux.IPAccessRuleEvent, 4),
 		}
 		for _, opt := range opts {
 			opt(r)
@@ -67,6 +77,8 @@ func (r *DefaultRegistry) Init(config *flux.Configuration) error {
 	config.SetDefaults(map[string]interface{}{
 		"endpoint-path": zkRegistryHttpEndpointPath,
 		"service-path":  zkRegistryBackendServicePath,
+		"cluster-path":  zkRegistryUpstreamClusterPath,
+		"ipaccess-path": zkRegistryIPAccessRulePath,
 	})
 	active := config.GetStringSlice("registry-active")
 	if len(active) == 0 {
@@ -75,6 +87,8 @@ func (r *DefaultRegistry) Init(config *flux.Configuration) error {
 	logger.Infow("ZookeeperRegistry active registry", "active-ids", active)
 	r.endpointPath = config.GetString("endpoint-path")
 	r.servicePath = config.GetString("service-path")
+	r.clusterPath = config.GetString("cluster-path")
+	r.ipaccessPath = config.GetString("ipaccess-path")
 	if r.endpointPath == "" || r.servicePath == "" {
 		return errors.New("config(endpoint-path, service-path) is empty")
 	}
@@ -141,6 +155,54 @@ func (r *DefaultRegistry) WatchBackendServices() (<-chan flux.BackendServiceEven
 	return r.serviceEvents, nil
 }
 
+// WatchUpstreamClusters Listen upstream clusters events
+func (r *DefaultRegistry) WatchUpstreamClusters() (<-chan flux.UpstreamClusterEvent, error) {
+	listener := func(event remoting.NodeEvent) {
+		defer func() {
+			if r := recover(); nil != r {
+				logger.Errorw("ZookeeperRegistry node listening", "event", event, "error", r)
+			}
+		}()
+		if evt, ok := NewUpstreamClusterEvent(event.Data, event.EventType); ok {
+			r.clusterEvents <- evt
+		}
+	}
+	if "" == r.clusterPath {
+		return r.clusterEvents, nil
+	}
+	logger.Infow("ZookeeperRegistry start listen clusters node", "node-path", r.clusterPath)
+	for _, retriever := range r.retrievers {
+		if err := r.watch(retriever, r.clusterPath, listener); err != nil {
+			return nil, err
+		}
+	}
+	return r.clusterEvents, nil
+}
+
+// WatchIPAccessRules Listen ip access rules events
+func (r *DefaultRegistry) WatchIPAccessRules() (<-chan flux.IPAccessRuleEvent, error) {
+	listener := func(event remoting.NodeEvent) {
+		defer func() {
+			if r := recover(); nil != r {
+				logger.Errorw("ZookeeperRegistry node listening", "event", event, "error", r)
+			}
+		}()
+		if evt, ok := NewIPAccessRuleEvent(event.Data, event.EventType); ok {
+			r.ipaccessEvents <- evt
+		}
+	}
+	if "" == r.ipaccessPath {
+		return r.ipaccessEvents, nil
+	}
+	logger.Infow("ZookeeperRegistry start listen ip access rules node", "node-path", r.ipaccessPath)
+	for _, retriever := range r.retrievers {
+		if err := r.watch(retriever, r.ipaccessPath, listener); err != nil {
+			return nil, err
+		}
+	}
+	return r.ipaccessEvents, nil
+}
+
 func (r *DefaultRegistry) watch(retriever *zk.ZookeeperRetriever, rootpath string, nodeListener func(remoting.NodeEvent)) error {
 	if exist, _ := retriever.Exists(rootpath); !exist {
 		if err := retriever.Create(rootpath); nil != err {
@@ -0,0 +1,48 @@
+package registry
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/remoting"
+)
+
+var (
+	invalidIPAccessRuleEvent = flux.IPAccessRuleEvent{}
+)
+
+func NewIPAccessRuleEvent(bytes []byte, etype remoting.EventType) (fxEvt flux.IPAccessRuleEvent, ok bool) {
+	// Check json text
+	size := len(bytes)
+	if size < len("{\"k\":0}") || (bytes[0] != '{' && bytes[size-1] != '}') {
+		logger.Infow("Invalid ip access rule event data.size", "data", string(bytes))
+		return invalidIPAccessRuleEvent, false
+	}
+	rule := flux.IPAccessRule{}
+	if err := ext.JSONUnmarshal(bytes, &rule); nil != err {
+		logger.Warnw("Invalid ip access rule data",
+			"event-type", etype, "data", string(bytes), "error", err)
+		return invalidIPAccessRuleEvent, false
+	}
+	logger.Infow("Received ip access rule event",
+		"event-type", etype, "rule-name", rule.Name, "data", string(bytes))
+	// 检查有效性
+	if !rule.IsValid() {
+		logger.Warnw("illegal ip access rule", "rule", rule)
+		return invalidIPAccessRuleEvent, false
+	}
+	event := flux.IPAccessRuleEvent{
+		Rule: rule,
+	}
+	switch etype {
+	case remoting.EventTypeNodeAdd:
+		event.EventType = flux.EventTypeAdded
+	case remoting.EventTypeNodeDelete:
+		event.EventType = flux.EventTypeRemoved
+	case remoting.EventTypeNodeUpdate:
+		event.EventType = flux.EventTypeUpdated
+	default:
+		return invalidIPAccessRuleEvent, false
+	}
+	return event, true
+}
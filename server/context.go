@@ -9,7 +9,10 @@ import (
 	"time"
 )
 
-var _ flux.Context = new(DefaultContext)
+var (
+	_ flux.Context          = new(DefaultContext)
+	_ flux.WebContextHolder = new(DefaultContext)
+)
 
 // Context接口实现
 type DefaultContext struct {
@@ -66,6 +69,15 @@ func (c *DefaultContext) Authorize() bool {
 	return c.endpoint.AttrAuthorize()
 }
 
+func (c *DefaultContext) WebContext() flux.WebContext {
+	return c.webc
+}
+
+// Snapshot 复制请求数据，返回独立超时的Context
+func (c *DefaultContext) Snapshot(timeout time.Duration) (flux.Context, context.CancelFunc) {
+	return newMirrorContext(c, c.Service(), timeout)
+}
+
 func (c *DefaultContext) Method() string {
 	return c.webc.Method()
 }
@@ -0,0 +1,585 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/spf13/cast"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	GraphQLOperationQuery    = "query"
+	GraphQLOperationMutation = "mutation"
+)
+
+const (
+	MIMEApplicationGraphQL = "application/graphql"
+)
+
+var (
+	graphqlNameInvalidChars = regexp.MustCompile(`[^_0-9A-Za-z]`)
+	// GraphQL参数允许映射的Http参数值域；Header、Attr等值域的参数，仍由原始请求提供
+	graphqlArgumentScopes = map[string]bool{
+		"":                   true,
+		flux.ScopeAuto:       true,
+		flux.ScopePath:       true,
+		flux.ScopeQuery:      true,
+		flux.ScopeQueryMulti: true,
+		flux.ScopeForm:       true,
+		flux.ScopeFormMulti:  true,
+		flux.ScopeParam:      true,
+	}
+)
+
+var (
+	// GraphQLJSON 后端服务的响应数据，以及复杂类型的参数
+	GraphQLJSON = gql.NewScalar(gql.ScalarConfig{
+		Name:        "JSON",
+		Description: "The `JSON` scalar type represents any JSON value",
+		Serialize: func(value interface{}) interface{} {
+			return value
+		},
+		ParseValue: func(value interface{}) interface{} {
+			return value
+		},
+		ParseLiteral: parseGraphQLLiteral,
+	})
+	// GraphQLLong 64位整数；GraphQL的Int类型为32位
+	GraphQLLong = gql.NewScalar(gql.ScalarConfig{
+		Name:        "Long",
+		Description: "The `Long` scalar type represents 64-bit signed integer",
+		Serialize: func(value interface{}) interface{} {
+			return cast.ToInt64(value)
+		},
+		ParseValue: func(value interface{}) interface{} {
+			if v, err := cast.ToInt64E(value); nil == err {
+				return v
+			}
+			return nil
+		},
+		ParseLiteral: func(value ast.Value) interface{} {
+			if v, ok := value.(*ast.IntValue); ok {
+				if i, err := strconv.ParseInt(v.Value, 10, 64); nil == err {
+					return i
+				}
+			}
+			return nil
+		},
+	})
+)
+
+type graphqlContextKey struct{}
+
+// GraphQLRequest GraphQL请求参数
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphqlField 由Endpoint或BackendService构建的GraphQL字段
+type graphqlField struct {
+	name      string
+	operation string
+	arguments map[string]string // GraphQL参数名 -> HttpName
+	endpoint  func(webc flux.WebContext) (*flux.Endpoint, bool)
+}
+
+// GraphQLHandler 将已注册的Endpoint，以及配置了 graphql-field 扩展属性的BackendService，构建为统一的GraphQL入口。
+// 每个字段与对应的REST端点执行相同的Filter链（权限验证、限流等），再通过 backend.DoInvokeCodec 调用后端服务；
+// 同级的query字段并发执行，mutation字段按顺序执行。
+type GraphQLHandler struct {
+	engine *HttpServeEngine
+	path   string
+	schema *gql.Schema
+	dirty  bool
+	mu     sync.Mutex
+}
+
+func NewGraphQLHandler(engine *HttpServeEngine, path string) *GraphQLHandler {
+	return &GraphQLHandler{
+		engine: engine,
+		path:   path,
+		dirty:  true,
+	}
+}
+
+// Invalidate 标记Schema失效；下次请求时重新构建
+func (h *GraphQLHandler) Invalidate() {
+	h.mu.Lock()
+	h.dirty = true
+	h.mu.Unlock()
+}
+
+// Schema 返回当前Schema；Endpoint或BackendService变更后，重新构建
+func (h *GraphQLHandler) Schema() (*gql.Schema, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.dirty && nil != h.schema {
+		return h.schema, nil
+	}
+	schema, err := h.build()
+	if nil != err {
+		return nil, err
+	}
+	h.schema, h.dirty = schema, false
+	return schema, nil
+}
+
+// ServeWebContext 处理GraphQL请求，支持GET查询参数，以及POST的JSON/GraphQL请求体
+func (h *GraphQLHandler) ServeWebContext(webc flux.WebContext) error {
+	request, err := h.parseRequest(webc)
+	if nil != err {
+		return &flux.ServeError{
+			StatusCode: flux.StatusBadRequest,
+			ErrorCode:  flux.ErrorCodeRequestInvalid,
+			Message:    flux.ErrorMessageGraphQLInvalidRequest,
+			Internal:   err,
+		}
+	}
+	schema, err := h.Schema()
+	if nil != err {
+		return &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageGraphQLSchemaInvalid,
+			Internal:   err,
+		}
+	}
+	result := gql.Do(gql.Params{
+		Schema:         *schema,
+		RequestString:  request.Query,
+		VariableValues: request.Variables,
+		OperationName:  request.OperationName,
+		Context:        context.WithValue(webc.Context(), graphqlContextKey{}, webc),
+	})
+	bytes, err := ext.LoadSerializer(ext.TypeNameSerializerJson).Marshal(result)
+	if nil != err {
+		return &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageWebServerResponseMarshal,
+			Internal:   err,
+		}
+	}
+	return webc.Write(flux.StatusOK, flux.MIMEApplicationJSONCharsetUTF8, bytes)
+}
+
+func (h *GraphQLHandler) parseRequest(webc flux.WebContext) (GraphQLRequest, error) {
+	request := GraphQLRequest{}
+	if http.MethodGet == webc.Method() {
+		request.Query = webc.QueryValue("query")
+		request.OperationName = webc.QueryValue("operationName")
+		if vars := webc.QueryValue("variables"); "" != vars {
+			if err := ext.LoadSerializer(ext.TypeNameSerializerJson).Unmarshal([]byte(vars), &request.Variables); nil != err {
+				return request, fmt.Errorf("decode variables: %w", err)
+			}
+		}
+	} else {
+		reader, err := webc.RequestBodyReader()
+		if nil != err {
+			return request, err
+		}
+		data, err := ioutil.ReadAll(reader)
+		_ = reader.Close()
+		if nil != err {
+			return request, err
+		}
+		if strings.HasPrefix(webc.HeaderValue(flux.HeaderContentType), MIMEApplicationGraphQL) {
+			request.Query = string(data)
+		} else if err := ext.LoadSerializer(ext.TypeNameSerializerJson).Unmarshal(data, &request); nil != err {
+			return request, fmt.Errorf("decode request: %w", err)
+		}
+	}
+	if "" == strings.TrimSpace(request.Query) {
+		return request, fmt.Errorf("graphql query is empty")
+	}
+	return request, nil
+}
+
+func (h *GraphQLHandler) build() (*gql.Schema, error) {
+	queries, mutations := gql.Fields{}, gql.Fields{}
+	add := func(field *graphqlField, args []flux.Argument) {
+		fields := queries
+		if GraphQLOperationMutation == field.operation {
+			fields = mutations
+		}
+		if _, ok := fields[field.name]; ok {
+			logger.Warnw("GraphQL field duplicated, ignored", "field", field.name, "operation", field.operation)
+			return
+		}
+		config := gql.FieldConfigArgument{}
+		field.arguments = make(map[string]string, len(args))
+		graphqlArgumentsOf(args, config, field.arguments)
+		fields[field.name] = &gql.Field{
+			Type:    GraphQLJSON,
+			Args:    config,
+			Resolve: h.newResolver(field),
+		}
+	}
+	// Endpoints：执行与REST端点相同的Filter链
+	endpoints := LoadEndpoints()
+	keys := make([]string, 0, len(endpoints))
+	for key := range endpoints {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		multi := endpoints[key]
+		endpoint := multi.RandomVersion()
+		if nil == endpoint || endpoint.ExtBool(flux.EndpointExtKeyGraphQLDisabled) || !endpoint.Service.IsValid() {
+			continue
+		}
+		add(&graphqlField{
+			name:      GraphQLFieldNameOf(endpoint.EmbeddedExtensions, endpoint.Service),
+			operation: GraphQLOperationOf(endpoint.EmbeddedExtensions, endpoint.HttpMethod),
+			endpoint: func(webc flux.WebContext) (*flux.Endpoint, bool) {
+				return multi.FindByVersion(h.engine.versionLookup(webc))
+			},
+		}, endpoint.Service.Arguments)
+	}
+	// BackendServices：仅开放配置了 graphql-field 扩展属性的服务
+	services := ext.LoadBackendServices()
+	ids := make([]string, 0, len(services))
+	for id, service := range services {
+		if id != service.AliasId && "" != service.ExtString(flux.EndpointExtKeyGraphQLField) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		service := services[id]
+		endpoint := &flux.Endpoint{HttpMethod: http.MethodPost, HttpPattern: h.path, Service: service}
+		add(&graphqlField{
+			name:      GraphQLFieldNameOf(service.EmbeddedExtensions, service),
+			operation: GraphQLOperationOf(service.EmbeddedExtensions, http.MethodGet),
+			endpoint: func(_ flux.WebContext) (*flux.Endpoint, bool) {
+				return endpoint, true
+			},
+		}, service.Arguments)
+	}
+	// GraphQL要求Query类型至少包含一个字段
+	if len(queries) == 0 {
+		queries["_empty"] = &gql.Field{Type: gql.Boolean}
+	}
+	config := gql.SchemaConfig{
+		Query: gql.NewObject(gql.ObjectConfig{Name: "Query", Fields: queries}),
+	}
+	if len(mutations) > 0 {
+		config.Mutation = gql.NewObject(gql.ObjectConfig{Name: "Mutation", Fields: mutations})
+	}
+	schema, err := gql.NewSchema(config)
+	if nil != err {
+		return nil, err
+	}
+	logger.Infow("GraphQL schema built", "queries", len(queries), "mutations", len(mutations))
+	return &schema, nil
+}
+
+func (h *GraphQLHandler) newResolver(field *graphqlField) gql.FieldResolveFn {
+	return func(p gql.ResolveParams) (interface{}, error) {
+		webc, ok := p.Context.Value(graphqlContextKey{}).(flux.WebContext)
+		if !ok {
+			return nil, fmt.Errorf("graphql web context not found")
+		}
+		// mutation按顺序执行
+		if GraphQLOperationMutation == field.operation {
+			return h.resolve(webc, field, p.Args)
+		}
+		// 同级query字段并发执行：返回Thunk，由执行器在全部字段解析后等待结果
+		type result struct {
+			value interface{}
+			err   error
+		}
+		done := make(chan result, 1)
+		go func() {
+			value, err := h.resolve(webc, field, p.Args)
+			done <- result{value: value, err: err}
+		}()
+		return func() (interface{}, error) {
+			r := <-done
+			if nil != r.err {
+				// Thunk返回的error会丢失extensions；以panic方式交由执行器按字段错误处理，保留错误码
+				panic(r.err)
+			}
+			return r.value, nil
+		}, nil
+	}
+}
+
+func (h *GraphQLHandler) resolve(webc flux.WebContext, field *graphqlField, args map[string]interface{}) (value interface{}, err error) {
+	requestId := cast.ToString(webc.GetValue(flux.HeaderXRequestId))
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Trace(requestId).Errorw("GraphQL resolve panics", "field", field.name, "recover", r)
+			logger.Trace(requestId).Error(string(debug.Stack()))
+			value, err = nil, &GraphQLError{ServeError: &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  flux.ErrorCodeGatewayInternal,
+				Message:    flux.ErrorMessageGraphQLResolvePanic,
+				Internal:   fmt.Errorf("graphql field: %s, panic: %v", field.name, r),
+			}}
+		}
+	}()
+	endpoint, ok := field.endpoint(webc)
+	if !ok {
+		return nil, &GraphQLError{ServeError: &flux.ServeError{
+			StatusCode: flux.StatusNotFound,
+			ErrorCode:  flux.ErrorCodeRequestNotFound,
+			Message:    flux.ErrorMessageEndpointVersionNotFound,
+		}}
+	}
+	ctxw := h.engine.acquireContext(requestId, &graphqlWebContext{WebContext: webc, values: graphqlValuesOf(field.arguments, args)}, endpoint)
+	defer h.engine.releaseContext(ctxw)
+	for _, hook := range h.engine.ctxHooks {
+		hook(webc, ctxw)
+	}
+	var response *flux.BackendResponse
+	serr := h.engine.router.RouteWith(ctxw, func(ctx flux.Context) *flux.ServeError {
+		defer func() {
+			ctx.AddMetric("M-Backend", ctx.ElapsedTime())
+		}()
+		resp, serr := backend.DoInvokeCodec(ctx, ctx.Service())
+		response = resp
+		return serr
+	})
+	if nil != serr {
+		logger.TraceContext(ctxw).Errorw("GraphQL resolve error", "field", field.name, "error", serr)
+		return nil, &GraphQLError{ServeError: serr}
+	}
+	logger.TraceContext(ctxw).Infow("GraphQL resolve end", "field", field.name, "metric", ctxw.LoadMetrics())
+	if nil == response {
+		return nil, nil
+	}
+	return decodeGraphQLBody(response.Body)
+}
+
+// GraphQLError 包装ServeError，错误码及状态码输出到GraphQL错误的extensions中
+type GraphQLError struct {
+	*flux.ServeError
+}
+
+func (e *GraphQLError) Error() string {
+	return e.ServeError.Message
+}
+
+func (e *GraphQLError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":   e.ServeError.GetErrorCode(),
+		"status": e.ServeError.StatusCode,
+	}
+}
+
+// graphqlWebContext 以GraphQL参数作为Path/Query/Form参数；Header、Cookie等仍读取原始请求
+type graphqlWebContext struct {
+	flux.WebContext
+	values url.Values
+}
+
+func (w *graphqlWebContext) QueryValues() url.Values {
+	return w.values
+}
+
+func (w *graphqlWebContext) PathValues() url.Values {
+	return w.values
+}
+
+func (w *graphqlWebContext) FormValues() url.Values {
+	return w.values
+}
+
+func (w *graphqlWebContext) QueryValue(name string) string {
+	return w.values.Get(name)
+}
+
+func (w *graphqlWebContext) PathValue(name string) string {
+	return w.values.Get(name)
+}
+
+func (w *graphqlWebContext) FormValue(name string) string {
+	return w.values.Get(name)
+}
+
+// GraphQLFieldNameOf 返回GraphQL字段名称：优先使用扩展属性 graphql-field；
+// 否则由服务接口名称的最后一段与方法名组成，例如：UserService_getUser
+func GraphQLFieldNameOf(extensions flux.EmbeddedExtensions, service flux.BackendService) string {
+	if name := extensions.ExtString(flux.EndpointExtKeyGraphQLField); "" != name {
+		return graphqlNameOf(name)
+	}
+	iface := strings.TrimRight(service.Interface, "/.")
+	if idx := strings.LastIndexAny(iface, "/."); idx >= 0 {
+		iface = iface[idx+1:]
+	}
+	if "" == iface {
+		return graphqlNameOf(service.Method)
+	}
+	return graphqlNameOf(iface + "_" + service.Method)
+}
+
+// GraphQLOperationOf 返回GraphQL操作类型：优先使用扩展属性 graphql-operation；否则GET为query，其它为mutation
+func GraphQLOperationOf(extensions flux.EmbeddedExtensions, httpMethod string) string {
+	switch strings.ToLower(extensions.ExtString(flux.EndpointExtKeyGraphQLOperation)) {
+	case GraphQLOperationQuery:
+		return GraphQLOperationQuery
+	case GraphQLOperationMutation:
+		return GraphQLOperationMutation
+	}
+	if strings.EqualFold(http.MethodGet, httpMethod) {
+		return GraphQLOperationQuery
+	}
+	return GraphQLOperationMutation
+}
+
+// GraphQLTypeOf 返回Argument对应的GraphQL输入类型
+func GraphQLTypeOf(arg flux.Argument) gql.Input {
+	switch arg.Class {
+	case flux.JavaLangStringClassName:
+		return gql.String
+	case flux.JavaLangIntegerClassName:
+		return gql.Int
+	case flux.JavaLangLongClassName:
+		return GraphQLLong
+	case flux.JavaLangFloatClassName, flux.JavaLangDoubleClassName:
+		return gql.Float
+	case flux.JavaLangBooleanClassName:
+		return gql.Boolean
+	case flux.JavaUtilListClassName:
+		return gql.NewList(gql.String)
+	default:
+		return GraphQLJSON
+	}
+}
+
+// graphqlArgumentsOf 将叶子参数按HttpName映射为GraphQL参数；POJO参数展开其字段
+func graphqlArgumentsOf(args []flux.Argument, config gql.FieldConfigArgument, names map[string]string) {
+	for _, arg := range args {
+		if nil != arg.ValueLoader {
+			continue
+		}
+		if len(arg.Fields) > 0 {
+			graphqlArgumentsOf(arg.Fields, config, names)
+			continue
+		}
+		if !graphqlArgumentScopes[strings.ToUpper(arg.HttpScope)] {
+			continue
+		}
+		httpName := arg.HttpName
+		if "" == httpName {
+			httpName = arg.Name
+		}
+		name := graphqlNameOf(httpName)
+		if _, ok := config[name]; ok {
+			continue
+		}
+		config[name] = &gql.ArgumentConfig{Type: GraphQLTypeOf(arg)}
+		names[name] = httpName
+	}
+}
+
+func graphqlValuesOf(names map[string]string, args map[string]interface{}) url.Values {
+	values := make(url.Values, len(args))
+	for name, value := range args {
+		httpName, ok := names[name]
+		if !ok || nil == value {
+			continue
+		}
+		switch v := value.(type) {
+		case []interface{}:
+			for _, item := range v {
+				values.Add(httpName, cast.ToString(item))
+			}
+		case map[string]interface{}:
+			if bytes, err := ext.LoadSerializer(ext.TypeNameSerializerJson).Marshal(v); nil == err {
+				values.Set(httpName, string(bytes))
+			}
+		default:
+			values.Set(httpName, cast.ToString(v))
+		}
+	}
+	return values
+}
+
+func graphqlNameOf(name string) string {
+	name = graphqlNameInvalidChars.ReplaceAllString(name, "_")
+	if "" == name || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+func decodeGraphQLBody(body interface{}) (interface{}, error) {
+	var data []byte
+	switch v := body.(type) {
+	case io.Reader:
+		if closer, ok := v.(io.Closer); ok {
+			defer closer.Close()
+		}
+		bs, err := ioutil.ReadAll(v)
+		if nil != err {
+			return nil, err
+		}
+		data = bs
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return body, nil
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var out interface{}
+	if err := ext.LoadSerializer(ext.TypeNameSerializerJson).Unmarshal(data, &out); nil == err {
+		return out, nil
+	}
+	return string(data), nil
+}
+
+func parseGraphQLLiteral(value ast.Value) interface{} {
+	switch v := value.(type) {
+	case *ast.StringValue:
+		return v.Value
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.IntValue:
+		if i, err := strconv.ParseInt(v.Value, 10, 64); nil == err {
+			return i
+		}
+		return v.Value
+	case *ast.FloatValue:
+		if f, err := strconv.ParseFloat(v.Value, 64); nil == err {
+			return f
+		}
+		return v.Value
+	case *ast.ListValue:
+		out := make([]interface{}, 0, len(v.Values))
+		for _, item := range v.Values {
+			out = append(out, parseGraphQLLiteral(item))
+		}
+		return out
+	case *ast.ObjectValue:
+		out := make(map[string]interface{}, len(v.Fields))
+		for _, field := range v.Fields {
+			out[field.Name.Value] = parseGraphQLLiteral(field.Value)
+		}
+		return out
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/webecho"
+	"github.com/labstack/echo/v4"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testGraphQLProto = "GRAPHQL-TEST"
+	testGraphQLHost  = "graphql.test"
+)
+
+// testGraphQLTransport 返回解析后的参数；barrier用于验证同级字段并发执行
+type testGraphQLTransport struct {
+	barrier *sync.WaitGroup
+}
+
+func (t *testGraphQLTransport) Exchange(ctx flux.Context) *flux.ServeError {
+	return nil
+}
+
+func (t *testGraphQLTransport) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	return nil, nil
+}
+
+func (t *testGraphQLTransport) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	if nil != t.barrier {
+		t.barrier.Done()
+		done := make(chan struct{})
+		go func() {
+			t.barrier.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			return nil, &flux.ServeError{StatusCode: flux.StatusServerError, Message: "NOT_PARALLEL"}
+		}
+	}
+	out := map[string]interface{}{"method": service.Method}
+	for _, arg := range service.Arguments {
+		v, err := arg.Resolve(ctx)
+		if nil != err {
+			return nil, &flux.ServeError{StatusCode: flux.StatusBadRequest, Message: "ARGUMENT", Internal: err}
+		}
+		out[arg.Name] = v
+	}
+	data, _ := json.Marshal(out)
+	return &flux.BackendResponse{StatusCode: flux.StatusOK, Headers: http.Header{}, Body: data}, nil
+}
+
+func (t *testGraphQLTransport) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return nil
+}
+
+// testGraphQLTokenFilter 模拟权限验证：请求Header缺少Token时拒绝访问
+type testGraphQLTokenFilter struct{}
+
+func (testGraphQLTokenFilter) TypeId() string {
+	return "graphql-test-token"
+}
+
+func (testGraphQLTokenFilter) DoFilter(next flux.FilterHandler) flux.FilterHandler {
+	return func(ctx flux.Context) *flux.ServeError {
+		if "" == ctx.Request().HeaderValue("X-Token") {
+			return &flux.ServeError{
+				StatusCode: flux.StatusAccessDenied,
+				ErrorCode:  flux.ErrorCodePermissionDenied,
+				Message:    flux.ErrorMessagePermissionAccessDenied,
+			}
+		}
+		return next(ctx)
+	}
+}
+
+type testGraphQLSelector struct{}
+
+func (testGraphQLSelector) Select(ctx flux.Context) flux.Activated {
+	return flux.Activated{FilterId: []string{"graphql-test-token"}}
+}
+
+var testGraphQLTransportInstance = &testGraphQLTransport{}
+
+func init() {
+	ext.StoreBackendTransport(testGraphQLProto, testGraphQLTransportInstance)
+	ext.StoreSelectiveFilter(new(testGraphQLTokenFilter))
+	ext.StoreHostedSelector(testGraphQLHost, new(testGraphQLSelector))
+	newTestGraphQLEndpoint(http.MethodGet, "/users/:id", "com.foo.UserService", "getUser",
+		ext.NewLongArgument("id"), ext.NewStringArgument("lang"))
+	newTestGraphQLEndpoint(http.MethodGet, "/orders", "com.foo.OrderService", "listOrders",
+		ext.NewIntegerArgument("page-size"))
+	newTestGraphQLEndpoint(http.MethodPost, "/users", "com.foo.UserService", "createUser",
+		ext.NewStringArgument("name"))
+}
+
+func newTestGraphQLEndpoint(method, pattern, iface, fn string, args ...flux.Argument) {
+	service := flux.BackendService{
+		Interface: iface,
+		Method:    fn,
+		Arguments: args,
+	}
+	service.Attributes = []flux.Attribute{{Tag: flux.ServiceAttrTagRpcProto, Name: "RpcProto", Value: testGraphQLProto}}
+	endpoint := &flux.Endpoint{HttpMethod: method, HttpPattern: pattern, Service: service}
+	RegisterMultiEndpoint(method+"#"+pattern, endpoint)
+}
+
+func doTestGraphQLRequest(handler *GraphQLHandler, token, body string) (int, map[string]interface{}, error) {
+	request := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	request.Host = testGraphQLHost
+	request.Header.Set(flux.HeaderContentType, flux.MIMEApplicationJSON)
+	if "" != token {
+		request.Header.Set("X-Token", token)
+	}
+	recorder := httptest.NewRecorder()
+	var webc flux.WebContext
+	webecho.RepeatableBodyReader(func(c echo.Context) error {
+		webc = webecho.NewAdaptWebContext(c, webecho.DefaultRequestBodyDecoder)
+		return nil
+	})(echo.New().NewContext(request, recorder))
+	if err := handler.ServeWebContext(webc); nil != err {
+		return 0, nil, err
+	}
+	out := make(map[string]interface{})
+	if err := json.Unmarshal(recorder.Body.Bytes(), &out); nil != err {
+		return 0, nil, fmt.Errorf("decode response: %s, err: %w", recorder.Body.String(), err)
+	}
+	return recorder.Code, out, nil
+}
+
+func TestGraphQLHandler(t *testing.T) {
+	engine := NewHttpServeEngineOverride()
+	handler := NewGraphQLHandler(engine, "/graphql")
+	assert := assert2.New(t)
+
+	// 同级query字段并发执行，参数映射到后端服务参数
+	barrier := new(sync.WaitGroup)
+	barrier.Add(2)
+	testGraphQLTransportInstance.barrier = barrier
+	status, out, err := doTestGraphQLRequest(handler, "t1",
+		`{"query":"query($size: Int){ UserService_getUser(id: 10000000000, lang: \"zh\") OrderService_listOrders(page_size: $size) }","variables":{"size":20}}`)
+	testGraphQLTransportInstance.barrier = nil
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, status)
+	assert.Nil(out["errors"])
+	data := out["data"].(map[string]interface{})
+	assert.Equal(map[string]interface{}{"method": "getUser", "id": float64(10000000000), "lang": "zh"}, data["UserService_getUser"])
+	assert.Equal(map[string]interface{}{"method": "listOrders", "page-size": float64(20)}, data["OrderService_listOrders"])
+
+	// mutation
+	_, out, err = doTestGraphQLRequest(handler, "t1", `{"query":"mutation { UserService_createUser(name: \"yongjia\") }"}`)
+	assert.Nil(err)
+	assert.Nil(out["errors"])
+	assert.Equal(map[string]interface{}{"method": "createUser", "name": "yongjia"}, out["data"].(map[string]interface{})["UserService_createUser"])
+
+	// 与REST端点相同的Filter：缺少Token时拒绝访问
+	_, out, err = doTestGraphQLRequest(handler, "", `{"query":"{ OrderService_listOrders }"}`)
+	assert.Nil(err)
+	errs := out["errors"].([]interface{})
+	assert.Equal(1, len(errs))
+	gqlerr := errs[0].(map[string]interface{})
+	assert.Equal(flux.ErrorMessagePermissionAccessDenied, gqlerr["message"])
+	assert.Equal(map[string]interface{}{"code": flux.ErrorCodePermissionDenied, "status": float64(http.StatusForbidden)}, gqlerr["extensions"])
+
+	// 无效请求
+	_, _, err = doTestGraphQLRequest(handler, "t1", `{"query":""}`)
+	serr, ok := err.(*flux.ServeError)
+	assert.True(ok)
+	assert.Equal(flux.StatusBadRequest, serr.StatusCode)
+	assert.Equal(flux.ErrorMessageGraphQLInvalidRequest, serr.Message)
+
+	// Endpoint变更后，重新构建Schema
+	newTestGraphQLEndpoint(http.MethodGet, "/items", "com.foo.ItemService", "listItems")
+	handler.Invalidate()
+	_, out, err = doTestGraphQLRequest(handler, "t1", `{"query":"{ ItemService_listItems }"}`)
+	assert.Nil(err)
+	assert.Nil(out["errors"])
+}
+
+func TestGraphQLFieldNameOf(t *testing.T) {
+	cases := []struct {
+		extensions map[string]interface{}
+		iface      string
+		method     string
+		expected   string
+	}{
+		{iface: "com.foo.UserService", method: "getUser", expected: "UserService_getUser"},
+		{iface: "/api/v1/users", method: "get-list", expected: "users_get_list"},
+		{iface: "", method: "1st", expected: "_1st"},
+		{extensions: map[string]interface{}{flux.EndpointExtKeyGraphQLField: "user"}, iface: "com.foo.UserService", method: "getUser", expected: "user"},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		service := flux.BackendService{Interface: tcase.iface, Method: tcase.method}
+		assert.Equal(tcase.expected, GraphQLFieldNameOf(flux.EmbeddedExtensions{Extensions: tcase.extensions}, service))
+	}
+}
@@ -33,6 +33,8 @@ type Metrics struct {
 	EndpointAccess *prometheus.CounterVec
 	EndpointError  *prometheus.CounterVec
 	RouteDuration  *prometheus.HistogramVec
+	MirrorTotal    *prometheus.CounterVec
+	MirrorDuration *prometheus.HistogramVec
 }
 
 func NewMetrics() *Metrics {
@@ -56,5 +58,18 @@ func NewMetrics() *Metrics {
 			Help:      "Spend time by processing a endpoint",
 			Buckets:   defaultMetricBuckets,
 		}, []string{"ComponentType", "TypeId"}),
+		MirrorTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "endpoint_mirror_total",
+			Help:      "Number of endpoint mirror requests, by shadow service and result",
+		}, []string{"ShadowService", "Result"}),
+		MirrorDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "endpoint_mirror_duration",
+			Help:      "Spend time by invoking a shadow service",
+			Buckets:   defaultMetricBuckets,
+		}, []string{"ShadowService"}),
 	}
 }
@@ -0,0 +1,624 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/cast"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// 影子调用结果
+const (
+	MirrorResultSent           = "sent"            // 已调用，未开启比较
+	MirrorResultMatch          = "match"           // 状态码与Body一致
+	MirrorResultStatusMismatch = "status-mismatch" // 状态码不一致
+	MirrorResultBodyMismatch   = "body-mismatch"   // 状态码一致，Body不一致
+	MirrorResultError          = "error"           // 影子服务调用失败
+	MirrorResultDropped        = "dropped"         // 并发影子调用超过限制，丢弃
+)
+
+const (
+	mirrorReportBodyMaxSize = 1024
+)
+
+var (
+	_mirrorJson = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+// MirrorReport 主服务与影子服务的响应差异记录
+type MirrorReport struct {
+	Time         time.Time `json:"time"`
+	RequestId    string    `json:"requestId"`
+	Endpoint     string    `json:"endpoint"`
+	Service      string    `json:"service"`
+	Shadow       string    `json:"shadow"`
+	Result       string    `json:"result"`
+	Status       int       `json:"status"`
+	ShadowStatus int       `json:"shadowStatus"`
+	Body         string    `json:"body,omitempty"`
+	ShadowBody   string    `json:"shadowBody,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Elapses      string    `json:"elapses"`
+}
+
+// Mirror 流量镜像：按Endpoint扩展属性，将请求异步地复制到影子BackendService；
+// 影子调用使用独立的超时，不影响客户端响应；可选比较主服务与影子服务的响应差异，记录到Metrics和Debug接口。
+type Mirror struct {
+	metrics *Metrics
+	timeout time.Duration
+	limit   chan struct{}
+	reports []MirrorReport
+	next    int
+	size    int
+	mu      sync.RWMutex
+}
+
+func NewMirror(metrics *Metrics) *Mirror {
+	m := &Mirror{metrics: metrics}
+	m.Configure(time.Second*3, 64, 100)
+	return m
+}
+
+// Configure 设置影子调用的默认超时、最大并发数量，以及保留的差异记录数量
+func (m *Mirror) Configure(timeout time.Duration, concurrency int, reports int) {
+	if timeout <= 0 {
+		timeout = time.Second * 3
+	}
+	if concurrency <= 0 {
+		concurrency = 64
+	}
+	if reports <= 0 {
+		reports = 100
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeout = timeout
+	m.limit = make(chan struct{}, concurrency)
+	m.reports = make([]MirrorReport, 0, reports)
+	m.next = 0
+	m.size = reports
+}
+
+// Shadow 按Endpoint配置采样，将当前请求复制到影子服务；perr为主服务调用的错误
+func (m *Mirror) Shadow(ctx flux.Context, perr *flux.ServeError) {
+	endpoint := ctx.Endpoint()
+	shadowId := endpoint.ExtString(flux.EndpointExtKeyMirrorService)
+	if "" == shadowId || !m.sampled(endpoint) {
+		return
+	}
+	// 流式请求的Body已被主服务读取，无法复制
+	if endpoint.ExtBool(flux.EndpointExtKeyRequestStreaming) {
+		return
+	}
+	service, ok := ext.LoadBackendService(shadowId)
+	if !ok {
+		logger.TraceContext(ctx).Warnw("MIRROR:SERVICE_NOT_FOUND", "shadow-id", shadowId)
+		m.metrics.MirrorTotal.WithLabelValues(shadowId, MirrorResultError).Inc()
+		return
+	}
+	transport, ok := ext.LoadBackendTransport(service.AttrRpcProto())
+	if !ok {
+		logger.TraceContext(ctx).Warnw("MIRROR:UNKNOWN_PROTOCOL", "shadow-id", shadowId, "proto", service.AttrRpcProto())
+		m.metrics.MirrorTotal.WithLabelValues(shadowId, MirrorResultError).Inc()
+		return
+	}
+	m.mu.RLock()
+	limit, timeout := m.limit, m.timeout
+	m.mu.RUnlock()
+	select {
+	case limit <- struct{}{}:
+	default:
+		m.metrics.MirrorTotal.WithLabelValues(shadowId, MirrorResultDropped).Inc()
+		return
+	}
+	if to := endpoint.ExtString(flux.EndpointExtKeyMirrorTimeout); "" != to {
+		if d, err := time.ParseDuration(to); nil == err && d > 0 {
+			timeout = d
+		}
+	}
+	report := MirrorReport{
+		RequestId: ctx.RequestId(),
+		Endpoint:  endpoint.HttpMethod + " " + endpoint.HttpPattern,
+		Service:   endpoint.Service.ServiceID(),
+		Shadow:    shadowId,
+	}
+	diff := endpoint.ExtBool(flux.EndpointExtKeyMirrorDiff)
+	var pbody []byte
+	if diff {
+		report.Status, pbody = mirrorPrimaryOf(ctx, perr)
+	}
+	shadowc, cancel := newMirrorContext(ctx, service, timeout)
+	go func() {
+		defer func() {
+			cancel()
+			<-limit
+			if r := recover(); nil != r {
+				logger.Trace(report.RequestId).Errorw("MIRROR:PANIC", "shadow-id", shadowId, "error", r, "stack", string(debug.Stack()))
+			}
+		}()
+		start := time.Now()
+		resp, serr := transport.InvokeCodec(shadowc, service)
+		elapsed := time.Since(start)
+		m.metrics.MirrorDuration.WithLabelValues(shadowId).Observe(elapsed.Seconds())
+		report.Time, report.Elapses = start, elapsed.String()
+		var sbody []byte
+		if nil != serr {
+			report.ShadowStatus, report.Error = serr.StatusCode, serr.Error()
+		} else {
+			report.ShadowStatus = resp.StatusCode
+			if diff {
+				sbody, _ = mirrorBodyOf(resp.Body)
+			} else if c, ok := resp.Body.(io.Closer); ok {
+				_ = c.Close()
+			}
+		}
+		switch {
+		case !diff && nil == serr:
+			report.Result = MirrorResultSent
+		case !diff || (nil != serr && (nil == perr || perr.StatusCode != serr.StatusCode)):
+			report.Result = MirrorResultError
+		case report.Status != report.ShadowStatus:
+			report.Result = MirrorResultStatusMismatch
+		case nil == perr && nil != pbody && !mirrorBodyEquals(pbody, sbody):
+			report.Result = MirrorResultBodyMismatch
+		default:
+			report.Result = MirrorResultMatch
+		}
+		m.metrics.MirrorTotal.WithLabelValues(shadowId, report.Result).Inc()
+		if MirrorResultMatch != report.Result && MirrorResultSent != report.Result {
+			report.Body, report.ShadowBody = mirrorTruncate(pbody), mirrorTruncate(sbody)
+			m.record(report)
+		}
+	}()
+}
+
+// Reports 返回最近的差异记录，按时间倒序
+func (m *Mirror) Reports() []MirrorReport {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]MirrorReport, 0, len(m.reports))
+	for i := 1; i <= len(m.reports); i++ {
+		out = append(out, m.reports[(m.next-i+len(m.reports))%len(m.reports)])
+	}
+	return out
+}
+
+func (m *Mirror) record(report MirrorReport) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.reports) < m.size {
+		m.reports = append(m.reports, report)
+	} else {
+		m.reports[m.next] = report
+	}
+	m.next = (m.next + 1) % m.size
+}
+
+func (m *Mirror) sampled(endpoint flux.Endpoint) bool {
+	v, ok := endpoint.Ext(flux.EndpointExtKeyMirrorPercent)
+	if !ok {
+		return true
+	}
+	percent := cast.ToFloat64(v)
+	return percent >= 100 || rand.Float64()*100 < percent
+}
+
+// mirrorPrimaryOf 读取主服务的响应状态码和Body；非流式的Reader类型Body读取后重新设置，不影响客户端响应
+func mirrorPrimaryOf(ctx flux.Context, perr *flux.ServeError) (int, []byte) {
+	if nil != perr {
+		return perr.StatusCode, nil
+	}
+	writer := ctx.Response()
+	body := writer.Body()
+	if r, ok := body.(io.Reader); ok {
+		// Endpoint声明流式响应时，Server在写入前才包装为StreamingBody，此处需直接检查Endpoint
+		if ctx.Endpoint().ExtBool(flux.EndpointExtKeyResponseStreaming) || IsStreamingResponse(writer.HeaderValues(), body) {
+			return writer.StatusCode(), nil
+		}
+		data, err := ioutil.ReadAll(r)
+		if nil != err {
+			// 保留读取错误，由ResponseWriter处理
+			writer.SetBody(&mirrorBodyReader{Reader: io.MultiReader(bytes.NewReader(data), r), body: r})
+			return writer.StatusCode(), nil
+		}
+		if c, ok := r.(io.Closer); ok {
+			_ = c.Close()
+		}
+		writer.SetBody(bytes.NewReader(data))
+		return writer.StatusCode(), data
+	}
+	data, _ := mirrorBodyOf(body)
+	return writer.StatusCode(), data
+}
+
+// mirrorBodyReader 读取失败的Body：先读取已读出的数据，再读取剩余数据；关闭时关闭原始Body
+type mirrorBodyReader struct {
+	io.Reader
+	body io.Reader
+}
+
+func (m *mirrorBodyReader) Close() error {
+	if closer, ok := m.body.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func mirrorBodyOf(body interface{}) ([]byte, error) {
+	switch b := body.(type) {
+	case nil:
+		return []byte{}, nil
+	case []byte:
+		return b, nil
+	case string:
+		return []byte(b), nil
+	case io.Reader:
+		if c, ok := b.(io.Closer); ok {
+			defer func() {
+				_ = c.Close()
+			}()
+		}
+		return ioutil.ReadAll(b)
+	default:
+		return _mirrorJson.Marshal(b)
+	}
+}
+
+// mirrorBodyEquals 比较响应Body；均为JSON时按数据结构比较，忽略字段顺序和空白
+func mirrorBodyEquals(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var av, bv interface{}
+	if nil != _mirrorJson.Unmarshal(a, &av) || nil != _mirrorJson.Unmarshal(b, &bv) {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+func mirrorTruncate(data []byte) string {
+	if len(data) > mirrorReportBodyMaxSize {
+		return string(data[:mirrorReportBodyMaxSize]) + "..."
+	}
+	return string(data)
+}
+
+////
+
+var (
+	_ flux.Context       = new(MirrorContext)
+	_ flux.RequestReader = new(MirrorRequestReader)
+)
+
+// MirrorContext 影子调用、后台刷新等异步调用的Context：复制原请求的数据，使用独立的超时；
+// 原请求结束并回收Context后，异步调用仍可安全地读取请求数据。
+type MirrorContext struct {
+	requestId  string
+	endpoint   flux.Endpoint
+	attributes *sync.Map
+	values     *sync.Map
+	metrics    []flux.Metric
+	beginTime  time.Time
+	request    *MirrorRequestReader
+	response   *DefaultResponseWriter
+	context    context.Context
+	ctxLogger  flux.Logger
+}
+
+func newMirrorContext(ctx flux.Context, shadow flux.BackendService, timeout time.Duration) (*MirrorContext, context.CancelFunc) {
+	endpoint := ctx.Endpoint()
+	endpoint.Service = shadow
+	c := &MirrorContext{
+		requestId:  ctx.RequestId(),
+		endpoint:   endpoint,
+		attributes: new(sync.Map),
+		values:     new(sync.Map),
+		metrics:    make([]flux.Metric, 0, 4),
+		beginTime:  time.Now(),
+		request:    NewMirrorRequestReader(ctx.Request()),
+		response:   NewDefaultResponseWriter(),
+		ctxLogger:  ctx.GetLogger(),
+	}
+	for k, v := range ctx.Attributes() {
+		c.attributes.Store(k, v)
+	}
+	if dc, ok := ctx.(*DefaultContext); ok {
+		dc.values.Range(func(k, v interface{}) bool {
+			c.values.Store(k, v)
+			return true
+		})
+	}
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		c.context, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		c.context, cancel = context.WithCancel(context.Background())
+	}
+	return c, cancel
+}
+
+func (c *MirrorContext) Method() string {
+	return c.request.Method()
+}
+
+func (c *MirrorContext) RequestURI() string {
+	return c.request.RequestURI()
+}
+
+func (c *MirrorContext) RequestId() string {
+	return c.requestId
+}
+
+func (c *MirrorContext) Request() flux.RequestReader {
+	return c.request
+}
+
+func (c *MirrorContext) Response() flux.ResponseWriter {
+	return c.response
+}
+
+func (c *MirrorContext) Endpoint() flux.Endpoint {
+	return c.endpoint
+}
+
+func (c *MirrorContext) Authorize() bool {
+	return c.endpoint.AttrAuthorize()
+}
+
+func (c *MirrorContext) Service() flux.BackendService {
+	return c.endpoint.Service
+}
+
+func (c *MirrorContext) ServiceInterface() (proto, host, interfaceName, methodName string) {
+	s := c.endpoint.Service
+	return s.AttrRpcProto(), s.RemoteHost, s.Interface, s.Method
+}
+
+func (c *MirrorContext) ServiceProto() string {
+	return c.endpoint.Service.AttrRpcProto()
+}
+
+func (c *MirrorContext) ServiceName() (interfaceName, methodName string) {
+	return c.endpoint.Service.Interface, c.endpoint.Service.Method
+}
+
+func (c *MirrorContext) Attributes() map[string]interface{} {
+	copied := make(map[string]interface{}, 16)
+	c.attributes.Range(func(k, v interface{}) bool {
+		copied[k.(string)] = v
+		return true
+	})
+	return copied
+}
+
+func (c *MirrorContext) GetAttribute(key string) (interface{}, bool) {
+	return c.attributes.Load(key)
+}
+
+func (c *MirrorContext) SetAttribute(name string, value interface{}) {
+	c.attributes.Store(name, value)
+}
+
+func (c *MirrorContext) GetAttributeString(key string, defaultValue string) string {
+	v, ok := c.GetAttribute(key)
+	if !ok {
+		return defaultValue
+	}
+	return cast.ToString(v)
+}
+
+func (c *MirrorContext) GetValue(name string) (interface{}, bool) {
+	return c.values.Load(name)
+}
+
+func (c *MirrorContext) SetValue(name string, value interface{}) {
+	c.values.Store(name, value)
+}
+
+func (c *MirrorContext) GetValueString(name string, defaultValue string) string {
+	v, ok := c.GetValue(name)
+	if !ok {
+		return defaultValue
+	}
+	return cast.ToString(v)
+}
+
+func (c *MirrorContext) Context() context.Context {
+	return c.context
+}
+
+func (c *MirrorContext) StartTime() time.Time {
+	return c.beginTime
+}
+
+func (c *MirrorContext) ElapsedTime() time.Duration {
+	return time.Since(c.beginTime)
+}
+
+func (c *MirrorContext) AddMetric(name string, elapsed time.Duration) {
+	c.metrics = append(c.metrics, flux.Metric{
+		Name: name, Elapsed: elapsed, Elapses: elapsed.String(),
+	})
+}
+
+func (c *MirrorContext) LoadMetrics() []flux.Metric {
+	dist := make([]flux.Metric, len(c.metrics))
+	copy(dist, c.metrics)
+	return dist
+}
+
+func (c *MirrorContext) SetLogger(logger flux.Logger) {
+	c.ctxLogger = logger
+}
+
+func (c *MirrorContext) GetLogger() flux.Logger {
+	return c.ctxLogger
+}
+
+// MirrorRequestReader 原请求数据的副本
+type MirrorRequestReader struct {
+	method  string
+	host    string
+	agent   string
+	uri     string
+	url     *url.URL
+	remote  string
+	client  string
+	body    []byte
+	header  http.Header
+	query   url.Values
+	path    url.Values
+	form    url.Values
+	cookies []*http.Cookie
+}
+
+func NewMirrorRequestReader(r flux.RequestReader) *MirrorRequestReader {
+	header, _ := r.HeaderValues()
+	mr := &MirrorRequestReader{
+		method:  r.Method(),
+		host:    r.Host(),
+		agent:   r.UserAgent(),
+		uri:     r.RequestURI(),
+		url:     &url.URL{},
+		remote:  r.RemoteAddr(),
+		client:  r.ClientIP(),
+		header:  header.Clone(),
+		query:   copyValues(r.QueryValues()),
+		path:    copyValues(r.PathValues()),
+		form:    copyValues(r.FormValues()),
+		cookies: r.CookieValues(),
+	}
+	if u, _ := r.RequestURL(); nil != u {
+		copied := *u
+		mr.url = &copied
+	}
+	if reader, err := r.RequestBodyReader(); nil == err && nil != reader {
+		mr.body, _ = ioutil.ReadAll(reader)
+		_ = reader.Close()
+	}
+	return mr
+}
+
+func (r *MirrorRequestReader) Method() string {
+	return r.method
+}
+
+func (r *MirrorRequestReader) Host() string {
+	return r.host
+}
+
+func (r *MirrorRequestReader) UserAgent() string {
+	return r.agent
+}
+
+func (r *MirrorRequestReader) RequestURI() string {
+	return r.uri
+}
+
+func (r *MirrorRequestReader) RequestURL() (*url.URL, bool) {
+	return r.url, true
+}
+
+func (r *MirrorRequestReader) RemoteAddr() string {
+	return r.remote
+}
+
+func (r *MirrorRequestReader) ClientIP() string {
+	return r.client
+}
+
+func (r *MirrorRequestReader) RequestBodyReader() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(r.body)), nil
+}
+
+func (r *MirrorRequestReader) RequestRewrite(method string, path string) {
+	if "" != method {
+		r.method = method
+	}
+	if "" != path {
+		r.url.Path = path
+	}
+}
+
+func (r *MirrorRequestReader) HeaderValues() (http.Header, bool) {
+	return r.header, true
+}
+
+func (r *MirrorRequestReader) QueryValues() url.Values {
+	return r.query
+}
+
+func (r *MirrorRequestReader) PathValues() url.Values {
+	return r.path
+}
+
+func (r *MirrorRequestReader) FormValues() url.Values {
+	return r.form
+}
+
+func (r *MirrorRequestReader) CookieValues() []*http.Cookie {
+	return r.cookies
+}
+
+func (r *MirrorRequestReader) HeaderValue(name string) string {
+	return r.header.Get(name)
+}
+
+func (r *MirrorRequestReader) QueryValue(name string) string {
+	return r.query.Get(name)
+}
+
+func (r *MirrorRequestReader) PathValue(name string) string {
+	return r.path.Get(name)
+}
+
+func (r *MirrorRequestReader) FormValue(name string) string {
+	return r.form.Get(name)
+}
+
+func (r *MirrorRequestReader) CookieValue(name string) (*http.Cookie, bool) {
+	for _, c := range r.cookies {
+		if name == c.Name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+func copyValues(values url.Values) url.Values {
+	out := make(url.Values, len(values))
+	for k, v := range values {
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
+
+// NewDebugQueryMirrorHandler 流量镜像差异记录查询
+func NewDebugQueryMirrorHandler(mirror *Mirror) http.HandlerFunc {
+	serializer := ext.LoadSerializer(ext.TypeNameSerializerJson)
+	return newSerializableHttpHandler(serializer, func(request *http.Request) interface{} {
+		reports := mirror.Reports()
+		if shadow := request.URL.Query().Get("shadow"); "" != shadow {
+			filtered := make([]MirrorReport, 0, len(reports))
+			for _, r := range reports {
+				if shadow == r.Shadow {
+					filtered = append(filtered, r)
+				}
+			}
+			reports = filtered
+		}
+		return reports
+	})
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/webecho"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	assert2 "github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	testMirrorProto = "MIRROR-TEST"
+)
+
+// testMirrorTransport 影子服务：按Method返回不同的响应
+type testMirrorTransport struct{}
+
+func (t *testMirrorTransport) Exchange(ctx flux.Context) *flux.ServeError {
+	return nil
+}
+
+func (t *testMirrorTransport) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	return nil, nil
+}
+
+func (t *testMirrorTransport) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	switch service.Method {
+	case "same":
+		body := `{"x": "` + ctx.Request().QueryValue("x") + `", "a": 1}`
+		return &flux.BackendResponse{StatusCode: flux.StatusOK, Headers: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader([]byte(body)))}, nil
+	case "other":
+		return &flux.BackendResponse{StatusCode: flux.StatusOK, Headers: http.Header{}, Body: map[string]interface{}{"a": 2}}, nil
+	case "missing":
+		return &flux.BackendResponse{StatusCode: flux.StatusNotFound, Headers: http.Header{}, Body: "not found"}, nil
+	default:
+		<-ctx.Context().Done()
+		return nil, &flux.ServeError{StatusCode: http.StatusGatewayTimeout, ErrorCode: flux.ErrorCodeGatewayBackend, Message: "TIMEOUT", Internal: ctx.Context().Err()}
+	}
+}
+
+func (t *testMirrorTransport) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return nil
+}
+
+func init() {
+	ext.StoreBackendTransport(testMirrorProto, new(testMirrorTransport))
+	for _, method := range []string{"same", "other", "missing", "slow"} {
+		service := flux.BackendService{ServiceId: "mirror." + method, Interface: "mirror.ShadowService", Method: method}
+		service.Attributes = []flux.Attribute{{Tag: flux.ServiceAttrTagRpcProto, Name: "RpcProto", Value: testMirrorProto}}
+		ext.StoreBackendService(service)
+	}
+}
+
+func newTestMirrorMetrics() *Metrics {
+	return &Metrics{
+		MirrorTotal:    prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_mirror_total"}, []string{"ShadowService", "Result"}),
+		MirrorDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_mirror_duration"}, []string{"ShadowService"}),
+	}
+}
+
+func newTestMirrorContext(shadow string, extensions map[string]interface{}) *DefaultContext {
+	request := httptest.NewRequest(http.MethodGet, "/users?x=v", nil)
+	var webc flux.WebContext
+	_ = webecho.RepeatableBodyReader(func(c echo.Context) error {
+		webc = webecho.NewAdaptWebContext(c, webecho.DefaultRequestBodyDecoder)
+		return nil
+	})(echo.New().NewContext(request, httptest.NewRecorder()))
+	endpoint := &flux.Endpoint{HttpMethod: http.MethodGet, HttpPattern: "/users",
+		Service: flux.BackendService{Interface: "com.foo.UserService", Method: "list"}}
+	endpoint.Extensions = map[string]interface{}{flux.EndpointExtKeyMirrorService: shadow}
+	for k, v := range extensions {
+		endpoint.Extensions[k] = v
+	}
+	ctx := DefaultContextFactory().(*DefaultContext)
+	ctx.Reattach("mirror-request", webc, endpoint)
+	ctx.Response().SetStatusCode(flux.StatusOK)
+	ctx.Response().SetBody(ioutil.NopCloser(bytes.NewReader([]byte(`{"a":1,"x":"v"}`))))
+	return ctx
+}
+
+func waitMirrorResult(metrics *Metrics, shadow, result string, expected float64) bool {
+	deadline := time.Now().Add(time.Second * 2)
+	for time.Now().Before(deadline) {
+		if testutil.ToFloat64(metrics.MirrorTotal.WithLabelValues(shadow, result)) >= expected {
+			return true
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+	return false
+}
+
+func TestMirror_Shadow(t *testing.T) {
+	diff := map[string]interface{}{flux.EndpointExtKeyMirrorDiff: true, flux.EndpointExtKeyMirrorTimeout: "50ms"}
+	cases := []struct {
+		shadow     string
+		extensions map[string]interface{}
+		result     string
+	}{
+		{shadow: "mirror.same", result: MirrorResultSent},
+		{shadow: "mirror.same", extensions: diff, result: MirrorResultMatch},
+		{shadow: "mirror.other", extensions: diff, result: MirrorResultBodyMismatch},
+		{shadow: "mirror.missing", extensions: diff, result: MirrorResultStatusMismatch},
+		{shadow: "mirror.slow", extensions: diff, result: MirrorResultError},
+		{shadow: "mirror.unknown", result: MirrorResultError},
+	}
+	assert := assert2.New(t)
+	metrics := newTestMirrorMetrics()
+	mirror := NewMirror(metrics)
+	for _, tcase := range cases {
+		ctx := newTestMirrorContext(tcase.shadow, tcase.extensions)
+		mirror.Shadow(ctx, nil)
+		// 主服务响应不受影响
+		data, err := ioutil.ReadAll(ctx.Response().Body().(io.Reader))
+		assert.Nil(err)
+		assert.Equal(`{"a":1,"x":"v"}`, string(data), tcase.shadow)
+		assert.True(waitMirrorResult(metrics, tcase.shadow, tcase.result, 1), tcase.shadow+":"+tcase.result)
+	}
+	reports := mirror.Reports()
+	assert.Equal(3, len(reports))
+	assert.Equal(MirrorResultError, reports[0].Result)
+	assert.Equal(http.StatusGatewayTimeout, reports[0].ShadowStatus)
+	assert.Equal(MirrorResultStatusMismatch, reports[1].Result)
+	assert.Equal(MirrorResultBodyMismatch, reports[2].Result)
+	assert.Equal(`{"a":1,"x":"v"}`, reports[2].Body)
+	assert.Equal(`{"a":2}`, reports[2].ShadowBody)
+
+	// 采样比例为0时不调用影子服务
+	mirror.Shadow(newTestMirrorContext("mirror.other", map[string]interface{}{flux.EndpointExtKeyMirrorPercent: 0}), nil)
+	// 超过并发限制时丢弃
+	mirror.Configure(time.Second, 1, 10)
+	mirror.Shadow(newTestMirrorContext("mirror.slow", map[string]interface{}{flux.EndpointExtKeyMirrorTimeout: "100ms"}), nil)
+	mirror.Shadow(newTestMirrorContext("mirror.slow", nil), nil)
+	assert.True(waitMirrorResult(metrics, "mirror.slow", MirrorResultDropped, 1))
+	assert.True(waitMirrorResult(metrics, "mirror.slow", MirrorResultError, 2))
+	assert.Equal(float64(0), testutil.ToFloat64(metrics.MirrorTotal.WithLabelValues("mirror.other", MirrorResultSent)))
+}
+
+// testMirrorBody 读取部分数据后返回错误，记录是否已关闭
+type testMirrorBody struct {
+	data   io.Reader
+	err    error
+	closed bool
+}
+
+func (b *testMirrorBody) Read(p []byte) (int, error) {
+	n, err := b.data.Read(p)
+	if err == io.EOF && nil != b.err {
+		return n, b.err
+	}
+	return n, err
+}
+
+func (b *testMirrorBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestMirrorPrimaryOf(t *testing.T) {
+	cases := []struct {
+		extensions map[string]interface{}
+		err        error
+		expected   string
+		read       bool
+	}{
+		{expected: `{"a":1}`, read: true},
+		{err: errors.New("read error"), expected: "", read: true},
+		{extensions: map[string]interface{}{flux.EndpointExtKeyResponseStreaming: true}, expected: "", read: false},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		ctx := newTestMirrorContext("mirror.same", tcase.extensions)
+		body := &testMirrorBody{data: bytes.NewReader([]byte(`{"a":1}`)), err: tcase.err}
+		ctx.Response().SetBody(body)
+		status, data := mirrorPrimaryOf(ctx, nil)
+		assert.Equal(flux.StatusOK, status)
+		assert.Equal(tcase.expected, string(data))
+		if !tcase.read {
+			// 流式响应：不读取Body，保持原始Body
+			assert.Same(body, ctx.Response().Body())
+			continue
+		}
+		replaced := ctx.Response().Body()
+		if nil == tcase.err {
+			assert.True(body.closed)
+			continue
+		}
+		// 读取失败：重新设置的Body包含已读出的数据及读取错误，关闭时关闭原始Body
+		closer, ok := replaced.(io.ReadCloser)
+		assert.True(ok)
+		remains, err := ioutil.ReadAll(closer)
+		assert.Equal(`{"a":1}`, string(remains))
+		assert.Equal(tcase.err, err)
+		assert.False(body.closed)
+		assert.Nil(closer.Close())
+		assert.True(body.closed)
+	}
+}
@@ -13,12 +13,15 @@ import (
 
 type Router struct {
 	metrics *Metrics
+	mirror  *Mirror
 	hooks   []flux.PrepareHookFunc
 }
 
 func NewRouter() *Router {
+	metrics := NewMetrics()
 	return &Router{
-		metrics: NewMetrics(),
+		metrics: metrics,
+		mirror:  NewMirror(metrics),
 		hooks:   make([]flux.PrepareHookFunc, 0, 4),
 	}
 }
@@ -107,6 +110,11 @@ func (r *Router) Shutdown(ctx context.Context) error {
 }
 
 func (r *Router) Route(ctx flux.Context) *flux.ServeError {
+	return r.RouteWith(ctx, r.exchange)
+}
+
+// RouteWith 选择并执行Filter链，最后由next处理请求
+func (r *Router) RouteWith(ctx flux.Context, next flux.FilterHandler) *flux.ServeError {
 	// 统计异常
 	doMetricEndpointFunc := func(err *flux.ServeError) *flux.ServeError {
 		// Access Counter: ProtoName, Interface, Method
@@ -137,26 +145,29 @@ func (r *Router) Route(ctx flux.Context) *flux.ServeError {
 	ctx.AddMetric("M-Selector", ctx.ElapsedTime())
 	// Walk filters
 	filters := append(globals, selective...)
-	err := r.walk(func(ctx flux.Context) *flux.ServeError {
-		protoName := ctx.ServiceProto()
-		defer func() {
-			ctx.AddMetric("M-Backend", ctx.ElapsedTime())
-		}()
-		if backend, ok := ext.LoadBackendTransport(protoName); !ok {
-			logger.TraceContext(ctx).Warnw("Route, unsupported protocol", "proto", protoName, "service", ctx.Endpoint().Service)
-			return &flux.ServeError{
-				StatusCode: flux.StatusNotFound,
-				ErrorCode:  flux.ErrorCodeRequestNotFound,
-				Message:    fmt.Sprintf("ROUTE:UNKNOWN_PROTOCOL:%s", protoName)}
-		} else {
-			// Backend exchange
-			timer := prometheus.NewTimer(r.metrics.RouteDuration.WithLabelValues("BackendTransport", protoName))
-			ret := backend.Exchange(ctx)
-			timer.ObserveDuration()
-			return ret
-		}
-	}, filters)(ctx)
-	return doMetricEndpointFunc(err)
+	return doMetricEndpointFunc(r.walk(next, filters)(ctx))
+}
+
+func (r *Router) exchange(ctx flux.Context) *flux.ServeError {
+	protoName := ctx.ServiceProto()
+	defer func() {
+		ctx.AddMetric("M-Backend", ctx.ElapsedTime())
+	}()
+	if backend, ok := ext.LoadBackendTransport(protoName); !ok {
+		logger.TraceContext(ctx).Warnw("Route, unsupported protocol", "proto", protoName, "service", ctx.Endpoint().Service)
+		return &flux.ServeError{
+			StatusCode: flux.StatusNotFound,
+			ErrorCode:  flux.ErrorCodeRequestNotFound,
+			Message:    fmt.Sprintf("ROUTE:UNKNOWN_PROTOCOL:%s", protoName)}
+	} else {
+		// Backend exchange
+		timer := prometheus.NewTimer(r.metrics.RouteDuration.WithLabelValues("BackendTransport", protoName))
+		ret := backend.Exchange(ctx)
+		timer.ObserveDuration()
+		// 流量镜像：异步调用影子服务，不影响客户端响应
+		r.mirror.Shadow(ctx, ret)
+		return ret
+	}
 }
 
 func (r *Router) walk(next flux.FilterHandler, filters []flux.Filter) flux.FilterHandler {
@@ -2,16 +2,21 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/bytepowered/flux"
 	"github.com/bytepowered/flux/ext"
 	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/pkg"
+	"github.com/bytepowered/flux/support"
 	"github.com/bytepowered/flux/webmidware"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cast"
+	"io"
 	"net/http"
 	_ "net/http/pprof"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -36,6 +41,19 @@ const (
 	HttpWebServerConfigKeyPort               = "port"
 	HttpWebServerConfigKeyTlsCertFile        = "tls-cert-file"
 	HttpWebServerConfigKeyTlsKeyFile         = "tls-key-file"
+	HttpWebServerConfigKeyBodyLimit          = "body-limit"
+	// 流式响应判定：MIME类型列表，以及Content-Length阈值
+	HttpWebServerConfigKeyStreamingContentTypes  = "response-streaming-content-types"
+	HttpWebServerConfigKeyStreamingContentLength = "response-streaming-content-length"
+	// GraphQL入口：是否开启，以及请求路径
+	HttpWebServerConfigKeyFeatureGraphQLEnable = "feature-graphql-enable"
+	HttpWebServerConfigKeyFeatureGraphQLPath   = "feature-graphql-path"
+	// 流量镜像：影子服务调用的默认超时、最大并发数量，以及Debug接口保留的差异记录数量
+	HttpWebServerConfigKeyMirrorTimeout     = "mirror-timeout"
+	HttpWebServerConfigKeyMirrorConcurrency = "mirror-max-concurrency"
+	HttpWebServerConfigKeyMirrorReportSize  = "mirror-report-size"
+	// 可信代理的CIDR列表：用于从X-Forwarded-For解析客户端IP
+	HttpWebServerConfigKeyTrustedProxies = "trusted-proxies"
 )
 
 type (
@@ -62,6 +80,8 @@ type HttpServeEngine struct {
 	started        chan struct{}
 	stopped        chan struct{}
 	banner         string
+	bodyLimit      int64
+	graphql        *GraphQLHandler
 }
 
 // WithServerResponseWriter 用于配置Web服务响应数据输出函数
@@ -138,6 +158,7 @@ func NewHttpServeEngineOverride(overrides ...Option) *HttpServeEngine {
 		WithServerDefaults(map[string]interface{}{
 			HttpWebServerConfigKeyFeatureDebugEnable: false,
 			HttpWebServerConfigKeyFeatureDebugPort:   9527,
+			HttpWebServerConfigKeyFeatureGraphQLPath: "/graphql",
 			HttpWebServerConfigKeyAddress:            "0.0.0.0",
 			HttpWebServerConfigKeyPort:               8080,
 		})}
@@ -172,6 +193,32 @@ func (s *HttpServeEngine) Initial() error {
 	// Http server
 	s.config = flux.NewConfigurationOf(HttpWebServerConfigRootName)
 	s.config.SetDefaults(s.defaults)
+	// 全局请求Body大小限制
+	if limit := s.config.GetString(HttpWebServerConfigKeyBodyLimit); "" != limit {
+		size, err := pkg.ParseByteSize(limit)
+		if nil != err {
+			return fmt.Errorf("config %s: %w", HttpWebServerConfigKeyBodyLimit, err)
+		}
+		s.bodyLimit = size
+	}
+	// 流式响应判定配置
+	if s.config.IsSet(HttpWebServerConfigKeyStreamingContentTypes) {
+		SetServerStreamingContentTypes(s.config.GetStringSlice(HttpWebServerConfigKeyStreamingContentTypes))
+	}
+	if s.config.IsSet(HttpWebServerConfigKeyStreamingContentLength) {
+		SetServerStreamingContentLength(s.config.GetInt64(HttpWebServerConfigKeyStreamingContentLength))
+	}
+	// 客户端IP解析配置
+	if proxies := s.config.GetStringSlice(HttpWebServerConfigKeyTrustedProxies); len(proxies) > 0 {
+		resolver, err := support.NewClientIPResolver(proxies)
+		if nil != err {
+			return fmt.Errorf("config %s: %w", HttpWebServerConfigKeyTrustedProxies, err)
+		}
+		ext.StoreClientIPResolveFunc(resolver.Resolve)
+	}
+	// 流量镜像配置
+	s.router.mirror.Configure(s.config.GetDuration(HttpWebServerConfigKeyMirrorTimeout),
+		s.config.GetInt(HttpWebServerConfigKeyMirrorConcurrency), s.config.GetInt(HttpWebServerConfigKeyMirrorReportSize))
 	// 创建WebServer
 	s.httpWebServer = ext.LoadWebServerFactory()(s.config)
 	// 默认必备的WebServer功能
@@ -201,6 +248,18 @@ func (s *HttpServeEngine) Initial() error {
 		http.DefaultServeMux.Handle("/debug/endpoints", NewDebugQueryEndpointHandler())
 		http.DefaultServeMux.Handle("/debug/services", NewDebugQueryServiceHandler())
 		http.DefaultServeMux.Handle("/debug/metrics", promhttp.Handler())
+		http.DefaultServeMux.Handle("/debug/mirrors", NewDebugQueryMirrorHandler(s.router.mirror))
+	}
+	// GraphQL feature：默认关闭，需要配置开启
+	if s.config.GetBool(HttpWebServerConfigKeyFeatureGraphQLEnable) {
+		path := s.config.GetString(HttpWebServerConfigKeyFeatureGraphQLPath)
+		if "" == path {
+			path = "/graphql"
+		}
+		logger.Infow("GraphQL handler register", "path", path)
+		s.graphql = NewGraphQLHandler(s, path)
+		s.httpWebServer.AddWebHandler(http.MethodGet, path, s.graphql.ServeWebContext)
+		s.httpWebServer.AddWebHandler(http.MethodPost, path, s.graphql.ServeWebContext)
 	}
 	// Echo feature
 	if s.config.GetBool(HttpWebServerConfigKeyFeatureEchoEnable) {
@@ -209,7 +268,17 @@ func (s *HttpServeEngine) Initial() error {
 			s.HandleHttpEndpointEvent(evt)
 		}
 	}
-	return s.router.Initial()
+	if err := s.router.Initial(); nil != err {
+		return err
+	}
+	// - 扩展组件注册的Debug查询接口
+	if s.config.GetBool(HttpWebServerConfigKeyFeatureDebugEnable) {
+		serializer := ext.LoadSerializer(ext.TypeNameSerializerJson)
+		for pattern, f := range ext.LoadDebugQueryFuncs() {
+			http.DefaultServeMux.Handle(pattern, newSerializableHttpHandler(serializer, f))
+		}
+	}
+	return nil
 }
 
 func (s *HttpServeEngine) Startup(version flux.BuildInfo) error {
@@ -246,6 +315,34 @@ func (s *HttpServeEngine) StartServe(info flux.BuildInfo, config *flux.Configura
 			logger.Info("BackendService event loop: Stopped")
 		}()
 	}
+	// Upstream clusters
+	if registry, ok := s.registry.(flux.UpstreamClusterRegistry); ok {
+		if events, err := registry.WatchUpstreamClusters(); nil != err {
+			return fmt.Errorf("start registry watching: %w", err)
+		} else {
+			go func() {
+				logger.Info("UpstreamCluster event loop: starting")
+				for event := range events {
+					s.HandleUpstreamClusterEvent(event)
+				}
+				logger.Info("UpstreamCluster event loop: Stopped")
+			}()
+		}
+	}
+	// IP access rules
+	if registry, ok := s.registry.(flux.IPAccessRuleRegistry); ok {
+		if events, err := registry.WatchIPAccessRules(); nil != err {
+			return fmt.Errorf("start registry watching: %w", err)
+		} else {
+			go func() {
+				logger.Info("IPAccessRule event loop: starting")
+				for event := range events {
+					s.HandleIPAccessRuleEvent(event)
+				}
+				logger.Info("IPAccessRule event loop: Stopped")
+			}()
+		}
+	}
 	close(s.started)
 	if "" != s.banner {
 		logger.Info(s.banner)
@@ -290,6 +387,10 @@ func (s *HttpServeEngine) HandleEndpointRequest(webc flux.WebContext, endpoints
 		}
 		return flux.ErrRouteNotFound
 	}
+	// 请求Body大小限制：Endpoint配置优先于全局配置
+	if serr := s.limitRequestBody(webc, endpoint); nil != serr {
+		return serr
+	}
 	ctxw := s.acquireContext(requestId, webc, endpoint)
 	defer s.releaseContext(ctxw)
 	// Route call
@@ -307,14 +408,60 @@ func (s *HttpServeEngine) HandleEndpointRequest(webc flux.WebContext, endpoints
 	// Route and response
 	response := ctxw.Response()
 	if err := s.router.Route(ctxw); nil != err {
+		if errors.Is(err.Internal, flux.ErrRequestBodyTooLarge) {
+			err = flux.NewRequestTooLargeError(s.bodyLimitOf(endpoint), err.Internal)
+		}
 		defer endcall(err.StatusCode, start)
 		logger.TraceContext(ctxw).Errorw("HttpServeEngine route error", "error", err)
+		// 连接已被BackendTransport接管，无法再写入错误响应
+		if isResponseHijacked(ctxw) {
+			return nil
+		}
 		err.MergeHeader(response.HeaderValues())
 		return err
 	} else {
 		defer endcall(response.StatusCode(), start)
-		return s.responseWriter(webc, requestId, response.HeaderValues(), response.StatusCode(), response.Body())
+		// 连接已被BackendTransport接管，响应已由其写入
+		if isResponseHijacked(ctxw) {
+			return nil
+		}
+		body := response.Body()
+		if r, ok := body.(io.Reader); ok && endpoint.ExtBool(flux.EndpointExtKeyResponseStreaming) {
+			body = NewStreamingBody(r)
+		}
+		return s.responseWriter(webc, requestId, response.HeaderValues(), response.StatusCode(), body)
+	}
+}
+
+// isResponseHijacked 判断连接是否已被BackendTransport接管
+func isResponseHijacked(ctx flux.Context) bool {
+	hijacked, ok := ctx.GetValue(flux.ValueKeyResponseHijacked)
+	return ok && cast.ToBool(hijacked)
+}
+
+// limitRequestBody 检查请求的Content-Length，并设置读取Body的大小限制
+func (s *HttpServeEngine) limitRequestBody(webc flux.WebContext, endpoint *flux.Endpoint) *flux.ServeError {
+	limit := s.bodyLimitOf(endpoint)
+	if limit <= 0 {
+		return nil
+	}
+	if length, err := strconv.ParseInt(webc.HeaderValue(flux.HeaderContentLength), 10, 64); nil == err && length > limit {
+		return flux.NewRequestTooLargeError(limit, flux.ErrRequestBodyTooLarge)
+	}
+	if streamer, ok := webc.(flux.WebBodyStreamer); ok {
+		streamer.SetRequestBodyLimit(limit)
 	}
+	return nil
+}
+
+func (s *HttpServeEngine) bodyLimitOf(endpoint *flux.Endpoint) int64 {
+	if v := endpoint.ExtString(flux.EndpointExtKeyRequestBodyLimit); "" != v {
+		if size, err := pkg.ParseByteSize(v); nil == err {
+			return size
+		}
+		logger.Warnw("Illegal endpoint request-body-limit", "limit", v, "pattern", endpoint.HttpPattern)
+	}
+	return s.bodyLimit
 }
 
 func (s *HttpServeEngine) HandleBackendServiceEvent(event flux.BackendServiceEvent) {
@@ -343,6 +490,45 @@ func (s *HttpServeEngine) HandleBackendServiceEvent(event flux.BackendServiceEve
 			ext.RemoveBackendService(service.AliasId)
 		}
 	}
+	if nil != s.graphql {
+		s.graphql.Invalidate()
+	}
+	notifyBackendServiceEvent(event)
+}
+
+// notifyBackendServiceEvent 通知服务协议对应的BackendTransport，用于预热或销毁后端调用资源
+func notifyBackendServiceEvent(event flux.BackendServiceEvent) {
+	transport, ok := ext.LoadBackendTransport(event.Service.AttrRpcProto())
+	if !ok {
+		return
+	}
+	if listener, ok := transport.(flux.BackendServiceListener); ok {
+		listener.OnBackendServiceEvent(event)
+	}
+}
+
+func (s *HttpServeEngine) HandleUpstreamClusterEvent(event flux.UpstreamClusterEvent) {
+	cluster := event.Cluster
+	switch event.EventType {
+	case flux.EventTypeAdded, flux.EventTypeUpdated:
+		logger.Infow("Update cluster", "cluster-name", cluster.Name, "hosts", cluster.Hosts)
+		ext.StoreUpstreamCluster(cluster)
+	case flux.EventTypeRemoved:
+		logger.Infow("Delete cluster", "cluster-name", cluster.Name)
+		ext.RemoveUpstreamCluster(cluster.Name)
+	}
+}
+
+func (s *HttpServeEngine) HandleIPAccessRuleEvent(event flux.IPAccessRuleEvent) {
+	rule := event.Rule
+	switch event.EventType {
+	case flux.EventTypeAdded, flux.EventTypeUpdated:
+		logger.Infow("Update ip access rule", "rule-name", rule.Name, "allow", rule.Allow, "deny", rule.Deny)
+		ext.StoreIPAccessRule(rule)
+	case flux.EventTypeRemoved:
+		logger.Infow("Delete ip access rule", "rule-name", rule.Name)
+		ext.RemoveIPAccessRule(rule.Name)
+	}
 }
 
 func (s *HttpServeEngine) HandleHttpEndpointEvent(event flux.HttpEndpointEvent) {
@@ -359,6 +545,10 @@ func (s *HttpServeEngine) HandleHttpEndpointEvent(event flux.HttpEndpointEvent)
 	initArguments(endpoint.Service.Arguments)
 	initArguments(endpoint.Permission.Arguments)
 	bind, isreg := s.selectMultiEndpoint(routeKey, &endpoint)
+	if nil != s.graphql {
+		defer s.graphql.Invalidate()
+	}
+	notifyHttpEndpointEvent(flux.HttpEndpointEvent{EventType: event.EventType, Endpoint: endpoint})
 	switch event.EventType {
 	case flux.EventTypeAdded:
 		logger.Infow("New endpoint", "version", endpoint.Version, "method", method, "pattern", pattern)
@@ -373,6 +563,22 @@ func (s *HttpServeEngine) HandleHttpEndpointEvent(event flux.HttpEndpointEvent)
 	case flux.EventTypeRemoved:
 		logger.Infow("Delete endpoint", "method", method, "pattern", pattern)
 		bind.Delete(endpoint.Version)
+		return
+	}
+	// 端点内嵌定义的后端服务，仅做预热；服务资源的销毁由BackendService事件触发
+	for _, service := range []flux.BackendService{endpoint.Service, endpoint.Permission} {
+		if service.IsValid() {
+			notifyBackendServiceEvent(flux.BackendServiceEvent{EventType: flux.EventTypeAdded, Service: service})
+		}
+	}
+}
+
+// notifyHttpEndpointEvent 通知实现EndpointListener接口的Filter，用于预处理或清理Endpoint相关的资源
+func notifyHttpEndpointEvent(event flux.HttpEndpointEvent) {
+	for _, filter := range append(ext.LoadGlobalFilters(), ext.LoadSelectiveFilters()...) {
+		if listener, ok := filter.(flux.EndpointListener); ok {
+			listener.OnHttpEndpointEvent(event)
+		}
 	}
 }
 
@@ -2,6 +2,7 @@ package server
 
 import (
 	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
 	"io"
 	"net/http"
 	"net/url"
@@ -12,6 +13,7 @@ var _ flux.RequestReader = new(DefaultRequestReader)
 // DefaultRequestReader Request请求读取接口的实现
 type DefaultRequestReader struct {
 	flux.WebContext
+	clientIP string
 }
 
 func (r *DefaultRequestReader) Method() string {
@@ -34,6 +36,18 @@ func (r *DefaultRequestReader) RequestURL() (url *url.URL, writable bool) {
 	return r.WebContext.RequestURL()
 }
 
+func (r *DefaultRequestReader) RemoteAddr() string {
+	return r.WebContext.RemoteAddr()
+}
+
+func (r *DefaultRequestReader) ClientIP() string {
+	if "" == r.clientIP {
+		header, _ := r.WebContext.HeaderValues()
+		r.clientIP = ext.LoadClientIPResolveFunc()(r.WebContext.RemoteAddr(), header)
+	}
+	return r.clientIP
+}
+
 func (r *DefaultRequestReader) RequestBodyReader() (io.ReadCloser, error) {
 	return r.WebContext.RequestBodyReader()
 }
@@ -84,10 +98,12 @@ func (r *DefaultRequestReader) HeaderValue(name string) string {
 
 func (r *DefaultRequestReader) reattach(webex flux.WebContext) {
 	r.WebContext = webex
+	r.clientIP = ""
 }
 
 func (r *DefaultRequestReader) reset() {
 	r.WebContext = nil
+	r.clientIP = ""
 }
 
 func NewDefaultRequestReader() *DefaultRequestReader {
@@ -130,6 +146,9 @@ func (r *DefaultResponseWriter) SetHeader(name, value string) {
 }
 
 func (r *DefaultResponseWriter) SetHeaders(headers http.Header) {
+	if nil == headers {
+		headers = http.Header{}
+	}
 	r.headers = headers
 }
 
@@ -3,17 +3,39 @@ package server
 import (
 	"fmt"
 	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
 	"github.com/bytepowered/flux/logger"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sync"
 )
 
 var (
 	serverWriterSerializer    flux.Serializer
 	serverResponseContentType string
+	streamingBufferPool       = sync.Pool{New: func() interface{} {
+		return make([]byte, 32*1024)
+	}}
 )
 
+// StreamingBody 标记需要以流式输出的响应Body
+type StreamingBody struct {
+	io.Reader
+}
+
+func (s *StreamingBody) Close() error {
+	if c, ok := s.Reader.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// NewStreamingBody 将Reader标记为流式响应Body
+func NewStreamingBody(r io.Reader) *StreamingBody {
+	return &StreamingBody{Reader: r}
+}
+
 // SetServerWriterSerializer 设置Http响应数据序列化接口实现；默认为JSON序列化实现。
 func SetServerWriterSerializer(s flux.Serializer) {
 	serverWriterSerializer = s
@@ -24,6 +46,16 @@ func GetServerWriterSerializer() flux.Serializer {
 	return serverWriterSerializer
 }
 
+// SetServerStreamingContentTypes 设置判定为流式响应的MIME类型列表
+func SetServerStreamingContentTypes(ctypes []string) {
+	ext.StoreStreamingContentTypes(ctypes)
+}
+
+// SetServerStreamingContentLength 设置判定为流式响应的Content-Length阈值；0表示不按长度判定
+func SetServerStreamingContentLength(length int64) {
+	ext.StoreStreamingContentLength(length)
+}
+
 // SetServerResponseContentType 设置Http响应的MIME类型字符串；默认为JSON/UTF8。
 func SetServerResponseContentType(ctype string) {
 	serverResponseContentType = ctype
@@ -36,13 +68,16 @@ func GetServerResponseContentType() string {
 
 func DefaultServerErrorsWriter(webc flux.WebContext, requestId string, header http.Header, serr *flux.ServeError) error {
 	SetupResponseDefaults(webc, requestId, header)
-	resp := map[string]string{
+	resp := map[string]interface{}{
 		"status":  "error",
 		"message": serr.Message,
 	}
 	if nil != serr.Internal {
 		resp["error"] = serr.Internal.Error()
 	}
+	if nil != serr.Details {
+		resp["details"] = serr.Details
+	}
 	bytes, err := SerializeWith(serverWriterSerializer, resp)
 	if nil != err {
 		return err
@@ -52,6 +87,9 @@ func DefaultServerErrorsWriter(webc flux.WebContext, requestId string, header ht
 
 func DefaultServerResponseWriter(webc flux.WebContext, requestId string, header http.Header, status int, body interface{}) error {
 	SetupResponseDefaults(webc, requestId, header)
+	if r, ok := body.(io.Reader); ok && IsStreamingResponse(header, body) {
+		return writeStreamingResponse(webc, requestId, header, status, r)
+	}
 	var output []byte
 	if r, ok := body.(io.Reader); ok {
 		if c, ok := r.(io.Closer); ok {
@@ -84,6 +122,84 @@ func DefaultServerResponseWriter(webc flux.WebContext, requestId string, header
 	return nil
 }
 
+// IsStreamingResponse 判定响应是否为流式数据：Body为StreamingBody，或者Content-Type为流式类型，或者Content-Length超过阈值
+func IsStreamingResponse(header http.Header, body interface{}) bool {
+	if _, ok := body.(io.Reader); !ok {
+		return false
+	}
+	if _, ok := body.(*StreamingBody); ok {
+		return true
+	}
+	return ext.IsStreamingContent(header)
+}
+
+// writeStreamingResponse 边读边写流式响应，每次写入后Flush；客户端取消请求时，关闭Body并停止写入
+func writeStreamingResponse(webc flux.WebContext, requestId string, header http.Header, status int, body io.Reader) error {
+	closer, _ := body.(io.Closer)
+	if nil != closer {
+		defer func() {
+			_ = closer.Close()
+		}()
+	}
+	contentType := header.Get(flux.HeaderContentType)
+	if "" == contentType {
+		contentType = "application/octet-stream"
+	}
+	w, err := webc.HttpResponseWriter()
+	if nil != err {
+		// 不支持标准ResponseWriter时，由Web框架直接写入
+		return webc.WriteStream(status, contentType, body)
+	}
+	webc.SetResponseHeader(flux.HeaderContentType, contentType)
+	// 阻塞读取时，客户端取消请求，通过关闭Body来中断读取
+	done := make(chan struct{})
+	defer close(done)
+	cancel := webc.Context().Done()
+	if nil != closer {
+		go func() {
+			select {
+			case <-cancel:
+				_ = closer.Close()
+			case <-done:
+			}
+		}()
+	}
+	w.WriteHeader(status)
+	flusher, _ := w.(http.Flusher)
+	buf := streamingBufferPool.Get().([]byte)
+	defer streamingBufferPool.Put(buf)
+	var written int64
+	defer func() {
+		logger.Trace(requestId).Infow("Http responseWriter, streaming", "content-type", contentType, "bytes", written)
+	}()
+	for {
+		select {
+		case <-cancel:
+			return nil
+		default:
+		}
+		n, rerr := body.Read(buf)
+		if n > 0 {
+			// 写入Http响应发生的错误，客户端已断开，无法再写入错误信息
+			if _, werr := w.Write(buf[:n]); nil != werr {
+				logger.Trace(requestId).Warnw("Http responseWriter, write stream", "error", werr)
+				return nil
+			}
+			written += int64(n)
+			if nil != flusher {
+				flusher.Flush()
+			}
+		}
+		if io.EOF == rerr {
+			return nil
+		}
+		if nil != rerr {
+			logger.Trace(requestId).Warnw("Http responseWriter, read stream", "error", rerr)
+			return nil
+		}
+	}
+}
+
 func SerializeWith(serializer flux.Serializer, data interface{}) ([]byte, *flux.ServeError) {
 	if bytes, err := serializer.Marshal(data); nil != err {
 		return nil, &flux.ServeError{
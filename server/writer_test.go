@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/webecho"
+	"github.com/labstack/echo/v4"
+	assert2 "github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsStreamingResponse(t *testing.T) {
+	cases := []struct {
+		header   http.Header
+		body     interface{}
+		expected bool
+	}{
+		{header: http.Header{"Content-Type": {"text/event-stream"}}, body: strings.NewReader(""), expected: true},
+		{header: http.Header{"Content-Type": {"video/mp4"}}, body: strings.NewReader(""), expected: true},
+		{header: http.Header{"Content-Type": {"application/json; charset=utf-8"}}, body: strings.NewReader(""), expected: false},
+		{header: http.Header{"Content-Length": {"2097152"}}, body: strings.NewReader(""), expected: true},
+		{header: http.Header{"Content-Length": {"1024"}}, body: strings.NewReader(""), expected: false},
+		{header: http.Header{}, body: NewStreamingBody(strings.NewReader("")), expected: true},
+		{header: http.Header{"Content-Type": {"text/event-stream"}}, body: map[string]string{}, expected: false},
+	}
+	assert := assert2.New(t)
+	for _, tcase := range cases {
+		assert.Equal(tcase.expected, IsStreamingResponse(tcase.header, tcase.body), tcase.header)
+	}
+}
+
+func TestDefaultServerResponseWriter_Streaming(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	webc := webecho.NewAdaptWebContext(echo.New().NewContext(httptest.NewRequest("GET", "/events", nil), recorder), nil)
+	header := http.Header{"Content-Type": {"text/event-stream"}}
+	body := "data: a\n\ndata: b\n\n"
+	err := DefaultServerResponseWriter(webc, "req-001", header, 200, strings.NewReader(body))
+	assert := assert2.New(t)
+	assert.Nil(err)
+	assert.Equal(body, recorder.Body.String())
+	assert.Equal("text/event-stream", recorder.Header().Get(flux.HeaderContentType))
+	assert.True(recorder.Flushed)
+}
+
+// blockingReader 模拟持续推送的流式Body，关闭后读取返回EOF
+type blockingReader struct {
+	closed chan struct{}
+}
+
+func (b *blockingReader) Read(p []byte) (int, error) {
+	select {
+	case <-b.closed:
+		return 0, io.EOF
+	case <-time.After(time.Millisecond):
+		return copy(p, "data: ping\n\n"), nil
+	}
+}
+
+func (b *blockingReader) Close() error {
+	select {
+	case <-b.closed:
+	default:
+		close(b.closed)
+	}
+	return nil
+}
+
+func TestDefaultServerResponseWriter_StreamingCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	request := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+	recorder := httptest.NewRecorder()
+	webc := webecho.NewAdaptWebContext(echo.New().NewContext(request, recorder), nil)
+	reader := &blockingReader{closed: make(chan struct{})}
+	time.AfterFunc(time.Millisecond*20, cancel)
+	done := make(chan error, 1)
+	go func() {
+		done <- DefaultServerResponseWriter(webc, "req-002", http.Header{}, 200, NewStreamingBody(reader))
+	}()
+	assert := assert2.New(t)
+	select {
+	case err := <-done:
+		assert.Nil(err)
+	case <-time.After(time.Second):
+		assert.Fail("streaming not cancelled")
+	}
+	<-reader.closed
+	assert.True(bytes.HasPrefix(recorder.Body.Bytes(), []byte("data: ping")))
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/bytepowered/flux"
 	"github.com/bytepowered/flux/ext"
 	assert2 "github.com/stretchr/testify/assert"
+	"net/url"
 	"testing"
 )
 
@@ -237,3 +238,79 @@ func TestComplexArgumentValueLoader(t *testing.T) {
 		assert.Equal(tcase.expected, v, "value match")
 	}
 }
+
+func TestArgumentValidate(t *testing.T) {
+	ext.StoreArgumentLookupFunc(DefaultArgumentValueLookupFunc)
+	assert := assert2.New(t)
+	intPtr := func(v int) *int { return &v }
+	floatPtr := func(v float64) *float64 { return &v }
+	ctx := NewValuesContext(map[string]interface{}{
+		"query-values": url.Values{
+			"name":  []string{"flux"},
+			"age":   []string{"200"},
+			"email": []string{"flux@bytepowered"},
+			"phone": []string{"+86-13800138000"},
+			"date":  []string{"2021-02-30"},
+			"level": []string{"gold"},
+			"tags":  []string{"a", "b", "c"},
+		},
+	})
+	newArg := func(arg flux.Argument, validation flux.ArgumentValidation) flux.Argument {
+		arg.Validation = validation
+		return arg
+	}
+	cases := []struct {
+		argument flux.Argument
+		rules    []string
+	}{
+		{argument: newArg(ext.NewStringArgument("missing"), flux.ArgumentValidation{Required: true}), rules: []string{"required"}},
+		{argument: newArg(ext.NewStringArgument("missing"), flux.ArgumentValidation{Required: true, Default: "x"})},
+		{argument: newArg(ext.NewStringArgument("missing"), flux.ArgumentValidation{MinLength: intPtr(1)})},
+		{argument: newArg(ext.NewStringArgument("name"), flux.ArgumentValidation{MinLength: intPtr(5), Pattern: "^[0-9]+$"}), rules: []string{"minLength", "pattern"}},
+		{argument: newArg(ext.NewStringArgument("name"), flux.ArgumentValidation{MaxLength: intPtr(4), Pattern: "^[a-z]+$"})},
+		{argument: newArg(ext.NewIntegerArgument("age"), flux.ArgumentValidation{Min: floatPtr(1), Max: floatPtr(150)}), rules: []string{"max"}},
+		{argument: newArg(ext.NewIntegerArgument("name"), flux.ArgumentValidation{Min: floatPtr(1)}), rules: []string{"type"}},
+		{argument: newArg(ext.NewStringArgument("email"), flux.ArgumentValidation{Format: flux.ArgumentFormatEmail}), rules: []string{"format"}},
+		{argument: newArg(ext.NewStringArgument("phone"), flux.ArgumentValidation{Format: flux.ArgumentFormatPhone})},
+		{argument: newArg(ext.NewStringArgument("date"), flux.ArgumentValidation{Format: flux.ArgumentFormatDate}), rules: []string{"format"}},
+		{argument: newArg(ext.NewStringArgument("level"), flux.ArgumentValidation{Enum: []interface{}{"silver", "gold"}})},
+		{argument: newArg(ext.NewStringArgument("level"), flux.ArgumentValidation{Enum: []interface{}{1, 2}}), rules: []string{"enum"}},
+	}
+	for _, tcase := range cases {
+		errs := tcase.argument.Validate(ctx)
+		rules := make([]string, 0, len(errs))
+		for _, e := range errs {
+			assert.Equal(tcase.argument.Name, e.Field)
+			rules = append(rules, e.Rule)
+		}
+		assert.Equal(len(tcase.rules), len(rules), tcase.argument.Name)
+		for i := range tcase.rules {
+			assert.Equal(tcase.rules[i], rules[i], tcase.argument.Name)
+		}
+	}
+	// 列表参数按元素数量校验长度
+	tags := flux.Argument{Name: "tags", HttpName: "tags", HttpScope: flux.ScopeQueryMulti,
+		LookupFunc: DefaultArgumentValueLookupFunc, Validation: flux.ArgumentValidation{MaxLength: intPtr(2)}}
+	assert.Equal([]flux.ArgumentFieldError{{Field: "tags", Rule: "maxLength", Message: "length must be less than or equal to 2"}}, tags.Validate(ctx))
+	// 子结构字段
+	pojo := ext.NewComplexArgument("com.foo.User", "user")
+	pojo.Fields = []flux.Argument{newArg(ext.NewStringArgument("missing"), flux.ArgumentValidation{Required: true})}
+	assert.Equal([]flux.ArgumentFieldError{{Field: "user.missing", Rule: "required", Message: "must not be empty"}}, pojo.Validate(ctx))
+}
+
+func TestArgumentResolveDefault(t *testing.T) {
+	ext.StoreArgumentLookupFunc(DefaultArgumentValueLookupFunc)
+	assert := assert2.New(t)
+	ctx := NewValuesContext(map[string]interface{}{
+		"query-values": url.Values{"size": []string{"50"}},
+	})
+	size := ext.NewIntegerArgument("size")
+	size.Validation.Default = 20
+	page := ext.NewIntegerArgument("page")
+	page.Validation.Default = 1
+	for arg, expected := range map[*flux.Argument]interface{}{&size: 50, &page: 1} {
+		value, err := arg.Resolve(ctx)
+		assert.NoError(err)
+		assert.Equal(expected, value, arg.Name)
+	}
+}
@@ -0,0 +1,110 @@
+package support
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/bytepowered/flux"
+)
+
+// ClientIPResolver 根据可信代理的CIDR列表解析客户端IP：
+// 请求连接来自可信代理时，从右向左遍历X-Forwarded-For，返回第一个非可信代理的IP；
+// 请求连接不是来自可信代理时，忽略代理Header，直接返回连接的远程IP。
+type ClientIPResolver struct {
+	trusted []*net.IPNet
+}
+
+func NewClientIPResolver(trustedProxies []string) (*ClientIPResolver, error) {
+	trusted, err := ParseCIDRs(trustedProxies)
+	if nil != err {
+		return nil, err
+	}
+	return &ClientIPResolver{trusted: trusted}, nil
+}
+
+// Resolve 解析客户端IP；实现 flux.ClientIPResolveFunc
+func (r *ClientIPResolver) Resolve(remoteAddr string, header http.Header) string {
+	remote := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); nil == err {
+		remote = host
+	}
+	if !r.IsTrusted(net.ParseIP(remote)) {
+		return remote
+	}
+	forwarded := make([]string, 0, 4)
+	for _, value := range header.Values(flux.HeaderXForwardedFor) {
+		for _, addr := range strings.Split(value, ",") {
+			if addr = strings.TrimSpace(addr); "" != addr {
+				forwarded = append(forwarded, addr)
+			}
+		}
+	}
+	if len(forwarded) == 0 {
+		if realIP := net.ParseIP(strings.TrimSpace(header.Get(flux.HeaderXRealIP))); nil != realIP {
+			return realIP.String()
+		}
+		return remote
+	}
+	client := remote
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(forwarded[i])
+		// 无效地址之前的数据不可信
+		if nil == ip {
+			break
+		}
+		client = ip.String()
+		if !r.IsTrusted(ip) {
+			break
+		}
+	}
+	return client
+}
+
+// IsTrusted 判断IP是否属于可信代理
+func (r *ClientIPResolver) IsTrusted(ip net.IP) bool {
+	return ContainsIP(r.trusted, ip)
+}
+
+// ParseCIDRs 解析CIDR列表；单个IP地址视为只包含该地址的网段
+func ParseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if "" == cidr {
+			continue
+		}
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if nil == ip {
+				return nil, fmt.Errorf("invalid ip address: %s", cidr)
+			}
+			if v4 := ip.To4(); nil != v4 {
+				nets = append(nets, &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)})
+			} else {
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)})
+			}
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if nil != err {
+			return nil, fmt.Errorf("invalid cidr: %s", cidr)
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets, nil
+}
+
+// ContainsIP 判断IP是否属于任意网段
+func ContainsIP(nets []*net.IPNet, ip net.IP) bool {
+	if nil == ip {
+		return false
+	}
+	for _, ipnet := range nets {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
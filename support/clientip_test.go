@@ -0,0 +1,55 @@
+package support
+
+import (
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestClientIPResolver_Resolve(t *testing.T) {
+	assert := assert2.New(t)
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	assert.NoError(err)
+	cases := []struct {
+		remote  string
+		forward []string
+		realIP  string
+		expect  string
+	}{
+		// 非可信代理，忽略代理Header
+		{remote: "1.2.3.4:8080", forward: []string{"5.6.7.8"}, expect: "1.2.3.4"},
+		{remote: "1.2.3.4", expect: "1.2.3.4"},
+		// 从右向左，返回第一个非可信代理IP
+		{remote: "10.0.0.1:8080", forward: []string{"5.6.7.8, 9.9.9.9, 10.0.0.2"}, expect: "9.9.9.9"},
+		{remote: "192.168.1.1:8080", forward: []string{"5.6.7.8", "10.0.0.3"}, expect: "5.6.7.8"},
+		// 全部为可信代理，返回最左侧IP
+		{remote: "10.0.0.1:8080", forward: []string{"10.0.0.5, 10.0.0.2"}, expect: "10.0.0.5"},
+		// 无效地址之前的数据不可信
+		{remote: "10.0.0.1:8080", forward: []string{"5.6.7.8, unknown, 10.0.0.2"}, expect: "10.0.0.2"},
+		{remote: "10.0.0.1:8080", realIP: "7.7.7.7", expect: "7.7.7.7"},
+		{remote: "10.0.0.1:8080", expect: "10.0.0.1"},
+		{remote: "[fd00::1]:8080", forward: []string{"2001:db8::1"}, expect: "2001:db8::1"},
+	}
+	for _, tcase := range cases {
+		header := http.Header{}
+		for _, v := range tcase.forward {
+			header.Add("X-Forwarded-For", v)
+		}
+		if "" != tcase.realIP {
+			header.Set("X-Real-IP", tcase.realIP)
+		}
+		assert.Equal(tcase.expect, resolver.Resolve(tcase.remote, header), tcase.remote)
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	assert := assert2.New(t)
+	nets, err := ParseCIDRs([]string{"10.0.0.0/8", " 1.2.3.4 ", "", "::1"})
+	assert.NoError(err)
+	assert.Equal(3, len(nets))
+	assert.Equal("1.2.3.4/32", nets[1].String())
+	_, err = ParseCIDRs([]string{"10.0.0.0/33"})
+	assert.Error(err)
+	_, err = ParseCIDRs([]string{"not-ip"})
+	assert.Error(err)
+}
@@ -57,6 +57,10 @@ func DefaultArgumentValueLookupFunc(scope, key string, ctx flux.Context) (value
 			return flux.WrapStringMTValue(ctx.Method()), nil
 		case "uri":
 			return flux.WrapStringMTValue(ctx.RequestURI()), nil
+		case "client-ip":
+			return flux.WrapStringMTValue(req.ClientIP()), nil
+		case "remote-addr":
+			return flux.WrapStringMTValue(req.RemoteAddr()), nil
 		default:
 			return flux.WrapStringMTValue(""), nil
 		}
@@ -32,6 +32,7 @@ func TestDefaultArgumentValueLookupFunc(t *testing.T) {
 		"attr":          "attr",
 		"auto":          "auto",
 		"value":         "value",
+		"remote-addr":   "10.0.0.1:5000",
 	}
 	valctx := NewValuesContext(values)
 	cases := []struct {
@@ -51,6 +52,8 @@ func TestDefaultArgumentValueLookupFunc(t *testing.T) {
 		{scope: flux.ScopeQueryMap, key: "query-values", expect: flux.WrapStrValuesMapMTValue(url.Values{})},
 		{scope: flux.ScopeFormMap, key: "form-query", expect: flux.WrapStrValuesMapMTValue(url.Values{})},
 		{scope: flux.ScopeHeaderMap, key: "header-map", expect: flux.WrapStrValuesMapMTValue(url.Values{})},
+		{scope: flux.ScopeRequest, key: "client-ip", expect: flux.WrapStringMTValue("10.0.0.1")},
+		{scope: flux.ScopeRequest, key: "remote-addr", expect: flux.WrapStringMTValue("10.0.0.1:5000")},
 	}
 	assert := assert2.New(t)
 	for _, c := range cases {
@@ -3,7 +3,9 @@ package support
 import (
 	"errors"
 	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
 	"github.com/spf13/cast"
+	"net/http"
 	"net/url"
 	"strings"
 )
@@ -38,6 +40,11 @@ func LookupWebContext(scope, key string, webc flux.WebContext) string {
 			return webc.Method()
 		case "uri":
 			return webc.RequestURI()
+		case "client-ip":
+			header, _ := webc.HeaderValues()
+			return ext.LoadClientIPResolveFunc()(webc.RemoteAddr(), header)
+		case "remote-addr":
+			return webc.RemoteAddr()
 		}
 		return webc.Method()
 	case flux.ScopeParam:
@@ -91,7 +98,9 @@ func ParseLookupExpr(lookupExpr string) (scope, key string, ok bool) {
 	return strings.ToUpper(kv[0]), kv[1], true
 }
 
-func WrapHeaderProviderFunc(req flux.RequestReader) func() url.Values {
+func WrapHeaderProviderFunc(req interface {
+	HeaderValues() (http.Header, bool)
+}) func() url.Values {
 	return func() url.Values {
 		h, _ := req.HeaderValues()
 		return url.Values(h)
@@ -3,6 +3,7 @@ package support
 import (
 	"context"
 	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
 	"github.com/bytepowered/flux/logger"
 	"github.com/spf13/cast"
 	"io"
@@ -45,6 +46,18 @@ func (r *ValuesRequestReader) RequestURL() (u *url.URL, writable bool) {
 	}
 }
 
+func (r *ValuesRequestReader) RemoteAddr() string {
+	return cast.ToString(r.values["remote-addr"])
+}
+
+func (r *ValuesRequestReader) ClientIP() string {
+	if v, ok := r.values["client-ip"]; ok {
+		return cast.ToString(v)
+	}
+	header, _ := r.HeaderValues()
+	return ext.LoadClientIPResolveFunc()(r.RemoteAddr(), header)
+}
+
 func (r *ValuesRequestReader) RequestBodyReader() (io.ReadCloser, error) {
 	if v, ok := r.values["body"]; ok {
 		return v.(io.ReadCloser), nil
@@ -2,32 +2,133 @@ package webecho
 
 import (
 	"bytes"
-	"fmt"
 	"github.com/bytepowered/flux"
 	"github.com/labstack/echo/v4"
 	"io"
 	"io/ioutil"
+	"sync"
 )
 
-// Body缓存，允许通过 GetBody 多次读取Body
+const (
+	keyWebLazyBody = "$internal.web.adapted.lazy.body"
+)
+
+// Body延迟缓存：仅在通过 GetBody 或 Body 读取时才缓存完整Body，允许多次读取；
+// 流式转发时通过 LazyBody.Stream 直接读取原始Body，不缓存数据。
 func RepeatableBodyReader(next echo.HandlerFunc) echo.HandlerFunc {
-	// 包装Http处理错误，统一由HttpErrorHandler处理
 	return func(echo echo.Context) error {
 		request := echo.Request()
-		data, err := ioutil.ReadAll(request.Body)
-		if nil != err {
-			return &flux.ServeError{
-				StatusCode: flux.StatusBadRequest,
-				ErrorCode:  flux.ErrorCodeGatewayInternal,
-				Message:    flux.ErrorMessageRequestPrepare,
-				Internal:   fmt.Errorf("read req-body, method: %s, uri:%s, err: %w", request.Method, request.RequestURI, err),
-			}
+		body := NewLazyBody(request.Body)
+		echo.Set(keyWebLazyBody, body)
+		request.GetBody = body.GetBody
+		// ParseForm解析后，request.Body无法重读，需要通过GetBody
+		request.Body = &lazyBodyReader{body: body}
+		return next(echo)
+	}
+}
+
+// LazyBody 延迟缓存的请求Body
+type LazyBody struct {
+	raw      io.ReadCloser
+	limit    int64
+	buffered bool
+	streamed bool
+	data     []byte
+	err      error
+	mu       sync.Mutex
+}
+
+func NewLazyBody(raw io.ReadCloser) *LazyBody {
+	return &LazyBody{raw: raw}
+}
+
+// SetLimit 设置Body的最大字节数；0表示不限制
+func (b *LazyBody) SetLimit(limit int64) {
+	b.mu.Lock()
+	b.limit = limit
+	b.mu.Unlock()
+}
+
+// GetBody 读取并缓存完整Body，返回可重复读取的Reader
+func (b *LazyBody) GetBody() (io.ReadCloser, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.buffered {
+		if b.streamed {
+			return nil, flux.ErrRequestBodyConsumed
 		}
-		request.GetBody = func() (io.ReadCloser, error) {
-			return ioutil.NopCloser(bytes.NewBuffer(data)), nil
+		b.buffered = true
+		if nil != b.raw {
+			b.data, b.err = ioutil.ReadAll(newLimitedReader(b.raw, b.limit))
 		}
-		// 恢复Body，但ParseForm解析后，request.Body无法重读，需要通过GetBody
-		request.Body = ioutil.NopCloser(bytes.NewBuffer(data))
-		return next(echo)
 	}
+	if nil != b.err {
+		return nil, b.err
+	}
+	return ioutil.NopCloser(bytes.NewReader(b.data)), nil
+}
+
+// Stream 返回原始Body流，只能读取一次；Body已缓存时，返回缓存数据
+func (b *LazyBody) Stream() (io.ReadCloser, error) {
+	b.mu.Lock()
+	if b.buffered {
+		b.mu.Unlock()
+		return b.GetBody()
+	}
+	defer b.mu.Unlock()
+	if b.streamed {
+		return nil, flux.ErrRequestBodyConsumed
+	}
+	b.streamed = true
+	if nil == b.raw {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+	return ioutil.NopCloser(newLimitedReader(b.raw, b.limit)), nil
+}
+
+// lazyBodyReader 读取时触发Body缓存
+type lazyBodyReader struct {
+	body   *LazyBody
+	reader io.Reader
+}
+
+func (r *lazyBodyReader) Read(p []byte) (int, error) {
+	if nil == r.reader {
+		rc, err := r.body.GetBody()
+		if nil != err {
+			return 0, err
+		}
+		r.reader = rc
+	}
+	return r.reader.Read(p)
+}
+
+func (r *lazyBodyReader) Close() error {
+	return nil
+}
+
+// limitedReader 读取超过限制时返回 flux.ErrRequestBodyTooLarge
+type limitedReader struct {
+	reader io.Reader
+	remain int64
+}
+
+func newLimitedReader(reader io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return reader
+	}
+	return &limitedReader{reader: reader, remain: limit}
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	// 多读取1字节，用于判定是否超过限制
+	if int64(len(p)) > l.remain+1 {
+		p = p[:l.remain+1]
+	}
+	n, err := l.reader.Read(p)
+	l.remain -= int64(n)
+	if l.remain < 0 {
+		return n, flux.ErrRequestBodyTooLarge
+	}
+	return n, err
 }
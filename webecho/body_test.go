@@ -0,0 +1,90 @@
+package webecho
+
+import (
+	"errors"
+	"github.com/bytepowered/flux"
+	"github.com/labstack/echo/v4"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestWebContext(method, contentType, body string) *AdaptWebContext {
+	request := httptest.NewRequest(method, "/upload", strings.NewReader(body))
+	if "" != contentType {
+		request.Header.Set(flux.HeaderContentType, contentType)
+	}
+	var webc *AdaptWebContext
+	handler := RepeatableBodyReader(func(c echo.Context) error {
+		webc = NewAdaptWebContext(c, DefaultRequestBodyDecoder)
+		return nil
+	})
+	_ = handler(echo.New().NewContext(request, httptest.NewRecorder()))
+	return webc
+}
+
+func TestLazyBody_Repeatable(t *testing.T) {
+	webc := newTestWebContext(http.MethodPost, "text/plain", "hello")
+	assert := assert2.New(t)
+	for i := 0; i < 2; i++ {
+		reader, err := webc.RequestBodyReader()
+		assert.Nil(err)
+		data, _ := ioutil.ReadAll(reader)
+		assert.Equal("hello", string(data))
+	}
+	// 已缓存时，流式读取返回缓存数据
+	reader, err := webc.RequestBodyStream()
+	assert.Nil(err)
+	data, _ := ioutil.ReadAll(reader)
+	assert.Equal("hello", string(data))
+}
+
+func TestLazyBody_Stream(t *testing.T) {
+	webc := newTestWebContext(http.MethodPost, "application/octet-stream", "binary-data")
+	assert := assert2.New(t)
+	reader, err := webc.RequestBodyStream()
+	assert.Nil(err)
+	data, _ := ioutil.ReadAll(reader)
+	assert.Equal("binary-data", string(data))
+	// 流式读取后，无法再次读取
+	_, err = webc.RequestBodyStream()
+	assert.Equal(flux.ErrRequestBodyConsumed, err)
+	_, err = webc.RequestBodyReader()
+	assert.Equal(flux.ErrRequestBodyConsumed, err)
+}
+
+func TestLazyBody_FormValues(t *testing.T) {
+	webc := newTestWebContext(http.MethodPost, flux.MIMEApplicationForm, "username=yongjiachen")
+	assert := assert2.New(t)
+	assert.Equal("yongjiachen", webc.FormValue("username"))
+	// 表单解析读取Body后，Body已缓存，可重复读取
+	reader, err := webc.RequestBodyReader()
+	assert.Nil(err)
+	data, _ := ioutil.ReadAll(reader)
+	assert.Equal("username=yongjiachen", string(data))
+}
+
+func TestLazyBody_Limit(t *testing.T) {
+	assert := assert2.New(t)
+	webc := newTestWebContext(http.MethodPost, "text/plain", strings.Repeat("a", 100))
+	webc.SetRequestBodyLimit(10)
+	_, err := webc.RequestBodyReader()
+	assert.True(errors.Is(err, flux.ErrRequestBodyTooLarge))
+
+	webc = newTestWebContext(http.MethodPost, "text/plain", strings.Repeat("a", 100))
+	webc.SetRequestBodyLimit(10)
+	reader, err := webc.RequestBodyStream()
+	assert.Nil(err)
+	_, err = ioutil.ReadAll(reader)
+	assert.True(errors.Is(err, flux.ErrRequestBodyTooLarge))
+
+	webc = newTestWebContext(http.MethodPost, "text/plain", strings.Repeat("a", 10))
+	webc.SetRequestBodyLimit(10)
+	reader, err = webc.RequestBodyReader()
+	assert.Nil(err)
+	data, _ := ioutil.ReadAll(reader)
+	assert.Equal(10, len(data))
+}
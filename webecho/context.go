@@ -14,7 +14,10 @@ const (
 	keyWebBodyDecoder = "$internal.web.adapted.body.decoder"
 )
 
-var _ flux.WebContext = new(AdaptWebContext)
+var (
+	_ flux.WebContext      = new(AdaptWebContext)
+	_ flux.WebBodyStreamer = new(AdaptWebContext)
+)
 
 func NewAdaptWebContext(echoc echo.Context, decoder flux.WebRequestBodyDecoder) *AdaptWebContext {
 	echoc.Set(keyWebBodyDecoder, decoder)
@@ -53,10 +56,27 @@ func (c *AdaptWebContext) RequestURL() (*url.URL, bool) {
 	return c.echoc.Request().URL, true
 }
 
+func (c *AdaptWebContext) RemoteAddr() string {
+	return c.echoc.Request().RemoteAddr
+}
+
 func (c *AdaptWebContext) RequestBodyReader() (io.ReadCloser, error) {
 	return c.echoc.Request().GetBody()
 }
 
+func (c *AdaptWebContext) RequestBodyStream() (io.ReadCloser, error) {
+	if body, ok := c.echoc.Get(keyWebLazyBody).(*LazyBody); ok {
+		return body.Stream()
+	}
+	return c.echoc.Request().Body, nil
+}
+
+func (c *AdaptWebContext) SetRequestBodyLimit(limit int64) {
+	if body, ok := c.echoc.Get(keyWebLazyBody).(*LazyBody); ok {
+		body.SetLimit(limit)
+	}
+}
+
 func (c *AdaptWebContext) RequestRewrite(method string, path string) {
 	c.echoc.Request().Method = method
 	c.echoc.Request().URL.Path = path
@@ -76,17 +76,30 @@ const (
 
 	// Ext
 	HeaderXRequestId = "X-Request-Id"
+	HeaderXCache     = "X-Cache"
+
+	// Cache
+	HeaderAge          = "Age"
+	HeaderCacheControl = "Cache-Control"
+
+	// Rate limit
+	HeaderRetryAfter         = "Retry-After"
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
 )
 
 // Common used status code
 const (
-	StatusOK           = http.StatusOK
-	StatusBadRequest   = http.StatusBadRequest
-	StatusNotFound     = http.StatusNotFound
-	StatusUnauthorized = http.StatusUnauthorized
-	StatusAccessDenied = http.StatusForbidden
-	StatusServerError  = http.StatusInternalServerError
-	StatusBadGateway   = http.StatusBadGateway
+	StatusOK                 = http.StatusOK
+	StatusBadRequest         = http.StatusBadRequest
+	StatusNotFound           = http.StatusNotFound
+	StatusTooManyRequests    = http.StatusTooManyRequests
+	StatusUnauthorized       = http.StatusUnauthorized
+	StatusAccessDenied       = http.StatusForbidden
+	StatusServerError        = http.StatusInternalServerError
+	StatusBadGateway         = http.StatusBadGateway
+	StatusServiceUnavailable = http.StatusServiceUnavailable
 )
 
 // Web interfaces defines
@@ -126,6 +139,9 @@ type WebContext interface {
 	// 注意：部分Web框架返回只读url.URL
 	RequestURL() (url *url.URL, writable bool)
 
+	// RemoteAddr 返回请求连接的远程地址，格式为 ip:port
+	RemoteAddr() string
+
 	// RequestBodyReader 返回可重复读取的Reader接口；
 	RequestBodyReader() (io.ReadCloser, error)
 
@@ -222,6 +238,16 @@ type WebContext interface {
 	RawWebResponse() interface{}
 }
 
+// WebBodyStreamer 可选接口：支持流式读取请求Body以及限制Body大小的WebContext
+type WebBodyStreamer interface {
+	// RequestBodyStream 返回原始请求Body流，只能读取一次，不缓存数据；
+	// 如果Body已被缓存（例如参数查找读取了Body），返回缓存数据
+	RequestBodyStream() (io.ReadCloser, error)
+
+	// SetRequestBodyLimit 设置请求Body的最大字节数；读取超过限制时返回 ErrRequestBodyTooLarge
+	SetRequestBodyLimit(limit int64)
+}
+
 // RawWebServer 定义Web框架服务器的接口；通过实现此接口来自定义支持不同的Web框架，用于支持不同的Web服务实现。
 // 例如默认Web框架为labstack.echo；可以支持git, fasthttp等框架。
 type WebServer interface {